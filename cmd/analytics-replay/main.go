@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/analytics/mq"
+	"github.com/cuihairu/croupier/internal/analytics/schema"
+)
+
+// analytics-replay re-publishes archived raw analytics records into a queue, e.g. to
+// backfill ClickHouse after a schema change or a worker fix. Records come from a file
+// queue directory or from NDJSON files (optionally gzipped) given as arguments.
+//
+//	analytics-replay -from data/analytics/queue -game g1 -since 2024-05-01 -to redis
+//	analytics-replay -stream payments -dry-run export-2024-05.ndjson.gz
+func main() {
+	from := flag.String("from", envOr("ANALYTICS_FILE_DIR", "data/analytics/queue"), "File queue directory to read when no files are given")
+	stream := flag.String("stream", mq.FileStreamEvents, "Stream to replay: events or payments")
+	to := flag.String("to", os.Getenv("ANALYTICS_MQ_TYPE"), "Queue to publish into: redis, kafka or file (configured by the usual ANALYTICS_* env)")
+	toDir := flag.String("to-dir", "", "File queue directory to publish into (default ANALYTICS_FILE_DIR)")
+	game := flag.String("game", "", "Only records of this game_id")
+	env := flag.String("env", "", "Only records of this env")
+	events := flag.String("event", "", "Only these events, comma separated")
+	since := flag.String("since", "", "Only records at or after this time (RFC 3339 or YYYY-MM-DD)")
+	until := flag.String("until", "", "Only records before this time (RFC 3339 or YYYY-MM-DD)")
+	rate := flag.Int("rate", 0, "Publish at most this many records per second (0 = unlimited)")
+	dry := flag.Bool("dry-run", false, "Count matching records without publishing")
+	flag.Parse()
+
+	if *stream != mq.FileStreamEvents && *stream != mq.FileStreamPayments {
+		log.Fatalf("Unknown stream %q", *stream)
+	}
+	f := filter{game: *game, env: *env}
+	if *events != "" {
+		f.events = strings.Split(*events, ",")
+	}
+	var err error
+	if f.since, err = parseBound(*since); err != nil {
+		log.Fatalf("Invalid -since: %v", err)
+	}
+	if f.until, err = parseBound(*until); err != nil {
+		log.Fatalf("Invalid -until: %v", err)
+	}
+
+	var q mq.Queue = mq.NewNoop()
+	if !*dry {
+		if *to == "" {
+			log.Fatal("Set -to or ANALYTICS_MQ_TYPE to the queue to publish into")
+		}
+		os.Setenv("ANALYTICS_MQ_TYPE", *to)
+		if *toDir != "" {
+			os.Setenv("ANALYTICS_FILE_DIR", *toDir)
+		}
+		q = mq.NewFromEnv()
+		if _, ok := q.(*mq.Noop); ok {
+			log.Fatalf("Queue %q is not available", *to)
+		}
+		defer q.Close()
+	}
+	publish := q.PublishEvent
+	if *stream == mq.FileStreamPayments {
+		publish = q.PublishPayment
+	}
+
+	var st stats
+	var throttle <-chan time.Time
+	if *rate > 0 {
+		t := time.NewTicker(time.Second / time.Duration(*rate))
+		defer t.Stop()
+		throttle = t.C
+	}
+	each := func(rec []byte) error {
+		st.read++
+		d := json.NewDecoder(bytes.NewReader(rec))
+		d.UseNumber()
+		var m map[string]any
+		if err := d.Decode(&m); err != nil || m == nil {
+			st.malformed++
+			return nil
+		}
+		if !f.match(m) {
+			return nil
+		}
+		st.matched++
+		if *dry {
+			return nil
+		}
+		if throttle != nil {
+			<-throttle
+		}
+		if err := publish(m); err != nil {
+			return fmt.Errorf("publish record %d: %w", st.read, err)
+		}
+		st.published++
+		return nil
+	}
+
+	if flag.NArg() > 0 {
+		for _, path := range flag.Args() {
+			if err := readNDJSON(path, each); err != nil {
+				log.Fatalf("%s: %v", path, err)
+			}
+		}
+	} else if err := readQueue(*from, *stream, each); err != nil {
+		log.Fatalf("%s: %v", *from, err)
+	}
+	fmt.Printf("✅ Read %d record(s): %d matched, %d published, %d undecodable\n", st.read, st.matched, st.published, st.malformed)
+}
+
+type stats struct{ read, matched, published, malformed int }
+
+type filter struct {
+	game, env    string
+	events       []string
+	since, until time.Time
+}
+
+func (f filter) match(m map[string]any) bool {
+	if f.game != "" && fmt.Sprint(schema.Envelope(m, "game_id")) != f.game {
+		return false
+	}
+	if f.env != "" && fmt.Sprint(schema.Envelope(m, "env")) != f.env {
+		return false
+	}
+	if len(f.events) > 0 {
+		name, _ := m["event"].(string)
+		found := false
+		for _, e := range f.events {
+			if schema.SameEvent(strings.TrimSpace(e), name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.since.IsZero() || !f.until.IsZero() {
+		t, ok := schema.EventTime(m)
+		if !ok || (!f.since.IsZero() && t.Before(f.since)) || (!f.until.IsZero() && !t.Before(f.until)) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseBound(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// readQueue reads stream up to where it ended when the replay started, so
+// replaying into the same directory does not read its own output.
+func readQueue(dir, stream string, each func([]byte) error) error {
+	end, err := mq.FileEnd(dir, stream)
+	if err != nil {
+		return err
+	}
+	r := mq.OpenFileReader(dir, stream, 0)
+	defer r.Close()
+	for {
+		rec, off, err := r.Next()
+		if errors.Is(err, io.EOF) || (err == nil && off >= end) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := each(rec); err != nil {
+			return err
+		}
+	}
+}
+
+func readNDJSON(path string, each func([]byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var in io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		in = gz
+	}
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for sc.Scan() {
+		if line := bytes.TrimSpace(sc.Bytes()); len(line) > 0 {
+			if err := each(line); err != nil {
+				return err
+			}
+		}
+	}
+	return sc.Err()
+}
+
+func envOr(k, def string) string {
+	if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+		return v
+	}
+	return def
+}
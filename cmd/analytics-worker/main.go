@@ -4,10 +4,10 @@ import (
 	"context"
 	"github.com/cuihairu/croupier/internal/analytics/worker"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 )
 
 func main() {
@@ -18,12 +18,24 @@ func main() {
 	}
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
+
+	addr := os.Getenv("WORKER_METRICS_ADDR")
+	if addr == "" {
+		addr = ":9108"
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", w.Metrics().Handler())
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, _ *http.Request) { rw.Write([]byte("ok")) })
 	go func() {
-		if err := w.Run(ctx); err != nil {
-			slog.Error("run", "err", err)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("metrics server", "err", err)
 		}
 	}()
-	slog.Info("analytics-worker started")
-	<-ctx.Done()
-	time.Sleep(200 * time.Millisecond)
+
+	slog.Info("analytics-worker started", "metrics", addr)
+	// Run returns after flushing what it buffered once ctx is done.
+	if err := w.Run(ctx); err != nil {
+		slog.Error("run", "err", err)
+		os.Exit(1)
+	}
 }
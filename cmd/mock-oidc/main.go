@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cuihairu/croupier/internal/security/oidc/oidctest"
+)
+
+// mock-oidc serves a local OpenID provider that signs in every login as the
+// configured user, for trying SSO without a real identity provider:
+//
+//	mock-oidc -addr 127.0.0.1:9999 -user alice -groups croupier-ops
+//
+// and in server.yaml: auth.oidc.issuer "http://127.0.0.1:9999", client_id "croupier".
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9999", "Listen address")
+	issuer := flag.String("issuer", "", "Issuer URL (default http://<addr>)")
+	clientID := flag.String("client-id", "croupier", "Accepted client id")
+	clientSecret := flag.String("client-secret", "", "Client secret; empty accepts public clients")
+	user := flag.String("user", "alice", "Username of every login")
+	email := flag.String("email", "", "Email of every login (default <user>@example.com)")
+	groups := flag.String("groups", "", "Comma separated groups of every login")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+	if *email == "" {
+		*email = *user + "@example.com"
+	}
+	idp, err := oidctest.New(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("mock idp: %v", err)
+	}
+	var gs []string
+	for _, g := range strings.Split(*groups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			gs = append(gs, g)
+		}
+	}
+	idp.SetUser(*user, *email, gs)
+	log.Printf("mock OIDC provider %s signing in %s (groups %v)", *issuer, *user, gs)
+	log.Fatal(http.ListenAndServe(*addr, idp))
+}
@@ -116,6 +116,10 @@ export default {
   'pages.rate.limits.export.csv': 'Export CSV',
   'pages.rate.limits.functions': 'Functions',
   'pages.rate.limits.services': 'Services',
+  'pages.rate.limits.games': 'Game/Env',
+  'pages.rate.limits.actors': 'Actors',
+  'pages.rate.limits.allowed': 'Allowed',
+  'pages.rate.limits.rejected': 'Rejected',
   'pages.rate.limits.key.function': 'Key (Function ID)',
   'pages.rate.limits.key.agent': 'Key (Agent ID)',
   
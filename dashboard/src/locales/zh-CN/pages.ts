@@ -113,6 +113,10 @@ export default {
   'pages.rate.limits.export.csv': '导出 CSV',
   'pages.rate.limits.functions': '函数',
   'pages.rate.limits.services': '服务',
+  'pages.rate.limits.games': '游戏/环境',
+  'pages.rate.limits.actors': '操作人',
+  'pages.rate.limits.allowed': '已放行',
+  'pages.rate.limits.rejected': '已拒绝',
   'pages.rate.limits.key.function': 'Key（函数ID）',
   'pages.rate.limits.key.agent': 'Key（Agent ID）',
   
@@ -12,9 +12,15 @@ export default function OperationLogsPage() {
   const defaultKinds = [
     'invoke','start_job','cancel_job',
     'assignments.update',
-    'user_create','user_update','user_delete','user_set_password','user_set_games',
+    'user_create','user_update','user_delete','user_set_password','user_set_games','user_set_game_envs',
+    'role_create','role_update','role_delete','role_set_perms',
+    'mfa_enroll','mfa_verify','mfa_failed','mfa_reset',
+    'session_revoke','session_revoke_all','session_reuse',
+    'service_account_create','service_account_update','service_account_delete','api_token_create','api_token_revoke',
+    'agent_cert_issue','agent_offline',
     'message_send','message_broadcast',
     'approval_approve','approval_reject',
+    'maintenance_override',
     // support ops
     'support.ticket_create','support.ticket_update','support.ticket_delete','support.ticket_comment','support.ticket_transition',
   ];
@@ -3,13 +3,14 @@ import { Card, Table, Space, Button, Modal, Form, InputNumber, Select, Input, Ap
 import { PageContainer } from '@ant-design/pro-components';
 import { useIntl } from '@umijs/max';
 import type { ColumnsType } from 'antd/es/table';
-import { listRateLimits, putRateLimits, deleteRateLimit, type RateLimitRule, listOpsFunctions, fetchOpsServices, previewRateLimit } from '@/services/croupier/ops';
+import { listRateLimits, putRateLimits, deleteRateLimit, type RateLimitRule, type RateLimitCounter, listOpsFunctions, fetchOpsServices, previewRateLimit } from '@/services/croupier/ops';
 
 export default function OpsRateLimitsPage() {
   const { message } = App.useApp();
   const intl = useIntl();
   const [loading, setLoading] = useState(false);
   const [rules, setRules] = useState<RateLimitRule[]>([]);
+  const [counters, setCounters] = useState<Record<string, RateLimitCounter>>({});
   const [open, setOpen] = useState(false);
   const [form] = Form.useForm();
   const [functions, setFunctions] = useState<string[]>([]);
@@ -47,6 +48,9 @@ export default function OpsRateLimitsPage() {
     try {
       const res = await listRateLimits();
       setRules(res.rules || []);
+      const byRule: Record<string, RateLimitCounter> = {};
+      (res.counters || []).forEach((c)=> { byRule[`${c.scope}:${c.key}`] = c; });
+      setCounters(byRule);
       // 从函数描述符加载函数ID列表（用于下拉选择）
       try {
         const s = await listOpsFunctions();
@@ -60,10 +64,18 @@ export default function OpsRateLimitsPage() {
   useEffect(()=>{ load(); }, []);
 
   const columns: ColumnsType<RateLimitRule> = [
-    { title:intl.formatMessage({ id: 'pages.scope' }), dataIndex:'scope', width:120, render:(v)=> v==='function'? <Tag color="blue">{intl.formatMessage({ id: 'pages.rate.limits.functions' })}</Tag>: <Tag color='purple'>{intl.formatMessage({ id: 'pages.rate.limits.services' })}</Tag> },
+    { title:intl.formatMessage({ id: 'pages.scope' }), dataIndex:'scope', width:120, render:(v)=> {
+      if (v==='function') return <Tag color="blue">{intl.formatMessage({ id: 'pages.rate.limits.functions' })}</Tag>;
+      if (v==='game') return <Tag color="green">{intl.formatMessage({ id: 'pages.rate.limits.games' })}</Tag>;
+      if (v==='actor') return <Tag color="orange">{intl.formatMessage({ id: 'pages.rate.limits.actors' })}</Tag>;
+      return <Tag color='purple'>{intl.formatMessage({ id: 'pages.rate.limits.services' })}</Tag>;
+    } },
     { title:'Key', dataIndex:'key', width:240 },
     { title:'QPS', dataIndex:'limit_qps', width:100 },
     { title:intl.formatMessage({ id: 'pages.rate.limits.percentage' }), dataIndex:'percent', width:100, render:(v)=> v||100 },
+    { title:'QPS (1s)', width:90, render:(_:any, r)=> counters[`${r.scope}:${r.key}`]?.qps ?? 0 },
+    { title:intl.formatMessage({ id: 'pages.rate.limits.allowed' }), width:100, render:(_:any, r)=> counters[`${r.scope}:${r.key}`]?.allowed ?? 0 },
+    { title:intl.formatMessage({ id: 'pages.rate.limits.rejected' }), width:100, render:(_:any, r)=> { const n = counters[`${r.scope}:${r.key}`]?.rejected ?? 0; return n>0 ? <Tag color="red">{n}</Tag> : 0; } },
     { title:intl.formatMessage({ id: 'pages.rate.limits.match' }).replace('（可选）', ''), dataIndex:'match', width:200, render:(m:any)=> m? Object.entries(m).map(([k,v])=> <Tag key={k}>{k}:{String(v)}</Tag>): '-' },
     { title:intl.formatMessage({ id: 'pages.permissions.actions' }), render: (_:any, r)=> (
       <Space>
@@ -132,12 +144,21 @@ export default function OpsRateLimitsPage() {
           <Form.Item label={intl.formatMessage({ id: 'pages.scope' })} name="scope" rules={[{required:true}]}> 
             <Select options={[
               {label:intl.formatMessage({ id: 'pages.rate.limits.functions' }), value:'function'},
-              {label:intl.formatMessage({ id: 'pages.rate.limits.services' }), value:'service'}
+              {label:intl.formatMessage({ id: 'pages.rate.limits.services' }), value:'service'},
+              {label:intl.formatMessage({ id: 'pages.rate.limits.games' }), value:'game'},
+              {label:intl.formatMessage({ id: 'pages.rate.limits.actors' }), value:'actor'}
             ]} onChange={()=> form.setFieldValue('key','')} /> 
           </Form.Item>
           <Form.Item noStyle shouldUpdate={(prev,cur)=> prev.scope!==cur.scope}>
             {() => {
               const scope = form.getFieldValue('scope');
+              if (scope==='game' || scope==='actor') {
+                return (
+                  <Form.Item label="Key" name="key" rules={[{required:true}]} tooltip="* = per value">
+                    <Input placeholder={scope==='game'? 'game_id | game_id/env | *' : 'username | *'} />
+                  </Form.Item>
+                );
+              }
               return (
                 <Form.Item 
                   label={scope==='service'? intl.formatMessage({ id: 'pages.rate.limits.key.agent' }): intl.formatMessage({ id: 'pages.rate.limits.key.function' })} 
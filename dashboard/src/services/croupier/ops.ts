@@ -25,9 +25,10 @@ export async function fetchOpsServices() {
   return request<{ agents: OpsAgent[] }>("/api/ops/services");
 }
 
-export type RateLimitRule = { scope: 'function'|'service'; key: string; limit_qps: number; match?: Record<string,string>; percent?: number };
+export type RateLimitRule = { scope: 'function'|'service'|'game'|'actor'; key: string; limit_qps: number; match?: Record<string,string>; percent?: number };
+export type RateLimitCounter = { scope: string; key: string; allowed: number; rejected: number; qps: number };
 export async function listRateLimits() {
-  return request<{ rules: RateLimitRule[] }>("/api/ops/rate-limits");
+  return request<{ rules: RateLimitRule[]; counters?: RateLimitCounter[] }>("/api/ops/rate-limits");
 }
 export async function putRateLimits(rules: RateLimitRule[]) {
   return request<void>("/api/ops/rate-limits", { method: 'PUT', data: { rules } });
@@ -0,0 +1,148 @@
+package ingestauth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const keysYAML = `
+keys:
+  - id: g1-old
+    game_id: g1
+    secret: old
+    not_after: 2024-06-01T00:00:00Z
+  - id: g1-new
+    game_id: g1
+    secret: new
+    requests_per_minute: 2
+  - id: g2-a
+    game_id: g2
+    env: prod
+    secret: g2
+revoked_games: [g3]
+`
+
+func newVerifier(t *testing.T, now time.Time) (*Verifier, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	if err := os.WriteFile(path, []byte(keysYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	kr, err := NewKeyring(path, "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{Keys: kr, Nonces: NewMemNonceCache(16), Counter: NewMemCounter(), Skew: 5 * time.Minute}
+	v.now = func() time.Time { return now }
+	return v, path
+}
+
+func signed(keyID, secret, nonce string, at time.Time, body string) Request {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return Request{KeyID: keyID, Timestamp: ts, Nonce: nonce, Signature: Sign(secret, ts, nonce, []byte(body)), Body: []byte(body)}
+}
+
+func TestVerifyRotationAndReplay(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	v, _ := newVerifier(t, now)
+	ctx := context.Background()
+
+	// Both keys of g1 are accepted while rotating.
+	for _, r := range []Request{
+		signed("g1-old", "old", "n1", now, "[]"),
+		signed("g1-new", "new", "n1", now, "[]"),
+		signed("", "legacy", "n1", now, "[]"),
+	} {
+		if _, err := v.Verify(ctx, r); err != nil {
+			t.Fatalf("verify %s: %v", r.KeyID, err)
+		}
+	}
+	if _, err := v.Verify(ctx, signed("g1-old", "old", "n1", now, "[]")); !errors.Is(err, ErrReplayed) {
+		t.Fatalf("replay: got %v", err)
+	}
+	if _, err := v.Verify(ctx, signed("g1-old", "new", "n2", now, "[]")); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("wrong secret: got %v", err)
+	}
+	// A rejected signature does not burn the nonce.
+	if _, err := v.Verify(ctx, signed("g1-old", "old", "n2", now, "[]")); err != nil {
+		t.Fatalf("nonce after bad signature: %v", err)
+	}
+	if _, err := v.Verify(ctx, signed("g1-old", "old", "n3", now.Add(-10*time.Minute), "[]")); !errors.Is(err, ErrTimestampSkew) {
+		t.Fatalf("skew: got %v", err)
+	}
+	if _, err := v.Verify(ctx, signed("nope", "x", "n4", now, "[]")); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("unknown key: got %v", err)
+	}
+
+	// After the rotation deadline only the new key works.
+	later := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+	v.now = func() time.Time { return later }
+	if _, err := v.Verify(ctx, signed("g1-old", "old", "n5", later, "[]")); !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("retired key: got %v", err)
+	}
+}
+
+func TestVerifyQuotaAndRevocation(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 30, 0, time.UTC)
+	v, path := newVerifier(t, now)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := v.Verify(ctx, signed("g1-new", "new", "q"+strconv.Itoa(i), now, "[]")); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	_, err := v.Verify(ctx, signed("g1-new", "new", "q2", now, "[]"))
+	var qe *QuotaError
+	if !errors.As(err, &qe) || qe.Quota != "requests" || qe.RetryAfter != 30*time.Second {
+		t.Fatalf("quota: got %v", err)
+	}
+
+	key, err := v.Verify(ctx, signed("g2-a", "g2", "r1", now, "[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Allows("g2", "prod") || key.Allows("g2", "dev") || key.Allows("g1", "prod") {
+		t.Fatalf("key %s allows the wrong games", key.ID)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(keysYAML, "[g3]", "[g3, g2]", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(ctx, signed("g2-a", "g2", "r2", now, "[]")); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("revoked game: got %v", err)
+	}
+}
+
+func TestMemNonceCacheFailsClosed(t *testing.T) {
+	c := NewMemNonceCache(2)
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	for _, n := range []string{"a", "b"} {
+		if ok, err := c.Use(ctx, n, time.Minute); !ok || err != nil {
+			t.Fatalf("%s: %v, %v", n, ok, err)
+		}
+	}
+	// Full of unexpired nonces: c is refused and a is still remembered.
+	if ok, err := c.Use(ctx, "c", time.Minute); ok || !errors.Is(err, ErrNonceCacheFull) {
+		t.Fatalf("c on a full cache: %v, %v", ok, err)
+	}
+	if ok, err := c.Use(ctx, "a", time.Minute); ok || err != nil {
+		t.Fatalf("a accepted twice: %v, %v", ok, err)
+	}
+	now = now.Add(2 * time.Minute)
+	for _, n := range []string{"a", "c"} {
+		if ok, err := c.Use(ctx, n, time.Minute); !ok || err != nil {
+			t.Fatalf("%s after expiry: %v, %v", n, ok, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Package ingestauth authenticates requests to the public analytics ingestion
+// endpoint: per-game signing keys, replay protection and per-key quotas.
+package ingestauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown key")
+	ErrKeyRevoked = errors.New("key revoked")
+	ErrKeyExpired = errors.New("key not valid at this time")
+)
+
+// Key is a signing key shipped with client builds of one game. Rotate a key by
+// adding its successor, shipping builds with it and then setting NotAfter on
+// the old key; both are accepted meanwhile. Revoked refuses the key at once,
+// RevokedGames in the key file every key of a game.
+type Key struct {
+	ID     string `yaml:"id"`
+	GameID string `yaml:"game_id"`
+	// Env restricts the key to one environment of the game; empty allows all.
+	Env    string `yaml:"env"`
+	Secret string `yaml:"secret"`
+	// SecretEnv names an environment variable holding the secret instead.
+	SecretEnv string    `yaml:"secret_env"`
+	NotBefore time.Time `yaml:"not_before"`
+	NotAfter  time.Time `yaml:"not_after"`
+	Revoked   bool      `yaml:"revoked"`
+	// Quotas per minute; zero means unlimited.
+	RequestsPerMinute int64 `yaml:"requests_per_minute"`
+	BytesPerMinute    int64 `yaml:"bytes_per_minute"`
+}
+
+type keyFile struct {
+	Keys         []Key    `yaml:"keys"`
+	RevokedGames []string `yaml:"revoked_games"`
+}
+
+type snapshot struct {
+	keys         map[string]Key
+	revokedGames map[string]bool
+	modTime      time.Time
+}
+
+// Keyring holds the keys of a key file, reloaded when the file changes, and
+// optionally a legacy key with the empty id for clients that send no key id.
+type Keyring struct {
+	path   string
+	legacy string
+
+	mu   sync.RWMutex
+	snap snapshot
+}
+
+// NewKeyring loads the key file at path, if any, and adds legacySecret, if
+// set, as the key of requests without a key id. Such a key is not bound to a
+// game.
+func NewKeyring(path, legacySecret string) (*Keyring, error) {
+	k := &Keyring{path: path, legacy: legacySecret}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload reads the key file again.
+func (k *Keyring) Reload() error {
+	snap := snapshot{keys: map[string]Key{}, revokedGames: map[string]bool{}}
+	if k.path != "" {
+		fi, err := os.Stat(k.path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(k.path)
+		if err != nil {
+			return err
+		}
+		var f keyFile
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("parse %s: %w", k.path, err)
+		}
+		for i, key := range f.Keys {
+			if key.ID == "" || key.GameID == "" {
+				return fmt.Errorf("%s: key %d needs an id and a game_id", k.path, i)
+			}
+			if key.SecretEnv != "" {
+				key.Secret = os.Getenv(key.SecretEnv)
+			}
+			if key.Secret == "" {
+				return fmt.Errorf("%s: key %s has no secret", k.path, key.ID)
+			}
+			if _, dup := snap.keys[key.ID]; dup {
+				return fmt.Errorf("%s: key %s defined twice", k.path, key.ID)
+			}
+			snap.keys[key.ID] = key
+		}
+		for _, g := range f.RevokedGames {
+			snap.revokedGames[g] = true
+		}
+		snap.modTime = fi.ModTime()
+	}
+	if k.legacy != "" {
+		snap.keys[""] = Key{Secret: k.legacy}
+	}
+	k.mu.Lock()
+	k.snap = snap
+	k.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of keys.
+func (k *Keyring) Len() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.snap.keys)
+}
+
+// Lookup returns the key id if it may be used at now.
+func (k *Keyring) Lookup(id string, now time.Time) (Key, error) {
+	k.mu.RLock()
+	key, ok := k.snap.keys[id]
+	gameRevoked := key.GameID != "" && k.snap.revokedGames[key.GameID]
+	k.mu.RUnlock()
+	switch {
+	case !ok:
+		return Key{}, ErrUnknownKey
+	case key.Revoked || gameRevoked:
+		return Key{}, ErrKeyRevoked
+	case !key.NotBefore.IsZero() && now.Before(key.NotBefore), !key.NotAfter.IsZero() && !now.Before(key.NotAfter):
+		return Key{}, ErrKeyExpired
+	}
+	return key, nil
+}
+
+// Watch reloads the key file every interval if it changed, until ctx is done,
+// so that revocations apply without a restart. A file that fails to load is
+// logged and the previous keys stay in use.
+func (k *Keyring) Watch(ctx context.Context, interval time.Duration) {
+	if k.path == "" {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		fi, err := os.Stat(k.path)
+		if err != nil {
+			slog.Error("ingest keys: stat", "path", k.path, "error", err)
+			continue
+		}
+		k.mu.RLock()
+		same := fi.ModTime().Equal(k.snap.modTime)
+		k.mu.RUnlock()
+		if same {
+			continue
+		}
+		if err := k.Reload(); err != nil {
+			slog.Error("ingest keys: reload", "path", k.path, "error", err)
+			continue
+		}
+		slog.Info("ingest keys reloaded", "path", k.path, "keys", k.Len())
+	}
+}
+
+// Allows reports whether key may send events of game and env. The legacy key
+// is not bound to a game.
+func (key Key) Allows(game, env string) bool {
+	if key.GameID == "" {
+		return true
+	}
+	return strings.EqualFold(game, key.GameID) && (key.Env == "" || strings.EqualFold(env, key.Env))
+}
@@ -0,0 +1,87 @@
+package ingestauth
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NonceCache remembers the nonces of accepted requests.
+type NonceCache interface {
+	// Use records nonce for ttl and reports false if it was already recorded.
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// ErrNonceCacheFull is returned when a MemNonceCache holds only unexpired
+// nonces: forgetting one would let its request be replayed, so the new
+// request is refused instead.
+var ErrNonceCacheFull = errors.New("nonce cache full")
+
+type nonceEntry struct {
+	nonce  string
+	expire time.Time
+}
+
+// MemNonceCache remembers nonces for a single ingest instance. Once full of
+// unexpired nonces it refuses new ones with ErrNonceCacheFull, so size it above
+// the number of requests expected within the nonce lifetime.
+type MemNonceCache struct {
+	mu    sync.Mutex
+	max   int
+	order *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+// NewMemNonceCache creates a cache holding up to max nonces.
+func NewMemNonceCache(max int) *MemNonceCache {
+	if max <= 0 {
+		max = 100000
+	}
+	return &MemNonceCache{max: max, order: list.New(), items: map[string]*list.Element{}, now: time.Now}
+}
+
+func (c *MemNonceCache) Use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if el, ok := c.items[nonce]; ok {
+		if now.Before(el.Value.(*nonceEntry).expire) {
+			return false, nil
+		}
+		c.order.Remove(el)
+		delete(c.items, nonce)
+	}
+	// Entries share one ttl, so the oldest expire first.
+	for el := c.order.Back(); el != nil && !now.Before(el.Value.(*nonceEntry).expire); el = c.order.Back() {
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*nonceEntry).nonce)
+	}
+	if c.order.Len() >= c.max {
+		return false, ErrNonceCacheFull
+	}
+	c.items[nonce] = c.order.PushFront(&nonceEntry{nonce: nonce, expire: now.Add(ttl)})
+	return true, nil
+}
+
+// RedisNonceCache shares nonces between ingest instances through Redis.
+type RedisNonceCache struct {
+	rdb    redis.UniversalClient
+	prefix string
+}
+
+// NewRedisNonceCache creates a cache on rdb; keys are prefixed with prefix.
+func NewRedisNonceCache(rdb redis.UniversalClient, prefix string) *RedisNonceCache {
+	if prefix == "" {
+		prefix = "analytics:ingest:nonce:"
+	}
+	return &RedisNonceCache{rdb: rdb, prefix: prefix}
+}
+
+func (c *RedisNonceCache) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return c.rdb.SetNX(ctx, c.prefix+nonce, 1, ttl).Result()
+}
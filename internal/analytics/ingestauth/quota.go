@@ -0,0 +1,77 @@
+package ingestauth
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Counter sums usage per bucket in one-minute windows.
+type Counter interface {
+	// Add adds n to bucket in the window starting at unix minute min and
+	// returns the window total.
+	Add(ctx context.Context, bucket string, min int64, n int64) (int64, error)
+}
+
+type window struct {
+	min int64
+	n   int64
+}
+
+// MemCounter keeps windows in memory for a single ingest instance.
+type MemCounter struct {
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+func NewMemCounter() *MemCounter { return &MemCounter{windows: map[string]*window{}} }
+
+func (c *MemCounter) Add(_ context.Context, bucket string, min int64, n int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.windows[bucket]
+	if w == nil {
+		if len(c.windows) > 4096 {
+			for k, w := range c.windows {
+				if w.min < min {
+					delete(c.windows, k)
+				}
+			}
+		}
+		w = &window{}
+		c.windows[bucket] = w
+	}
+	if w.min != min {
+		w.min, w.n = min, 0
+	}
+	w.n += n
+	return w.n, nil
+}
+
+// RedisCounter shares windows between ingest instances through Redis.
+type RedisCounter struct {
+	rdb    redis.UniversalClient
+	prefix string
+}
+
+// NewRedisCounter creates a counter on rdb; keys are prefixed with prefix.
+func NewRedisCounter(rdb redis.UniversalClient, prefix string) *RedisCounter {
+	if prefix == "" {
+		prefix = "analytics:ingest:quota:"
+	}
+	return &RedisCounter{rdb: rdb, prefix: prefix}
+}
+
+func (c *RedisCounter) Add(ctx context.Context, bucket string, min int64, n int64) (int64, error) {
+	key := c.prefix + bucket + ":" + strconv.FormatInt(min, 10)
+	pipe := c.rdb.TxPipeline()
+	incr := pipe.IncrBy(ctx, key, n)
+	pipe.Expire(ctx, key, 2*time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
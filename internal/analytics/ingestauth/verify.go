@@ -0,0 +1,128 @@
+package ingestauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrBadTimestamp  = errors.New("bad timestamp")
+	ErrTimestampSkew = errors.New("timestamp outside the allowed skew")
+	ErrBadSignature  = errors.New("bad signature")
+	ErrReplayed      = errors.New("nonce already used")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// QuotaError is returned when a key exceeds one of its quotas.
+type QuotaError struct {
+	KeyID      string
+	Quota      string // "requests" or "bytes"
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("key %s: %s per minute exceeded", e.KeyID, e.Quota)
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExceeded }
+
+// Sign computes the X-Signature of a request:
+// base64(HMAC-SHA256(secret, ts + "\n" + nonce + "\n" + hex(sha256(body)))).
+func Sign(secret, ts, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(ts + "\n" + nonce + "\n" + hex.EncodeToString(sum[:])))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verifier authenticates signed ingestion requests.
+type Verifier struct {
+	Keys   *Keyring
+	Nonces NonceCache
+	// Counter enforces the key quotas; nil disables them.
+	Counter Counter
+	// Skew is how far X-Timestamp may be from the server clock.
+	Skew time.Duration
+
+	now func() time.Time
+}
+
+// Request holds the authentication headers and body of a request.
+type Request struct {
+	KeyID     string
+	Timestamp string
+	Nonce     string
+	Signature string
+	Body      []byte
+}
+
+// Verify checks the signature of r with its key, refuses replays of the
+// nonce, then charges the request to the key quotas. It returns the key.
+func (v *Verifier) Verify(ctx context.Context, r Request) (Key, error) {
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+	ts, err := strconv.ParseInt(r.Timestamp, 10, 64)
+	if err != nil {
+		return Key{}, ErrBadTimestamp
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > v.Skew || d < -v.Skew {
+		return Key{}, ErrTimestampSkew
+	}
+	key, err := v.Keys.Lookup(r.KeyID, now)
+	if err != nil {
+		return Key{}, err
+	}
+	if !hmac.Equal([]byte(Sign(key.Secret, r.Timestamp, r.Nonce, r.Body)), []byte(r.Signature)) {
+		return Key{}, ErrBadSignature
+	}
+	// A timestamp is accepted from Skew in the past to Skew in the future, so
+	// its nonce must be remembered twice as long.
+	fresh, err := v.Nonces.Use(ctx, r.KeyID+"|"+r.Nonce, 2*v.Skew)
+	if err != nil {
+		return Key{}, fmt.Errorf("nonce cache: %w", err)
+	}
+	if !fresh {
+		return Key{}, ErrReplayed
+	}
+	if err := v.charge(ctx, key, int64(len(r.Body)), now); err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+func (v *Verifier) charge(ctx context.Context, key Key, bytes int64, now time.Time) error {
+	if v.Counter == nil {
+		return nil
+	}
+	min := now.Unix() / 60
+	retry := time.Unix((min+1)*60, 0).Sub(now)
+	for _, q := range []struct {
+		name  string
+		n     int64
+		limit int64
+	}{
+		{"requests", 1, key.RequestsPerMinute},
+		{"bytes", bytes, key.BytesPerMinute},
+	} {
+		if q.limit <= 0 {
+			continue
+		}
+		total, err := v.Counter.Add(ctx, key.ID+":"+q.name, min, q.n)
+		if err != nil {
+			return fmt.Errorf("quota counter: %w", err)
+		}
+		if total > q.limit {
+			return &QuotaError{KeyID: key.ID, Quota: q.name, RetryAfter: retry}
+		}
+	}
+	return nil
+}
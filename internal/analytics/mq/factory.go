@@ -6,7 +6,7 @@ import (
 )
 
 // NewFromEnv builds a Queue based on env configuration.
-// ANALYTICS_MQ_TYPE: redis|kafka|noop (default)
+// ANALYTICS_MQ_TYPE: redis|kafka|file|noop (default)
 // For redis/kafka, real implementations can be added without changing callers.
 func NewFromEnv() Queue {
 	t := os.Getenv("ANALYTICS_MQ_TYPE")
@@ -24,6 +24,13 @@ func NewFromEnv() Queue {
 		}
 		log.Printf("[analytics-mq] kafka requested; fallback to noop")
 		return NewNoop()
+	case "file":
+		q, err := newFileFromEnv()
+		if err == nil {
+			return q
+		}
+		log.Printf("[analytics-mq] file queue requested; fallback to noop: %v", err)
+		return NewNoop()
 	default:
 		if t == "" {
 			log.Printf("[analytics-mq] ANALYTICS_MQ_TYPE not set; using noop")
@@ -0,0 +1,354 @@
+package mq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Streams of a file queue, each a subdirectory of its directory.
+const (
+	FileStreamEvents   = "events"
+	FileStreamPayments = "payments"
+	FileStreamDead     = "dead"
+)
+
+// DefaultSegmentBytes is the size at which a file queue starts a new segment.
+const DefaultSegmentBytes = 64 << 20
+
+// FileQueue appends messages as NDJSON lines to segment files, one directory
+// per stream. A segment is named after the stream offset of its first byte,
+// so a record's offset is the segment name plus its position in the file.
+// Several processes may append to the same directory.
+type FileQueue struct {
+	dir          string
+	segmentBytes int64
+
+	mu      sync.Mutex
+	writers map[string]*segmentWriter
+}
+
+type segmentWriter struct {
+	lock *os.File
+	f    *os.File
+	base int64
+}
+
+// NewFile returns a queue writing under dir, starting a new segment once
+// one reaches segmentBytes.
+func NewFile(dir string, segmentBytes int64) (*FileQueue, error) {
+	if segmentBytes <= 0 {
+		segmentBytes = DefaultSegmentBytes
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileQueue{dir: dir, segmentBytes: segmentBytes, writers: map[string]*segmentWriter{}}, nil
+}
+
+func newFileFromEnv() (Queue, error) {
+	dir := strings.TrimSpace(os.Getenv("ANALYTICS_FILE_DIR"))
+	if dir == "" {
+		dir = "data/analytics/queue"
+	}
+	seg, _ := strconv.ParseInt(os.Getenv("ANALYTICS_FILE_SEGMENT_BYTES"), 10, 64)
+	q, err := NewFile(dir, seg)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[analytics-mq] file queue enabled: dir=%s", dir)
+	return q, nil
+}
+
+func (q *FileQueue) PublishEvent(evt map[string]any) error {
+	return q.publish(FileStreamEvents, evt)
+}
+
+func (q *FileQueue) PublishPayment(pay map[string]any) error {
+	return q.publish(FileStreamPayments, pay)
+}
+
+func (q *FileQueue) PublishDeadLetter(rec map[string]any) error {
+	return q.publish(FileStreamDead, rec)
+}
+
+func (q *FileQueue) publish(stream string, m map[string]any) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return q.Append(stream, b)
+}
+
+// Append writes rec, a JSON document on one line, to stream.
+func (q *FileQueue) Append(stream string, rec []byte) error {
+	if bytes.IndexByte(rec, '\n') >= 0 {
+		return errors.New("file queue: record spans lines")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	w, err := q.writer(stream)
+	if err != nil {
+		return err
+	}
+	if err := lockFile(w.lock); err != nil {
+		return err
+	}
+	defer unlockFile(w.lock)
+	// Segments only grow under the lock, so every writer that finds its
+	// segment full moves on to the same next one.
+	for {
+		st, err := w.f.Stat()
+		if err != nil {
+			return err
+		}
+		if st.Size() < q.segmentBytes {
+			break
+		}
+		f, err := os.OpenFile(segmentPath(q.dir, stream, w.base+st.Size()), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		_ = w.f.Close()
+		w.f, w.base = f, w.base+st.Size()
+	}
+	_, err = w.f.Write(append(rec[:len(rec):len(rec)], '\n'))
+	return err
+}
+
+func (q *FileQueue) writer(stream string) (*segmentWriter, error) {
+	if w := q.writers[stream]; w != nil {
+		return w, nil
+	}
+	dir := filepath.Join(q.dir, stream)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := segments(q.dir, stream)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	var base int64
+	if len(bases) > 0 {
+		base = bases[len(bases)-1]
+	}
+	f, err := os.OpenFile(segmentPath(q.dir, stream, base), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	w := &segmentWriter{lock: lock, f: f, base: base}
+	q.writers[stream] = w
+	return w, nil
+}
+
+func (q *FileQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var err error
+	for stream, w := range q.writers {
+		if e := w.f.Sync(); e != nil && err == nil {
+			err = e
+		}
+		if e := w.f.Close(); e != nil && err == nil {
+			err = e
+		}
+		_ = w.lock.Close()
+		delete(q.writers, stream)
+	}
+	return err
+}
+
+func segmentPath(dir, stream string, base int64) string {
+	return filepath.Join(dir, stream, fmt.Sprintf("%020d.ndjson", base))
+}
+
+// segments returns the bases of the segments of stream in order.
+func segments(dir, stream string) ([]int64, error) {
+	ents, err := os.ReadDir(filepath.Join(dir, stream))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []int64
+	for _, e := range ents {
+		name, ok := strings.CutSuffix(e.Name(), ".ndjson")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if base, err := strconv.ParseInt(name, 10, 64); err == nil {
+			out = append(out, base)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out, nil
+}
+
+// FileEnd returns the offset just past the last record written to stream.
+func FileEnd(dir, stream string) (int64, error) {
+	bases, err := segments(dir, stream)
+	if err != nil || len(bases) == 0 {
+		return 0, err
+	}
+	last := bases[len(bases)-1]
+	st, err := os.Stat(segmentPath(dir, stream, last))
+	if err != nil {
+		return 0, err
+	}
+	return last + st.Size(), nil
+}
+
+// FileReader reads the records of a file queue stream in order, following
+// the stream as it grows.
+type FileReader struct {
+	dir    string
+	stream string
+	start  int64
+
+	f    *os.File
+	r    *bufio.Reader
+	base int64
+	pos  int64
+}
+
+// OpenFileReader returns a reader of stream in dir starting at offset. If
+// the segment holding offset was removed, it starts at the oldest one.
+func OpenFileReader(dir, stream string, offset int64) *FileReader {
+	return &FileReader{dir: dir, stream: stream, start: offset}
+}
+
+// Offset returns the offset of the next record.
+func (r *FileReader) Offset() int64 {
+	if r.f == nil {
+		return r.start
+	}
+	return r.base + r.pos
+}
+
+// Next returns the next record and its offset, or io.EOF if no complete
+// record follows yet.
+func (r *FileReader) Next() ([]byte, int64, error) {
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return nil, 0, err
+		}
+	}
+	drained := false
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if err == nil {
+			off := r.base + r.pos
+			r.pos += int64(len(line))
+			if rec := bytes.TrimSpace(line); len(rec) > 0 {
+				return rec, off, nil
+			}
+			continue
+		}
+		if err != io.EOF {
+			return nil, 0, err
+		}
+		// Leave a line still being written for the next call.
+		if _, err := r.f.Seek(r.pos, io.SeekStart); err != nil {
+			return nil, 0, err
+		}
+		r.r.Reset(r.f)
+		if len(line) > 0 {
+			return nil, 0, io.EOF
+		}
+		next, ok, err := r.nextSegment()
+		if err != nil || !ok {
+			return nil, 0, io.EOF
+		}
+		// A segment is complete once the next one exists, but it may have
+		// grown since it was read: drain it once more before moving on.
+		if !drained {
+			drained = true
+			continue
+		}
+		if err := r.openSegment(next, 0); err != nil {
+			return nil, 0, err
+		}
+		drained = false
+	}
+}
+
+func (r *FileReader) open() error {
+	bases, err := segments(r.dir, r.stream)
+	if err != nil {
+		return err
+	}
+	if len(bases) == 0 {
+		return io.EOF
+	}
+	base := bases[0]
+	for _, b := range bases {
+		if b <= r.start {
+			base = b
+		}
+	}
+	pos := r.start - base
+	if pos < 0 {
+		pos = 0
+	}
+	return r.openSegment(base, pos)
+}
+
+func (r *FileReader) openSegment(base, pos int64) error {
+	f, err := os.Open(segmentPath(r.dir, r.stream, base))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(pos, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	if r.f != nil {
+		_ = r.f.Close()
+	}
+	r.f, r.base, r.pos = f, base, pos
+	if r.r == nil {
+		r.r = bufio.NewReaderSize(f, 64<<10)
+	} else {
+		r.r.Reset(f)
+	}
+	return nil
+}
+
+func (r *FileReader) nextSegment() (int64, bool, error) {
+	bases, err := segments(r.dir, r.stream)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, b := range bases {
+		if b > r.base {
+			return b, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (r *FileReader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.start, r.f = r.base+r.pos, nil
+	return err
+}
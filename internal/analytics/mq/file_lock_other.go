@@ -0,0 +1,10 @@
+//go:build !unix
+
+package mq
+
+import "os"
+
+// Without flock only one process may write to a file queue directory.
+func lockFile(*os.File) error { return nil }
+
+func unlockFile(*os.File) {}
@@ -0,0 +1,91 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+func readAll(t *testing.T, r *FileReader) []string {
+	t.Helper()
+	var out []string
+	for {
+		rec, _, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, string(rec))
+	}
+}
+
+func TestFileQueueSegmentsAndOffsets(t *testing.T) {
+	dir := t.TempDir()
+	// Two writers on one directory, as ingest and the server would be.
+	a, err := NewFile(dir, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewFile(dir, 24)
+	defer a.Close()
+	defer b.Close()
+
+	r := OpenFileReader(dir, FileStreamEvents, 0)
+	defer r.Close()
+	if got := readAll(t, r); len(got) != 0 {
+		t.Fatalf("empty stream returned %v", got)
+	}
+	for i := 0; i < 10; i++ {
+		q := a
+		if i%2 == 1 {
+			q = b
+		}
+		if err := q.PublishEvent(map[string]any{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bases, _ := segments(dir, FileStreamEvents)
+	if len(bases) < 3 {
+		t.Fatalf("expected several segments, got %v", bases)
+	}
+	got := readAll(t, r)
+	if len(got) != 10 || got[0] != `{"n":0}` || got[9] != `{"n":9}` {
+		t.Fatalf("read %v", got)
+	}
+	end, _ := FileEnd(dir, FileStreamEvents)
+	if r.Offset() != end {
+		t.Fatalf("reader at %d, stream ends at %d", r.Offset(), end)
+	}
+
+	// A reader resumes from the offset of any record, across segments.
+	r2 := OpenFileReader(dir, FileStreamEvents, 0)
+	var offsets []int64
+	for {
+		_, off, err := r2.Next()
+		if err != nil {
+			break
+		}
+		offsets = append(offsets, off)
+	}
+	r3 := OpenFileReader(dir, FileStreamEvents, offsets[7])
+	if got := readAll(t, r3); len(got) != 3 || got[0] != `{"n":7}` {
+		t.Fatalf("resumed read %v", got)
+	}
+
+	// A line still being written is left for later.
+	last := bases[len(bases)-1]
+	f, _ := os.OpenFile(segmentPath(dir, FileStreamEvents, last), os.O_APPEND|os.O_WRONLY, 0)
+	fmt.Fprint(f, `{"n":`)
+	if got := readAll(t, r); len(got) != 0 {
+		t.Fatalf("partial line returned %v", got)
+	}
+	fmt.Fprint(f, "10}\n")
+	f.Close()
+	if got := readAll(t, r); len(got) != 1 || got[0] != `{"n":10}` {
+		t.Fatalf("completed line %v", got)
+	}
+}
@@ -14,6 +14,7 @@ import (
 type kafkaQueue struct {
 	wEvents   *kafka.Writer
 	wPayments *kafka.Writer
+	wDead     *kafka.Writer
 }
 
 func NewKafka(brokers []string, topicEvents, topicPayments string) Queue {
@@ -29,7 +30,8 @@ func NewKafka(brokers []string, topicEvents, topicPayments string) Queue {
 	// Writers are safe for concurrent use
 	we := &kafka.Writer{Addr: kafka.TCP(brokers...), Topic: topicEvents, RequiredAcks: kafka.RequireOne, Balancer: &kafka.LeastBytes{}, BatchTimeout: 50 * time.Millisecond}
 	wp := &kafka.Writer{Addr: kafka.TCP(brokers...), Topic: topicPayments, RequiredAcks: kafka.RequireOne, Balancer: &kafka.LeastBytes{}, BatchTimeout: 50 * time.Millisecond}
-	return &kafkaQueue{wEvents: we, wPayments: wp}
+	wd := &kafka.Writer{Addr: kafka.TCP(brokers...), Topic: topicEvents + ".dead", RequiredAcks: kafka.RequireOne, Balancer: &kafka.LeastBytes{}, BatchTimeout: 50 * time.Millisecond}
+	return &kafkaQueue{wEvents: we, wPayments: wp, wDead: wd}
 }
 
 func newKafkaFromEnv() (Queue, error) {
@@ -40,6 +42,11 @@ func newKafkaFromEnv() (Queue, error) {
 	events := strings.TrimSpace(os.Getenv("ANALYTICS_KAFKA_TOPIC_EVENTS"))
 	pays := strings.TrimSpace(os.Getenv("ANALYTICS_KAFKA_TOPIC_PAYMENTS"))
 	q := NewKafka(strings.Split(bs, ","), events, pays)
+	if kq, ok := q.(*kafkaQueue); ok {
+		if dead := strings.TrimSpace(os.Getenv("ANALYTICS_KAFKA_TOPIC_DEAD")); dead != "" {
+			kq.wDead.Topic = dead
+		}
+	}
 	log.Printf("[analytics-mq] kafka publisher enabled: brokers=%s events=%s payments=%s", bs, events, pays)
 	return q, nil
 }
@@ -56,6 +63,11 @@ func (q *kafkaQueue) Close() error {
 			err = e
 		}
 	}
+	if q.wDead != nil {
+		if e := q.wDead.Close(); e != nil {
+			err = e
+		}
+	}
 	return err
 }
 
@@ -69,5 +81,6 @@ func (q *kafkaQueue) write(w *kafka.Writer, m map[string]any) error {
 	return w.WriteMessages(ctx, kafka.Message{Value: b})
 }
 
-func (q *kafkaQueue) PublishEvent(evt map[string]any) error   { return q.write(q.wEvents, evt) }
-func (q *kafkaQueue) PublishPayment(pay map[string]any) error { return q.write(q.wPayments, pay) }
+func (q *kafkaQueue) PublishEvent(evt map[string]any) error      { return q.write(q.wEvents, evt) }
+func (q *kafkaQueue) PublishPayment(pay map[string]any) error    { return q.write(q.wPayments, pay) }
+func (q *kafkaQueue) PublishDeadLetter(rec map[string]any) error { return q.write(q.wDead, rec) }
@@ -2,7 +2,8 @@ package mq
 
 type Noop struct{}
 
-func NewNoop() *Noop                                { return &Noop{} }
-func (n *Noop) PublishEvent(map[string]any) error   { return nil }
-func (n *Noop) PublishPayment(map[string]any) error { return nil }
-func (n *Noop) Close() error                        { return nil }
+func NewNoop() *Noop                                   { return &Noop{} }
+func (n *Noop) PublishEvent(map[string]any) error      { return nil }
+func (n *Noop) PublishPayment(map[string]any) error    { return nil }
+func (n *Noop) PublishDeadLetter(map[string]any) error { return nil }
+func (n *Noop) Close() error                           { return nil }
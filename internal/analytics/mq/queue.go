@@ -5,5 +5,8 @@ package mq
 type Queue interface {
 	PublishEvent(evt map[string]any) error
 	PublishPayment(pay map[string]any) error
+	// PublishDeadLetter quarantines a message that failed validation or
+	// processing, together with the reason, for inspection and replay.
+	PublishDeadLetter(rec map[string]any) error
 	Close() error
 }
@@ -15,6 +15,7 @@ type redisQueue struct {
 	cli            *redis.Client
 	streamEvents   string
 	streamPayments string
+	streamDead     string
 	maxLenApprox   bool
 	maxLen         int64
 }
@@ -26,7 +27,7 @@ func NewRedis(url, streamEvents, streamPayments string, maxLen int64, approx boo
 		return NewNoop()
 	}
 	cli := redis.NewClient(opt)
-	return &redisQueue{cli: cli, streamEvents: streamEvents, streamPayments: streamPayments, maxLen: maxLen, maxLenApprox: approx}
+	return &redisQueue{cli: cli, streamEvents: streamEvents, streamPayments: streamPayments, streamDead: streamEvents + ":dead", maxLen: maxLen, maxLenApprox: approx}
 }
 
 func newRedisFromEnv() (Queue, error) {
@@ -52,7 +53,13 @@ func newRedisFromEnv() (Queue, error) {
 	if v := os.Getenv("ANALYTICS_REDIS_MAXLEN_APPROX"); v != "" {
 		approx = (v == "1" || v == "true" || v == "yes")
 	}
-	return NewRedis(url, se, sp, ml, approx), nil
+	q := NewRedis(url, se, sp, ml, approx)
+	if rq, ok := q.(*redisQueue); ok {
+		if sd := os.Getenv("ANALYTICS_REDIS_STREAM_DEAD"); sd != "" {
+			rq.streamDead = sd
+		}
+	}
+	return q, nil
 }
 
 func (q *redisQueue) Close() error { return q.cli.Close() }
@@ -79,3 +86,9 @@ func (q *redisQueue) PublishPayment(pay map[string]any) error {
 	defer cancel()
 	return q.xadd(ctx, q.streamPayments, pay)
 }
+
+func (q *redisQueue) PublishDeadLetter(rec map[string]any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return q.xadd(ctx, q.streamDead, rec)
+}
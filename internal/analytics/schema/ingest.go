@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Publisher is the part of the analytics queue ingestion writes to.
+type Publisher interface {
+	PublishEvent(evt map[string]any) error
+	PublishDeadLetter(rec map[string]any) error
+}
+
+// Mode decides what happens to events failing validation.
+type Mode string
+
+const (
+	// ModeQuarantine writes invalid events to the dead-letter stream.
+	ModeQuarantine Mode = "quarantine"
+	// ModeReject drops invalid events; the client only learns from the report.
+	ModeReject Mode = "reject"
+)
+
+// ParseMode parses a configured mode; empty means ModeQuarantine.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeQuarantine:
+		return ModeQuarantine, nil
+	case ModeReject:
+		return ModeReject, nil
+	}
+	return "", fmt.Errorf("invalid analytics mode %q: want quarantine or reject", s)
+}
+
+// Rejection reports an event refused by Ingest, by its position in the batch.
+type Rejection struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+	Field  string `json:"field,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report counts the events of a batch accepted and rejected by Ingest.
+type Report struct {
+	Accepted int         `json:"accepted"`
+	Rejected int         `json:"rejected"`
+	Errors   []Rejection `json:"errors,omitempty"`
+}
+
+// Ingest validates each event against spec, publishing valid events normalized
+// and handling invalid ones according to mode. A nil spec publishes every
+// event unchanged. On a queue error the report covers the events before it.
+func Ingest(spec *Spec, q Publisher, mode Mode, events []map[string]any) (Report, error) {
+	var rep Report
+	for i, raw := range events {
+		if raw == nil {
+			continue
+		}
+		evt := raw
+		if spec != nil {
+			norm, err := spec.Normalize(raw)
+			if err != nil {
+				var se *Error
+				if !errors.As(err, &se) {
+					return rep, err
+				}
+				if mode != ModeReject {
+					if err := q.PublishDeadLetter(DeadLetter(raw, se, time.Now())); err != nil {
+						return rep, err
+					}
+				}
+				rep.Rejected++
+				rep.Errors = append(rep.Errors, Rejection{Index: i, Reason: se.Reason, Field: se.Field, Detail: se.Detail})
+				continue
+			}
+			evt = norm
+		}
+		if err := q.PublishEvent(evt); err != nil {
+			return rep, err
+		}
+		rep.Accepted++
+	}
+	return rep, nil
+}
+
+// DeadLetter builds the dead-letter record of raw, refused for err.
+func DeadLetter(raw map[string]any, err *Error, at time.Time) map[string]any {
+	rec := map[string]any{
+		"reason":      err.Reason,
+		"detail":      err.Detail,
+		"event":       raw,
+		"received_at": at.UTC().Format(time.RFC3339Nano),
+	}
+	if err.Field != "" {
+		rec["field"] = err.Field
+	}
+	return rec
+}
@@ -0,0 +1,302 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Reasons an event is refused.
+const (
+	ReasonUnknownEvent       = "unknown_event"
+	ReasonMissingAttribute   = "missing_attribute"
+	ReasonInvalidType        = "invalid_type"
+	ReasonInvalidEnum        = "invalid_enum"
+	ReasonInvalidTime        = "invalid_time"
+	ReasonDuplicateAttribute = "duplicate_attribute"
+)
+
+// Error explains why an event does not match the specification.
+type Error struct {
+	Reason string
+	Field  string
+	Detail string
+}
+
+func (e *Error) Error() string {
+	if e.Field == "" {
+		return e.Reason + ": " + e.Detail
+	}
+	return e.Reason + ": " + e.Field + ": " + e.Detail
+}
+
+// envelope fields describe where an event comes from rather than the event itself.
+var envelope = map[string]bool{"event": true, "ts": true, "game_id": true, "env": true, "event_id": true}
+
+// nested holds attributes sent as an object of their own; the SDKs use attrs.
+var nested = []string{"attrs", "attributes", "props"}
+
+// aliases maps short attribute names clients send to the specification's.
+var aliases = map[string]string{"uid": "user_id", "sid": "session_id"}
+
+// Normalize checks raw against the specification and returns it in the shape
+// the analytics worker consumes: the envelope (event, ts, game_id, env,
+// event_id) and the common attributes at the top level, every other attribute
+// in props. Attribute names are converted to snake_case, enum values to lower
+// case and times to RFC 3339 in UTC; ts is the event_time attribute, which
+// defaults to the envelope ts. A failure is an *Error.
+func (s *Spec) Normalize(raw map[string]any) (map[string]any, error) {
+	name, _ := raw["event"].(string)
+	if strings.TrimSpace(name) == "" {
+		return nil, &Error{Reason: ReasonUnknownEvent, Field: "event", Detail: "missing event name"}
+	}
+	def, ok := s.Event(name)
+	if !ok {
+		return nil, &Error{Reason: ReasonUnknownEvent, Field: "event", Detail: fmt.Sprintf("%q is not defined", name)}
+	}
+
+	attrs := map[string]any{}
+	put := func(k string, v any) error {
+		k = AttributeName(k)
+		if cur, ok := attrs[k]; ok && !reflect.DeepEqual(cur, v) {
+			return &Error{Reason: ReasonDuplicateAttribute, Field: k, Detail: "sent twice with different values"}
+		}
+		attrs[k] = v
+		return nil
+	}
+	for k, v := range raw {
+		if envelope[strings.ToLower(k)] || isNested(k) {
+			continue
+		}
+		if err := put(k, v); err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range nested {
+		m, _ := raw[n].(map[string]any)
+		for k, v := range m {
+			if envelope[strings.ToLower(k)] {
+				continue
+			}
+			if err := put(k, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, ok := attrs["event_time"]; !ok && raw["ts"] != nil {
+		attrs["event_time"] = raw["ts"]
+	}
+
+	for k, v := range attrs {
+		nv, err := s.normalizeValue(k, v)
+		if err != nil {
+			return nil, err
+		}
+		attrs[k] = nv
+	}
+	for _, k := range def.Required {
+		if isEmpty(attrs[k]) {
+			return nil, &Error{Reason: ReasonMissingAttribute, Field: k, Detail: "required by " + def.ID}
+		}
+	}
+
+	out := map[string]any{"event": def.ID}
+	for _, k := range []string{"game_id", "env", "event_id"} {
+		if v := Envelope(raw, k); v != nil {
+			sv, ok := asString(v)
+			if !ok {
+				return nil, &Error{Reason: ReasonInvalidType, Field: k, Detail: "want string"}
+			}
+			out[k] = sv
+		}
+	}
+	if t, ok := attrs["event_time"]; ok {
+		out["ts"] = t
+		delete(attrs, "event_time")
+	}
+	props := map[string]any{}
+	for k, v := range attrs {
+		if _, ok := s.common[k]; ok {
+			out[k] = v
+		} else {
+			props[k] = v
+		}
+	}
+	if len(props) > 0 {
+		out["props"] = props
+	}
+	return out, nil
+}
+
+// Envelope returns the envelope field k of raw, which clients may also send
+// inside the nested attributes.
+func Envelope(raw map[string]any, k string) any {
+	if v := raw[k]; v != nil {
+		return v
+	}
+	for _, n := range nested {
+		if m, ok := raw[n].(map[string]any); ok && m[k] != nil {
+			return m[k]
+		}
+	}
+	return nil
+}
+
+// EventTime returns the time of raw taken from its ts envelope field.
+func EventTime(raw map[string]any) (time.Time, bool) {
+	return parseTime(Envelope(raw, "ts"))
+}
+
+// normalizeValue checks v against the type the specification gives k.
+func (s *Spec) normalizeValue(k string, v any) (any, error) {
+	typ, enum := "", ""
+	if a, ok := s.common[k]; ok {
+		typ, enum = a.Type, a.Enum
+	} else if _, ok := s.enums[k]; ok {
+		typ, enum = TypeEnum, k
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch typ {
+	case TypeString:
+		sv, ok := asString(v)
+		if !ok {
+			return nil, &Error{Reason: ReasonInvalidType, Field: k, Detail: "want string"}
+		}
+		return sv, nil
+	case TypeEnum:
+		sv, ok := v.(string)
+		if !ok {
+			return nil, &Error{Reason: ReasonInvalidType, Field: k, Detail: "want string"}
+		}
+		sv = strings.ToLower(strings.TrimSpace(sv))
+		if !s.enums[enum][sv] {
+			return nil, &Error{Reason: ReasonInvalidEnum, Field: k, Detail: fmt.Sprintf("%q is not one of %s", sv, strings.Join(s.Enums[enum], ", "))}
+		}
+		return sv, nil
+	case TypeDatetime:
+		t, ok := parseTime(v)
+		if !ok {
+			return nil, &Error{Reason: ReasonInvalidTime, Field: k, Detail: "want RFC 3339 or a unix timestamp"}
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	return v, nil
+}
+
+// AttributeName converts a client attribute name such as userId, User-ID or
+// "app version" to the snake_case used by the specification.
+func AttributeName(k string) string {
+	rs := []rune(strings.TrimSpace(k))
+	var b strings.Builder
+	for i, r := range rs {
+		switch {
+		case r == '-' || r == ' ' || r == '.' || r == '_':
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+		case unicode.IsUpper(r):
+			if i > 0 && !strings.HasSuffix(b.String(), "_") {
+				prev := rs[i-1]
+				nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	name := strings.TrimSuffix(b.String(), "_")
+	if a, ok := aliases[name]; ok {
+		return a
+	}
+	return name
+}
+
+func isNested(k string) bool {
+	for _, n := range nested {
+		if strings.EqualFold(k, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// asString accepts strings and whole numbers, which clients send for numeric
+// ids. Numbers may be json.Number when decoded with UseNumber.
+func asString(v any) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < 1<<53 {
+			return strconv.FormatInt(int64(t), 10), true
+		}
+	case int:
+		return strconv.Itoa(t), true
+	case int64:
+		return strconv.FormatInt(t, 10), true
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return t.String(), true
+		}
+	}
+	return "", false
+}
+
+// parseTime accepts RFC 3339 and unix timestamps in seconds or milliseconds,
+// as numbers or numeric strings.
+func parseTime(v any) (time.Time, bool) {
+	var n float64
+	switch t := v.(type) {
+	case string:
+		t = strings.TrimSpace(t)
+		if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return ts, true
+		}
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		n = f
+	case float64:
+		n = t
+	case int:
+		n = float64(t)
+	case int64:
+		n = float64(t)
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		n = f
+	default:
+		return time.Time{}, false
+	}
+	if n <= 0 {
+		return time.Time{}, false
+	}
+	// Seconds reach 1e12 in the year 33658; anything larger is milliseconds.
+	if n >= 1e12 {
+		return time.UnixMilli(int64(n)), true
+	}
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
@@ -0,0 +1,120 @@
+// Package schema validates and normalizes analytics events against the event
+// specification in configs/analytics/events.yaml.
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Attribute types of the specification.
+const (
+	TypeString   = "string"
+	TypeEnum     = "enum"
+	TypeDatetime = "datetime"
+)
+
+// Attribute is a common attribute shared by all events.
+type Attribute struct {
+	Key  string `yaml:"key"`
+	Type string `yaml:"type"`
+	// Enum names the entry of Spec.Enums holding the values of an enum attribute.
+	Enum     string `yaml:"enum"`
+	Format   string `yaml:"format"`
+	Required bool   `yaml:"required"`
+}
+
+// EventDef defines one event id and the attributes it carries.
+type EventDef struct {
+	ID       string   `yaml:"id"`
+	Category string   `yaml:"category"`
+	Required []string `yaml:"required_attributes"`
+	Optional []string `yaml:"optional_attributes"`
+	Signal   string   `yaml:"signal"`
+}
+
+// Spec is a loaded event specification. Common attributes fix the type of an
+// attribute wherever it appears; which attributes an event needs is decided by
+// its own required_attributes. Attributes named after an enum, such as
+// match_result, only take that enum's values.
+type Spec struct {
+	Version          string              `yaml:"version"`
+	Enums            map[string][]string `yaml:"enums"`
+	CommonAttributes []Attribute         `yaml:"common_attributes"`
+	Events           []EventDef          `yaml:"events"`
+
+	common map[string]Attribute
+	events map[string]*EventDef
+	// byKey finds events sent as session_start for session.start.
+	byKey map[string]*EventDef
+	enums map[string]map[string]bool
+}
+
+// Load reads the specification at path.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses a specification.
+func Parse(data []byte) (*Spec, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	var s Spec
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse event spec: %w", err)
+	}
+	s.common = make(map[string]Attribute, len(s.CommonAttributes))
+	for _, a := range s.CommonAttributes {
+		if a.Type == TypeEnum && s.Enums[a.Enum] == nil {
+			return nil, fmt.Errorf("attribute %s: unknown enum %q", a.Key, a.Enum)
+		}
+		s.common[a.Key] = a
+	}
+	s.events = make(map[string]*EventDef, len(s.Events))
+	s.byKey = make(map[string]*EventDef, len(s.Events))
+	for i := range s.Events {
+		ev := &s.Events[i]
+		if ev.ID == "" {
+			return nil, fmt.Errorf("event %d: missing id", i)
+		}
+		if s.events[ev.ID] != nil {
+			return nil, fmt.Errorf("event %s: defined twice", ev.ID)
+		}
+		s.events[ev.ID] = ev
+		s.byKey[eventKey(ev.ID)] = ev
+	}
+	s.enums = make(map[string]map[string]bool, len(s.Enums))
+	for name, vals := range s.Enums {
+		m := make(map[string]bool, len(vals))
+		for _, v := range vals {
+			m[strings.ToLower(v)] = true
+		}
+		s.enums[name] = m
+	}
+	return &s, nil
+}
+
+// Event returns the definition of the event id, accepting the variants Normalize does.
+func (s *Spec) Event(id string) (*EventDef, bool) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if ev := s.events[id]; ev != nil {
+		return ev, true
+	}
+	ev := s.byKey[eventKey(id)]
+	return ev, ev != nil
+}
+
+// SameEvent reports whether a and b name the same event, accepting the
+// variants Normalize does.
+func SameEvent(a, b string) bool { return eventKey(a) == eventKey(b) }
+
+func eventKey(id string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToLower(id))
+}
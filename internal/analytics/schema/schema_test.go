@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func loadSpec(t *testing.T) *Spec {
+	t.Helper()
+	_, file, _, _ := runtime.Caller(0)
+	s, err := Load(filepath.Join(filepath.Dir(file), "..", "..", "..", "configs", "analytics", "events.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAttributeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"user_id":     "user_id",
+		"userId":      "user_id",
+		"UserID":      "user_id",
+		"app version": "app_version",
+		"Level-Id":    "level_id",
+		"uid":         "user_id",
+		"HTTPStatus":  "http_status",
+	} {
+		if got := AttributeName(in); got != want {
+			t.Errorf("AttributeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	s := loadSpec(t)
+	out, err := s.Normalize(map[string]any{
+		"event": "Session_Start",
+		"ts":    float64(1700000000000),
+		"attrs": map[string]any{
+			"game_id":    "g1",
+			"uid":        "u1",
+			"sessionId":  "s1",
+			"Platform":   "IOS",
+			"entryPoint": "push",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["event"] != "session.start" || out["user_id"] != "u1" || out["session_id"] != "s1" || out["platform"] != "ios" {
+		t.Fatalf("out = %v", out)
+	}
+	if out["ts"] != "2023-11-14T22:13:20Z" || out["game_id"] != "g1" {
+		t.Fatalf("envelope = %v", out)
+	}
+	if props, _ := out["props"].(map[string]any); props["entry_point"] != "push" {
+		t.Fatalf("props = %v", out["props"])
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	s := loadSpec(t)
+	base := func() map[string]any {
+		return map[string]any{"event": "match.end", "ts": "2024-01-01T00:00:00Z", "user_id": "u1", "session_id": "s1", "match_id": "m1", "match_result": "win"}
+	}
+	cases := []struct {
+		name   string
+		edit   func(map[string]any)
+		reason string
+		field  string
+	}{
+		{"unknown event", func(m map[string]any) { m["event"] = "match.teleport" }, ReasonUnknownEvent, "event"},
+		{"missing", func(m map[string]any) { delete(m, "match_id") }, ReasonMissingAttribute, "match_id"},
+		{"empty", func(m map[string]any) { m["user_id"] = "" }, ReasonMissingAttribute, "user_id"},
+		{"enum", func(m map[string]any) { m["match_result"] = "victory" }, ReasonInvalidEnum, "match_result"},
+		{"type", func(m map[string]any) { m["session_id"] = true }, ReasonInvalidType, "session_id"},
+		{"time", func(m map[string]any) { m["ts"] = "yesterday" }, ReasonInvalidTime, "event_time"},
+		{"duplicate", func(m map[string]any) { m["userId"] = "u2" }, ReasonDuplicateAttribute, "user_id"},
+	}
+	for _, c := range cases {
+		m := base()
+		c.edit(m)
+		_, err := s.Normalize(m)
+		var se *Error
+		if !errors.As(err, &se) || se.Reason != c.reason || se.Field != c.field {
+			t.Errorf("%s: err = %v, want %s on %s", c.name, err, c.reason, c.field)
+		}
+	}
+	if _, err := s.Normalize(base()); err != nil {
+		t.Fatalf("valid event: %v", err)
+	}
+}
+
+type recorder struct{ events, dead []map[string]any }
+
+func (r *recorder) PublishEvent(e map[string]any) error      { r.events = append(r.events, e); return nil }
+func (r *recorder) PublishDeadLetter(e map[string]any) error { r.dead = append(r.dead, e); return nil }
+
+func TestIngest(t *testing.T) {
+	s := loadSpec(t)
+	batch := []map[string]any{
+		{"event": "user.login", "ts": 1700000000, "user_id": "u1"},
+		{"event": "user.login", "ts": json.Number("1700000000000"), "user_id": json.Number("42")},
+		{"event": "user.login"},
+		{"event": "nope"},
+	}
+	q := &recorder{}
+	rep, err := Ingest(s, q, ModeQuarantine, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Accepted != 2 || rep.Rejected != 2 || len(q.events) != 2 || len(q.dead) != 2 {
+		t.Fatalf("report = %+v, published %d, dead %d", rep, len(q.events), len(q.dead))
+	}
+	if q.events[1]["user_id"] != "42" || q.events[1]["ts"] != "2023-11-14T22:13:20Z" {
+		t.Fatalf("json.Number event = %v", q.events[1])
+	}
+	if rep.Errors[0].Index != 2 || rep.Errors[1].Reason != ReasonUnknownEvent || q.dead[0]["reason"] != ReasonMissingAttribute {
+		t.Fatalf("errors = %+v, dead = %v", rep.Errors, q.dead)
+	}
+
+	q = &recorder{}
+	if rep, _ := Ingest(s, q, ModeReject, batch); rep.Rejected != 2 || len(q.dead) != 0 {
+		t.Fatalf("reject mode: report = %+v, dead %d", rep, len(q.dead))
+	}
+}
@@ -0,0 +1,194 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// --- Aggregation helpers ---
+
+type revRow struct {
+	revenue uint64
+	refunds uint64
+	failed  uint64
+}
+
+func (w *Worker) touchAgg(ctx context.Context, m map[string]any) {
+	if w.rdb == nil {
+		return
+	}
+	w.aggMu.Lock()
+	defer w.aggMu.Unlock()
+	ts := asString(m, "ts")
+	t, _ := time.Parse(time.RFC3339, ts)
+	if ts == "" || t.IsZero() {
+		t = time.Now()
+	}
+	game := asString(m, "game_id")
+	env := asString(m, "env")
+	uid := asString(m, "user_id")
+	// Validated events use the spec ids (session.start); older clients send session_start.
+	evt := strings.ReplaceAll(strings.ToLower(asString(m, "event")), ".", "_")
+	// minute online (heartbeat or session_start)
+	if evt == "heartbeat" || evt == "session_start" {
+		min := t.Truncate(time.Minute)
+		k := fmt.Sprintf("hll:online:%s:%s:%s", game, env, min.Format("200601021504"))
+		_ = w.rdb.PFAdd(ctx, k, uid).Err()
+		_ = w.rdb.Expire(ctx, k, 48*time.Hour).Err()
+		w.touchedMinutes[k] = struct{}{}
+	}
+	// DAU
+	if evt == "login" || evt == "user_login" || evt == "session_start" {
+		day := t.Format("2006-01-02")
+		k := fmt.Sprintf("hll:dau:%s:%s:%s", game, env, day)
+		_ = w.rdb.PFAdd(ctx, k, uid).Err()
+		_ = w.rdb.Expire(ctx, k, 30*24*time.Hour).Err()
+		w.touchedDays[fmt.Sprintf("%s|%s|%s", day, game, env)] = struct{}{}
+	}
+	// new users
+	if evt == "register" || evt == "user_register" || evt == "first_active" {
+		day := t.Format("2006-01-02")
+		k := fmt.Sprintf("hll:new:%s:%s:%s", game, env, day)
+		_ = w.rdb.PFAdd(ctx, k, uid).Err()
+		_ = w.rdb.Expire(ctx, k, 30*24*time.Hour).Err()
+		w.touchedDays[fmt.Sprintf("%s|%s|%s", day, game, env)] = struct{}{}
+	}
+}
+
+func (w *Worker) touchRevenue(ctx context.Context, m map[string]any) {
+	w.aggMu.Lock()
+	defer w.aggMu.Unlock()
+	ts := asString(m, "ts")
+	t, _ := time.Parse(time.RFC3339, ts)
+	if ts == "" || t.IsZero() {
+		t = time.Now()
+	}
+	day := t.Format("2006-01-02")
+	game := asString(m, "game_id")
+	env := asString(m, "env")
+	status := strings.ToLower(asString(m, "status"))
+	amt := uint64(asFloat(m, "amount_cents"))
+	key := fmt.Sprintf("%s|%s|%s", day, game, env)
+	rv := w.revAgg[key]
+	if rv == nil {
+		rv = &revRow{}
+		w.revAgg[key] = rv
+	}
+	if status == "success" {
+		rv.revenue += amt
+	}
+	if status == "refunded" {
+		rv.refunds += amt
+	}
+	if status == "failed" {
+		rv.failed += 1
+	}
+}
+
+// flushAggregates writes the aggregates of closed minutes and the daily
+// counters touched since the last call. The online and daily-user
+// aggregates need Redis.
+func (w *Worker) flushAggregates(ctx context.Context) error {
+	w.aggMu.Lock()
+	defer w.aggMu.Unlock()
+	nowMin := time.Now().Truncate(time.Minute)
+	// flush minute_online for minutes earlier than current minute
+	for k := range w.touchedMinutes {
+		parts := strings.Split(k, ":") // hll:online:game:env:YYYYMMDDHHmm
+		if len(parts) < 5 {
+			delete(w.touchedMinutes, k)
+			continue
+		}
+		ts := parts[len(parts)-1]
+		t, err := time.Parse("200601021504", ts)
+		if err != nil || !t.Before(nowMin) {
+			continue
+		}
+		game := parts[2]
+		env := parts[3]
+		n, err := w.rdb.PFCount(ctx, k).Result()
+		if err != nil {
+			slog.Warn("pfcount", "key", k, "err", err)
+			continue
+		}
+		if n < 0 {
+			n = 0
+		}
+		batch, err := w.ch.PrepareBatch(ctx, "INSERT INTO analytics.minute_online (m, game_id, env, online)")
+		if err != nil {
+			slog.Warn("ch batch", "err", err)
+			continue
+		}
+		if err := batch.Append(t, game, env, uint32(n)); err != nil {
+			slog.Warn("batch append", "err", err)
+			continue
+		}
+		if err := batch.Send(); err != nil {
+			slog.Warn("batch send", "err", err)
+			continue
+		}
+		delete(w.touchedMinutes, k)
+	}
+	// flush daily_users
+	for dk := range w.touchedDays {
+		sp := strings.Split(dk, "|")
+		if len(sp) != 3 {
+			delete(w.touchedDays, dk)
+			continue
+		}
+		day, game, env := sp[0], sp[1], sp[2]
+		kdau := fmt.Sprintf("hll:dau:%s:%s:%s", game, env, day)
+		knew := fmt.Sprintf("hll:new:%s:%s:%s", game, env, day)
+		dau, _ := w.rdb.PFCount(ctx, kdau).Result()
+		neu, _ := w.rdb.PFCount(ctx, knew).Result()
+		d, _ := time.Parse("2006-01-02", day)
+		ver := uint64(time.Now().Unix())
+		batch, err := w.ch.PrepareBatch(ctx, "INSERT INTO analytics.daily_users (d, game_id, env, dau, new_users, version)")
+		if err != nil {
+			slog.Warn("ch daily_users", "err", err)
+			continue
+		}
+		if err := batch.Append(d, game, env, uint64(max0(dau)), uint64(max0(neu)), ver); err != nil {
+			slog.Warn("daily_users append", "err", err)
+			continue
+		}
+		if err := batch.Send(); err != nil {
+			slog.Warn("daily_users send", "err", err)
+			continue
+		}
+	}
+	// flush daily_revenue
+	for rk, rv := range w.revAgg {
+		sp := strings.Split(rk, "|")
+		if len(sp) != 3 {
+			continue
+		}
+		day, game, env := sp[0], sp[1], sp[2]
+		d, _ := time.Parse("2006-01-02", day)
+		ver := uint64(time.Now().Unix())
+		batch, err := w.ch.PrepareBatch(ctx, "INSERT INTO analytics.daily_revenue (d, game_id, env, revenue_cents, refunds_cents, failed, version)")
+		if err != nil {
+			slog.Warn("ch daily_revenue", "err", err)
+			continue
+		}
+		if err := batch.Append(d, game, env, rv.revenue, rv.refunds, rv.failed, ver); err != nil {
+			slog.Warn("daily_revenue append", "err", err)
+			continue
+		}
+		if err := batch.Send(); err != nil {
+			slog.Warn("daily_revenue send", "err", err)
+			continue
+		}
+	}
+	return nil
+}
+
+func max0(n int64) int64 {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// Tables rows are inserted into.
+const (
+	TableEvents   = "analytics.events"
+	TablePayments = "analytics.payments"
+)
+
+// Sink stores batches of analytics rows.
+type Sink interface {
+	// Insert stores rows into table all at once. A row that cannot be stored
+	// fails the batch with a *RowError naming it.
+	Insert(ctx context.Context, table string, rows []map[string]any) error
+}
+
+// RowError reports the row of a batch that failed it.
+type RowError struct {
+	Index int
+	Err   error
+}
+
+func (e *RowError) Error() string { return fmt.Sprintf("row %d: %v", e.Index, e.Err) }
+func (e *RowError) Unwrap() error { return e.Err }
+
+type clickhouseSink struct{ ch clickhouse.Conn }
+
+func (s clickhouseSink) Insert(ctx context.Context, table string, rows []map[string]any) error {
+	var query string
+	var values func(map[string]any) []any
+	switch table {
+	case TableEvents:
+		query, values = "INSERT INTO analytics.events (event_time, game_id, env, user_id, session_id, event, channel, platform, country, app_version, event_id, props_json)", eventValues
+	case TablePayments:
+		query, values = "INSERT INTO analytics.payments (time, game_id, env, user_id, order_id, amount_cents, currency, status, channel, platform, country, region, city, product_id, reason)", paymentValues
+	default:
+		return fmt.Errorf("unknown table %s", table)
+	}
+	batch, err := s.ch.PrepareBatch(ctx, query)
+	if err != nil {
+		return err
+	}
+	for i, r := range rows {
+		if err := batch.Append(values(r)...); err != nil {
+			_ = batch.Abort()
+			return &RowError{Index: i, Err: err}
+		}
+	}
+	return batch.Send()
+}
+
+func eventValues(m map[string]any) []any {
+	propsBytes, _ := json.Marshal(m["props"]) // may be nil
+	return []any{
+		rowTime(m, "ts"), asString(m, "game_id"), asString(m, "env"), asString(m, "user_id"), asString(m, "session_id"),
+		asString(m, "event"), asString(m, "channel"), asString(m, "platform"), asString(m, "country"), asString(m, "app_version"),
+		eventID(m), string(propsBytes),
+	}
+}
+
+// eventID returns the event_id column, which is a UUID; events without an id
+// get the nil UUID.
+func eventID(m map[string]any) string {
+	if id := asString(m, "event_id"); id != "" {
+		return id
+	}
+	return "00000000-0000-0000-0000-000000000000"
+}
+
+func paymentValues(m map[string]any) []any {
+	return []any{
+		rowTime(m, "ts"), asString(m, "game_id"), asString(m, "env"), asString(m, "user_id"), asString(m, "order_id"),
+		uint64(asFloat(m, "amount_cents")), asString(m, "currency"), asString(m, "status"), asString(m, "channel"),
+		asString(m, "platform"), asString(m, "country"), asString(m, "region"), asString(m, "city"),
+		asString(m, "product_id"), asString(m, "reason"),
+	}
+}
+
+// rowTime parses the RFC 3339 time in m[k], defaulting to now.
+func rowTime(m map[string]any, k string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, asString(m, k)); err == nil {
+		return t
+	}
+	return time.Now()
+}
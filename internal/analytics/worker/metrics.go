@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics counts what the worker consumed, stored and dead-lettered.
+type Metrics struct {
+	mu             sync.Mutex
+	consumedTotal  map[string]int64 // by stream
+	insertedRows   map[string]int64 // by table
+	batches        map[string]int64
+	insertSeconds  map[string]float64
+	insertFailures map[string]int64
+	deadLetters    map[string]int64 // by reason
+	claimedTotal   int64
+	lag            map[string]int64 // by stream
+	pending        map[string]int64
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		consumedTotal:  map[string]int64{},
+		insertedRows:   map[string]int64{},
+		batches:        map[string]int64{},
+		insertSeconds:  map[string]float64{},
+		insertFailures: map[string]int64{},
+		deadLetters:    map[string]int64{},
+		lag:            map[string]int64{},
+		pending:        map[string]int64{},
+	}
+}
+
+func (m *Metrics) consumed(stream string) {
+	m.mu.Lock()
+	m.consumedTotal[stream]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) inserted(table string, rows int, d time.Duration) {
+	m.mu.Lock()
+	m.insertedRows[table] += int64(rows)
+	m.batches[table]++
+	m.insertSeconds[table] += d.Seconds()
+	m.mu.Unlock()
+}
+
+func (m *Metrics) insertFailed(table string) {
+	m.mu.Lock()
+	m.insertFailures[table]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) deadLettered(reason string) {
+	m.mu.Lock()
+	m.deadLetters[reason]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) claimed(n int) {
+	m.mu.Lock()
+	m.claimedTotal += int64(n)
+	m.mu.Unlock()
+}
+
+func (m *Metrics) setLag(stream string, lag, pending int64) {
+	m.mu.Lock()
+	m.lag[stream] = lag
+	m.pending[stream] = pending
+	m.mu.Unlock()
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format.
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeFamily(w, "analytics_worker_consumed_total", "Messages read from the queue", "counter", "stream", m.consumedTotal)
+	writeFamily(w, "analytics_worker_inserted_rows_total", "Rows stored in ClickHouse", "counter", "table", m.insertedRows)
+	writeSummary(w, "analytics_worker_batch_rows", "Rows per stored batch", m.insertedRows, m.batches)
+	writeSummary(w, "analytics_worker_insert_seconds", "Time to store a batch", m.insertSeconds, m.batches)
+	writeFamily(w, "analytics_worker_insert_failures_total", "Batches that failed to store and were left for retry", "counter", "table", m.insertFailures)
+	writeFamily(w, "analytics_worker_dead_letters_total", "Messages moved to the dead-letter queue", "counter", "reason", m.deadLetters)
+	writeFamily(w, "analytics_worker_claimed_total", "Pending messages claimed for retry", "counter", "", map[string]int64{"": m.claimedTotal})
+	writeFamily(w, "analytics_worker_lag", "Messages not yet read by the consumer group", "gauge", "stream", m.lag)
+	writeFamily(w, "analytics_worker_pending", "Messages read but not yet acknowledged", "gauge", "stream", m.pending)
+}
+
+// Handler serves the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WritePrometheus(w)
+	})
+}
+
+// writeSummary writes a summary without quantiles, by table.
+func writeSummary[V int64 | float64](w io.Writer, name, help string, sum map[string]V, count map[string]int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n", name, help, name)
+	keys := make([]string, 0, len(count))
+	for k := range count {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s_sum{table=%q} %v\n%s_count{table=%q} %d\n", name, k, sum[k], name, k, count[k])
+	}
+}
+
+func writeFamily[V int64 | float64](w io.Writer, name, help, typ, label string, vals map[string]V) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if label == "" {
+			fmt.Fprintf(w, "%s %v\n", name, vals[k])
+		} else {
+			fmt.Fprintf(w, "%s{%s=%q} %v\n", name, label, k, vals[k])
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// Message is a queued analytics record handed to the worker by a Source.
+type Message struct {
+	// Stream is the stream or topic the message was read from.
+	Stream string
+	// ID identifies the message within Stream.
+	ID string
+	// Table is the ClickHouse table the message is stored into.
+	Table string
+	// Data is the JSON encoded record.
+	Data []byte
+	// Deliveries is how often the message was delivered before without
+	// being stored; new messages have 0.
+	Deliveries int64
+
+	partition int
+	offset    int64
+}
+
+// Backlog is how far a consumer is behind its queue.
+type Backlog struct {
+	// Lag counts messages not yet read.
+	Lag int64
+	// Pending counts messages read but not yet acknowledged.
+	Pending int64
+}
+
+// Source is a queue the worker drains. A message stays owed to the worker
+// until acknowledged, so it is delivered again after a failed insert or a
+// crash. Each Source is drained by one goroutine; use several to consume
+// in parallel.
+type Source interface {
+	// Read waits up to block for at most max messages.
+	Read(ctx context.Context, max int, block time.Duration) ([]Message, error)
+	// Ack marks msgs as stored or dead-lettered.
+	Ack(ctx context.Context, msgs []Message) error
+	// Retry hands back msgs whose insert failed.
+	Retry(ctx context.Context, msgs []Message)
+	// Recover returns messages due for another delivery, such as those left
+	// unacknowledged by a crashed consumer.
+	Recover(ctx context.Context, max int) ([]Message, error)
+	// DeadLetter stores a dead-letter record.
+	DeadLetter(ctx context.Context, rec []byte) error
+	// Backlog reports the backlog by stream.
+	Backlog(ctx context.Context) map[string]Backlog
+	Close() error
+}
+
+// NewSources returns the sources selected by cfg.Source. rdb is only used
+// for Redis Streams.
+func NewSources(cfg Config, rdb *redis.Client) ([]Source, error) {
+	switch cfg.Source {
+	case "", "redis":
+		if rdb == nil {
+			return nil, fmt.Errorf("redis source needs a redis client")
+		}
+		return []Source{NewRedisSource(rdb, cfg)}, nil
+	case "kafka":
+		return NewKafkaSources(cfg)
+	case "file":
+		return NewFileSources(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported source %q", cfg.Source)
+	}
+}
+
+// ackLog tracks the messages of a partition in read order, so that a
+// position is committed only once everything before it is acknowledged.
+type ackLog struct {
+	read  []logEntry
+	acked map[int64]bool
+	// next is where the last message read ends.
+	next int64
+}
+
+type logEntry struct{ offset, end int64 }
+
+func newAckLog() *ackLog { return &ackLog{acked: map[int64]bool{}} }
+
+func (l *ackLog) add(offset, end int64) {
+	l.read = append(l.read, logEntry{offset, end})
+	l.next = end
+}
+
+// last returns the offset of the last message read, or -1.
+func (l *ackLog) last() int64 {
+	if len(l.read) == 0 {
+		return -1
+	}
+	return l.read[len(l.read)-1].offset
+}
+
+func (l *ackLog) ack(offset int64) { l.acked[offset] = true }
+
+// advance drops the acknowledged messages at the front and returns where
+// the last of them ends.
+func (l *ackLog) advance() (int64, bool) {
+	n := 0
+	for n < len(l.read) && l.acked[l.read[n].offset] {
+		delete(l.acked, l.read[n].offset)
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	end := l.read[n-1].end
+	l.read = l.read[n:]
+	return end, true
+}
+
+// retryQueue holds the messages of failed inserts until they are due.
+type retryQueue struct {
+	msgs  []Message
+	at    time.Time
+	delay time.Duration
+}
+
+func (q *retryQueue) push(msgs []Message) {
+	for i := range msgs {
+		msgs[i].Deliveries++
+	}
+	q.msgs = append(append([]Message(nil), msgs...), q.msgs...)
+	q.at = time.Now().Add(q.delay)
+}
+
+// pop waits up to block for the queued messages to be due and returns at
+// most max of them. ok is false if nothing is queued, in which case new
+// messages may be read.
+func (q *retryQueue) pop(ctx context.Context, max int, block time.Duration) (msgs []Message, ok bool, err error) {
+	if len(q.msgs) == 0 {
+		return nil, false, nil
+	}
+	if wait := min(time.Until(q.at), block); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	if time.Now().Before(q.at) {
+		return nil, true, nil
+	}
+	n := min(max, len(q.msgs))
+	msgs = append([]Message(nil), q.msgs[:n]...)
+	q.msgs = q.msgs[n:]
+	return msgs, true, nil
+}
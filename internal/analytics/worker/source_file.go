@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/analytics/mq"
+)
+
+// fileSource reads one stream of a file queue. The group's position is kept
+// beside the segments in <group>.offset and only moves past acknowledged
+// messages; failed messages are retried after ClaimIdle. A group must be
+// read by a single worker.
+type fileSource struct {
+	stream     string
+	table      string
+	offsetPath string
+	r          *mq.FileReader
+	dead       *mq.FileQueue
+
+	retry retryQueue
+	read  *ackLog
+}
+
+// NewFileSources returns sources reading the event and payment streams of
+// the file queue in cfg.FileDir as group cfg.Group.
+func NewFileSources(cfg Config) ([]Source, error) {
+	dead, err := mq.NewFile(cfg.FileDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out []Source
+	for _, t := range []struct{ stream, table string }{{mq.FileStreamEvents, TableEvents}, {mq.FileStreamPayments, TablePayments}} {
+		path := filepath.Join(cfg.FileDir, t.stream, cfg.Group+".offset")
+		offset, err := readOffset(path)
+		if err != nil {
+			return nil, err
+		}
+		l := newAckLog()
+		l.next = offset
+		out = append(out, &fileSource{
+			stream:     t.stream,
+			table:      t.table,
+			offsetPath: path,
+			r:          mq.OpenFileReader(cfg.FileDir, t.stream, offset),
+			dead:       dead,
+			retry:      retryQueue{delay: cfg.ClaimIdle},
+			read:       l,
+		})
+	}
+	return out, nil
+}
+
+func readOffset(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+func (s *fileSource) Read(ctx context.Context, max int, block time.Duration) ([]Message, error) {
+	// Failed messages go first; nothing new is read until they are stored.
+	if msgs, ok, err := s.retry.pop(ctx, max, block); ok {
+		return msgs, err
+	}
+	deadline := time.Now().Add(block)
+	var out []Message
+	for len(out) < max {
+		rec, off, err := s.r.Next()
+		if err == io.EOF {
+			wait := min(100*time.Millisecond, time.Until(deadline))
+			if len(out) > 0 || wait <= 0 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		s.read.add(off, s.r.Offset())
+		out = append(out, Message{Stream: s.stream, ID: strconv.FormatInt(off, 10), Table: s.table, Data: rec, offset: off})
+	}
+	return out, nil
+}
+
+// Ack saves the group's position once the messages before it are done.
+func (s *fileSource) Ack(_ context.Context, msgs []Message) error {
+	for _, m := range msgs {
+		s.read.ack(m.offset)
+	}
+	end, ok := s.read.advance()
+	if !ok {
+		return nil
+	}
+	tmp := s.offsetPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(end, 10)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.offsetPath)
+}
+
+func (s *fileSource) Retry(_ context.Context, msgs []Message) { s.retry.push(msgs) }
+
+// Recover returns nothing: failed messages are retried by Read, and after a
+// restart reading resumes at the saved position.
+func (s *fileSource) Recover(context.Context, int) ([]Message, error) { return nil, nil }
+
+func (s *fileSource) DeadLetter(_ context.Context, rec []byte) error {
+	return s.dead.Append(mq.FileStreamDead, rec)
+}
+
+// Backlog reports pending messages only; the file queue does not count
+// unread ones.
+func (s *fileSource) Backlog(context.Context) map[string]Backlog {
+	return map[string]Backlog{s.stream: {Pending: int64(len(s.read.read))}}
+}
+
+func (s *fileSource) Close() error {
+	err := s.r.Close()
+	if e := s.dead.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
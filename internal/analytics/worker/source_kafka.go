@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// kafkaSource is one member of the worker's consumer group on a topic. The
+// group assigns each member its own partitions, so members consume in
+// parallel while every partition is read in order by one of them.
+//
+// Offsets are committed only up to the first message not yet acknowledged,
+// so nothing read before an unstored message is skipped after a restart.
+// A failed batch is delivered again by the same member after ClaimIdle.
+type kafkaSource struct {
+	r      *kafka.Reader
+	commit func(context.Context, ...kafka.Message) error
+	dead   *kafka.Writer
+	topic  string
+	table  string
+	name   string
+
+	retry retryQueue
+	// read holds the offsets read but not yet committed, by partition.
+	read map[int]*ackLog
+	hwm  map[int]int64
+}
+
+// NewKafkaSources returns the members consuming cfg.TopicEvents and
+// cfg.TopicPayments as group cfg.Group: cfg.KafkaReaders per topic, or one
+// per partition when it is 0.
+func NewKafkaSources(cfg Config) ([]Source, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka source needs brokers")
+	}
+	var out []Source
+	for _, t := range []struct{ topic, table string }{{cfg.TopicEvents, TableEvents}, {cfg.TopicPayments, TablePayments}} {
+		n := cfg.KafkaReaders
+		if n <= 0 {
+			n = partitionCount(cfg.Brokers, t.topic)
+		}
+		for i := 0; i < n; i++ {
+			// CommitInterval 0 commits synchronously in CommitMessages.
+			r := kafka.NewReader(kafka.ReaderConfig{
+				Brokers: cfg.Brokers, GroupID: cfg.Group, Topic: t.topic,
+				MaxWait: 500 * time.Millisecond, StartOffset: kafka.FirstOffset,
+			})
+			out = append(out, &kafkaSource{
+				r:      r,
+				commit: r.CommitMessages,
+				dead:   &kafka.Writer{Addr: kafka.TCP(cfg.Brokers...), Topic: cfg.TopicDead, RequiredAcks: kafka.RequireOne, Balancer: &kafka.LeastBytes{}, BatchTimeout: 50 * time.Millisecond},
+				topic:  t.topic,
+				table:  t.table,
+				name:   t.topic + "#" + strconv.Itoa(i),
+				retry:  retryQueue{delay: cfg.ClaimIdle},
+				read:   map[int]*ackLog{},
+				hwm:    map[int]int64{},
+			})
+		}
+		slog.Info("kafka source", "topic", t.topic, "group", cfg.Group, "readers", n)
+	}
+	return out, nil
+}
+
+// partitionCount returns how many partitions topic has, or 1 if that
+// cannot be told.
+func partitionCount(brokers []string, topic string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := kafka.DialContext(ctx, "tcp", brokers[0])
+	if err != nil {
+		slog.Warn("kafka partitions", "topic", topic, "err", err)
+		return 1
+	}
+	defer conn.Close()
+	ps, err := conn.ReadPartitions(topic)
+	if err != nil || len(ps) == 0 {
+		slog.Warn("kafka partitions", "topic", topic, "err", err)
+		return 1
+	}
+	return len(ps)
+}
+
+func (s *kafkaSource) Read(ctx context.Context, max int, block time.Duration) ([]Message, error) {
+	// Failed messages go first; nothing new is read until they are stored.
+	if msgs, ok, err := s.retry.pop(ctx, max, block); ok {
+		return msgs, err
+	}
+	fctx, cancel := context.WithTimeout(ctx, block)
+	defer cancel()
+	var out []Message
+	for len(out) < max {
+		km, err := s.r.FetchMessage(fctx)
+		if err != nil {
+			if len(out) > 0 || (errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil) {
+				break
+			}
+			return nil, err
+		}
+		s.track(km)
+		out = append(out, Message{
+			Stream: km.Topic, ID: fmt.Sprintf("%d:%d", km.Partition, km.Offset), Table: s.table, Data: km.Value,
+			partition: km.Partition, offset: km.Offset,
+		})
+	}
+	return out, nil
+}
+
+func (s *kafkaSource) track(km kafka.Message) {
+	l := s.read[km.Partition]
+	if l == nil || km.Offset <= l.last() {
+		// New partition, or the reader went back to the committed offset
+		// after a rebalance: what was read before is delivered again.
+		l = newAckLog()
+		s.read[km.Partition] = l
+	}
+	l.add(km.Offset, km.Offset+1)
+	s.hwm[km.Partition] = km.HighWaterMark
+}
+
+// Ack commits, per partition, the offsets up to the first message that is
+// still unacknowledged.
+func (s *kafkaSource) Ack(ctx context.Context, msgs []Message) error {
+	touched := map[int]bool{}
+	for _, m := range msgs {
+		if l := s.read[m.partition]; l != nil {
+			l.ack(m.offset)
+			touched[m.partition] = true
+		}
+	}
+	var commits []kafka.Message
+	for p := range touched {
+		// CommitMessages commits the offset after the message given.
+		if end, ok := s.read[p].advance(); ok {
+			commits = append(commits, kafka.Message{Topic: s.topic, Partition: p, Offset: end - 1})
+		}
+	}
+	if len(commits) == 0 {
+		return nil
+	}
+	return s.commit(ctx, commits...)
+}
+
+func (s *kafkaSource) Retry(_ context.Context, msgs []Message) { s.retry.push(msgs) }
+
+// Recover returns nothing: failed messages are retried by Read, and those
+// of a crashed member are read again by whoever gets its partitions.
+func (s *kafkaSource) Recover(context.Context, int) ([]Message, error) { return nil, nil }
+
+func (s *kafkaSource) DeadLetter(ctx context.Context, rec []byte) error {
+	return s.dead.WriteMessages(ctx, kafka.Message{Value: rec})
+}
+
+func (s *kafkaSource) Backlog(context.Context) map[string]Backlog {
+	var b Backlog
+	for p, l := range s.read {
+		if lag := s.hwm[p] - l.next; lag > 0 {
+			b.Lag += lag
+		}
+		b.Pending += int64(len(l.read))
+	}
+	return map[string]Backlog{s.name: b}
+}
+
+func (s *kafkaSource) Close() error {
+	err := s.r.Close()
+	if e := s.dead.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package worker
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// newTestKafkaSource returns a source without a reader that records its
+// commits instead of sending them.
+func newTestKafkaSource() (*kafkaSource, *[]kafka.Message) {
+	var commits []kafka.Message
+	s := &kafkaSource{
+		commit: func(_ context.Context, msgs ...kafka.Message) error {
+			commits = append(commits, msgs...)
+			return nil
+		},
+		topic: "events",
+		table: TableEvents,
+		name:  "events#0",
+		read:  map[int]*ackLog{},
+		hwm:   map[int]int64{},
+	}
+	return s, &commits
+}
+
+// fetch tracks offsets of partition as FetchMessage would return them.
+func fetch(s *kafkaSource, partition int, offsets ...int64) []Message {
+	var out []Message
+	for _, off := range offsets {
+		s.track(kafka.Message{Topic: s.topic, Partition: partition, Offset: off, HighWaterMark: 10})
+		out = append(out, Message{Stream: s.topic, Table: s.table, partition: partition, offset: off})
+	}
+	return out
+}
+
+// committed returns the offsets of commits, which name the last message
+// committed; the group resumes after it.
+func committed(commits []kafka.Message) []int64 {
+	var out []int64
+	for _, c := range commits {
+		out = append(out, c.Offset)
+	}
+	return out
+}
+
+func TestKafkaAckCommitsInOrder(t *testing.T) {
+	ctx := context.Background()
+	s, commits := newTestKafkaSource()
+	msgs := fetch(s, 0, 0, 1, 2, 3)
+
+	if err := s.Ack(ctx, []Message{msgs[1], msgs[3]}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*commits) != 0 {
+		t.Fatalf("committed %v while offset 0 is unacknowledged", committed(*commits))
+	}
+	if err := s.Ack(ctx, msgs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := committed(*commits); !slices.Equal(got, []int64{1}) {
+		t.Fatalf("committed %v, want [1]", got)
+	}
+	if err := s.Ack(ctx, msgs[2:3]); err != nil {
+		t.Fatal(err)
+	}
+	if got := committed(*commits); !slices.Equal(got, []int64{1, 3}) {
+		t.Fatalf("committed %v, want [1 3]", got)
+	}
+	if b := s.Backlog(ctx)["events#0"]; b.Pending != 0 || b.Lag != 6 {
+		t.Fatalf("backlog = %+v, want no pending and a lag of 6", b)
+	}
+}
+
+func TestKafkaAckKeepsPartitionsApart(t *testing.T) {
+	ctx := context.Background()
+	s, commits := newTestKafkaSource()
+	p0 := fetch(s, 0, 5, 6)
+	p1 := fetch(s, 1, 7)
+
+	if err := s.Ack(ctx, []Message{p0[1], p1[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*commits) != 1 || (*commits)[0].Partition != 1 || (*commits)[0].Offset != 7 {
+		t.Fatalf("commits = %+v, want partition 1 up to offset 7", *commits)
+	}
+}
+
+func TestKafkaRetryHoldsBackNewMessages(t *testing.T) {
+	ctx := context.Background()
+	s, commits := newTestKafkaSource()
+	msgs := fetch(s, 0, 0, 1)
+
+	s.Retry(ctx, msgs[:1])
+	if err := s.Ack(ctx, msgs[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if len(*commits) != 0 {
+		t.Fatalf("committed %v past a message left for retry", committed(*commits))
+	}
+	// With no delay the retry is due at once; Read returns it before
+	// fetching anything new, so the nil reader is never used.
+	again, err := s.Read(ctx, 10, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 1 || again[0].offset != 0 || again[0].Deliveries != 1 {
+		t.Fatalf("retried %+v, want offset 0 delivered once", again)
+	}
+	if err := s.Ack(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	if got := committed(*commits); !slices.Equal(got, []int64{1}) {
+		t.Fatalf("committed %v, want [1]", got)
+	}
+}
+
+func TestKafkaRebalanceRereadsFromCommit(t *testing.T) {
+	ctx := context.Background()
+	s, commits := newTestKafkaSource()
+	old := fetch(s, 0, 0, 1, 2, 3)
+	if err := s.Ack(ctx, old[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// After a rebalance the partition is read again from the commit.
+	reread := fetch(s, 0, 1, 2)
+	// Acks of messages read before the rebalance do not commit anything.
+	if err := s.Ack(ctx, old[3:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ack(ctx, reread[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if got := committed(*commits); !slices.Equal(got, []int64{0}) {
+		t.Fatalf("committed %v, want [0]", got)
+	}
+	if err := s.Ack(ctx, reread[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := committed(*commits); !slices.Equal(got, []int64{0, 2}) {
+		t.Fatalf("committed %v, want [0 2]", got)
+	}
+}
@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// redisSource reads the event and payment streams with a consumer group.
+// Unacknowledged messages stay pending and are claimed again once idle for
+// ClaimIdle.
+type redisSource struct {
+	rdb         *redis.Client
+	cfg         Config
+	grouped     bool
+	claimCursor map[string]string
+}
+
+// NewRedisSource returns a source reading cfg.StreamEvents and
+// cfg.StreamPayments as consumer cfg.Consumer of group cfg.Group.
+func NewRedisSource(rdb *redis.Client, cfg Config) Source {
+	return &redisSource{rdb: rdb, cfg: cfg, claimCursor: map[string]string{}}
+}
+
+func (s *redisSource) streams() []string { return []string{s.cfg.StreamEvents, s.cfg.StreamPayments} }
+
+func (s *redisSource) table(stream string) string {
+	if stream == s.cfg.StreamPayments {
+		return TablePayments
+	}
+	return TableEvents
+}
+
+func (s *redisSource) ensureGroups(ctx context.Context) {
+	for _, st := range s.streams() {
+		_ = s.rdb.XGroupCreateMkStream(ctx, st, s.cfg.Group, "$").Err()
+	}
+	s.grouped = true
+}
+
+func (s *redisSource) Read(ctx context.Context, max int, block time.Duration) ([]Message, error) {
+	if !s.grouped {
+		s.ensureGroups(ctx)
+	}
+	args := &redis.XReadGroupArgs{Group: s.cfg.Group, Consumer: s.cfg.Consumer, Count: int64(max), Block: block}
+	args.Streams = append(args.Streams, s.streams()...)
+	for range s.streams() {
+		args.Streams = append(args.Streams, ">")
+	}
+	res, err := s.rdb.XReadGroup(ctx, args).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "NOGROUP") {
+			s.ensureGroups(ctx)
+		}
+		return nil, err
+	}
+	var out []Message
+	for _, str := range res {
+		out = append(out, s.messages(str.Stream, str.Messages, nil)...)
+	}
+	return out, nil
+}
+
+func (s *redisSource) messages(stream string, xs []redis.XMessage, deliveries map[string]int64) []Message {
+	out := make([]Message, len(xs))
+	for i, x := range xs {
+		out[i] = Message{Stream: stream, ID: x.ID, Table: s.table(stream), Data: []byte(fmtAny(x.Values["data"])), Deliveries: deliveries[x.ID]}
+	}
+	return out
+}
+
+func (s *redisSource) Ack(ctx context.Context, msgs []Message) error {
+	ids := map[string][]string{}
+	for _, m := range msgs {
+		ids[m.Stream] = append(ids[m.Stream], m.ID)
+	}
+	for stream, ids := range ids {
+		if err := s.rdb.XAck(ctx, stream, s.cfg.Group, ids...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retry leaves msgs pending; Recover claims them once idle.
+func (s *redisSource) Retry(context.Context, []Message) {}
+
+// Recover claims the messages pending for ClaimIdle, including those of
+// crashed consumers, walking each stream's pending list across calls.
+func (s *redisSource) Recover(ctx context.Context, max int) ([]Message, error) {
+	if !s.grouped {
+		s.ensureGroups(ctx)
+	}
+	var out []Message
+	for _, st := range s.streams() {
+		cursor := s.claimCursor[st]
+		if cursor == "" {
+			cursor = "0-0"
+		}
+		// XAUTOCLAIM does not report delivery counts; read them first.
+		pending, err := s.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: st, Group: s.cfg.Group, Idle: s.cfg.ClaimIdle, Start: cursor, End: "+", Count: int64(max),
+		}).Result()
+		if err != nil {
+			slog.Warn("xpending", "stream", st, "err", err)
+			continue
+		}
+		deliveries := make(map[string]int64, len(pending))
+		for _, p := range pending {
+			deliveries[p.ID] = p.RetryCount
+		}
+		msgs, next, err := s.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream: st, Group: s.cfg.Group, Consumer: s.cfg.Consumer, MinIdle: s.cfg.ClaimIdle, Start: cursor, Count: int64(max),
+		}).Result()
+		if err != nil {
+			slog.Warn("xautoclaim", "stream", st, "err", err)
+			continue
+		}
+		s.claimCursor[st] = next
+		for _, m := range s.messages(st, msgs, deliveries) {
+			if m.Deliveries == 0 {
+				m.Deliveries = 1
+			}
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (s *redisSource) DeadLetter(ctx context.Context, rec []byte) error {
+	return s.rdb.XAdd(ctx, &redis.XAddArgs{Stream: s.cfg.StreamDead, Values: map[string]any{"data": string(rec)}}).Err()
+}
+
+func (s *redisSource) Backlog(ctx context.Context) map[string]Backlog {
+	out := map[string]Backlog{}
+	for _, st := range s.streams() {
+		groups, err := s.rdb.XInfoGroups(ctx, st).Result()
+		if err != nil {
+			continue
+		}
+		for _, g := range groups {
+			if g.Name == s.cfg.Group {
+				out[st] = Backlog{Lag: g.Lag, Pending: g.Pending}
+			}
+		}
+	}
+	return out
+}
+
+func (s *redisSource) Close() error { return nil }
@@ -3,55 +3,151 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
 	redis "github.com/redis/go-redis/v9"
 )
 
+// Config tunes how the worker reads, batches, retries and dead-letters messages.
+type Config struct {
+	// Source is the queue drained: redis (default), kafka or file, as chosen
+	// for the publisher by ANALYTICS_MQ_TYPE.
+	Source         string
+	StreamEvents   string
+	StreamPayments string
+	// StreamDead receives messages that cannot be stored, with the reason.
+	StreamDead    string
+	Brokers       []string
+	TopicEvents   string
+	TopicPayments string
+	TopicDead     string
+	// KafkaReaders is how many group members consume each topic; 0 starts
+	// one per partition.
+	KafkaReaders int
+	// FileDir is the directory of the file queue.
+	FileDir  string
+	Group    string
+	Consumer string
+	// BatchSize and FlushInterval bound how many messages are buffered per
+	// table and for how long before they are inserted.
+	BatchSize     int
+	FlushInterval time.Duration
+	// ClaimIdle is how long a message may stay unacknowledged, e.g. by a
+	// crashed consumer or after a failed insert, before it is retried. It
+	// must exceed FlushInterval.
+	ClaimIdle time.Duration
+	// MaxDeliveries is how often a message is tried before it is dead-lettered.
+	MaxDeliveries int64
+}
+
+// ConfigFromEnv reads the configuration from the environment, using the
+// publisher's variables for the queue.
+func ConfigFromEnv() Config {
+	c := Config{
+		Source:         envOr("ANALYTICS_MQ_TYPE", "redis"),
+		StreamEvents:   envOr("ANALYTICS_REDIS_STREAM_EVENTS", "analytics:events"),
+		StreamPayments: envOr("ANALYTICS_REDIS_STREAM_PAYMENTS", "analytics:payments"),
+		Brokers:        strings.Split(envOr("KAFKA_BROKERS", "localhost:9092"), ","),
+		TopicEvents:    envOr("ANALYTICS_KAFKA_TOPIC_EVENTS", "analytics.events"),
+		TopicPayments:  envOr("ANALYTICS_KAFKA_TOPIC_PAYMENTS", "analytics.payments"),
+		FileDir:        envOr("ANALYTICS_FILE_DIR", "data/analytics/queue"),
+		Group:          envOr("WORKER_GROUP", "analytics-worker"),
+		Consumer:       envOr("WORKER_CONSUMER", ""),
+		BatchSize:      1000,
+		FlushInterval:  2 * time.Second,
+		ClaimIdle:      time.Minute,
+		MaxDeliveries:  5,
+	}
+	c.StreamDead = envOr("ANALYTICS_REDIS_STREAM_DEAD", c.StreamEvents+":dead")
+	c.TopicDead = envOr("ANALYTICS_KAFKA_TOPIC_DEAD", c.TopicEvents+".dead")
+	if c.Consumer == "" {
+		host, _ := os.Hostname()
+		c.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if n, err := strconv.Atoi(os.Getenv("WORKER_KAFKA_READERS")); err == nil && n > 0 {
+		c.KafkaReaders = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WORKER_BATCH_SIZE")); err == nil && n > 0 {
+		c.BatchSize = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("WORKER_FLUSH_INTERVAL")); err == nil && d > 0 {
+		c.FlushInterval = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("WORKER_CLAIM_IDLE")); err == nil && d > 0 {
+		c.ClaimIdle = d
+	}
+	if n, err := strconv.ParseInt(os.Getenv("WORKER_MAX_DELIVERIES"), 10, 64); err == nil && n > 0 {
+		c.MaxDeliveries = n
+	}
+	return c
+}
+
+func envOr(k, def string) string {
+	if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+		return v
+	}
+	return def
+}
+
+// message is a queued message waiting to be inserted.
+type message struct {
+	Message
+	data map[string]any
+}
+
+// Worker moves analytics messages from its sources into ClickHouse. Messages
+// are acknowledged only once stored or dead-lettered, so a crash or a failed
+// insert leaves them to be retried: delivery is at least once.
 type Worker struct {
-	rdb            *redis.Client
-	ch             clickhouse.Conn
-	streamEvents   string
-	streamPayments string
-	group          string
-	consumer       string
-	// aggregation state
+	srcs    []Source
+	rdb     *redis.Client
+	ch      clickhouse.Conn
+	sink    Sink
+	cfg     Config
+	metrics *Metrics
+
+	// aggregation state, shared by the lanes
+	aggMu          sync.Mutex
 	touchedMinutes map[string]struct{}
 	touchedDays    map[string]struct{}
 	revAgg         map[string]*revRow
 }
 
+// lane drains one source in its own goroutine.
+type lane struct {
+	src         Source
+	buf         map[string][]message // by table
+	bufSince    map[string]time.Time
+	lastRecover time.Time
+}
+
 func NewWorker() (*Worker, error) {
-	// Redis
+	cfg := ConfigFromEnv()
+	// Redis holds the online and daily-user sketches; with Kafka or files it
+	// is optional and those aggregates are skipped without it.
+	var rdb *redis.Client
 	rurl := os.Getenv("REDIS_URL")
-	if rurl == "" {
-		rurl = "redis://localhost:6379/0"
+	if rurl != "" || cfg.Source == "" || cfg.Source == "redis" {
+		if rurl == "" {
+			rurl = "redis://localhost:6379/0"
+		}
+		ropt, err := redis.ParseURL(rurl)
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		rdb = redis.NewClient(ropt)
 	}
-	ropt, err := redis.ParseURL(rurl)
+	srcs, err := NewSources(cfg, rdb)
 	if err != nil {
-		return nil, fmt.Errorf("redis: %w", err)
-	}
-	rdb := redis.NewClient(ropt)
-	se := os.Getenv("ANALYTICS_REDIS_STREAM_EVENTS")
-	if se == "" {
-		se = "analytics:events"
-	}
-	sp := os.Getenv("ANALYTICS_REDIS_STREAM_PAYMENTS")
-	if sp == "" {
-		sp = "analytics:payments"
-	}
-	grp := os.Getenv("WORKER_GROUP")
-	if grp == "" {
-		grp = "analytics-worker"
-	}
-	cons := os.Getenv("WORKER_CONSUMER")
-	if cons == "" {
-		cons = fmt.Sprintf("c-%d", time.Now().UnixNano())
+		return nil, err
 	}
 	// ClickHouse
 	dsn := os.Getenv("CLICKHOUSE_DSN")
@@ -68,64 +164,239 @@ func NewWorker() (*Worker, error) {
 	if err != nil {
 		return nil, fmt.Errorf("clickhouse: %w", err)
 	}
-	return &Worker{rdb: rdb, ch: ch, streamEvents: se, streamPayments: sp, group: grp, consumer: cons, touchedMinutes: map[string]struct{}{}, touchedDays: map[string]struct{}{}, revAgg: map[string]*revRow{}}, nil
+	return New(srcs, rdb, ch, cfg), nil
 }
 
-func (w *Worker) ensureGroups(ctx context.Context) {
-	_ = w.rdb.XGroupCreateMkStream(ctx, w.streamEvents, w.group, "$").Err()
-	_ = w.rdb.XGroupCreateMkStream(ctx, w.streamPayments, w.group, "$").Err()
+// New creates a worker draining srcs into ch. rdb keeps the sketches behind
+// the online and daily-user aggregates, which are skipped if it is nil.
+func New(srcs []Source, rdb *redis.Client, ch clickhouse.Conn, cfg Config) *Worker {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 2 * time.Second
+	}
+	if cfg.ClaimIdle <= cfg.FlushInterval {
+		cfg.ClaimIdle = 2 * cfg.FlushInterval
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = 5
+	}
+	return &Worker{
+		srcs:           srcs,
+		rdb:            rdb,
+		ch:             ch,
+		sink:           clickhouseSink{ch},
+		cfg:            cfg,
+		metrics:        newMetrics(),
+		touchedMinutes: map[string]struct{}{},
+		touchedDays:    map[string]struct{}{},
+		revAgg:         map[string]*revRow{},
+	}
 }
 
+// Metrics returns the worker metrics.
+func (w *Worker) Metrics() *Metrics { return w.metrics }
+
+// Run consumes until ctx is done, then flushes what it buffered and closes
+// the sources.
 func (w *Worker) Run(ctx context.Context) error {
-	w.ensureGroups(ctx)
-	// periodic flush
-	go func() {
-		tk := time.NewTicker(15 * time.Second)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-tk.C:
-				if err := w.flush(ctx); err != nil {
-					slog.Warn("flush", "err", err)
+	var wg sync.WaitGroup
+	for _, src := range w.srcs {
+		l := &lane{src: src, buf: map[string][]message{}, bufSince: map[string]time.Time{}}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.drain(ctx, l)
+		}()
+	}
+	t := time.NewTicker(15 * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := w.flushAggregates(ctx); err != nil {
+				slog.Warn("flush aggregates", "err", err)
+			}
+		case <-ctx.Done():
+			wg.Wait()
+			for _, src := range w.srcs {
+				if err := src.Close(); err != nil {
+					slog.Warn("close source", "err", err)
 				}
 			}
+			return nil
 		}
-	}()
-	for {
-		sel := []string{w.streamEvents, w.streamPayments}
-		res, err := w.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: w.group, Consumer: w.consumer, Streams: append([]string{}, append([]string{}, sel...)...), Count: 200, Block: 2 * time.Second}).Result()
-		if err != nil && err != redis.Nil {
-			slog.Warn("xreadgroup", "err", err)
+	}
+}
+
+func (w *Worker) drain(ctx context.Context, l *lane) {
+	// Pick up what this consumer left pending before a restart.
+	w.recover(ctx, l)
+	for ctx.Err() == nil {
+		w.read(ctx, l)
+		now := time.Now()
+		for table, msgs := range l.buf {
+			if n := len(msgs); n >= w.cfg.BatchSize || (n > 0 && now.Sub(l.bufSince[table]) >= w.cfg.FlushInterval) {
+				w.flush(ctx, l, table)
+			}
+		}
+		if now.Sub(l.lastRecover) >= w.cfg.ClaimIdle/2 {
+			w.recover(ctx, l)
+		}
+	}
+	fctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for table := range l.buf {
+		w.flush(fctx, l, table)
+	}
+}
+
+// read waits for new messages until the oldest buffered batch is due.
+func (w *Worker) read(ctx context.Context, l *lane) {
+	block := w.cfg.FlushInterval
+	room := w.cfg.BatchSize
+	for table, msgs := range l.buf {
+		if len(msgs) == 0 {
 			continue
 		}
-		for _, str := range res {
-			for _, msg := range str.Messages {
-				data := string(fmtAny(msg.Values["data"]))
-				if data == "" {
-					_ = w.rdb.XAck(ctx, str.Stream, w.group, msg.ID).Err()
-					continue
-				}
-				var m map[string]any
-				if err := json.Unmarshal([]byte(data), &m); err != nil {
-					_ = w.rdb.XAck(ctx, str.Stream, w.group, msg.ID).Err()
-					continue
-				}
-				if str.Stream == w.streamEvents {
-					// Update Redis HLL for minute online, DAU/new_users
-					w.touchAgg(ctx, m)
-					if err := w.insertEvent(ctx, m); err != nil {
-						slog.Warn("insert event", "err", err)
-					}
-				} else if str.Stream == w.streamPayments {
-					w.touchRevenue(ctx, m)
-					if err := w.insertPayment(ctx, m); err != nil {
-						slog.Warn("insert payment", "err", err)
-					}
-				}
-				_ = w.rdb.XAck(ctx, str.Stream, w.group, msg.ID).Err()
+		if d := w.cfg.FlushInterval - time.Since(l.bufSince[table]); d < block {
+			block = d
+		}
+		if r := w.cfg.BatchSize - len(msgs); r < room {
+			room = r
+		}
+	}
+	if block < 10*time.Millisecond {
+		block = 10 * time.Millisecond
+	}
+	if room < 1 {
+		room = 1
+	}
+	msgs, err := l.src.Read(ctx, room, block)
+	if err != nil && ctx.Err() == nil {
+		slog.Warn("read", "err", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+	w.add(ctx, l, msgs)
+}
+
+// add buffers msgs, dead-lettering those that cannot be decoded or were
+// delivered too often.
+func (w *Worker) add(ctx context.Context, l *lane, msgs []Message) {
+	for _, msg := range msgs {
+		w.metrics.consumed(msg.Stream)
+		if msg.Deliveries >= w.cfg.MaxDeliveries {
+			w.deadLetter(ctx, l, msg, "max_deliveries", fmt.Sprintf("not stored after %d deliveries", msg.Deliveries))
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal(msg.Data, &m); err != nil || m == nil {
+			w.deadLetter(ctx, l, msg, "malformed", fmt.Sprintf("undecodable data: %v", err))
+			continue
+		}
+		if len(l.buf[msg.Table]) == 0 {
+			l.bufSince[msg.Table] = time.Now()
+		}
+		l.buf[msg.Table] = append(l.buf[msg.Table], message{Message: msg, data: m})
+	}
+}
+
+// flush inserts the buffered messages of table and acknowledges them. Rows
+// ClickHouse refuses are dead-lettered; if the insert itself fails the
+// messages are handed back to the source for a later retry.
+func (w *Worker) flush(ctx context.Context, l *lane, table string) {
+	msgs := l.buf[table]
+	delete(l.buf, table)
+	for len(msgs) > 0 {
+		rows := make([]map[string]any, len(msgs))
+		for i, m := range msgs {
+			rows[i] = m.data
+		}
+		start := time.Now()
+		err := w.sink.Insert(ctx, table, rows)
+		var re *RowError
+		if errors.As(err, &re) {
+			w.deadLetter(ctx, l, msgs[re.Index].Message, "invalid_row", re.Err.Error())
+			msgs = append(append([]message(nil), msgs[:re.Index]...), msgs[re.Index+1:]...)
+			continue
+		}
+		if err != nil {
+			w.metrics.insertFailed(table)
+			slog.Warn("insert batch; left for retry", "table", table, "rows", len(rows), "err", err)
+			l.src.Retry(ctx, queued(msgs))
+			return
+		}
+		w.metrics.inserted(table, len(rows), time.Since(start))
+		for _, r := range rows {
+			if table == TableEvents {
+				w.touchAgg(ctx, r)
+			} else {
+				w.touchRevenue(ctx, r)
 			}
 		}
+		if err := l.src.Ack(ctx, queued(msgs)); err != nil {
+			// The rows are stored; the messages will be inserted again once retried.
+			slog.Warn("ack", "table", table, "err", err)
+		}
+		return
+	}
+}
+
+func queued(msgs []message) []Message {
+	out := make([]Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = m.Message
+	}
+	return out
+}
+
+// recover buffers the messages the source has due for another delivery
+// and refreshes the backlog metrics.
+func (w *Worker) recover(ctx context.Context, l *lane) {
+	l.lastRecover = time.Now()
+	msgs, err := l.src.Recover(ctx, w.cfg.BatchSize)
+	if err != nil {
+		slog.Warn("recover", "err", err)
+	}
+	if len(msgs) > 0 {
+		w.metrics.claimed(len(msgs))
+		slog.Info("claimed pending messages", "count", len(msgs))
+		w.add(ctx, l, msgs)
+	}
+	for stream, b := range l.src.Backlog(ctx) {
+		w.metrics.setLag(stream, b.Lag, b.Pending)
+	}
+}
+
+// deadLetter records msg in the dead-letter queue and acknowledges it. If
+// the dead-letter write fails the message is handed back for a retry.
+func (w *Worker) deadLetter(ctx context.Context, l *lane, msg Message, reason, detail string) {
+	rec := map[string]any{
+		"reason":    reason,
+		"detail":    detail,
+		"stream":    msg.Stream,
+		"id":        msg.ID,
+		"failed_at": time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	var evt any
+	if json.Unmarshal(msg.Data, &evt) == nil {
+		rec["event"] = evt
+	} else {
+		rec["event"] = string(msg.Data)
+	}
+	b, _ := json.Marshal(rec)
+	if err := l.src.DeadLetter(ctx, b); err != nil {
+		slog.Warn("dead-letter", "stream", msg.Stream, "id", msg.ID, "err", err)
+		l.src.Retry(ctx, []Message{msg})
+		return
+	}
+	w.metrics.deadLettered(reason)
+	if err := l.src.Ack(ctx, []Message{msg}); err != nil {
+		slog.Warn("ack dead letter", "stream", msg.Stream, "id", msg.ID, "err", err)
 	}
 }
 
@@ -162,231 +433,3 @@ func asFloat(m map[string]any, k string) float64 {
 	}
 	return 0
 }
-
-func (w *Worker) insertEvent(ctx context.Context, m map[string]any) error {
-	// Minimal fields; props_json stays raw
-	ts := asString(m, "ts")
-	if ts == "" {
-		ts = time.Now().Format(time.RFC3339)
-	}
-	game := asString(m, "game_id")
-	env := asString(m, "env")
-	uid := asString(m, "user_id")
-	sid := asString(m, "session_id")
-	evt := asString(m, "event")
-	channel := asString(m, "channel")
-	platform := asString(m, "platform")
-	country := asString(m, "country")
-	appv := asString(m, "app_version")
-	eid := asString(m, "event_id")
-	propsBytes, _ := json.Marshal(m["props"]) // may be nil
-	batch, err := w.ch.PrepareBatch(ctx, "INSERT INTO analytics.events (event_time, game_id, env, user_id, session_id, event, channel, platform, country, app_version, event_id, props_json)")
-	if err != nil {
-		return err
-	}
-	if err := batch.Append(ts, game, env, uid, sid, evt, channel, platform, country, appv, eid, string(propsBytes)); err != nil {
-		return err
-	}
-	return batch.Send()
-}
-
-func (w *Worker) insertPayment(ctx context.Context, m map[string]any) error {
-	ts := asString(m, "ts")
-	if ts == "" {
-		ts = time.Now().Format(time.RFC3339)
-	}
-	game := asString(m, "game_id")
-	env := asString(m, "env")
-	uid := asString(m, "user_id")
-	oid := asString(m, "order_id")
-	amount := asFloat(m, "amount_cents")
-	curr := asString(m, "currency")
-	status := asString(m, "status")
-	channel := asString(m, "channel")
-	platform := asString(m, "platform")
-	country := asString(m, "country")
-	region := asString(m, "region")
-	city := asString(m, "city")
-	product := asString(m, "product_id")
-	reason := asString(m, "reason")
-	batch, err := w.ch.PrepareBatch(ctx, "INSERT INTO analytics.payments (time, game_id, env, user_id, order_id, amount_cents, currency, status, channel, platform, country, region, city, product_id, reason)")
-	if err != nil {
-		return err
-	}
-	if err := batch.Append(ts, game, env, uid, oid, uint64(amount), curr, status, channel, platform, country, region, city, product, reason); err != nil {
-		return err
-	}
-	return batch.Send()
-}
-
-// --- Aggregation helpers ---
-
-type revRow struct {
-	revenue uint64
-	refunds uint64
-	failed  uint64
-}
-
-func (w *Worker) touchAgg(ctx context.Context, m map[string]any) {
-	ts := asString(m, "ts")
-	t, _ := time.Parse(time.RFC3339, ts)
-	if ts == "" || t.IsZero() {
-		t = time.Now()
-	}
-	game := asString(m, "game_id")
-	env := asString(m, "env")
-	uid := asString(m, "user_id")
-	evt := strings.ToLower(asString(m, "event"))
-	// minute online (heartbeat or session_start)
-	if evt == "heartbeat" || evt == "session_start" {
-		min := t.Truncate(time.Minute)
-		k := fmt.Sprintf("hll:online:%s:%s:%s", game, env, min.Format("200601021504"))
-		_ = w.rdb.PFAdd(ctx, k, uid).Err()
-		_ = w.rdb.Expire(ctx, k, 48*time.Hour).Err()
-		w.touchedMinutes[k] = struct{}{}
-	}
-	// DAU
-	if evt == "login" || evt == "session_start" {
-		day := t.Format("2006-01-02")
-		k := fmt.Sprintf("hll:dau:%s:%s:%s", game, env, day)
-		_ = w.rdb.PFAdd(ctx, k, uid).Err()
-		_ = w.rdb.Expire(ctx, k, 30*24*time.Hour).Err()
-		w.touchedDays[fmt.Sprintf("%s|%s|%s", day, game, env)] = struct{}{}
-	}
-	// new users
-	if evt == "register" || evt == "first_active" {
-		day := t.Format("2006-01-02")
-		k := fmt.Sprintf("hll:new:%s:%s:%s", game, env, day)
-		_ = w.rdb.PFAdd(ctx, k, uid).Err()
-		_ = w.rdb.Expire(ctx, k, 30*24*time.Hour).Err()
-		w.touchedDays[fmt.Sprintf("%s|%s|%s", day, game, env)] = struct{}{}
-	}
-}
-
-func (w *Worker) touchRevenue(ctx context.Context, m map[string]any) {
-	ts := asString(m, "ts")
-	t, _ := time.Parse(time.RFC3339, ts)
-	if ts == "" || t.IsZero() {
-		t = time.Now()
-	}
-	day := t.Format("2006-01-02")
-	game := asString(m, "game_id")
-	env := asString(m, "env")
-	status := strings.ToLower(asString(m, "status"))
-	amt := uint64(asFloat(m, "amount_cents"))
-	key := fmt.Sprintf("%s|%s|%s", day, game, env)
-	rv := w.revAgg[key]
-	if rv == nil {
-		rv = &revRow{}
-		w.revAgg[key] = rv
-	}
-	if status == "success" {
-		rv.revenue += amt
-	}
-	if status == "refunded" {
-		rv.refunds += amt
-	}
-	if status == "failed" {
-		rv.failed += 1
-	}
-}
-
-func (w *Worker) flush(ctx context.Context) error {
-	nowMin := time.Now().Truncate(time.Minute)
-	// flush minute_online for minutes earlier than current minute
-	for k := range w.touchedMinutes {
-		parts := strings.Split(k, ":") // hll:online:game:env:YYYYMMDDHHmm
-		if len(parts) < 5 {
-			delete(w.touchedMinutes, k)
-			continue
-		}
-		ts := parts[len(parts)-1]
-		t, err := time.Parse("200601021504", ts)
-		if err != nil || !t.Before(nowMin) {
-			continue
-		}
-		game := parts[2]
-		env := parts[3]
-		n, err := w.rdb.PFCount(ctx, k).Result()
-		if err != nil {
-			slog.Warn("pfcount", "key", k, "err", err)
-			continue
-		}
-		if n < 0 {
-			n = 0
-		}
-		batch, err := w.ch.PrepareBatch(ctx, "INSERT INTO analytics.minute_online (m, game_id, env, online)")
-		if err != nil {
-			slog.Warn("ch batch", "err", err)
-			continue
-		}
-		if err := batch.Append(t, game, env, uint32(n)); err != nil {
-			slog.Warn("batch append", "err", err)
-			continue
-		}
-		if err := batch.Send(); err != nil {
-			slog.Warn("batch send", "err", err)
-			continue
-		}
-		delete(w.touchedMinutes, k)
-	}
-	// flush daily_users
-	for dk := range w.touchedDays {
-		sp := strings.Split(dk, "|")
-		if len(sp) != 3 {
-			delete(w.touchedDays, dk)
-			continue
-		}
-		day, game, env := sp[0], sp[1], sp[2]
-		kdau := fmt.Sprintf("hll:dau:%s:%s:%s", game, env, day)
-		knew := fmt.Sprintf("hll:new:%s:%s:%s", game, env, day)
-		dau, _ := w.rdb.PFCount(ctx, kdau).Result()
-		neu, _ := w.rdb.PFCount(ctx, knew).Result()
-		d, _ := time.Parse("2006-01-02", day)
-		ver := uint64(time.Now().Unix())
-		batch, err := w.ch.PrepareBatch(ctx, "INSERT INTO analytics.daily_users (d, game_id, env, dau, new_users, version)")
-		if err != nil {
-			slog.Warn("ch daily_users", "err", err)
-			continue
-		}
-		if err := batch.Append(d, game, env, uint64(max0(dau)), uint64(max0(neu)), ver); err != nil {
-			slog.Warn("daily_users append", "err", err)
-			continue
-		}
-		if err := batch.Send(); err != nil {
-			slog.Warn("daily_users send", "err", err)
-			continue
-		}
-	}
-	// flush daily_revenue
-	for rk, rv := range w.revAgg {
-		sp := strings.Split(rk, "|")
-		if len(sp) != 3 {
-			continue
-		}
-		day, game, env := sp[0], sp[1], sp[2]
-		d, _ := time.Parse("2006-01-02", day)
-		ver := uint64(time.Now().Unix())
-		batch, err := w.ch.PrepareBatch(ctx, "INSERT INTO analytics.daily_revenue (d, game_id, env, revenue_cents, refunds_cents, failed, version)")
-		if err != nil {
-			slog.Warn("ch daily_revenue", "err", err)
-			continue
-		}
-		if err := batch.Append(d, game, env, rv.revenue, rv.refunds, rv.failed, ver); err != nil {
-			slog.Warn("daily_revenue append", "err", err)
-			continue
-		}
-		if err := batch.Send(); err != nil {
-			slog.Warn("daily_revenue send", "err", err)
-			continue
-		}
-	}
-	return nil
-}
-
-func max0(n int64) int64 {
-	if n < 0 {
-		return 0
-	}
-	return n
-}
@@ -0,0 +1,191 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// memSink stores rows in memory and fails inserts while err is set.
+type memSink struct {
+	rows []map[string]any
+	err  error
+}
+
+func (s *memSink) Insert(_ context.Context, _ string, rows []map[string]any) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.rows = append(s.rows, rows...)
+	return nil
+}
+
+// memSource records what the worker acknowledges, retries and dead-letters.
+// Ack checks that the acknowledged rows were stored first.
+type memSource struct {
+	sink    *memSink
+	acked   []Message
+	retried []Message
+	dead    [][]byte
+}
+
+func (s *memSource) Read(context.Context, int, time.Duration) ([]Message, error) { return nil, nil }
+
+func (s *memSource) Ack(_ context.Context, msgs []Message) error {
+	if s.sink != nil && len(s.sink.rows) < len(s.acked)+len(msgs) {
+		return errors.New("acknowledged before insert")
+	}
+	s.acked = append(s.acked, msgs...)
+	return nil
+}
+
+func (s *memSource) Retry(_ context.Context, msgs []Message) { s.retried = append(s.retried, msgs...) }
+
+func (s *memSource) Recover(context.Context, int) ([]Message, error) { return nil, nil }
+
+func (s *memSource) DeadLetter(_ context.Context, rec []byte) error {
+	s.dead = append(s.dead, rec)
+	return nil
+}
+
+func (s *memSource) Backlog(context.Context) map[string]Backlog { return nil }
+
+func (s *memSource) Close() error { return nil }
+
+func newTestWorker(src Source, sink Sink, cfg Config) (*Worker, *lane) {
+	w := New([]Source{src}, nil, nil, cfg)
+	w.sink = sink
+	return w, &lane{src: src, buf: map[string][]message{}, bufSince: map[string]time.Time{}}
+}
+
+func event(id string) Message {
+	return Message{Stream: "events", ID: id, Table: TableEvents, Data: []byte(`{"event":"login","user_id":"u` + id + `"}`)}
+}
+
+func TestFlushAcksOnlyStoredMessages(t *testing.T) {
+	ctx := context.Background()
+	sink := &memSink{err: errors.New("clickhouse down")}
+	src := &memSource{sink: sink}
+	w, l := newTestWorker(src, sink, Config{MaxDeliveries: 3})
+
+	w.add(ctx, l, []Message{event("1"), event("2")})
+	w.flush(ctx, l, TableEvents)
+	if len(src.acked) != 0 {
+		t.Fatalf("acknowledged %d messages after a failed insert", len(src.acked))
+	}
+	if len(src.retried) != 2 {
+		t.Fatalf("retried %d messages, want 2", len(src.retried))
+	}
+
+	sink.err = nil
+	w.add(ctx, l, src.retried)
+	w.flush(ctx, l, TableEvents)
+	if len(sink.rows) != 2 || len(src.acked) != 2 {
+		t.Fatalf("stored %d rows and acknowledged %d messages, want 2 and 2", len(sink.rows), len(src.acked))
+	}
+	if len(src.dead) != 0 {
+		t.Fatalf("dead-lettered %d messages", len(src.dead))
+	}
+}
+
+func TestDeadLetterAfterMaxDeliveries(t *testing.T) {
+	ctx := context.Background()
+	sink := &memSink{}
+	src := &memSource{}
+	w, l := newTestWorker(src, sink, Config{MaxDeliveries: 3})
+
+	tired := event("1")
+	tired.Deliveries = 3
+	malformed := event("2")
+	malformed.Data = []byte("{")
+	w.add(ctx, l, []Message{tired, malformed, event("3")})
+	if len(l.buf[TableEvents]) != 1 {
+		t.Fatalf("buffered %d messages, want 1", len(l.buf[TableEvents]))
+	}
+	if len(src.dead) != 2 || len(src.acked) != 2 {
+		t.Fatalf("dead-lettered %d and acknowledged %d messages, want 2 and 2", len(src.dead), len(src.acked))
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(src.dead[0], &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["reason"] != "max_deliveries" || rec["id"] != "1" {
+		t.Fatalf("dead letter = %v", rec)
+	}
+}
+
+func TestRedisRecoverClaimsPendingMessages(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	now := time.Now()
+	mr.SetTime(now)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	cfg := Config{
+		StreamEvents: "events", StreamPayments: "payments", StreamDead: "events:dead",
+		Group: "workers", Consumer: "c1",
+		FlushInterval: time.Second, ClaimIdle: time.Minute, MaxDeliveries: 2,
+	}
+	sink := &memSink{err: errors.New("clickhouse down")}
+	src := NewRedisSource(rdb, cfg)
+	w, l := newTestWorker(src, sink, cfg)
+
+	if msgs, err := src.Read(ctx, 10, 10*time.Millisecond); err != nil || len(msgs) != 0 {
+		t.Fatalf("read empty stream: %v, %v", msgs, err)
+	}
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "events", Values: map[string]any{"data": `{"event":"login"}`}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	w.read(ctx, l)
+	w.flush(ctx, l, TableEvents)
+	pending := func() int64 {
+		t.Helper()
+		p, err := rdb.XPending(ctx, "events", "workers").Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Count
+	}
+	if n := pending(); n != 1 {
+		t.Fatalf("pending after failed insert = %d, want 1", n)
+	}
+
+	// Not idle long enough yet.
+	w.recover(ctx, l)
+	if len(l.buf[TableEvents]) != 0 {
+		t.Fatalf("claimed a message before ClaimIdle")
+	}
+
+	// The unacknowledged message is claimed once idle for ClaimIdle.
+	mr.SetTime(now.Add(2 * time.Minute))
+	w.recover(ctx, l)
+	if got := l.buf[TableEvents]; len(got) != 1 || got[0].Deliveries != 1 {
+		t.Fatalf("claimed %+v, want one message delivered once", got)
+	}
+	w.flush(ctx, l, TableEvents)
+	if n := pending(); n != 1 {
+		t.Fatalf("pending after second failed insert = %d, want 1", n)
+	}
+
+	// After MaxDeliveries it is dead-lettered and acknowledged.
+	mr.SetTime(now.Add(4 * time.Minute))
+	w.recover(ctx, l)
+	if len(l.buf[TableEvents]) != 0 {
+		t.Fatalf("buffered a message delivered MaxDeliveries times")
+	}
+	if n := pending(); n != 0 {
+		t.Fatalf("pending after dead-letter = %d, want 0", n)
+	}
+	if n, err := rdb.XLen(ctx, "events:dead").Result(); err != nil || n != 1 {
+		t.Fatalf("dead-letter stream length = %d, %v", n, err)
+	}
+	if len(sink.rows) != 0 {
+		t.Fatalf("stored %d rows through a failing sink", len(sink.rows))
+	}
+}
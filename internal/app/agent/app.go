@@ -6,35 +6,76 @@ import (
 	agentlocal "github.com/cuihairu/croupier/internal/platform/agentlocal"
 	localv1 "github.com/cuihairu/croupier/pkg/pb/croupier/agent/local/v1"
 	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	tunnelv1 "github.com/cuihairu/croupier/pkg/pb/croupier/tunnel/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // App assembles minimal gRPC services for Agent process.
 type App struct {
+	agentID  string
 	store    *agentlocal.LocalStore
 	jobs     *jobIndex
+	function *FunctionServer
 	upstream *UpstreamClient
+	certs    *certSource
 }
 
 func New(serverAddr, agentID string) *App {
 	store := agentlocal.NewLocalStore()
+	jobs := newJobIndex()
 	return &App{
+		agentID:  agentID,
 		store:    store,
-		jobs:     newJobIndex(),
+		jobs:     jobs,
+		function: &FunctionServer{store: store, jobs: jobs},
 		upstream: NewUpstreamClient(serverAddr, agentID, store),
 	}
 }
 
 func (a *App) RegisterGRPC(s *grpc.Server) {
 	// Function service (local-forwarding implementation over protobuf)
-	functionv1.RegisterFunctionServiceServer(s, &FunctionServer{store: a.store, jobs: a.jobs})
+	functionv1.RegisterFunctionServiceServer(s, a.function)
 	// Local registration service provides RegisterLocal/Heartbeat/ListLocal
 	localv1.RegisterLocalControlServiceServer(s, agentlocal.NewServer(a.store))
 }
 
+// SetMetadata sets what the agent reports about itself when it registers.
+func (a *App) SetMetadata(m Metadata) { a.upstream.meta = m }
+
+// UseTLS makes the agent dial the server and edge over mTLS with the certificate
+// in cfg, renewing it through the server before it expires.
+func (a *App) UseTLS(cfg TLSConfig) error {
+	certs, err := newCertSource(cfg)
+	if err != nil {
+		return err
+	}
+	a.certs = certs
+	a.upstream.certs = certs
+	return nil
+}
+
+// ServerCredentials returns TLS credentials presenting the agent certificate, or
+// nil when the agent has none.
+func (a *App) ServerCredentials() credentials.TransportCredentials {
+	if a.certs == nil {
+		return nil
+	}
+	return a.certs.serverCredentials()
+}
+
 // Run starts the agent's background processes (upstream sync).
 func (a *App) Run(ctx context.Context) error {
 	return a.upstream.Start(ctx)
 }
 
+// RunTunnel keeps an outbound tunnel to the Edge at edgeAddr until ctx is done, so
+// the agent can be driven without accepting inbound connections.
+func (a *App) RunTunnel(ctx context.Context, edgeAddr, gameID, env string) error {
+	hello := &tunnelv1.Hello{AgentId: a.agentID, GameId: gameID, Env: env}
+	tc := NewTunnelClient(edgeAddr, hello, a.function)
+	tc.certs = a.certs
+	return tc.Run(ctx)
+}
+
 // FunctionServer implemented in function_server.go
@@ -2,27 +2,41 @@ package agent
 
 import (
     "context"
+    "encoding/json"
+    "io"
+    "sync"
     "time"
     agentlocal "github.com/cuihairu/croupier/internal/platform/agentlocal"
     functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
     "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
     "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/status"
 )
 
 // FunctionServer forwards protobuf calls to local game servers that expose FunctionService.
 type FunctionServer struct{
     functionv1.UnimplementedFunctionServiceServer
-    store *agentlocal.LocalStore
-    jobs  *jobIndex
+    store  *agentlocal.LocalStore
+    jobs   *jobIndex
+    routes router
 }
 
-// pickInstance returns the first available instance for a function id.
-func (s *FunctionServer) pickInstance(fid string) (addr string, ok bool) {
-    if s.store == nil || fid == "" { return "", false }
-    snap := s.store.List()
-    arr := snap[fid]
-    if len(arr) == 0 { return "", false }
-    return arr[0].Addr, true
+// instances returns a snapshot of instances serving a function id.
+func (s *FunctionServer) instances(fid string) []agentlocal.Instance {
+    if s.store == nil || fid == "" { return nil }
+    return s.store.List()[fid]
+}
+
+// pickInstance selects an instance for the request according to its route.
+func (s *FunctionServer) pickInstance(in *functionv1.InvokeRequest) (string, error) {
+    arr := s.instances(in.GetFunctionId())
+    it, err := s.routes.pick(arr, in)
+    if err != nil {
+        if len(arr) > 0 { return "", status.Error(codes.InvalidArgument, err.Error()) }
+        return "", status.Error(codes.Unavailable, err.Error())
+    }
+    return it.Addr, nil
 }
 
 func (s *FunctionServer) dial(addr string) (*grpc.ClientConn, functionv1.FunctionServiceClient, error) {
@@ -35,21 +49,56 @@ func (s *FunctionServer) dial(addr string) (*grpc.ClientConn, functionv1.Functio
 }
 
 func (s *FunctionServer) Invoke(ctx context.Context, in *functionv1.InvokeRequest) (*functionv1.InvokeResponse, error) {
-    addr, ok := s.pickInstance(in.GetFunctionId())
-    if !ok { return &functionv1.InvokeResponse{Payload: nil}, nil }
+    if routeOf(in) == RouteBroadcast { return s.broadcast(ctx, in) }
+    addr, err := s.pickInstance(in)
+    if err != nil { return nil, err }
+    return s.invokeAt(ctx, addr, in)
+}
+
+func (s *FunctionServer) invokeAt(ctx context.Context, addr string, in *functionv1.InvokeRequest) (*functionv1.InvokeResponse, error) {
+    release := s.routes.acquire(addr)
+    defer release()
     cc, cli, err := s.dial(addr)
-    if err != nil { return &functionv1.InvokeResponse{Payload: nil}, nil }
+    if err != nil { return nil, status.Error(codes.Unavailable, err.Error()) }
     defer cc.Close()
     c2, cancel := context.WithTimeout(ctx, 3*time.Second)
     defer cancel()
     return cli.Invoke(c2, in)
 }
 
+// broadcast invokes every instance of the function concurrently and aggregates
+// per-instance results as {"results":[{service_id, addr, payload, error}]}.
+func (s *FunctionServer) broadcast(ctx context.Context, in *functionv1.InvokeRequest) (*functionv1.InvokeResponse, error) {
+    arr := sortedInstances(s.instances(in.GetFunctionId()))
+    if len(arr) == 0 { return nil, status.Errorf(codes.Unavailable, "no instance for function %s", in.GetFunctionId()) }
+    results := make([]broadcastResult, len(arr))
+    var wg sync.WaitGroup
+    for i, it := range arr {
+        wg.Add(1)
+        go func(i int, it agentlocal.Instance) {
+            defer wg.Done()
+            res := broadcastResult{ServiceID: it.ServiceID, Addr: it.Addr}
+            resp, err := s.invokeAt(ctx, it.Addr, in)
+            if err != nil {
+                res.Error = err.Error()
+            } else if p := resp.GetPayload(); len(p) > 0 {
+                if json.Valid(p) { res.Payload = p } else { res.Payload, _ = json.Marshal(string(p)) }
+            }
+            results[i] = res
+        }(i, it)
+    }
+    wg.Wait()
+    payload, err := json.Marshal(map[string]any{"results": results})
+    if err != nil { return nil, status.Error(codes.Internal, err.Error()) }
+    return &functionv1.InvokeResponse{Payload: payload}, nil
+}
+
 func (s *FunctionServer) StartJob(ctx context.Context, in *functionv1.InvokeRequest) (*functionv1.StartJobResponse, error) {
-    addr, ok := s.pickInstance(in.GetFunctionId())
-    if !ok { return &functionv1.StartJobResponse{JobId: ""}, nil }
+    // jobs are owned by a single instance; broadcast falls back to lb
+    addr, err := s.pickInstance(in)
+    if err != nil { return nil, err }
     cc, cli, err := s.dial(addr)
-    if err != nil { return &functionv1.StartJobResponse{JobId: ""}, nil }
+    if err != nil { return nil, status.Error(codes.Unavailable, err.Error()) }
     defer cc.Close()
     c2, cancel := context.WithTimeout(ctx, 3*time.Second)
     defer cancel()
@@ -77,3 +126,31 @@ func (s *FunctionServer) CancelJob(ctx context.Context, in *functionv1.CancelJob
     // fallback: acknowledge cancel
     return &functionv1.StartJobResponse{JobId: in.GetJobId()}, nil
 }
+
+// StreamJob relays job events from the game server instance that owns the job.
+func (s *FunctionServer) StreamJob(in *functionv1.JobStreamRequest, stream functionv1.FunctionService_StreamJobServer) error {
+    return s.relayJob(stream.Context(), in.GetJobId(), stream.Send)
+}
+
+// relayJob streams events of a job from its owning instance to send until the job
+// finishes or the upstream stream ends.
+func (s *FunctionServer) relayJob(ctx context.Context, jobID string, send func(*functionv1.JobEvent) error) error {
+    if jobID == "" || s.jobs == nil { return status.Error(codes.InvalidArgument, "job_id required") }
+    addr, ok := s.jobs.Get(jobID)
+    if !ok { return status.Error(codes.NotFound, "job not found") }
+    cc, cli, err := s.dial(addr)
+    if err != nil { return status.Error(codes.Unavailable, err.Error()) }
+    defer cc.Close()
+    up, err := cli.StreamJob(ctx, &functionv1.JobStreamRequest{JobId: jobID})
+    if err != nil { return err }
+    for {
+        ev, err := up.Recv()
+        if err == io.EOF { return nil }
+        if err != nil { return err }
+        if err := send(ev); err != nil { return err }
+        if ev.GetType() == "done" || ev.GetType() == "error" {
+            s.jobs.Delete(jobID)
+            return nil
+        }
+    }
+}
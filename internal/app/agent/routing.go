@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cuihairu/croupier/internal/function/route"
+	agentlocal "github.com/cuihairu/croupier/internal/platform/agentlocal"
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+)
+
+// Route modes declared by descriptor semantics.route / FunctionOptions.route.
+const (
+	RouteLB        = route.LB
+	RouteBroadcast = route.Broadcast
+	RouteTargeted  = route.Targeted
+	RouteHash      = route.Hash
+)
+
+// router picks game server instances for a request. The zero value is ready to use.
+type router struct {
+	mu       sync.Mutex
+	inflight map[string]int
+	rr       uint64
+}
+
+// routeOf returns the normalized route mode carried in the request metadata.
+func routeOf(in *functionv1.InvokeRequest) string {
+	return route.Mode(in.GetMetadata()["route"])
+}
+
+// pick selects a single instance according to the request route. Broadcast
+// requests are resolved as lb here; callers fan out with instances instead.
+func (r *router) pick(arr []agentlocal.Instance, in *functionv1.InvokeRequest) (agentlocal.Instance, error) {
+	if len(arr) == 0 {
+		return agentlocal.Instance{}, fmt.Errorf("no instance for function %s", in.GetFunctionId())
+	}
+	switch routeOf(in) {
+	case RouteTargeted:
+		target := strings.TrimSpace(in.GetMetadata()["target_service_id"])
+		if target == "" {
+			return agentlocal.Instance{}, route.ErrNoTarget
+		}
+		for _, it := range arr {
+			if it.ServiceID == target {
+				return it, nil
+			}
+		}
+		return agentlocal.Instance{}, fmt.Errorf("target service %s not found for function %s", target, in.GetFunctionId())
+	case RouteHash:
+		key, err := route.HashValue(in.GetMetadata()["hash_key"], in.GetPayload())
+		if err != nil {
+			return agentlocal.Instance{}, err
+		}
+		return pickByHash(arr, key), nil
+	default:
+		return r.leastInflight(arr), nil
+	}
+}
+
+// leastInflight returns the instance with the fewest calls in flight, rotating among ties.
+func (r *router) leastInflight(arr []agentlocal.Instance) agentlocal.Instance {
+	sorted := sortedInstances(arr)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rr++
+	best := -1
+	for i := range sorted {
+		idx := (i + int(r.rr%uint64(len(sorted)))) % len(sorted)
+		if best < 0 || r.inflight[sorted[idx].Addr] < r.inflight[sorted[best].Addr] {
+			best = idx
+		}
+	}
+	return sorted[best]
+}
+
+// acquire marks a call in flight for addr and returns its release func.
+func (r *router) acquire(addr string) func() {
+	r.mu.Lock()
+	if r.inflight == nil {
+		r.inflight = map[string]int{}
+	}
+	r.inflight[addr]++
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		if r.inflight[addr]--; r.inflight[addr] <= 0 {
+			delete(r.inflight, addr)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// pickByHash routes key to the same service while that service stays registered.
+func pickByHash(arr []agentlocal.Instance, key string) agentlocal.Instance {
+	sorted := sortedInstances(arr)
+	ids := make([]string, len(sorted))
+	for i, it := range sorted {
+		ids[i] = it.ServiceID
+	}
+	return sorted[route.Pick(ids, key)]
+}
+
+// sortedInstances returns instances deduplicated by service id in a stable order.
+func sortedInstances(arr []agentlocal.Instance) []agentlocal.Instance {
+	seen := make(map[string]bool, len(arr))
+	out := make([]agentlocal.Instance, 0, len(arr))
+	for _, it := range arr {
+		if seen[it.ServiceID] {
+			continue
+		}
+		seen[it.ServiceID] = true
+		out = append(out, it)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ServiceID < out[j].ServiceID })
+	return out
+}
+
+// broadcastResult is the per-instance entry of an aggregated broadcast response.
+type broadcastResult struct {
+	ServiceID string          `json:"service_id"`
+	Addr      string          `json:"addr"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"testing"
+
+	agentlocal "github.com/cuihairu/croupier/internal/platform/agentlocal"
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func testInstances(ids ...string) []agentlocal.Instance {
+	out := make([]agentlocal.Instance, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, agentlocal.Instance{ServiceID: id, Addr: id + ":9000"})
+	}
+	return out
+}
+
+func TestRouterHashIsStable(t *testing.T) {
+	var r router
+	arr := testInstances("shard-a", "shard-b", "shard-c")
+	in := &functionv1.InvokeRequest{
+		FunctionId: "player.ban",
+		Payload:    []byte(`{"player_id":"1004"}`),
+		Metadata:   map[string]string{"route": "hash", "hash_key": "player_id"},
+	}
+	first, err := r.pick(arr, in)
+	if err != nil {
+		t.Fatalf("pick: %v", err)
+	}
+	reversed := []agentlocal.Instance{arr[2], arr[1], arr[0]}
+	for i := 0; i < 10; i++ {
+		got, _ := r.pick(reversed, in)
+		if got.ServiceID != first.ServiceID {
+			t.Fatalf("hash route moved from %s to %s", first.ServiceID, got.ServiceID)
+		}
+	}
+	// Without hash_key the payload's player_id is used.
+	def := &functionv1.InvokeRequest{FunctionId: "player.ban", Payload: in.Payload, Metadata: map[string]string{"route": "hash"}}
+	if got, _ := r.pick(arr, def); got.ServiceID != first.ServiceID {
+		t.Fatalf("default hash key routed to %s, want %s", got.ServiceID, first.ServiceID)
+	}
+	// Removing another shard must not move the key.
+	var rest []agentlocal.Instance
+	dropped := false
+	for _, it := range arr {
+		if !dropped && it.ServiceID != first.ServiceID {
+			dropped = true
+			continue
+		}
+		rest = append(rest, it)
+	}
+	if got, _ := r.pick(rest, in); got.ServiceID != first.ServiceID {
+		t.Fatalf("key moved to %s after unrelated shard left", got.ServiceID)
+	}
+}
+
+func TestRouterHashKeyMissing(t *testing.T) {
+	s := &FunctionServer{store: agentlocal.NewLocalStore()}
+	s.store.Register("shard-a", "shard-a:9000", "1.0", []string{"player.ban"})
+	for _, in := range []*functionv1.InvokeRequest{
+		{FunctionId: "player.ban", Payload: []byte(`{"player_id":"1004"}`), Metadata: map[string]string{"route": "hash", "hash_key": "account_id"}},
+		{FunctionId: "player.ban", Payload: []byte(`{"reason":"spam"}`), Metadata: map[string]string{"route": "hash"}},
+		{FunctionId: "player.ban", Payload: []byte(`not json`), Metadata: map[string]string{"route": "hash"}},
+	} {
+		if _, err := s.pickInstance(in); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("payload %s: got %v, want InvalidArgument", in.Payload, err)
+		}
+	}
+}
+
+func TestRouterTargeted(t *testing.T) {
+	var r router
+	arr := testInstances("a", "b")
+	in := &functionv1.InvokeRequest{Metadata: map[string]string{"route": "targeted", "target_service_id": "b"}}
+	got, err := r.pick(arr, in)
+	if err != nil || got.ServiceID != "b" {
+		t.Fatalf("pick targeted = %v, %v", got.ServiceID, err)
+	}
+	in.Metadata["target_service_id"] = "missing"
+	if _, err := r.pick(arr, in); err == nil {
+		t.Fatal("expected error for unknown target")
+	}
+}
+
+func TestRouterLeastInflight(t *testing.T) {
+	var r router
+	arr := testInstances("a", "b")
+	release := r.acquire("a:9000")
+	defer release()
+	for i := 0; i < 4; i++ {
+		if got := r.leastInflight(arr); got.ServiceID != "b" {
+			t.Fatalf("expected idle instance b, got %s", got.ServiceID)
+		}
+	}
+}
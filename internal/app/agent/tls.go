@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// renewRetry is the delay before retrying a failed certificate renewal.
+const renewRetry = time.Minute
+
+// TLSConfig holds the agent certificate issued by the Croupier CA and the CA
+// used to verify the server and edge.
+type TLSConfig struct {
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+	ServerName string
+	// RenewBefore renews the certificate this long before it expires; zero
+	// renews once a third of its lifetime is left.
+	RenewBefore time.Duration
+}
+
+// certSource serves the current agent certificate to every handshake, so a
+// renewed certificate is used by new connections without a restart.
+type certSource struct {
+	cfg   TLSConfig
+	creds credentials.TransportCredentials
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertSource(cfg TLSConfig) (*certSource, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" || cfg.CAFile == "" {
+		return nil, errors.New("tls: cert, key and ca files are required")
+	}
+	caPEM, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("append ca: invalid pem")
+	}
+	s := &certSource{cfg: cfg}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.creds = credentials.NewTLS(&tls.Config{
+		RootCAs:    pool,
+		ServerName: cfg.ServerName,
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			return s.cert, nil
+		},
+	})
+	return s, nil
+}
+
+// load reads the key pair from disk.
+func (s *certSource) load() error {
+	cert, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load keypair: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.cert = &cert
+	s.mu.Unlock()
+	return nil
+}
+
+// renewAt is when the current certificate is due for renewal.
+func (s *certSource) renewAt() time.Time {
+	s.mu.RLock()
+	leaf := s.cert.Leaf
+	s.mu.RUnlock()
+	before := s.cfg.RenewBefore
+	if before <= 0 {
+		before = leaf.NotAfter.Sub(leaf.NotBefore) / 3
+	}
+	return leaf.NotAfter.Add(-before)
+}
+
+// renew has the server sign a certificate for a fresh key and replaces the key
+// pair on disk. The key never leaves the agent.
+func (s *certSource) renew(ctx context.Context, cc grpc.ClientConnInterface) error {
+	s.mu.RLock()
+	agentID := s.cert.Leaf.Subject.CommonName
+	s.mu.RUnlock()
+	key, keyPEM, err := agentcert.NewKey()
+	if err != nil {
+		return err
+	}
+	csr, err := agentcert.NewCSR(key, agentID)
+	if err != nil {
+		return err
+	}
+	certPEM, err := agentcert.Renew(ctx, cc, csr)
+	if err != nil {
+		return err
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return fmt.Errorf("renewed certificate: %w", err)
+	}
+	// Replace the key first: a crash in between leaves a mismatched pair that
+	// fails loudly rather than an old key that silently keeps working.
+	if err := writeFileAtomic(s.cfg.KeyFile, keyPEM, 0o600); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.cfg.CertFile, certPEM, 0o644); err != nil {
+		return err
+	}
+	return s.load()
+}
+
+// renewLoop renews the certificate over cc whenever it is due until ctx is done.
+func (s *certSource) renewLoop(ctx context.Context, cc grpc.ClientConnInterface) {
+	for {
+		wait := time.Until(s.renewAt())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if err := s.renew(ctx, cc); err != nil {
+			slog.Error("agent certificate renewal failed", "error", err, "retry_in", renewRetry)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(renewRetry):
+			}
+			continue
+		}
+		slog.Info("agent certificate renewed", "next_renewal", s.renewAt())
+	}
+}
+
+// serverCredentials serves the current agent certificate, so the server calling
+// the agent can verify it against the Croupier CA.
+func (s *certSource) serverCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			return s.cert, nil
+		},
+	})
+}
+
+// dialCredentials returns mTLS credentials when the agent has a certificate.
+func (s *certSource) dialCredentials(target string) grpc.DialOption {
+	if s == nil {
+		slog.Warn("dialing without TLS; configure an agent certificate for production", "addr", target)
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	return grpc.WithTransportCredentials(s.creds)
+}
+
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	tunnelv1 "github.com/cuihairu/croupier/pkg/pb/croupier/tunnel/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	tunnelMinBackoff = time.Second
+	tunnelMaxBackoff = 30 * time.Second
+)
+
+// TunnelClient keeps one outbound stream to an Edge and serves the function, job and
+// list requests the Edge multiplexes over it. It lets agents in networks without
+// inbound connectivity be driven by the server.
+type TunnelClient struct {
+	edgeAddr string
+	hello    *tunnelv1.Hello
+	fn       *FunctionServer
+	certs    *certSource
+
+	sendMu sync.Mutex
+	stream tunnelv1.TunnelService_OpenClient
+
+	mu   sync.Mutex
+	jobs map[string]*tunnelv1.GetJobResultResponse
+}
+
+// NewTunnelClient creates a tunnel client that serves calls with fn.
+func NewTunnelClient(edgeAddr string, hello *tunnelv1.Hello, fn *FunctionServer) *TunnelClient {
+	return &TunnelClient{edgeAddr: edgeAddr, hello: hello, fn: fn, jobs: map[string]*tunnelv1.GetJobResultResponse{}}
+}
+
+// Run keeps the tunnel open until ctx is done, reconnecting with exponential backoff.
+func (c *TunnelClient) Run(ctx context.Context) error {
+	backoff := tunnelMinBackoff
+	for {
+		start := time.Now()
+		err := c.runOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(start) > tunnelMaxBackoff {
+			backoff = tunnelMinBackoff
+		}
+		slog.Warn("edge tunnel disconnected", "addr", c.edgeAddr, "error", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > tunnelMaxBackoff {
+			backoff = tunnelMaxBackoff
+		}
+	}
+}
+
+func (c *TunnelClient) runOnce(ctx context.Context) error {
+	cc, err := grpc.DialContext(ctx, c.edgeAddr, c.certs.dialCredentials(c.edgeAddr))
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := tunnelv1.NewTunnelServiceClient(cc).Open(sctx)
+	if err != nil {
+		return err
+	}
+	c.sendMu.Lock()
+	c.stream = stream
+	c.sendMu.Unlock()
+	if err := c.send(&tunnelv1.TunnelMessage{Type: "hello", Hello: c.hello}); err != nil {
+		return err
+	}
+	slog.Info("edge tunnel opened", "addr", c.edgeAddr, "agent_id", c.hello.GetAgentId())
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		go c.handle(sctx, msg)
+	}
+}
+
+func (c *TunnelClient) send(msg *tunnelv1.TunnelMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.stream.Send(msg)
+}
+
+func (c *TunnelClient) reply(msg *tunnelv1.TunnelMessage) {
+	if err := c.send(msg); err != nil {
+		slog.Warn("edge tunnel send failed", "type", msg.GetType(), "error", err)
+	}
+}
+
+func (c *TunnelClient) handle(ctx context.Context, msg *tunnelv1.TunnelMessage) {
+	switch msg.GetType() {
+	case "invoke":
+		in := msg.GetInvoke()
+		res := &tunnelv1.ResultFrame{RequestId: in.GetRequestId()}
+		resp, err := c.fn.Invoke(ctx, &functionv1.InvokeRequest{
+			FunctionId:     in.GetFunctionId(),
+			IdempotencyKey: in.GetIdempotencyKey(),
+			Payload:        in.GetPayload(),
+			Metadata:       in.GetMetadata(),
+		})
+		if err != nil {
+			res.Error = status.Convert(err).Message()
+		} else {
+			res.Payload = resp.GetPayload()
+		}
+		c.reply(&tunnelv1.TunnelMessage{Type: "result", Result: res})
+	case "start":
+		in := msg.GetStart()
+		res := &tunnelv1.StartJobResult{RequestId: in.GetRequestId()}
+		resp, err := c.fn.StartJob(ctx, &functionv1.InvokeRequest{
+			FunctionId:     in.GetFunctionId(),
+			IdempotencyKey: in.GetIdempotencyKey(),
+			Payload:        in.GetPayload(),
+			Metadata:       in.GetMetadata(),
+		})
+		if err != nil {
+			res.Error = status.Convert(err).Message()
+		} else {
+			res.JobId = resp.GetJobId()
+		}
+		c.reply(&tunnelv1.TunnelMessage{Type: "start_r", StartR: res})
+		if res.JobId != "" {
+			c.relayJob(ctx, res.JobId)
+		}
+	case "cancel":
+		jobID := msg.GetCancel().GetJobId()
+		if _, err := c.fn.CancelJob(ctx, &functionv1.CancelJobRequest{JobId: jobID}); err != nil {
+			slog.Warn("cancel job over tunnel failed", "job_id", jobID, "error", err)
+		}
+		c.setJob(jobID, &tunnelv1.GetJobResultResponse{State: "cancelled"})
+	case "list_req":
+		in := msg.GetListReq()
+		res := &tunnelv1.ListLocalResponse{RequestId: in.GetRequestId(), FunctionId: in.GetFunctionId()}
+		for _, it := range sortedInstances(c.fn.instances(in.GetFunctionId())) {
+			res.ServiceIds = append(res.ServiceIds, it.ServiceID)
+		}
+		c.reply(&tunnelv1.TunnelMessage{Type: "list_res", ListRes: res})
+	case "job_res_req":
+		in := msg.GetJobResReq()
+		res := &tunnelv1.GetJobResultResponse{RequestId: in.GetRequestId(), State: "unknown"}
+		c.mu.Lock()
+		if js := c.jobs[in.GetJobId()]; js != nil {
+			res.State, res.Payload, res.Error = js.GetState(), js.GetPayload(), js.GetError()
+		}
+		c.mu.Unlock()
+		c.reply(&tunnelv1.TunnelMessage{Type: "job_res_res", JobResRes: res})
+	}
+}
+
+// relayJob forwards the events of a job started over the tunnel back to the Edge.
+func (c *TunnelClient) relayJob(ctx context.Context, jobID string) {
+	c.setJob(jobID, &tunnelv1.GetJobResultResponse{State: "running"})
+	err := c.fn.relayJob(ctx, jobID, func(ev *functionv1.JobEvent) error {
+		switch ev.GetType() {
+		case "done":
+			c.setJob(jobID, &tunnelv1.GetJobResultResponse{State: "succeeded", Payload: ev.GetPayload()})
+		case "error":
+			c.setJob(jobID, &tunnelv1.GetJobResultResponse{State: "failed", Payload: ev.GetPayload(), Error: ev.GetMessage()})
+		}
+		return c.send(&tunnelv1.TunnelMessage{Type: "job_evt", JobEvt: &tunnelv1.JobEventFrame{
+			JobId:    jobID,
+			Type:     ev.GetType(),
+			Message:  ev.GetMessage(),
+			Progress: ev.GetProgress(),
+			Payload:  ev.GetPayload(),
+		}})
+	})
+	if err != nil && ctx.Err() == nil {
+		msg := status.Convert(err).Message()
+		c.setJob(jobID, &tunnelv1.GetJobResultResponse{State: "failed", Error: msg})
+		c.reply(&tunnelv1.TunnelMessage{Type: "job_evt", JobEvt: &tunnelv1.JobEventFrame{JobId: jobID, Type: "error", Message: msg}})
+	}
+}
+
+func (c *TunnelClient) setJob(jobID string, res *tunnelv1.GetJobResultResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if prev := c.jobs[jobID]; prev != nil && prev.GetState() == "cancelled" {
+		return
+	}
+	c.jobs[jobID] = res
+}
@@ -4,21 +4,50 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	agentlocal "github.com/cuihairu/croupier/internal/platform/agentlocal"
 	serverv1 "github.com/cuihairu/croupier/pkg/pb/croupier/server/v1"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	maxHeartbeatInterval = 30 * time.Second
+	minHeartbeatInterval = time.Second
+	registerMinBackoff   = time.Second
+	registerMaxBackoff   = 30 * time.Second
+)
+
+// Metadata describes the agent to the server when it registers.
+type Metadata struct {
+	GameID  string
+	Env     string
+	RPCAddr string
+	// EdgeAddr is the edge the agent keeps a tunnel to when it accepts no
+	// inbound calls; the server calls the agent through it.
+	EdgeAddr string
+	Version  string
+	Region   string
+	Zone     string
+	Labels   map[string]string
+}
+
 // UpstreamClient manages the connection to the central Croupier Server.
 type UpstreamClient struct {
 	serverAddr string
 	agentID    string
+	meta       Metadata
 	store      *agentlocal.LocalStore
 	client     serverv1.ControlServiceClient
 	conn       *grpc.ClientConn
+	certs      *certSource
+
+	mu        sync.Mutex
+	sessionID string
+	expireAt  time.Time
 }
 
 // NewUpstreamClient creates a new upstream client.
@@ -30,7 +59,9 @@ func NewUpstreamClient(serverAddr, agentID string, store *agentlocal.LocalStore)
 	}
 }
 
-// Start begins the upstream synchronization process.
+// Start begins the upstream synchronization process. The agent keeps registering
+// with backoff until the server accepts it, and registers again whenever the
+// server drops its session, e.g. after a restart or a long disconnect.
 func (c *UpstreamClient) Start(ctx context.Context) error {
 	if c.serverAddr == "" {
 		slog.Info("upstream server address not configured, skipping upstream connection")
@@ -38,51 +69,116 @@ func (c *UpstreamClient) Start(ctx context.Context) error {
 	}
 
 	slog.Info("connecting to upstream server", "addr", c.serverAddr)
-	conn, err := grpc.Dial(c.serverAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-		grpc.WithTimeout(5*time.Second),
-	)
+	conn, err := grpc.Dial(c.serverAddr, c.certs.dialCredentials(c.serverAddr))
 	if err != nil {
 		return fmt.Errorf("failed to connect to upstream server: %w", err)
 	}
 	c.conn = conn
 	c.client = serverv1.NewControlServiceClient(conn)
 
-	// Initial sync
-	if err := c.sync(ctx); err != nil {
-		slog.Error("initial sync failed", "error", err)
-	}
-
 	// Register update callback
 	c.store.OnUpdate(func() {
-		// Debounce updates slightly? For now, just sync.
+		// Before the first registration the session loop will send the functions.
+		if c.session() == "" {
+			return
+		}
 		// Use a detached context or the background context since the callback might be async
 		if err := c.sync(context.Background()); err != nil {
 			slog.Error("sync failed", "error", err)
 		}
 	})
 
-	// Heartbeat loop
-	go c.heartbeatLoop(ctx)
+	go c.sessionLoop(ctx)
+
+	if c.certs != nil {
+		go c.certs.renewLoop(ctx, conn)
+	}
 
 	return nil
 }
 
-func (c *UpstreamClient) heartbeatLoop(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// sessionLoop registers and heartbeats until ctx is done.
+func (c *UpstreamClient) sessionLoop(ctx context.Context) {
+	for c.register(ctx) {
+		c.heartbeatLoop(ctx)
+	}
+}
 
+// register syncs with the server, backing off between failures. It reports
+// false once ctx is done.
+func (c *UpstreamClient) register(ctx context.Context) bool {
+	backoff := registerMinBackoff
+	for {
+		err := c.sync(ctx)
+		if err == nil {
+			return true
+		}
+		slog.Warn("upstream registration failed", "addr", c.serverAddr, "error", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > registerMaxBackoff {
+			backoff = registerMaxBackoff
+		}
+	}
+}
+
+// heartbeatLoop keeps the session alive and returns once it is lost.
+func (c *UpstreamClient) heartbeatLoop(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			if _, err := c.client.Heartbeat(ctx, &serverv1.HeartbeatRequest{AgentId: c.agentID}); err != nil {
-				slog.Error("heartbeat failed", "error", err)
+		case <-time.After(c.heartbeatInterval()):
+		}
+		sessionID := c.session()
+		resp, err := c.client.Heartbeat(ctx, &serverv1.HeartbeatRequest{AgentId: c.agentID, SessionId: sessionID})
+		if err == nil {
+			c.mu.Lock()
+			c.expireAt = time.Unix(resp.GetExpireAt(), 0)
+			c.mu.Unlock()
+			continue
+		}
+		if status.Code(err) == codes.NotFound {
+			if c.session() != sessionID {
+				// A sync registered a new session meanwhile.
+				continue
 			}
+			slog.Warn("upstream session lost, registering again", "agent_id", c.agentID)
+			return
 		}
+		slog.Error("heartbeat failed", "error", err)
+		c.mu.Lock()
+		expired := time.Now().After(c.expireAt)
+		c.mu.Unlock()
+		if expired {
+			// The server has evicted us by now; register once it is reachable.
+			return
+		}
+	}
+}
+
+// heartbeatInterval spaces heartbeats to a third of the remaining session
+// lifetime so that two may be lost before the server evicts the agent.
+func (c *UpstreamClient) heartbeatInterval() time.Duration {
+	c.mu.Lock()
+	d := time.Until(c.expireAt) / 3
+	c.mu.Unlock()
+	if d > maxHeartbeatInterval {
+		return maxHeartbeatInterval
+	}
+	if d < minHeartbeatInterval {
+		return minHeartbeatInterval
 	}
+	return d
+}
+
+func (c *UpstreamClient) session() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
 }
 
 func (c *UpstreamClient) sync(ctx context.Context) error {
@@ -101,14 +197,25 @@ func (c *UpstreamClient) sync(ctx context.Context) error {
 	req := &serverv1.RegisterRequest{
 		AgentId:   c.agentID,
 		Functions: funcs,
-		// TODO: Populate other fields like GameID, Env from config if available
+		GameId:    c.meta.GameID,
+		Env:       c.meta.Env,
+		RpcAddr:   c.meta.RPCAddr,
+		EdgeAddr:  c.meta.EdgeAddr,
+		Version:   c.meta.Version,
+		Region:    c.meta.Region,
+		Zone:      c.meta.Zone,
+		Labels:    c.meta.Labels,
 	}
 
-	_, err := c.client.Register(ctx, req)
+	resp, err := c.client.Register(ctx, req)
 	if err != nil {
 		return err
 	}
-	slog.Info("synced with upstream server", "functions", len(funcs))
+	c.mu.Lock()
+	c.sessionID = resp.GetSessionId()
+	c.expireAt = time.Unix(resp.GetExpireAt(), 0)
+	c.mu.Unlock()
+	slog.Info("synced with upstream server", "functions", len(funcs), "session_id", resp.GetSessionId())
 	return nil
 }
 
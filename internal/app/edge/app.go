@@ -10,32 +10,39 @@ import (
     "google.golang.org/grpc"
 )
 
-// App assembles gRPC services for Edge process. For now, tunnel/function/job are stubs.
+// App assembles gRPC services for Edge process. Agents dial out to the edge over
+// TunnelService; function and job calls are forwarded through those tunnels.
 type App struct {
     ctrl *ctrl.Server
+    hub  *Hub
+    requireIdentity bool
 }
 
 func New(registry *reg.Store) *App {
     if registry == nil { registry = reg.NewStore() }
-    return &App{ctrl: ctrl.NewServer(registry)}
+    return &App{ctrl: ctrl.NewServer(registry), hub: NewHub()}
+}
+
+// RequireAgentIdentity binds agent registrations and tunnels to the verified client
+// certificate, and keeps agents from calling the function and job services that
+// drive the other agents. The gRPC server must be running with mTLS.
+func (a *App) RequireAgentIdentity() {
+    a.requireIdentity = true
+    a.ctrl.RequireAgentIdentity()
 }
 
 // RegisterGRPC registers gRPC services on the given server.
 func (a *App) RegisterGRPC(s *grpc.Server) {
     serverv1.RegisterControlServiceServer(s, a.ctrl)
-    tunnelv1.RegisterTunnelServiceServer(s, &TunnelServer{})
-    functionv1.RegisterFunctionServiceServer(s, &FunctionServer{})
-    jobv1.RegisterJobServiceServer(s, &JobServer{})
+    tunnelv1.RegisterTunnelServiceServer(s, &TunnelServer{hub: a.hub, requireIdentity: a.requireIdentity})
+    functionv1.RegisterFunctionServiceServer(s, &FunctionServer{hub: a.hub, requireIdentity: a.requireIdentity})
+    jobv1.RegisterJobServiceServer(s, &JobServer{hub: a.hub, requireIdentity: a.requireIdentity})
 }
 
-// MetricsMap exposes aggregated metrics (placeholder for now).
-func (a *App) MetricsMap() map[string]any { return map[string]any{} }
-
-// TunnelServer is a stub implementation.
-type TunnelServer struct{ tunnelv1.UnimplementedTunnelServiceServer }
+// Hub returns the tunnel hub shared by the edge services.
+func (a *App) Hub() *Hub { return a.hub }
 
-// FunctionServer is a stub implementation.
-type FunctionServer struct{ functionv1.UnimplementedFunctionServiceServer }
-
-// JobServer is a stub implementation.
-type JobServer struct{ jobv1.UnimplementedJobServiceServer }
+// MetricsMap exposes aggregated metrics.
+func (a *App) MetricsMap() map[string]any {
+    return map[string]any{"tunnels": len(a.hub.Agents())}
+}
@@ -0,0 +1,212 @@
+package edge
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	jobv1 "github.com/cuihairu/croupier/pkg/pb/croupier/edge/job/v1"
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	tunnelv1 "github.com/cuihairu/croupier/pkg/pb/croupier/tunnel/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FunctionServer forwards FunctionService calls over the tunnel of the agent that
+// serves the request's game/env (or metadata agent_id when set).
+type FunctionServer struct {
+	functionv1.UnimplementedFunctionServiceServer
+	hub *Hub
+	// requireIdentity refuses callers presenting an agent certificate.
+	requireIdentity bool
+}
+
+// checkCaller lets through the server and other callers with a verified client
+// certificate that is not issued to an agent.
+func checkCaller(ctx context.Context, requireIdentity bool) error {
+	if !requireIdentity {
+		return nil
+	}
+	cert, err := agentcert.PeerCertificate(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if id, err := agentcert.FromCertificate(cert); err == nil {
+		return status.Errorf(codes.PermissionDenied, "agent %s may not call other agents through the edge", id.AgentID)
+	}
+	return nil
+}
+
+func tunnelError(err error) error {
+	switch {
+	case errors.Is(err, ErrNoTunnel):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, ErrTunnelClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return err
+	}
+}
+
+func (s *FunctionServer) pick(in *functionv1.InvokeRequest) (string, *agentTunnel, error) {
+	md := in.GetMetadata()
+	return s.hub.pick(md["agent_id"], md["game_id"], md["env"])
+}
+
+func (s *FunctionServer) Invoke(ctx context.Context, in *functionv1.InvokeRequest) (*functionv1.InvokeResponse, error) {
+	if err := checkCaller(ctx, s.requireIdentity); err != nil {
+		return nil, err
+	}
+	_, t, err := s.pick(in)
+	if err != nil {
+		return nil, tunnelError(err)
+	}
+	reqID := s.hub.nextID()
+	reply, err := t.call(ctx, reqID, &tunnelv1.TunnelMessage{Type: MsgInvoke, Invoke: &tunnelv1.InvokeFrame{
+		RequestId:      reqID,
+		FunctionId:     in.GetFunctionId(),
+		IdempotencyKey: in.GetIdempotencyKey(),
+		Payload:        in.GetPayload(),
+		Metadata:       in.GetMetadata(),
+	}})
+	if err != nil {
+		return nil, tunnelError(err)
+	}
+	if msg := reply.GetResult().GetError(); msg != "" {
+		return nil, status.Error(codes.Internal, msg)
+	}
+	return &functionv1.InvokeResponse{Payload: reply.GetResult().GetPayload()}, nil
+}
+
+func (s *FunctionServer) StartJob(ctx context.Context, in *functionv1.InvokeRequest) (*functionv1.StartJobResponse, error) {
+	if err := checkCaller(ctx, s.requireIdentity); err != nil {
+		return nil, err
+	}
+	agentID, t, err := s.pick(in)
+	if err != nil {
+		return nil, tunnelError(err)
+	}
+	reqID := s.hub.nextID()
+	reply, err := t.call(ctx, reqID, &tunnelv1.TunnelMessage{Type: MsgStart, Start: &tunnelv1.StartJobFrame{
+		RequestId:      reqID,
+		FunctionId:     in.GetFunctionId(),
+		IdempotencyKey: in.GetIdempotencyKey(),
+		Payload:        in.GetPayload(),
+		Metadata:       in.GetMetadata(),
+	}})
+	if err != nil {
+		return nil, tunnelError(err)
+	}
+	res := reply.GetStartR()
+	if res.GetError() != "" {
+		return nil, status.Error(codes.Internal, res.GetError())
+	}
+	if res.GetJobId() == "" {
+		return nil, status.Error(codes.Internal, "agent returned empty job id")
+	}
+	s.hub.trackJob(res.GetJobId(), agentID)
+	return &functionv1.StartJobResponse{JobId: res.GetJobId()}, nil
+}
+
+func (s *FunctionServer) StreamJob(in *functionv1.JobStreamRequest, stream functionv1.FunctionService_StreamJobServer) error {
+	if err := checkCaller(stream.Context(), s.requireIdentity); err != nil {
+		return err
+	}
+	ch, cancel, ok := s.hub.subscribe(in.GetJobId())
+	if !ok {
+		return status.Error(codes.NotFound, "job not found")
+	}
+	defer cancel()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(&functionv1.JobEvent{
+				Type:     ev.GetType(),
+				Message:  ev.GetMessage(),
+				Progress: ev.GetProgress(),
+				Payload:  ev.GetPayload(),
+			}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *FunctionServer) CancelJob(ctx context.Context, in *functionv1.CancelJobRequest) (*functionv1.StartJobResponse, error) {
+	if err := checkCaller(ctx, s.requireIdentity); err != nil {
+		return nil, err
+	}
+	agentID, ok := s.hub.jobOwner(in.GetJobId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "job not found")
+	}
+	t := s.hub.tunnel(agentID)
+	if t == nil {
+		return nil, tunnelError(ErrNoTunnel)
+	}
+	if err := t.send(&tunnelv1.TunnelMessage{Type: MsgCancel, Cancel: &tunnelv1.CancelJobFrame{JobId: in.GetJobId()}}); err != nil {
+		return nil, tunnelError(err)
+	}
+	s.hub.markCancelled(in.GetJobId())
+	return &functionv1.StartJobResponse{JobId: in.GetJobId()}, nil
+}
+
+// JobServer answers job result queries from the hub cache, asking the owning agent
+// over its tunnel while the job is still running.
+type JobServer struct {
+	jobv1.UnimplementedJobServiceServer
+	hub *Hub
+	// requireIdentity refuses callers presenting an agent certificate.
+	requireIdentity bool
+}
+
+func (s *JobServer) GetJobResult(ctx context.Context, in *jobv1.GetJobResultRequest) (*jobv1.GetJobResultResponse, error) {
+	if err := checkCaller(ctx, s.requireIdentity); err != nil {
+		return nil, err
+	}
+	state, payload, errMsg, done := s.hub.jobResult(in.GetJobId())
+	if done {
+		return &jobv1.GetJobResultResponse{State: state, Payload: payload, Error: errMsg}, nil
+	}
+	agentID, ok := s.hub.jobOwner(in.GetJobId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "job not found")
+	}
+	t := s.hub.tunnel(agentID)
+	if t == nil {
+		return &jobv1.GetJobResultResponse{State: state}, nil
+	}
+	reqID := s.hub.nextID()
+	reply, err := t.call(ctx, reqID, &tunnelv1.TunnelMessage{Type: MsgJobResReq, JobResReq: &tunnelv1.GetJobResultRequest{RequestId: reqID, JobId: in.GetJobId()}})
+	if err != nil {
+		return nil, tunnelError(err)
+	}
+	res := reply.GetJobResRes()
+	return &jobv1.GetJobResultResponse{State: res.GetState(), Payload: res.GetPayload(), Error: res.GetError()}, nil
+}
+
+// ListLocal asks an agent which local services expose a function.
+func (h *Hub) ListLocal(ctx context.Context, agentID, functionID string) ([]string, error) {
+	t := h.tunnel(agentID)
+	if t == nil {
+		return nil, ErrNoTunnel
+	}
+	reqID := h.nextID()
+	reply, err := t.call(ctx, reqID, &tunnelv1.TunnelMessage{Type: MsgListReq, ListReq: &tunnelv1.ListLocalRequest{RequestId: reqID, FunctionId: functionID}})
+	if err != nil {
+		return nil, err
+	}
+	if msg := reply.GetListRes().GetError(); msg != "" {
+		return nil, errors.New(msg)
+	}
+	return reply.GetListRes().GetServiceIds(), nil
+}
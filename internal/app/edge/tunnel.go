@@ -0,0 +1,397 @@
+package edge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	tunnelv1 "github.com/cuihairu/croupier/pkg/pb/croupier/tunnel/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Tunnel message types carried in TunnelMessage.type.
+const (
+	MsgHello     = "hello"
+	MsgInvoke    = "invoke"
+	MsgResult    = "result"
+	MsgStart     = "start"
+	MsgStartR    = "start_r"
+	MsgCancel    = "cancel"
+	MsgJobEvent  = "job_evt"
+	MsgListReq   = "list_req"
+	MsgListRes   = "list_res"
+	MsgJobResReq = "job_res_req"
+	MsgJobResRes = "job_res_res"
+)
+
+var (
+	ErrNoTunnel     = errors.New("no agent tunnel available")
+	ErrTunnelClosed = errors.New("agent tunnel closed")
+)
+
+// jobEventBuffer bounds the per-job event history replayed to late subscribers.
+const jobEventBuffer = 256
+
+// jobRetention is how long a finished job stays in the hub so that its result can
+// still be fetched and its events replayed.
+var jobRetention = 10 * time.Minute
+
+// agentTunnel is one open agent stream. Sends are serialized because grpc streams
+// do not allow concurrent Send calls.
+type agentTunnel struct {
+	hello  *tunnelv1.Hello
+	stream tunnelv1.TunnelService_OpenServer
+	sendMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *tunnelv1.TunnelMessage
+	closed  bool
+}
+
+func (t *agentTunnel) send(msg *tunnelv1.TunnelMessage) error {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	return t.stream.Send(msg)
+}
+
+// call sends msg and waits for the reply carrying the same request id.
+func (t *agentTunnel) call(ctx context.Context, reqID string, msg *tunnelv1.TunnelMessage) (*tunnelv1.TunnelMessage, error) {
+	ch := make(chan *tunnelv1.TunnelMessage, 1)
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, ErrTunnelClosed
+	}
+	t.pending[reqID] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, reqID)
+		t.mu.Unlock()
+	}()
+	if err := t.send(msg); err != nil {
+		return nil, err
+	}
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, ErrTunnelClosed
+		}
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *agentTunnel) resolve(reqID string, msg *tunnelv1.TunnelMessage) {
+	t.mu.Lock()
+	ch := t.pending[reqID]
+	t.mu.Unlock()
+	if ch != nil {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+func (t *agentTunnel) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	for id, ch := range t.pending {
+		close(ch)
+		delete(t.pending, id)
+	}
+}
+
+// jobState tracks the owner and event history of a job started through a tunnel.
+type jobState struct {
+	agentID string
+	events  []*tunnelv1.JobEventFrame
+	subs    map[chan *tunnelv1.JobEventFrame]struct{}
+	state   string
+	payload []byte
+	err     string
+	done    bool
+}
+
+func newJobState(agentID string) *jobState {
+	return &jobState{agentID: agentID, state: "running", subs: map[chan *tunnelv1.JobEventFrame]struct{}{}}
+}
+
+// Hub keeps open agent tunnels and correlates requests, replies and job events.
+type Hub struct {
+	mu      sync.RWMutex
+	tunnels map[string]*agentTunnel
+	jobs    map[string]*jobState
+	seq     uint64
+	rr      uint64
+}
+
+// NewHub creates an empty tunnel hub.
+func NewHub() *Hub {
+	return &Hub{tunnels: map[string]*agentTunnel{}, jobs: map[string]*jobState{}}
+}
+
+func (h *Hub) nextID() string {
+	return fmt.Sprintf("r-%d-%d", time.Now().UnixNano(), atomic.AddUint64(&h.seq, 1))
+}
+
+// Agents returns the ids of agents with an open tunnel.
+func (h *Hub) Agents() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]string, 0, len(h.tunnels))
+	for id := range h.tunnels {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// pick returns the tunnel of agentID when given, otherwise rotates among tunnels
+// matching game/env. Agents announcing no game or env match any.
+func (h *Hub) pick(agentID, gameID, env string) (string, *agentTunnel, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if agentID != "" {
+		if t := h.tunnels[agentID]; t != nil {
+			return agentID, t, nil
+		}
+		return "", nil, ErrNoTunnel
+	}
+	ids := make([]string, 0, len(h.tunnels))
+	for id, t := range h.tunnels {
+		hg, he := t.hello.GetGameId(), t.hello.GetEnv()
+		if gameID != "" && hg != "" && hg != gameID {
+			continue
+		}
+		if env != "" && he != "" && he != env {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return "", nil, ErrNoTunnel
+	}
+	sort.Strings(ids)
+	id := ids[atomic.AddUint64(&h.rr, 1)%uint64(len(ids))]
+	return id, h.tunnels[id], nil
+}
+
+func (h *Hub) tunnel(agentID string) *agentTunnel {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.tunnels[agentID]
+}
+
+func (h *Hub) register(agentID string, t *agentTunnel) {
+	h.mu.Lock()
+	prev := h.tunnels[agentID]
+	h.tunnels[agentID] = t
+	h.mu.Unlock()
+	if prev != nil {
+		prev.close()
+	}
+}
+
+// unregister removes the tunnel of agentID unless another one replaced it; jobs
+// still running over it then fail, since their events can no longer arrive.
+func (h *Hub) unregister(agentID string, t *agentTunnel) {
+	h.mu.Lock()
+	if h.tunnels[agentID] == t {
+		delete(h.tunnels, agentID)
+		for id, js := range h.jobs {
+			if js.agentID == agentID && !js.done {
+				h.publishLocked(&tunnelv1.JobEventFrame{JobId: id, Type: "error", Message: ErrTunnelClosed.Error()})
+			}
+		}
+	}
+	h.mu.Unlock()
+	t.close()
+}
+
+func (h *Hub) trackJob(jobID, agentID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.jobs[jobID]; !ok {
+		h.jobs[jobID] = newJobState(agentID)
+	}
+}
+
+func (h *Hub) jobOwner(jobID string) (string, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	js := h.jobs[jobID]
+	if js == nil {
+		return "", false
+	}
+	return js.agentID, true
+}
+
+// publish records a job event and fans it out to subscribers.
+func (h *Hub) publish(agentID string, ev *tunnelv1.JobEventFrame) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.jobs[ev.GetJobId()] == nil {
+		h.jobs[ev.GetJobId()] = newJobState(agentID)
+	}
+	h.publishLocked(ev)
+}
+
+// publishLocked applies ev to its tracked job; callers hold h.mu. Subscriber
+// channels keep their last slot for the terminal frame, so a slow subscriber may
+// miss progress but always learns how the job ended. Finished jobs are dropped
+// after jobRetention.
+func (h *Hub) publishLocked(ev *tunnelv1.JobEventFrame) {
+	js := h.jobs[ev.GetJobId()]
+	if js == nil || js.done {
+		return
+	}
+	if len(js.events) < jobEventBuffer {
+		js.events = append(js.events, ev)
+	}
+	switch ev.GetType() {
+	case "done":
+		js.state, js.payload, js.done = "succeeded", ev.GetPayload(), true
+	case "error":
+		js.state, js.payload, js.err, js.done = "failed", ev.GetPayload(), ev.GetMessage(), true
+	}
+	for ch := range js.subs {
+		if js.done {
+			ch <- ev
+			close(ch)
+			delete(js.subs, ch)
+			continue
+		}
+		if len(ch) < cap(ch)-1 {
+			ch <- ev
+		}
+	}
+	if js.done {
+		jobID := ev.GetJobId()
+		time.AfterFunc(jobRetention, func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if h.jobs[jobID] == js {
+				delete(h.jobs, jobID)
+			}
+		})
+	}
+}
+
+// subscribe replays the recorded events of a job and streams new ones; the channel
+// is closed once the job finishes.
+func (h *Hub) subscribe(jobID string) (<-chan *tunnelv1.JobEventFrame, func(), bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	js := h.jobs[jobID]
+	if js == nil {
+		return nil, nil, false
+	}
+	// One slot beyond the buffer is kept for the terminal frame.
+	ch := make(chan *tunnelv1.JobEventFrame, jobEventBuffer+len(js.events)+1)
+	for _, ev := range js.events {
+		ch <- ev
+	}
+	if js.done {
+		close(ch)
+		return ch, func() {}, true
+	}
+	js.subs[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := js.subs[ch]; ok {
+			delete(js.subs, ch)
+			close(ch)
+		}
+	}, true
+}
+
+// jobResult returns the cached outcome of a finished job.
+func (h *Hub) jobResult(jobID string) (state string, payload []byte, errMsg string, done bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	js := h.jobs[jobID]
+	if js == nil {
+		return "", nil, "", false
+	}
+	return js.state, js.payload, js.err, js.done
+}
+
+func (h *Hub) markCancelled(jobID string) {
+	h.publish("", &tunnelv1.JobEventFrame{JobId: jobID, Type: "error", Message: "cancelled"})
+	h.mu.Lock()
+	if js := h.jobs[jobID]; js != nil {
+		js.state = "cancelled"
+	}
+	h.mu.Unlock()
+}
+
+// TunnelServer accepts agent-initiated tunnels. The first message must be a hello.
+type TunnelServer struct {
+	tunnelv1.UnimplementedTunnelServiceServer
+	hub *Hub
+	// requireIdentity rejects hellos not matching the agent certificate.
+	requireIdentity bool
+}
+
+func (s *TunnelServer) Open(stream tunnelv1.TunnelService_OpenServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	hello := first.GetHello()
+	if first.GetType() != MsgHello || hello == nil || strings.TrimSpace(hello.GetAgentId()) == "" {
+		return status.Error(codes.InvalidArgument, "first tunnel message must be hello with agent_id")
+	}
+	agentID := hello.GetAgentId()
+	if s.requireIdentity {
+		id, err := agentcert.FromContext(stream.Context())
+		if err != nil {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+		if err := id.Check(agentID, hello.GetGameId(), hello.GetEnv()); err != nil {
+			slog.Warn("agent tunnel refused", "agent_id", agentID, "cert_agent_id", id.AgentID, "game_id", hello.GetGameId(), "env", hello.GetEnv())
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	t := &agentTunnel{hello: hello, stream: stream, pending: map[string]chan *tunnelv1.TunnelMessage{}}
+	s.hub.register(agentID, t)
+	defer s.hub.unregister(agentID, t)
+	slog.Info("agent tunnel opened", "agent_id", agentID, "game_id", hello.GetGameId(), "env", hello.GetEnv())
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			slog.Info("agent tunnel closed", "agent_id", agentID, "error", err)
+			return nil
+		}
+		switch msg.GetType() {
+		case MsgResult:
+			t.resolve(msg.GetResult().GetRequestId(), msg)
+		case MsgStartR:
+			t.resolve(msg.GetStartR().GetRequestId(), msg)
+		case MsgListRes:
+			t.resolve(msg.GetListRes().GetRequestId(), msg)
+		case MsgJobResRes:
+			t.resolve(msg.GetJobResRes().GetRequestId(), msg)
+		case MsgJobEvent:
+			if ev := msg.GetJobEvt(); ev != nil && ev.GetJobId() != "" {
+				s.hub.publish(agentID, ev)
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package edge
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	tunnelv1 "github.com/cuihairu/croupier/pkg/pb/croupier/tunnel/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestTunnelForwardsInvokeAndJobEvents(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	app := New(nil)
+	srv := grpc.NewServer()
+	app.RegisterGRPC(srv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer cc.Close()
+
+	// Fake agent: answers invokes by echoing the payload and starts job-1.
+	stream, err := tunnelv1.NewTunnelServiceClient(cc).Open(ctx)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := stream.Send(&tunnelv1.TunnelMessage{Type: MsgHello, Hello: &tunnelv1.Hello{AgentId: "agent-1", GameId: "g1"}}); err != nil {
+		t.Fatalf("hello: %v", err)
+	}
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			switch msg.GetType() {
+			case MsgInvoke:
+				in := msg.GetInvoke()
+				_ = stream.Send(&tunnelv1.TunnelMessage{Type: MsgResult, Result: &tunnelv1.ResultFrame{RequestId: in.GetRequestId(), Payload: in.GetPayload()}})
+			case MsgStart:
+				in := msg.GetStart()
+				_ = stream.Send(&tunnelv1.TunnelMessage{Type: MsgStartR, StartR: &tunnelv1.StartJobResult{RequestId: in.GetRequestId(), JobId: "job-1"}})
+				_ = stream.Send(&tunnelv1.TunnelMessage{Type: MsgJobEvent, JobEvt: &tunnelv1.JobEventFrame{JobId: "job-1", Type: "progress", Progress: 50}})
+				_ = stream.Send(&tunnelv1.TunnelMessage{Type: MsgJobEvent, JobEvt: &tunnelv1.JobEventFrame{JobId: "job-1", Type: "done", Payload: []byte(`{"ok":true}`)}})
+			}
+		}
+	}()
+	for len(app.Hub().Agents()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("agent tunnel was not registered")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	fn := functionv1.NewFunctionServiceClient(cc)
+	resp, err := fn.Invoke(ctx, &functionv1.InvokeRequest{FunctionId: "player.ban", Payload: []byte(`{"player_id":"1"}`), Metadata: map[string]string{"game_id": "g1"}})
+	if err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	if string(resp.GetPayload()) != `{"player_id":"1"}` {
+		t.Fatalf("unexpected payload %q", resp.GetPayload())
+	}
+	if _, err := fn.Invoke(ctx, &functionv1.InvokeRequest{FunctionId: "player.ban", Metadata: map[string]string{"game_id": "other"}}); err == nil {
+		t.Fatal("expected no tunnel for other game")
+	}
+
+	job, err := fn.StartJob(ctx, &functionv1.InvokeRequest{FunctionId: "player.export", Metadata: map[string]string{"game_id": "g1"}})
+	if err != nil || job.GetJobId() != "job-1" {
+		t.Fatalf("start job = %v, %v", job.GetJobId(), err)
+	}
+	events, err := fn.StreamJob(ctx, &functionv1.JobStreamRequest{JobId: "job-1"})
+	if err != nil {
+		t.Fatalf("stream job: %v", err)
+	}
+	var last *functionv1.JobEvent
+	for {
+		ev, err := events.Recv()
+		if err != nil {
+			break
+		}
+		last = ev
+	}
+	if last == nil || last.GetType() != "done" {
+		t.Fatalf("expected done event, got %v", last)
+	}
+}
+
+func TestHubDeliversTerminalFrameToSlowSubscriber(t *testing.T) {
+	h := NewHub()
+	h.trackJob("job-1", "agent-1")
+	ch, cancel, ok := h.subscribe("job-1")
+	if !ok {
+		t.Fatal("job-1 not tracked")
+	}
+	defer cancel()
+	// Nobody reads while the agent sends more progress than the channel holds.
+	for i := 0; i < 2*jobEventBuffer; i++ {
+		h.publish("agent-1", &tunnelv1.JobEventFrame{JobId: "job-1", Type: "progress"})
+	}
+	h.publish("agent-1", &tunnelv1.JobEventFrame{JobId: "job-1", Type: "done"})
+	var last *tunnelv1.JobEventFrame
+	for ev := range ch {
+		last = ev
+	}
+	if last.GetType() != "done" {
+		t.Fatalf("last frame %v, want done", last)
+	}
+}
+
+func TestHubForgetsJobs(t *testing.T) {
+	jobRetention = 10 * time.Millisecond
+	defer func() { jobRetention = 10 * time.Minute }()
+	h := NewHub()
+	tun := &agentTunnel{pending: map[string]chan *tunnelv1.TunnelMessage{}}
+	h.register("agent-1", tun)
+	h.trackJob("job-1", "agent-1")
+	h.trackJob("job-2", "agent-1")
+	h.publish("agent-1", &tunnelv1.JobEventFrame{JobId: "job-1", Type: "done"})
+
+	// Jobs still running when their tunnel closes fail.
+	h.unregister("agent-1", tun)
+	if state, _, msg, done := h.jobResult("job-2"); !done || state != "failed" || msg != ErrTunnelClosed.Error() {
+		t.Fatalf("job-2 = %s %q done=%v, want failed", state, msg, done)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, ok1 := h.jobOwner("job-1")
+		_, ok2 := h.jobOwner("job-2")
+		if !ok1 && !ok2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("finished jobs were not dropped")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
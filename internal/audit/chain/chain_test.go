@@ -0,0 +1,135 @@
+package chain
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriterResumesAndRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, err := Open(path, Options{MaxBytes: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.Log("invoke", "alice", "player.ban", map[string]string{"game_id": "g1", "env": "prod"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	// Reopening must continue the chain instead of starting from a zero hash.
+	w, err = Open(path, Options{MaxBytes: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.Log("login", "bob", "", map[string]string{"ip": "10.0.0.1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	segs, err := Segments(path)
+	if err != nil || len(segs) == 0 {
+		t.Fatalf("expected rotated segments, got %v %v", segs, err)
+	}
+	sum, err := Verify(path)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if sum.Records != 6 {
+		t.Fatalf("records = %d, want 6", sum.Records)
+	}
+
+	evs, total, err := Query(path, Filter{Kinds: []string{"invoke"}, GameID: "g1"}, 0, 2)
+	if err != nil || total != 3 || len(evs) != 2 || evs[0].Actor != "alice" {
+		t.Fatalf("query: %v total=%d %+v", err, total, evs)
+	}
+}
+
+func TestVerifyPinpointsTamperedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, actor := range []string{"alice", "bob", "carol"} {
+		if err := w.Log("invoke", actor, "player.ban", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	b, _ := os.ReadFile(path)
+	b = bytes.Replace(b, []byte(`"actor":"bob"`), []byte(`"actor":"eve"`), 1)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Verify(path)
+	var te *TamperError
+	if !errors.As(err, &te) || te.Line != 2 {
+		t.Fatalf("expected tamper at line 2, got %v", err)
+	}
+}
+
+func TestOpenDropsTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Log("login", "alice", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"time":"2025-01-01T00:00:00Z","kind":"log`)
+	f.Close()
+
+	w, err = NewWriter(path)
+	if err != nil {
+		t.Fatalf("reopen after a torn write: %v", err)
+	}
+	if err := w.Log("login", "bob", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if sum, err := Verify(path); err != nil || sum.Records != 2 {
+		t.Fatalf("verify: %+v, %v", sum, err)
+	}
+}
+
+func TestQueryStopsAtLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, err := Open(path, Options{MaxBytes: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, actor := range []string{"a", "b", "c", "d", "e", "f"} {
+		if err := w.Log("invoke", actor, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	segs, _ := Segments(path)
+	if len(segs) < 2 {
+		t.Fatalf("expected several segments, got %v", segs)
+	}
+	// Make the oldest segment unreadable; a query satisfied by newer ones never reads it.
+	if err := os.Remove(segs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(segs[0], 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Query(path, Filter{}, 0, 0); err == nil {
+		t.Fatal("a full query read past the unreadable segment")
+	}
+	evs, total, err := Query(path, Filter{}, 1, 2)
+	if err != nil || len(evs) != 2 || evs[0].Actor != "e" || evs[1].Actor != "d" || total != 4 {
+		t.Fatalf("query: %v total=%d %+v", err, total, evs)
+	}
+}
@@ -0,0 +1,140 @@
+package chain
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Filter selects audit events. Empty fields match everything; GameID, Env and IP
+// match the game_id, env and ip meta entries.
+type Filter struct {
+	Actor  string
+	Kinds  []string
+	Target string
+	GameID string
+	Env    string
+	IP     string
+	Since  time.Time
+	Until  time.Time
+}
+
+// Match reports whether ev passes the filter.
+func (f Filter) Match(ev Event) bool {
+	if f.Actor != "" && ev.Actor != f.Actor {
+		return false
+	}
+	if len(f.Kinds) > 0 {
+		ok := false
+		for _, k := range f.Kinds {
+			if strings.EqualFold(k, ev.Kind) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.Target != "" && !strings.Contains(ev.Target, f.Target) {
+		return false
+	}
+	if f.GameID != "" && ev.Meta["game_id"] != f.GameID {
+		return false
+	}
+	if f.Env != "" && ev.Meta["env"] != f.Env {
+		return false
+	}
+	if f.IP != "" && ev.Meta["ip"] != f.IP {
+		return false
+	}
+	if !f.Since.IsZero() && ev.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && ev.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Query returns matching events of the chain at path newest first, skipping offset
+// and returning at most limit (0 = all). Segments are read one at a time from the
+// newest and the scan stops once the page and one more match are found, so total
+// counts the matches seen: it is exact when the scan reached the oldest segment and
+// otherwise exceeds offset+limit to signal that more events match.
+func Query(path string, f Filter, offset, limit int) ([]Event, int, error) {
+	segs, err := Segments(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset = max(offset, 0)
+	out := []Event{}
+	total := 0
+	files := append(segs, path)
+	for i := len(files) - 1; i >= 0; i-- {
+		// A segment rotated before Since holds only older events, as do those before it.
+		if at, ok := rotatedAt(path, files[i]); ok && !f.Since.IsZero() && at.Before(f.Since) {
+			break
+		}
+		var matched []Event
+		if err := readEvents(files[i], func(ev Event) {
+			if f.Match(ev) {
+				matched = append(matched, ev)
+			}
+		}); err != nil {
+			return nil, 0, err
+		}
+		for j := len(matched) - 1; j >= 0; j-- {
+			if limit > 0 && total >= offset+limit {
+				return out, total + 1, nil
+			}
+			if total >= offset {
+				out = append(out, matched[j])
+			}
+			total++
+		}
+	}
+	return out, total, nil
+}
+
+// rotatedAt returns the rotation time encoded in the name of a rotated segment.
+func rotatedAt(path, segment string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(segment, path+".")
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(segmentTimeLayout, suffix)
+	return t, err == nil
+}
+
+// readEvents calls fn for each readable record of file; a missing file has none and
+// unreadable lines (e.g. a write in progress) are skipped.
+func readEvents(file string, fn func(Event)) error {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var ev Event
+			if json.Unmarshal(line, &ev) == nil {
+				fn(ev)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package chain
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sealSuffix is appended to a rotated segment name to form its seal file.
+const sealSuffix = ".seal"
+
+// Seal is written next to a rotated segment. Digest is the sha256 of the segment
+// file; FirstHash/LastHash bound the chain it contains.
+type Seal struct {
+	Segment   string    `json:"segment"`
+	Records   int       `json:"records"`
+	FirstHash string    `json:"first_hash"`
+	LastHash  string    `json:"last_hash"`
+	Digest    string    `json:"digest"`
+	SealedAt  time.Time `json:"sealed_at"`
+}
+
+// segmentTimeLayout formats the rotation time in segment names.
+const segmentTimeLayout = "20060102T150405.000000000Z"
+
+// segmentName names a rotated segment after the active path and rotation time so
+// that lexical order is chronological, e.g. audit.log.20250101T000000.000000000Z.
+func segmentName(path string, at time.Time) string {
+	return path + "." + at.UTC().Format(segmentTimeLayout)
+}
+
+func writeSeal(segment string, s Seal) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := segment + sealSuffix + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, segment+sealSuffix)
+}
+
+func readSeal(segment string) (*Seal, error) {
+	b, err := os.ReadFile(segment + sealSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var s Seal
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Segments returns the rotated segments of the chain at path, oldest first.
+func Segments(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if strings.HasSuffix(m, sealSuffix) || strings.HasSuffix(m, ".tmp") {
+			continue
+		}
+		out = append(out, m)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// lastSeal returns the seal of the newest rotated segment, or nil when there is none.
+func lastSeal(path string) (*Seal, error) {
+	segs, err := Segments(path)
+	if err != nil || len(segs) == 0 {
+		return nil, err
+	}
+	return readSeal(segs[len(segs)-1])
+}
@@ -1,32 +1,111 @@
 package chain
 
 import (
+	"bufio"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"sync"
 	"time"
 )
 
+// Options control segment rotation. A zero value disables the corresponding limit.
+type Options struct {
+	// MaxBytes rotates the active segment before a record would grow it past this size.
+	MaxBytes int64
+	// MaxAge rotates the active segment once its first record is older than this.
+	MaxAge time.Duration
+}
+
+// Writer appends hash-chained audit events to the active segment at path. Each record
+// carries the hash of the previous one; the chain continues across restarts and
+// rotated segments.
 type Writer struct {
-	mu   sync.Mutex
-	f    *os.File
-	prev []byte // previous hash
+	mu      sync.Mutex
+	path    string
+	opts    Options
+	f       *os.File
+	size    int64
+	records int
+	first   string    // hash of the first record in the active segment
+	started time.Time // time of the first record in the active segment
+	prev    []byte    // previous hash
+	now     func() time.Time
 }
 
-func NewWriter(path string) (*Writer, error) {
+// NewWriter opens the chain at path without rotation.
+func NewWriter(path string) (*Writer, error) { return Open(path, Options{}) }
+
+// Open opens the chain at path, resuming from the last record of the active segment
+// or, when it is empty, from the seal of the newest rotated segment.
+func Open(path string, opts Options) (*Writer, error) {
 	if err := os.MkdirAll(filepathDir(path), 0o755); err != nil {
 		return nil, err
 	}
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	w := &Writer{path: path, opts: opts, prev: make([]byte, 32), now: func() time.Time { return time.Now().UTC() }}
+	st, err := scanSegment(path)
 	if err != nil {
 		return nil, err
 	}
-	return &Writer{f: f, prev: make([]byte, 32)}, nil
+	if st.torn {
+		// A crash mid-write left a partial last record; drop it so the next record
+		// starts on its own line and the chain resumes from the last complete one.
+		if err := os.Truncate(path, st.size); err != nil {
+			return nil, fmt.Errorf("truncate partial audit record: %w", err)
+		}
+	}
+	if st.records > 0 {
+		w.records, w.first, w.started = st.records, st.first, st.started
+		if err := w.setPrev(st.last); err != nil {
+			return nil, fmt.Errorf("resume audit chain: %w", err)
+		}
+	} else if seal, err := lastSeal(path); err != nil {
+		return nil, err
+	} else if seal != nil {
+		if err := w.setPrev(seal.LastHash); err != nil {
+			return nil, fmt.Errorf("resume audit chain: %w", err)
+		}
+	}
+	if err := w.openActive(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) setPrev(hash string) error {
+	b, err := hex.DecodeString(hash)
+	if err != nil || len(b) != 32 {
+		return fmt.Errorf("invalid hash %q", hash)
+	}
+	w.prev = b
+	return nil
+}
+
+func (w *Writer) openActive() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size = f, info.Size()
+	return nil
 }
 
-func (w *Writer) Close() error { return w.f.Close() }
+// Path returns the path of the active segment.
+func (w *Writer) Path() string { return w.path }
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
 
 type Event struct {
 	Time   time.Time         `json:"time"`
@@ -38,21 +117,154 @@ type Event struct {
 	Hash   string            `json:"hash"`
 }
 
+// computeHash returns the chain hash of ev: sha256(prev || json(ev without hash)).
+func computeHash(prev []byte, ev Event) []byte {
+	ev.Hash = ""
+	b, _ := json.Marshal(ev)
+	h := sha256.Sum256(append(append([]byte(nil), prev...), b...))
+	return h[:]
+}
+
 func (w *Writer) Log(kind, actor, target string, meta map[string]string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	ev := Event{Time: time.Now().UTC(), Kind: kind, Actor: actor, Target: target, Meta: meta, Prev: hex.EncodeToString(w.prev)}
+	now := w.now()
+	ev := Event{Time: now, Kind: kind, Actor: actor, Target: target, Meta: meta, Prev: hex.EncodeToString(w.prev)}
+	h := computeHash(w.prev, ev)
+	ev.Hash = hex.EncodeToString(h)
 	b, _ := json.Marshal(ev)
-	h := sha256.Sum256(append(w.prev, b...))
-	ev.Hash = hex.EncodeToString(h[:])
-	b, _ = json.Marshal(ev)
-	if _, err := w.f.Write(append(b, '\n')); err != nil {
+	line := append(b, '\n')
+	if w.shouldRotate(int64(len(line)), now) {
+		if err := w.rotate(now); err != nil {
+			return err
+		}
+	}
+	if _, err := w.f.Write(line); err != nil {
 		return err
 	}
-	copy(w.prev, h[:])
+	if w.records == 0 {
+		w.first, w.started = ev.Hash, now
+	}
+	w.records++
+	w.size += int64(len(line))
+	copy(w.prev, h)
 	return nil
 }
 
+func (w *Writer) shouldRotate(n int64, now time.Time) bool {
+	if w.records == 0 {
+		return false
+	}
+	if w.opts.MaxBytes > 0 && w.size+n > w.opts.MaxBytes {
+		return true
+	}
+	return w.opts.MaxAge > 0 && now.Sub(w.started) >= w.opts.MaxAge
+}
+
+// Rotate seals the active segment and starts a new one; it is a no-op when the
+// active segment is empty.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.records == 0 {
+		return nil
+	}
+	return w.rotate(w.now())
+}
+
+func (w *Writer) rotate(now time.Time) error {
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	digest, err := fileDigest(w.path)
+	if err != nil {
+		return err
+	}
+	seg := segmentName(w.path, now)
+	if err := os.Rename(w.path, seg); err != nil {
+		return err
+	}
+	seal := Seal{
+		Segment:   filepathBase(seg),
+		Records:   w.records,
+		FirstHash: w.first,
+		LastHash:  hex.EncodeToString(w.prev),
+		Digest:    digest,
+		SealedAt:  now,
+	}
+	if err := writeSeal(seg, seal); err != nil {
+		return err
+	}
+	w.records, w.first, w.started = 0, "", time.Time{}
+	return w.openActive()
+}
+
+// segmentStat summarizes the records of one segment file.
+type segmentStat struct {
+	records int
+	first   string
+	last    string
+	started time.Time
+	// size is where the last complete record ends; torn reports a partial record
+	// after it.
+	size int64
+	torn bool
+}
+
+// scanSegment reads the records of a segment; a missing file is an empty segment. A
+// last line without newline is a write cut short and is reported as torn rather
+// than as an error.
+func scanSegment(path string) (segmentStat, error) {
+	var st segmentStat
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			st.torn = len(line) > 0
+			return st, nil
+		}
+		if len(line) > 0 {
+			var ev Event
+			if jerr := json.Unmarshal(line, &ev); jerr != nil || ev.Hash == "" {
+				return st, fmt.Errorf("%s:%d: unreadable audit record", path, n)
+			}
+			if st.records == 0 {
+				st.first, st.started = ev.Hash, ev.Time
+			}
+			st.records++
+			st.last = ev.Hash
+			st.size += int64(len(line))
+		}
+		if err != nil {
+			return st, err
+		}
+	}
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func filepathDir(p string) string {
 	for i := len(p) - 1; i >= 0; i-- {
 		if p[i] == '/' {
@@ -61,3 +273,12 @@ func filepathDir(p string) string {
 	}
 	return "."
 }
+
+func filepathBase(p string) string {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i] == '/' {
+			return p[i+1:]
+		}
+	}
+	return p
+}
@@ -72,6 +72,7 @@ func EnsureDevCA(dir string) (caCrt, caKey string, err error) {
 }
 
 // EnsureServerCert ensures a server cert signed by CA; hosts may include DNS/IP.
+// The cert also authenticates the server to the edges it calls agents through.
 func EnsureServerCert(dir, caCrtPath, caKeyPath string, hosts []string) (crtPath, keyPath string, err error) {
 	crtPath = filepath.Join(dir, "server.crt")
 	keyPath = filepath.Join(dir, "server.key")
@@ -110,7 +111,7 @@ func EnsureServerCert(dir, caCrtPath, caKeyPath string, hosts []string) (crtPath
 		NotBefore:             time.Now().Add(-time.Hour),
 		NotAfter:              time.Now().AddDate(1, 0, 0),
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
 	}
 	for _, h := range hosts {
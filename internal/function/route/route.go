@@ -0,0 +1,74 @@
+// Package route holds the routing modes of function calls. The server uses them to
+// pick an agent and the agent to pick a game server instance, so a hash key lands
+// on the same instance whichever replica forwards the call.
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Route modes declared by descriptor semantics.route / FunctionOptions.route.
+const (
+	LB        = "lb"
+	Broadcast = "broadcast"
+	Targeted  = "targeted"
+	Hash      = "hash"
+)
+
+// DefaultHashField is hashed when a hash route does not name a key explicitly.
+const DefaultHashField = "player_id"
+
+var (
+	// ErrNoTarget is returned for a targeted call without target_service_id.
+	ErrNoTarget = errors.New("target_service_id required for targeted route")
+	// ErrNoHashKey is returned for a hash call whose payload lacks the key field.
+	ErrNoHashKey = errors.New("hash key missing from payload")
+)
+
+// Mode normalizes a route; anything unknown is load balanced.
+func Mode(r string) string {
+	switch r = strings.ToLower(strings.TrimSpace(r)); r {
+	case Broadcast, Targeted, Hash:
+		return r
+	default:
+		return LB
+	}
+}
+
+// HashValue returns the value a hash call is routed by: the top-level payload
+// field named by hashKey, or DefaultHashField when hashKey is empty.
+func HashValue(hashKey string, payload []byte) (string, error) {
+	field := strings.TrimSpace(hashKey)
+	if field == "" {
+		field = DefaultHashField
+	}
+	var m map[string]any
+	_ = json.Unmarshal(payload, &m)
+	v, ok := m[field]
+	if !ok || v == nil {
+		return "", fmt.Errorf("%w: %s", ErrNoHashKey, field)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// Pick uses rendezvous hashing to choose one of ids for key, so a key keeps
+// landing on the same id while it stays listed, and only its keys move when it
+// leaves. It returns -1 for no ids.
+func Pick(ids []string, key string) int {
+	best := -1
+	var bestScore uint64
+	for i, id := range ids {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(id))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+		if score := h.Sum64(); best < 0 || score > bestScore || (score == bestScore && id < ids[best]) {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
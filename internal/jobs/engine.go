@@ -0,0 +1,327 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer bounds events queued for a subscriber; a slow reader blocks only
+// its own goroutine, which catches up from the event log.
+const subscriberBuffer = 64
+
+// Engine drives job state transitions on top of a Store, appends every change to the
+// job event log and fans events out to subscribers. Deadlines are enforced with
+// timers that are re-armed by Recover after a restart.
+type Engine struct {
+	store Store
+	now   func() time.Time
+	// opMu serializes read-modify-write cycles on job records.
+	opMu sync.Mutex
+
+	mu        sync.Mutex
+	subs      map[string]map[chan struct{}]struct{}
+	timers    map[string]*time.Timer
+	onTimeout func(*Job)
+}
+
+// NewEngine creates an engine backed by store; a nil store uses an in-memory store.
+func NewEngine(store Store) *Engine {
+	if store == nil {
+		store = NewMemStore()
+	}
+	return &Engine{
+		store:  store,
+		now:    time.Now,
+		subs:   map[string]map[chan struct{}]struct{}{},
+		timers: map[string]*time.Timer{},
+	}
+}
+
+// Store returns the underlying store.
+func (e *Engine) Store() Store { return e.store }
+
+// OnTimeout registers a callback invoked after a job is moved to timed_out, e.g. to
+// cancel it on the agent.
+func (e *Engine) OnTimeout(fn func(*Job)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onTimeout = fn
+}
+
+// Create persists a new job in the pending state (or the state already set on job)
+// and arms its deadline when Timeout is set.
+func (e *Engine) Create(ctx context.Context, job *Job) error {
+	if strings.TrimSpace(job.ID) == "" {
+		return fmt.Errorf("job id required")
+	}
+	now := e.now()
+	if job.State == "" {
+		job.State = StatePending
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	if job.State == StateRunning && job.StartedAt.IsZero() {
+		job.StartedAt = now
+	}
+	if job.Timeout > 0 && job.Deadline.IsZero() {
+		job.Deadline = job.CreatedAt.Add(job.Timeout)
+	}
+	if err := e.store.Create(ctx, job); err != nil {
+		return err
+	}
+	if err := e.appendEvent(ctx, &Event{JobID: job.ID, Type: EventState, Message: string(job.State)}); err != nil {
+		return err
+	}
+	e.arm(job)
+	return nil
+}
+
+// Get returns a job by id.
+func (e *Engine) Get(ctx context.Context, id string) (*Job, error) { return e.store.Get(ctx, id) }
+
+// List returns jobs matching opts, newest first, and the total count.
+func (e *Engine) List(ctx context.Context, opts ListOptions) ([]*Job, int64, error) {
+	return e.store.List(ctx, opts)
+}
+
+// Events returns the event log of a job after afterSeq.
+func (e *Engine) Events(ctx context.Context, id string, afterSeq int64) ([]Event, error) {
+	return e.store.Events(ctx, id, afterSeq)
+}
+
+// Start moves a pending job to running.
+func (e *Engine) Start(ctx context.Context, id string) error {
+	_, err := e.transition(ctx, id, StateRunning, nil, "")
+	return err
+}
+
+// Progress records a progress event for a running job.
+func (e *Engine) Progress(ctx context.Context, id string, progress int32, message string) error {
+	e.opMu.Lock()
+	defer e.opMu.Unlock()
+	job, err := e.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.State.Terminal() {
+		return ErrInvalidTransition
+	}
+	job.Progress = progress
+	if err := e.store.Update(ctx, job); err != nil {
+		return err
+	}
+	return e.appendEvent(ctx, &Event{JobID: id, Type: EventProgress, Message: message, Progress: progress})
+}
+
+// Log appends a log line to a job's event log.
+func (e *Engine) Log(ctx context.Context, id, message string) error {
+	return e.appendEvent(ctx, &Event{JobID: id, Type: EventLog, Message: message})
+}
+
+// Finish moves a job to a terminal state and appends the closing done/error event.
+// Finishing an already finished job returns ErrInvalidTransition.
+func (e *Engine) Finish(ctx context.Context, id string, state State, result []byte, errMsg string) (*Job, error) {
+	if !state.Terminal() {
+		return nil, ErrInvalidTransition
+	}
+	return e.transition(ctx, id, state, result, errMsg)
+}
+
+func (e *Engine) transition(ctx context.Context, id string, next State, result []byte, errMsg string) (*Job, error) {
+	e.opMu.Lock()
+	defer e.opMu.Unlock()
+	job, err := e.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !job.State.CanTransition(next) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, job.State, next)
+	}
+	now := e.now()
+	job.State = next
+	if next == StateRunning && job.StartedAt.IsZero() {
+		job.StartedAt = now
+	}
+	if next.Terminal() {
+		if job.StartedAt.IsZero() {
+			job.StartedAt = now
+		}
+		job.EndedAt = now
+		job.Error = errMsg
+		if len(result) > 0 {
+			job.Result = append([]byte(nil), result...)
+		}
+		if next == StateSucceeded {
+			job.Progress = 100
+		}
+	}
+	if err := e.store.Update(ctx, job); err != nil {
+		return nil, err
+	}
+	if err := e.appendEvent(ctx, &Event{JobID: id, Type: EventState, Message: string(next)}); err != nil {
+		return nil, err
+	}
+	if next.Terminal() {
+		e.disarm(id)
+		final := &Event{JobID: id, Type: EventDone, Payload: job.Result, Progress: job.Progress}
+		if next != StateSucceeded {
+			msg := errMsg
+			if msg == "" {
+				msg = string(next)
+			}
+			final = &Event{JobID: id, Type: EventError, Message: msg, Payload: job.Result}
+		}
+		if err := e.appendEvent(ctx, final); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+func (e *Engine) appendEvent(ctx context.Context, ev *Event) error {
+	if ev.Time.IsZero() {
+		ev.Time = e.now()
+	}
+	if err := e.store.AppendEvent(ctx, ev); err != nil {
+		return err
+	}
+	e.notify(ev.JobID)
+	return nil
+}
+
+func (e *Engine) notify(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ch := range e.subs[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Subscribe streams the events of a job with Seq greater than afterSeq: recorded
+// events are replayed first, then new ones are delivered as they are appended. The
+// channel is closed after the final done/error event or when ctx is done.
+func (e *Engine) Subscribe(ctx context.Context, id string, afterSeq int64) (<-chan Event, error) {
+	if _, err := e.store.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	wake := make(chan struct{}, 1)
+	e.mu.Lock()
+	if e.subs[id] == nil {
+		e.subs[id] = map[chan struct{}]struct{}{}
+	}
+	e.subs[id][wake] = struct{}{}
+	e.mu.Unlock()
+
+	out := make(chan Event, subscriberBuffer)
+	go func() {
+		defer close(out)
+		defer func() {
+			e.mu.Lock()
+			delete(e.subs[id], wake)
+			if len(e.subs[id]) == 0 {
+				delete(e.subs, id)
+			}
+			e.mu.Unlock()
+		}()
+		seq := afterSeq
+		for {
+			evs, err := e.store.Events(ctx, id, seq)
+			if err != nil {
+				return
+			}
+			for _, ev := range evs {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+				seq = ev.Seq
+				if ev.Final() {
+					return
+				}
+			}
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Recover re-arms deadlines of unfinished jobs after a restart, times out jobs whose
+// deadline already passed, and returns the jobs that are still active.
+func (e *Engine) Recover(ctx context.Context) ([]*Job, error) {
+	active, _, err := e.store.List(ctx, ListOptions{States: []State{StatePending, StateRunning}})
+	if err != nil {
+		return nil, err
+	}
+	now := e.now()
+	out := make([]*Job, 0, len(active))
+	for _, job := range active {
+		if !job.Deadline.IsZero() && !now.Before(job.Deadline) {
+			e.expire(job.ID)
+			continue
+		}
+		e.arm(job)
+		out = append(out, job)
+	}
+	return out, nil
+}
+
+func (e *Engine) arm(job *Job) {
+	if job.Deadline.IsZero() || job.State.Terminal() {
+		return
+	}
+	d := job.Deadline.Sub(e.now())
+	if d < 0 {
+		d = 0
+	}
+	id := job.ID
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if t := e.timers[id]; t != nil {
+		t.Stop()
+	}
+	e.timers[id] = time.AfterFunc(d, func() { e.expire(id) })
+}
+
+func (e *Engine) disarm(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if t := e.timers[id]; t != nil {
+		t.Stop()
+		delete(e.timers, id)
+	}
+}
+
+func (e *Engine) expire(id string) {
+	job, err := e.transition(context.Background(), id, StateTimedOut, nil, "job timed out")
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	fn := e.onTimeout
+	e.mu.Unlock()
+	if fn != nil {
+		fn(job)
+	}
+}
+
+// Close stops all deadline timers.
+func (e *Engine) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id, t := range e.timers {
+		t.Stop()
+		delete(e.timers, id)
+	}
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func collect(t *testing.T, ch <-chan Event) []Event {
+	t.Helper()
+	var out []Event
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return out
+			}
+			out = append(out, ev)
+		case <-timeout:
+			t.Fatalf("subscription did not close; got %d events", len(out))
+		}
+	}
+}
+
+func TestEngineLifecycleAndReplay(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine(nil)
+	defer e.Close()
+	if err := e.Create(ctx, &Job{ID: "j1", FunctionID: "mail.broadcast"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := e.Start(ctx, "j1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := e.Progress(ctx, "j1", 40, "sent 400"); err != nil {
+		t.Fatalf("progress: %v", err)
+	}
+	live, err := e.Subscribe(ctx, "j1", 0)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, err := e.Finish(ctx, "j1", StateSucceeded, []byte(`{"sent":1000}`), ""); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if _, err := e.Finish(ctx, "j1", StateFailed, nil, "late"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected invalid transition, got %v", err)
+	}
+	evs := collect(t, live)
+	if len(evs) == 0 || evs[len(evs)-1].Type != EventDone {
+		t.Fatalf("expected stream to end with done, got %+v", evs)
+	}
+
+	// A late subscriber replays the full history.
+	late, err := e.Subscribe(ctx, "j1", 0)
+	if err != nil {
+		t.Fatalf("late subscribe: %v", err)
+	}
+	replayed := collect(t, late)
+	if len(replayed) != len(evs) {
+		t.Fatalf("replayed %d events, want %d", len(replayed), len(evs))
+	}
+	job, _ := e.Get(ctx, "j1")
+	if job.State != StateSucceeded || job.Progress != 100 || string(job.Result) != `{"sent":1000}` {
+		t.Fatalf("unexpected job %+v", job)
+	}
+}
+
+func TestEngineTimeoutAndRecover(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemStore()
+	e := NewEngine(store)
+	timedOut := make(chan string, 1)
+	e.OnTimeout(func(j *Job) { timedOut <- j.ID })
+	if err := e.Create(ctx, &Job{ID: "j2", State: StateRunning, Timeout: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	select {
+	case id := <-timedOut:
+		if id != "j2" {
+			t.Fatalf("timed out %s", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not time out")
+	}
+	if job, _ := e.Get(ctx, "j2"); job.State != StateTimedOut {
+		t.Fatalf("state = %s", job.State)
+	}
+
+	// A restarted engine times out overdue jobs and keeps the rest active.
+	_ = store.Create(ctx, &Job{ID: "overdue", State: StateRunning, Deadline: time.Now().Add(-time.Minute)})
+	_ = store.Create(ctx, &Job{ID: "active", State: StateRunning, Deadline: time.Now().Add(time.Hour)})
+	e2 := NewEngine(store)
+	defer e2.Close()
+	active, err := e2.Recover(ctx)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if len(active) != 1 || active[0].ID != "active" {
+		t.Fatalf("active = %+v", active)
+	}
+	if job, _ := e2.Get(ctx, "overdue"); job.State != StateTimedOut {
+		t.Fatalf("overdue state = %s", job.State)
+	}
+}
@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// State is the lifecycle state of a job.
+type State string
+
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+	StateTimedOut  State = "timed_out"
+)
+
+// Terminal reports whether no further transition is allowed from s.
+func (s State) Terminal() bool {
+	switch s {
+	case StateSucceeded, StateFailed, StateCancelled, StateTimedOut:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether a job may move from s to next.
+func (s State) CanTransition(next State) bool {
+	switch s {
+	case StatePending:
+		return next != StatePending
+	case StateRunning:
+		return next != StatePending && next != StateRunning
+	}
+	return false
+}
+
+// Event types stored in the job event log. progress/log/done/error mirror
+// FunctionService JobEvent types; state records lifecycle transitions.
+const (
+	EventState    = "state"
+	EventProgress = "progress"
+	EventLog      = "log"
+	EventDone     = "done"
+	EventError    = "error"
+)
+
+var (
+	ErrNotFound          = errors.New("job not found")
+	ErrExists            = errors.New("job already exists")
+	ErrInvalidTransition = errors.New("invalid job state transition")
+)
+
+// Job is the persisted record of a job. ID is assigned by the server with NewID;
+// AgentJobID is the id the agent handed out, which is only unique per agent.
+// EdgeAddr is set when the agent is called through the edge holding its tunnel.
+type Job struct {
+	ID             string
+	FunctionID     string
+	GameID         string
+	Env            string
+	Actor          string
+	IdempotencyKey string
+	AgentID        string
+	AgentJobID     string
+	AgentAddr      string
+	EdgeAddr       string
+	TraceID        string
+	State          State
+	Progress       int32
+	Result         []byte
+	Error          string
+	Timeout        time.Duration
+	Deadline       time.Time
+	CreatedAt      time.Time
+	StartedAt      time.Time
+	EndedAt        time.Time
+}
+
+// NewID returns a random job id.
+func NewID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "job-" + hex.EncodeToString(b)
+}
+
+// Event is one entry of a job's append-only event log. Seq starts at 1 per job.
+type Event struct {
+	JobID    string
+	Seq      int64
+	Type     string
+	Message  string
+	Progress int32
+	Payload  []byte
+	Time     time.Time
+}
+
+// Final reports whether the event closes the job stream.
+func (e Event) Final() bool { return e.Type == EventDone || e.Type == EventError }
+
+// ListOptions filters jobs returned by Store.List; newest first.
+type ListOptions struct {
+	FunctionID string
+	GameID     string
+	Env        string
+	Actor      string
+	States     []State
+	Limit      int
+	Offset     int
+}
+
+// Store persists jobs and their event logs.
+type Store interface {
+	Create(ctx context.Context, job *Job) error
+	Get(ctx context.Context, id string) (*Job, error)
+	Update(ctx context.Context, job *Job) error
+	List(ctx context.Context, opts ListOptions) ([]*Job, int64, error)
+	// AppendEvent assigns ev.Seq and stores the event.
+	AppendEvent(ctx context.Context, ev *Event) error
+	// Events returns events of a job with Seq greater than afterSeq, in order.
+	Events(ctx context.Context, jobID string, afterSeq int64) ([]Event, error)
+}
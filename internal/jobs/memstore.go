@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemStore is an in-memory Store for tests and single-process development.
+type MemStore struct {
+	mu     sync.RWMutex
+	jobs   map[string]*Job
+	events map[string][]Event
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{jobs: map[string]*Job{}, events: map[string][]Event{}}
+}
+
+func (m *MemStore) Create(_ context.Context, job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[job.ID]; ok {
+		return ErrExists
+	}
+	cp := *job
+	m.jobs[job.ID] = &cp
+	return nil
+}
+
+func (m *MemStore) Get(_ context.Context, id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	j := m.jobs[id]
+	if j == nil {
+		return nil, ErrNotFound
+	}
+	cp := *j
+	return &cp, nil
+}
+
+func (m *MemStore) Update(_ context.Context, job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[job.ID]; !ok {
+		return ErrNotFound
+	}
+	cp := *job
+	m.jobs[job.ID] = &cp
+	return nil
+}
+
+func (m *MemStore) List(_ context.Context, opts ListOptions) ([]*Job, int64, error) {
+	m.mu.RLock()
+	out := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if matches(j, opts) {
+			cp := *j
+			out = append(out, &cp)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].CreatedAt.Equal(out[k].CreatedAt) {
+			return out[i].ID > out[k].ID
+		}
+		return out[i].CreatedAt.After(out[k].CreatedAt)
+	})
+	total := int64(len(out))
+	if opts.Offset > 0 {
+		if opts.Offset >= len(out) {
+			return []*Job{}, total, nil
+		}
+		out = out[opts.Offset:]
+	}
+	if opts.Limit > 0 && len(out) > opts.Limit {
+		out = out[:opts.Limit]
+	}
+	return out, total, nil
+}
+
+func (m *MemStore) AppendEvent(_ context.Context, ev *Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[ev.JobID]; !ok {
+		return ErrNotFound
+	}
+	ev.Seq = int64(len(m.events[ev.JobID])) + 1
+	m.events[ev.JobID] = append(m.events[ev.JobID], *ev)
+	return nil
+}
+
+func (m *MemStore) Events(_ context.Context, jobID string, afterSeq int64) ([]Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.jobs[jobID]; !ok {
+		return nil, ErrNotFound
+	}
+	all := m.events[jobID]
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+	if afterSeq >= int64(len(all)) {
+		return []Event{}, nil
+	}
+	return append([]Event(nil), all[afterSeq:]...), nil
+}
+
+func matches(j *Job, opts ListOptions) bool {
+	if opts.FunctionID != "" && j.FunctionID != opts.FunctionID {
+		return false
+	}
+	if opts.GameID != "" && j.GameID != opts.GameID {
+		return false
+	}
+	if opts.Env != "" && j.Env != opts.Env {
+		return false
+	}
+	if opts.Actor != "" && j.Actor != opts.Actor {
+		return false
+	}
+	if len(opts.States) > 0 {
+		for _, s := range opts.States {
+			if j.State == s {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
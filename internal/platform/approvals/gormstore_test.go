@@ -0,0 +1,85 @@
+package approvals
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGormStoreLifecycle(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "approvals.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Approval{ID: "a1", Actor: "alice", FunctionID: "player.ban", Mode: "invoke", Payload: []byte(`{"player_id":"p1"}`),
+		Threshold: 2, ApproverRoles: []string{"admin"}, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.Create(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Approve("a1", "alice", []string{"admin"}); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("self approval: %v", err)
+	}
+	if got, err := s.Approve("a1", "bob", []string{"admin"}); err != nil || got.State != StatePending {
+		t.Fatalf("first vote: %v %+v", err, got)
+	}
+	got, err := s.Approve("a1", "carol", []string{"admin"})
+	if err != nil || got.State != StateApproved || len(got.Votes) != 2 {
+		t.Fatalf("second vote: %v %+v", err, got)
+	}
+	if _, err := s.Reject("a1", "dave", "too late"); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("reject approved: %v", err)
+	}
+	if _, err := s.Complete("a1", Outcome{JobID: "job-1"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.Get("a1")
+	if err != nil || got.State != StateExecuted || got.JobID != "job-1" || string(got.Payload) != `{"player_id":"p1"}` {
+		t.Fatalf("get: %v %+v", err, got)
+	}
+	hist, err := s.History("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, ev := range hist {
+		actions = append(actions, ev.Action+":"+ev.Actor)
+	}
+	want := []string{"created:alice", "approved:bob", "approved:carol", "executed:alice"}
+	if len(actions) != len(want) {
+		t.Fatalf("history %v", actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("history %v", actions)
+		}
+	}
+}
+
+func TestGormStoreListAndExpiry(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "approvals.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Minute)
+	for i, id := range []string{"a1", "a2", "a3"} {
+		exp := time.Now().Add(time.Hour)
+		if id == "a3" {
+			exp = time.Now().Add(-time.Second)
+		}
+		if err := s.Create(&Approval{ID: id, Actor: "alice", GameID: "g1", ExpiresAt: exp, CreatedAt: base.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, total, err := s.List(Filter{State: StatePending}, Page{Page: 1, Size: 1, Sort: "created_at asc"})
+	if err != nil || total != 2 || len(items) != 1 || items[0].ID != "a1" {
+		t.Fatalf("list pending: %v total=%d %+v", err, total, items)
+	}
+	items, total, err = s.List(Filter{State: StateExpired}, Page{})
+	if err != nil || total != 1 || items[0].ID != "a3" {
+		t.Fatalf("list expired: %v total=%d", err, total)
+	}
+	if _, err := s.Approve("a3", "bob", nil); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("approve expired: %v", err)
+	}
+}
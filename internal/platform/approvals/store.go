@@ -8,10 +8,59 @@ import (
     "time"
 )
 
+// Approval states. A pending approval becomes approved once enough votes are in;
+// the held call is then dispatched and the record ends as executed or failed.
+const (
+    StatePending  = "pending"
+    StateApproved = "approved"
+    StateExecuted = "executed"
+    StateFailed   = "failed"
+    StateRejected = "rejected"
+    StateExpired  = "expired"
+)
+
+var (
+    ErrNotFound      = errors.New("approval not found")
+    ErrNotPending    = errors.New("approval is not pending")
+    ErrExpired       = errors.New("approval expired")
+    ErrSelfApproval  = errors.New("requester cannot approve own request")
+    ErrDuplicateVote = errors.New("approver already voted")
+    ErrApproverRole  = errors.New("approver lacks a required role")
+    ErrConflict      = errors.New("approval was modified concurrently")
+)
+
+// History actions recorded for every change of an approval.
+const (
+    ActionCreated  = "created"
+    ActionApproved = "approved"
+    ActionRejected = "rejected"
+    ActionExpired  = "expired"
+    ActionExecuted = "executed"
+    ActionFailed   = "failed"
+)
+
+// Event is one entry of an approval's history: who did what and the resulting state.
+type Event struct {
+    ApprovalID string
+    Action     string
+    Actor      string
+    Role       string
+    State      string
+    Detail     string
+    At         time.Time
+}
+
+// Vote is one approver's decision.
+type Vote struct {
+    Approver string
+    Role     string
+    At       time.Time
+}
+
 // Approval represents a two-person rule approval record.
 type Approval struct {
     ID         string
-    State      string // pending|approved|rejected
+    State      string // pending|approved|executed|failed|rejected|expired
     FunctionID string
     GameID     string
     Env        string
@@ -24,10 +73,33 @@ type Approval struct {
     HashKey        string
     Payload        []byte
     Reason     string
+    // Two-person rule policy captured at request time
+    Threshold     int
+    ApproverRoles []string
+    ExpiresAt     time.Time
+    Votes         []Vote
+    RejectedBy    string
+    // Outcome of the dispatched call
+    Result     []byte
+    JobID      string
+    Error      string
+    ExecutedAt time.Time
     CreatedAt  time.Time
     UpdatedAt  time.Time
 }
 
+// Expired reports whether a pending approval passed its expiry at now.
+func (a *Approval) Expired(now time.Time) bool {
+    return a.State == StatePending && !a.ExpiresAt.IsZero() && !now.Before(a.ExpiresAt)
+}
+
+// Outcome is the result of dispatching an approved call.
+type Outcome struct {
+    Result []byte
+    JobID  string
+    Error  string
+}
+
 type Filter struct {
     State      string
     FunctionID string
@@ -44,34 +116,159 @@ type Page struct {
 }
 
 type Store interface {
+    Create(a *Approval) error
     List(f Filter, p Page) ([]*Approval, int, error)
     Get(id string) (*Approval, error)
-    Approve(id string) (*Approval, error)
-    Reject(id, reason string) (*Approval, error)
+    // Approve records a vote from approver holding roles. The approval moves to
+    // approved when the threshold is reached; only that call observes the transition.
+    Approve(id, approver string, roles []string) (*Approval, error)
+    Reject(id, approver, reason string) (*Approval, error)
+    // Complete records the dispatch outcome of an approved approval exactly once.
+    Complete(id string, out Outcome) (*Approval, error)
+    // History returns the recorded events of an approval, oldest first.
+    History(id string) ([]Event, error)
+}
+
+// SortSpec parses Page.Sort ("created_at desc", "updated_at asc", ...) into a column
+// and direction; the default is updated_at descending.
+func SortSpec(sort string) (field string, asc bool) {
+    field = "updated_at"
+    parts := strings.Fields(strings.ToLower(sort))
+    if len(parts) > 0 && (parts[0] == "created_at" || parts[0] == "updated_at") { field = parts[0] }
+    if len(parts) > 1 && parts[1] == "asc" { asc = true }
+    return field, asc
+}
+
+// voteEvent describes the last vote applied to a.
+func voteEvent(a *Approval) Event {
+    v := a.Votes[len(a.Votes)-1]
+    return Event{ApprovalID: a.ID, Action: ActionApproved, Actor: v.Approver, Role: v.Role, State: a.State, At: v.At}
+}
+
+// outcomeEvent describes the dispatch outcome recorded on a.
+func outcomeEvent(a *Approval) Event {
+    action := ActionExecuted
+    if a.State == StateFailed { action = ActionFailed }
+    return Event{ApprovalID: a.ID, Action: action, Actor: a.Actor, State: a.State, Detail: a.Error, At: a.ExecutedAt}
+}
+
+// ApplyVote validates a vote against a pending approval and records it. Stores use it
+// so that all backends enforce the same rules.
+func ApplyVote(a *Approval, approver string, roles []string, now time.Time) error {
+    if a.Expired(now) {
+        a.State = StateExpired
+        a.UpdatedAt = now
+        return ErrExpired
+    }
+    if a.State != StatePending { return ErrNotPending }
+    if approver == "" || approver == a.Actor { return ErrSelfApproval }
+    for _, v := range a.Votes {
+        if v.Approver == approver { return ErrDuplicateVote }
+    }
+    role := ""
+    if len(a.ApproverRoles) > 0 {
+        for _, want := range a.ApproverRoles {
+            for _, have := range roles {
+                if strings.EqualFold(want, have) { role = have; break }
+            }
+            if role != "" { break }
+        }
+        if role == "" { return ErrApproverRole }
+    } else if len(roles) > 0 {
+        role = roles[0]
+    }
+    a.Votes = append(a.Votes, Vote{Approver: approver, Role: role, At: now})
+    threshold := a.Threshold
+    if threshold <= 0 { threshold = 1 }
+    if len(a.Votes) >= threshold { a.State = StateApproved }
+    a.UpdatedAt = now
+    return nil
+}
+
+// ApplyReject moves a pending approval to rejected.
+func ApplyReject(a *Approval, approver, reason string, now time.Time) error {
+    if a.State != StatePending { return ErrNotPending }
+    a.State = StateRejected
+    a.RejectedBy = approver
+    a.Reason = reason
+    a.UpdatedAt = now
+    return nil
+}
+
+// ApplyOutcome moves an approved approval to executed or failed.
+func ApplyOutcome(a *Approval, out Outcome, now time.Time) error {
+    if a.State != StateApproved { return ErrNotPending }
+    a.State = StateExecuted
+    if out.Error != "" { a.State = StateFailed }
+    a.Result = out.Result
+    a.JobID = out.JobID
+    a.Error = out.Error
+    a.ExecutedAt = now
+    a.UpdatedAt = now
+    return nil
 }
 
 // MemStore is an in-memory approval store for tests/dev.
 type MemStore struct {
-    mu   sync.RWMutex
-    data map[string]*Approval
+    mu      sync.RWMutex
+    data    map[string]*Approval
+    history map[string][]Event
+}
+
+func NewMemStore() *MemStore {
+    return &MemStore{data: map[string]*Approval{}, history: map[string][]Event{}}
 }
 
-func NewMemStore() *MemStore { return &MemStore{data: map[string]*Approval{}} }
+func (s *MemStore) record(ev Event) { s.history[ev.ApprovalID] = append(s.history[ev.ApprovalID], ev) }
+
+// expire marks a as expired when its deadline passed; callers hold s.mu.
+func (s *MemStore) expire(a *Approval, now time.Time) bool {
+    if !a.Expired(now) { return false }
+    a.State = StateExpired
+    a.UpdatedAt = now
+    s.record(Event{ApprovalID: a.ID, Action: ActionExpired, State: a.State, At: now})
+    return true
+}
+
+func clone(a *Approval) *Approval {
+    cp := *a
+    cp.Votes = append([]Vote(nil), a.Votes...)
+    cp.ApproverRoles = append([]string(nil), a.ApproverRoles...)
+    return &cp
+}
+
+func (s *MemStore) Create(a *Approval) error {
+    s.mu.Lock(); defer s.mu.Unlock()
+    now := time.Now()
+    if a.State == "" { a.State = StatePending }
+    if a.CreatedAt.IsZero() { a.CreatedAt = now }
+    a.UpdatedAt = now
+    s.data[a.ID] = clone(a)
+    s.record(Event{ApprovalID: a.ID, Action: ActionCreated, Actor: a.Actor, State: a.State, At: now})
+    return nil
+}
 
 func (s *MemStore) List(f Filter, p Page) ([]*Approval, int, error) {
-    s.mu.RLock(); defer s.mu.RUnlock()
+    s.mu.Lock(); defer s.mu.Unlock()
+    now := time.Now()
     out := make([]*Approval, 0, len(s.data))
     for _, a := range s.data {
+        s.expire(a, now)
         if f.State != "" && !strings.EqualFold(a.State, f.State) { continue }
         if f.FunctionID != "" && a.FunctionID != f.FunctionID { continue }
         if f.GameID != "" && a.GameID != f.GameID { continue }
         if f.Env != "" && a.Env != f.Env { continue }
         if f.Actor != "" && a.Actor != f.Actor { continue }
         if f.Mode != "" && a.Mode != f.Mode { continue }
-        out = append(out, a)
+        out = append(out, clone(a))
     }
-    // simple sort by updated_at desc by default
-    sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+    field, asc := SortSpec(p.Sort)
+    sort.Slice(out, func(i, j int) bool {
+        ti, tj := out[i].UpdatedAt, out[j].UpdatedAt
+        if field == "created_at" { ti, tj = out[i].CreatedAt, out[j].CreatedAt }
+        if asc { return ti.Before(tj) }
+        return ti.After(tj)
+    })
     total := len(out)
     if p.Size <= 0 { p.Size = 50 }
     if p.Page <= 0 { p.Page = 1 }
@@ -83,30 +280,46 @@ func (s *MemStore) List(f Filter, p Page) ([]*Approval, int, error) {
 }
 
 func (s *MemStore) Get(id string) (*Approval, error) {
-    s.mu.RLock(); defer s.mu.RUnlock()
-    if a := s.data[id]; a != nil { return a, nil }
-    return nil, errors.New("not found")
+    s.mu.Lock(); defer s.mu.Unlock()
+    a := s.data[id]
+    if a == nil { return nil, ErrNotFound }
+    s.expire(a, time.Now())
+    return clone(a), nil
 }
 
-func (s *MemStore) Approve(id string) (*Approval, error) {
+func (s *MemStore) Approve(id, approver string, roles []string) (*Approval, error) {
     s.mu.Lock(); defer s.mu.Unlock()
     a := s.data[id]
-    if a == nil { return nil, errors.New("not found") }
-    a.State = "approved"
-    a.UpdatedAt = time.Now()
-    return a, nil
+    if a == nil { return nil, ErrNotFound }
+    now := time.Now()
+    if s.expire(a, now) { return nil, ErrExpired }
+    if err := ApplyVote(a, approver, roles, now); err != nil { return nil, err }
+    s.record(voteEvent(a))
+    return clone(a), nil
 }
 
-func (s *MemStore) Reject(id, reason string) (*Approval, error) {
+func (s *MemStore) Reject(id, approver, reason string) (*Approval, error) {
     s.mu.Lock(); defer s.mu.Unlock()
     a := s.data[id]
-    if a == nil { return nil, errors.New("not found") }
-    a.State = "rejected"
-    a.Reason = reason
-    a.UpdatedAt = time.Now()
-    return a, nil
+    if a == nil { return nil, ErrNotFound }
+    now := time.Now()
+    if s.expire(a, now) { return nil, ErrExpired }
+    if err := ApplyReject(a, approver, reason, now); err != nil { return nil, err }
+    s.record(Event{ApprovalID: id, Action: ActionRejected, Actor: approver, State: a.State, Detail: reason, At: now})
+    return clone(a), nil
 }
 
-// NewPGStore / NewSQLiteStore placeholders: not implemented in this repo snapshot.
-func NewPGStore(_ string) (*MemStore, error)     { return nil, errors.New("pg store not available") }
-func NewSQLiteStore(_ string) (*MemStore, error) { return nil, errors.New("sqlite store not available") }
+func (s *MemStore) Complete(id string, out Outcome) (*Approval, error) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    a := s.data[id]
+    if a == nil { return nil, ErrNotFound }
+    if err := ApplyOutcome(a, out, time.Now()); err != nil { return nil, err }
+    s.record(outcomeEvent(a))
+    return clone(a), nil
+}
+
+func (s *MemStore) History(id string) ([]Event, error) {
+    s.mu.RLock(); defer s.mu.RUnlock()
+    if s.data[id] == nil { return nil, ErrNotFound }
+    return append([]Event(nil), s.history[id]...), nil
+}
@@ -0,0 +1,54 @@
+package approvals
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemStoreApproveThreshold(t *testing.T) {
+	s := NewMemStore()
+	a := &Approval{ID: "a1", Actor: "alice", FunctionID: "player.ban", Threshold: 2, ApproverRoles: []string{"admin"}, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.Create(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Approve("a1", "alice", []string{"admin"}); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("self approval: %v", err)
+	}
+	if _, err := s.Approve("a1", "bob", []string{"viewer"}); !errors.Is(err, ErrApproverRole) {
+		t.Fatalf("role check: %v", err)
+	}
+	got, err := s.Approve("a1", "bob", []string{"admin"})
+	if err != nil || got.State != StatePending {
+		t.Fatalf("first vote: %v %+v", err, got)
+	}
+	if _, err := s.Approve("a1", "bob", []string{"admin"}); !errors.Is(err, ErrDuplicateVote) {
+		t.Fatalf("duplicate vote: %v", err)
+	}
+	got, err = s.Approve("a1", "carol", []string{"Admin"})
+	if err != nil || got.State != StateApproved {
+		t.Fatalf("second vote: %v %+v", err, got)
+	}
+	if _, err := s.Approve("a1", "dave", []string{"admin"}); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("vote after approval: %v", err)
+	}
+	done, err := s.Complete("a1", Outcome{Result: []byte(`{"ok":true}`)})
+	if err != nil || done.State != StateExecuted {
+		t.Fatalf("complete: %v %+v", err, done)
+	}
+	if _, err := s.Complete("a1", Outcome{}); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("second complete: %v", err)
+	}
+}
+
+func TestMemStoreExpiry(t *testing.T) {
+	s := NewMemStore()
+	_ = s.Create(&Approval{ID: "a2", Actor: "alice", ExpiresAt: time.Now().Add(-time.Second)})
+	if _, err := s.Approve("a2", "bob", nil); !errors.Is(err, ErrExpired) {
+		t.Fatalf("approve expired: %v", err)
+	}
+	got, err := s.Get("a2")
+	if err != nil || got.State != StateExpired {
+		t.Fatalf("get expired: %v %+v", err, got)
+	}
+}
@@ -0,0 +1,51 @@
+package control
+
+import (
+	"context"
+
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// identity returns the certificate identity of the caller when identities are
+// required, failing unless it names agentID and is not revoked. It is nil otherwise.
+func (s *Server) identity(ctx context.Context, agentID string) (*agentcert.Identity, error) {
+	if !s.requireIdentity {
+		return nil, nil
+	}
+	cert, err := agentcert.PeerCertificate(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	id, err := agentcert.FromCertificate(cert)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if id.AgentID != agentID {
+		return nil, status.Errorf(codes.PermissionDenied, "certificate is issued to agent %q", id.AgentID)
+	}
+	if s.revoked != nil {
+		revoked, err := s.revoked.Revoked(cert)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "revocation list: %v", err)
+		}
+		if revoked {
+			return nil, status.Errorf(codes.PermissionDenied, "certificate of agent %q is revoked", id.AgentID)
+		}
+	}
+	return id, nil
+}
+
+// checkIdentity rejects registrations for an agent id or game the caller's
+// certificate does not grant.
+func (s *Server) checkIdentity(ctx context.Context, agentID, gameID, env string) error {
+	id, err := s.identity(ctx, agentID)
+	if err != nil || id == nil {
+		return err
+	}
+	if err := id.Check(agentID, gameID, env); err != nil {
+		return status.Errorf(codes.PermissionDenied, "certificate of agent %q does not cover game %q env %q", agentID, gameID, env)
+	}
+	return nil
+}
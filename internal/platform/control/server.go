@@ -4,46 +4,92 @@ import (
     "bytes"
     "compress/gzip"
     "context"
+    "crypto/rand"
+    "encoding/hex"
     "encoding/json"
+    "errors"
     "fmt"
     "io"
     "os"
     "time"
 
     reg "github.com/cuihairu/croupier/internal/platform/registry"
+    "github.com/cuihairu/croupier/internal/security/agentcert"
     serverv1 "github.com/cuihairu/croupier/pkg/pb/croupier/server/v1"
     commonv1 "github.com/cuihairu/croupier/pkg/pb/croupier/common/v1"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
     "google.golang.org/protobuf/types/known/emptypb"
 )
 
+// DefaultSessionTTL is how long an agent session lives without a heartbeat.
+const DefaultSessionTTL = 60 * time.Second
+
 // Server implements the ControlService and exposes a registry store for other components.
 type Server struct {
     serverv1.UnimplementedControlServiceServer
     reg *reg.Store
+    // requireIdentity binds registrations to the verified agent certificate.
+    requireIdentity bool
+    // revoked lists certificates refused even though they verify.
+    revoked    agentcert.Revocations
+    sessionTTL time.Duration
 }
 
 func NewServer(registry *reg.Store) *Server {
     if registry == nil {
         registry = reg.NewStore()
     }
-    return &Server{reg: registry}
+    return &Server{reg: registry, sessionTTL: DefaultSessionTTL}
+}
+
+// SetSessionTTL sets how long agent sessions live without a heartbeat.
+func (s *Server) SetSessionTTL(ttl time.Duration) {
+    if ttl > 0 { s.sessionTTL = ttl }
 }
 
+// RequireAgentIdentity makes the server accept only agents presenting a client
+// certificate whose identity matches the agent id and game they announce.
+func (s *Server) RequireAgentIdentity() { s.requireIdentity = true }
+
+// SetRevocations refuses agents whose certificate revoked lists; it applies to
+// every call that checks the agent identity.
+func (s *Server) SetRevocations(revoked agentcert.Revocations) { s.revoked = revoked }
+
 // Store returns the underlying registry Store (for function server / HTTP handlers).
 func (s *Server) Store() *reg.Store { return s.reg }
 
-// Register registers or updates an agent session. Minimal fields are accepted.
+// Register registers or updates an agent and starts a new session for it. The
+// session id must accompany every heartbeat; registering again replaces it.
 func (s *Server) Register(ctx context.Context, in *serverv1.RegisterRequest) (*serverv1.RegisterResponse, error) {
-    if in == nil { return &serverv1.RegisterResponse{}, nil }
+    if in == nil || in.GetAgentId() == "" {
+        return nil, status.Error(codes.InvalidArgument, "agent_id is required")
+    }
+    if err := s.checkIdentity(ctx, in.GetAgentId(), in.GetGameId(), in.GetEnv()); err != nil {
+        return nil, err
+    }
+    sessionID, err := newSessionID()
+    if err != nil {
+        return nil, status.Error(codes.Internal, err.Error())
+    }
+    now := time.Now()
+    labels := make(map[string]string, len(in.GetLabels()))
+    for k, v := range in.GetLabels() { labels[k] = v }
     sess := &reg.AgentSession{
         AgentID:  in.GetAgentId(),
         GameID:   in.GetGameId(),
         Env:      in.GetEnv(),
         RPCAddr:  in.GetRpcAddr(),
+        EdgeAddr: in.GetEdgeAddr(),
         Version:  in.GetVersion(),
-        // Region/Zone/Labels are not present in current proto; leave empty
-        ExpireAt: time.Now().Add(60 * time.Second),
+        Region:   in.GetRegion(),
+        Zone:     in.GetZone(),
+        Labels:   labels,
+        ExpireAt: now.Add(s.sessionTTL),
         Functions: map[string]reg.FunctionMeta{},
+        SessionID:    sessionID,
+        RegisteredAt: now,
+        LastSeen:     now,
     }
     // Populate functions from request descriptors (id -> enabled)
     if in.Functions != nil {
@@ -52,19 +98,38 @@ func (s *Server) Register(ctx context.Context, in *serverv1.RegisterRequest) (*s
             sess.Functions[f.GetId()] = reg.FunctionMeta{Enabled: f.GetEnabled()}
         }
     }
-    s.reg.UpsertAgent(sess)
-    return &serverv1.RegisterResponse{}, nil
+    if err := s.reg.UpsertAgent(sess); err != nil {
+        return nil, status.Errorf(codes.Unavailable, "registry: %v", err)
+    }
+    return &serverv1.RegisterResponse{SessionId: sessionID, ExpireAt: sess.ExpireAt.Unix()}, nil
 }
 
-// Heartbeat extends the expiry of an agent session.
+// Heartbeat extends the expiry of an agent session. Unknown, replaced or expired
+// sessions fail with NotFound so the agent registers again.
 func (s *Server) Heartbeat(ctx context.Context, in *serverv1.HeartbeatRequest) (*serverv1.HeartbeatResponse, error) {
-    if in == nil || in.GetAgentId() == "" { return &serverv1.HeartbeatResponse{}, nil }
-    s.reg.Mu().Lock()
-    if a := s.reg.AgentsUnsafe()[in.GetAgentId()]; a != nil {
-        a.ExpireAt = time.Now().Add(60 * time.Second)
+    if in == nil || in.GetAgentId() == "" {
+        return nil, status.Error(codes.InvalidArgument, "agent_id is required")
+    }
+    if _, err := s.identity(ctx, in.GetAgentId()); err != nil {
+        return nil, err
     }
-    s.reg.Mu().Unlock()
-    return &serverv1.HeartbeatResponse{}, nil
+    now := time.Now()
+    expireAt := now.Add(s.sessionTTL)
+    if err := s.reg.Heartbeat(in.GetAgentId(), in.GetSessionId(), now, expireAt); err != nil {
+        if errors.Is(err, reg.ErrUnknownSession) {
+            return nil, status.Error(codes.NotFound, err.Error())
+        }
+        return nil, status.Errorf(codes.Unavailable, "registry: %v", err)
+    }
+    return &serverv1.HeartbeatResponse{ExpireAt: expireAt.Unix()}, nil
+}
+
+func newSessionID() (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
 }
 
 // RegisterCapabilities handles provider manifest registration with language-agnostic declaration.
@@ -77,6 +142,10 @@ func (s *Server) RegisterCapabilities(ctx context.Context, in *serverv1.Register
     if provider == nil || provider.GetId() == "" {
         return &serverv1.RegisterCapabilitiesResponse{}, fmt.Errorf("provider metadata is required")
     }
+    // Only the agent holding the provider's certificate may replace its manifest.
+    if _, err := s.identity(ctx, provider.GetId()); err != nil {
+        return nil, err
+    }
 
     // Decompress the manifest JSON
     manifestData, err := s.decompressManifest(in.GetManifestJsonGz())
@@ -94,7 +163,9 @@ func (s *Server) RegisterCapabilities(ctx context.Context, in *serverv1.Register
         UpdatedAt: time.Now(),
     }
 
-    s.reg.UpsertProviderCaps(providerCaps)
+    if err := s.reg.UpsertProviderCaps(providerCaps); err != nil {
+        return &serverv1.RegisterCapabilitiesResponse{}, fmt.Errorf("failed to store provider capabilities: %w", err)
+    }
 
     return &serverv1.RegisterCapabilitiesResponse{}, nil
 }
@@ -0,0 +1,66 @@
+package control
+
+import (
+	"errors"
+
+	reg "github.com/cuihairu/croupier/internal/platform/registry"
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	serverv1 "github.com/cuihairu/croupier/pkg/pb/croupier/server/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Watch streams registry changes after the requested revision. With agent
+// identities required, agent and assignment events are limited to the games of
+// the caller's certificate; function and provider events are not game scoped.
+func (s *Server) Watch(in *serverv1.WatchRequest, stream serverv1.ControlService_WatchServer) error {
+	ctx := stream.Context()
+	var id *agentcert.Identity
+	if s.requireIdentity {
+		var err error
+		if id, err = agentcert.FromContext(ctx); err != nil {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+	types := map[string]bool{}
+	for _, t := range in.GetTypes() {
+		types[t] = true
+	}
+	events, err := s.reg.Watch(ctx, in.GetFromRevision())
+	if errors.Is(err, reg.ErrCompacted) {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	for ev := range events {
+		if len(types) > 0 && !types[string(ev.Type)] {
+			continue
+		}
+		if id != nil && ev.GameID != "" && !id.Allows(ev.GameID, ev.Env) {
+			continue
+		}
+		if err := stream.Send(watchEvent(ev)); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	// The watcher fell behind and events were dropped.
+	return status.Error(codes.OutOfRange, reg.ErrCompacted.Error())
+}
+
+// watchEvent converts a registry event for the wire.
+func watchEvent(ev reg.Event) *serverv1.WatchEvent {
+	return &serverv1.WatchEvent{
+		Revision:   ev.Revision,
+		Type:       string(ev.Type),
+		Ts:         ev.At.UnixMilli(),
+		AgentId:    ev.AgentID,
+		GameId:     ev.GameID,
+		Env:        ev.Env,
+		ProviderId: ev.ProviderID,
+		FunctionId: ev.FunctionID,
+	}
+}
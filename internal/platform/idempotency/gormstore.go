@@ -0,0 +1,141 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KeyRecord persists an idempotency key under ID. Done is false while the request
+// holding the key is still running.
+type KeyRecord struct {
+	ID          string `gorm:"primaryKey;size:512"`
+	Fingerprint string `gorm:"size:64"`
+	Done        bool
+	Unknown     bool
+	Payload     []byte
+	JobID       string    `gorm:"size:128"`
+	AgentID     string    `gorm:"size:128"`
+	TraceID     string    `gorm:"size:128"`
+	ApprovalID  string    `gorm:"size:64"`
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+// TableName returns the table name for KeyRecord model
+func (KeyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+// GormStore implements Store on gorm (Postgres or SQLite), so every server replica
+// sees the same keys and they survive restarts.
+type GormStore struct {
+	db  *gorm.DB
+	now func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+var _ Store = (*GormStore)(nil)
+
+// NewGormStore migrates the idempotency table and returns a store on gdb.
+func NewGormStore(gdb *gorm.DB) (*GormStore, error) {
+	if err := gdb.AutoMigrate(&KeyRecord{}); err != nil {
+		return nil, err
+	}
+	return &GormStore{db: gdb, now: time.Now}, nil
+}
+
+func (s *GormStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	now := s.now()
+	s.sweep(ctx, now)
+	db := s.db.WithContext(ctx)
+	if err := db.Where("id = ? AND expires_at <= ?", key, now).Delete(&KeyRecord{}).Error; err != nil {
+		return nil, err
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&KeyRecord{ID: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl), CreatedAt: now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil, nil
+	}
+	// Another request holds the key or completed it.
+	var r KeyRecord
+	if err := db.Where("id = ?", key).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInProgress
+		}
+		return nil, err
+	}
+	if r.Fingerprint != fingerprint {
+		return nil, ErrMismatch
+	}
+	if !r.Done {
+		return nil, ErrInProgress
+	}
+	return fromKeyRecord(&r), nil
+}
+
+func (s *GormStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	now := s.now()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	r := &KeyRecord{
+		ID:          key,
+		Fingerprint: rec.Fingerprint,
+		Done:        true,
+		Unknown:     rec.Unknown,
+		Payload:     rec.Payload,
+		JobID:       rec.JobID,
+		AgentID:     rec.AgentID,
+		TraceID:     rec.TraceID,
+		ApprovalID:  rec.ApprovalID,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   rec.CreatedAt,
+	}
+	cols := []string{"done", "unknown", "payload", "job_id", "agent_id", "trace_id", "approval_id", "expires_at", "created_at"}
+	if rec.Fingerprint != "" {
+		cols = append(cols, "fingerprint")
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns(cols),
+	}).Create(r).Error
+}
+
+func (s *GormStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("id = ? AND done = ?", key, false).Delete(&KeyRecord{}).Error
+}
+
+// sweep deletes expired keys at most once a minute.
+func (s *GormStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+	_ = s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&KeyRecord{}).Error
+}
+
+func fromKeyRecord(r *KeyRecord) *Record {
+	return &Record{
+		Fingerprint: r.Fingerprint,
+		Payload:     r.Payload,
+		JobID:       r.JobID,
+		AgentID:     r.AgentID,
+		TraceID:     r.TraceID,
+		ApprovalID:  r.ApprovalID,
+		Unknown:     r.Unknown,
+		CreatedAt:   r.CreatedAt,
+	}
+}
@@ -0,0 +1,59 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/db"
+)
+
+func TestGormStoreSharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	gdb, err := db.Open("file:" + filepath.Join(t.TempDir(), "idempotency.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewGormStore(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewGormStore(gdb)
+	key := Key("wallet.grant", "g1", "prod", "k1")
+
+	if rec, err := a.Reserve(ctx, key, "fp", time.Hour); err != nil || rec != nil {
+		t.Fatalf("first reserve = %v, %v", rec, err)
+	}
+	if _, err := b.Reserve(ctx, key, "fp", time.Hour); !errors.Is(err, ErrInProgress) {
+		t.Fatalf("duplicate on another replica err = %v", err)
+	}
+	if _, err := b.Reserve(ctx, key, "other", time.Hour); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("mismatched reuse err = %v", err)
+	}
+	if err := a.Complete(ctx, key, Record{Payload: []byte(`{"gems":1000}`), JobID: "j1"}, time.Hour); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	rec, err := b.Reserve(ctx, key, "fp", time.Hour)
+	if err != nil || rec == nil || string(rec.Payload) != `{"gems":1000}` || rec.JobID != "j1" || rec.Fingerprint != "fp" {
+		t.Fatalf("retry = %+v, %v", rec, err)
+	}
+	// A completed key is not released.
+	_ = b.Release(ctx, key)
+	if rec, _ := a.Reserve(ctx, key, "fp", time.Hour); rec == nil {
+		t.Fatal("release dropped a completed key")
+	}
+
+	k2 := Key("wallet.grant", "g1", "prod", "k2")
+	_, _ = a.Reserve(ctx, k2, "fp", time.Hour)
+	_ = a.Release(ctx, k2)
+	if rec, err := b.Reserve(ctx, k2, "fp", time.Hour); err != nil || rec != nil {
+		t.Fatalf("reserve after release = %v, %v", rec, err)
+	}
+
+	a.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if rec, err := a.Reserve(ctx, key, "fp", time.Hour); err != nil || rec != nil {
+		t.Fatalf("reserve after ttl = %v, %v", rec, err)
+	}
+}
@@ -0,0 +1,128 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInProgress is returned when another request holds the key.
+	ErrInProgress = errors.New("request with this idempotency key is in progress")
+	// ErrMismatch is returned when a key is reused with a different request.
+	ErrMismatch = errors.New("idempotency key reused with a different request")
+	// ErrOutcomeUnknown is returned for a key whose first request timed out or lost
+	// its agent, so it may or may not have taken effect.
+	ErrOutcomeUnknown = errors.New("outcome of the request with this idempotency key is unknown")
+)
+
+// Record is the cached outcome of a completed request.
+type Record struct {
+	Fingerprint string
+	Payload     []byte
+	JobID       string
+	AgentID     string
+	TraceID     string
+	// ApprovalID is set when the request was parked for two-person approval.
+	ApprovalID string
+	// Unknown marks a request that failed without telling whether it took effect.
+	Unknown   bool
+	CreatedAt time.Time
+}
+
+// Key builds the store key scoping an idempotency key to function, game and env.
+func Key(functionID, gameID, env, key string) string {
+	return strings.Join([]string{functionID, gameID, env, key}, "|")
+}
+
+// Store deduplicates requests by idempotency key.
+type Store interface {
+	// Reserve claims key for a new request. It returns the cached record when the key
+	// already completed, ErrInProgress while another request holds it, and ErrMismatch
+	// when the key was used with a different fingerprint.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error)
+	// Complete stores the outcome of the request holding key for ttl.
+	Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error
+	// Release drops a reservation so the request can be retried, e.g. after a failure.
+	Release(ctx context.Context, key string) error
+}
+
+type entry struct {
+	fingerprint string
+	rec         *Record
+	expireAt    time.Time
+}
+
+// MemStore is an in-memory Store; expired keys are swept lazily.
+type MemStore struct {
+	mu        sync.Mutex
+	data      map[string]*entry
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{data: map[string]*entry{}, now: time.Now}
+}
+
+func (m *MemStore) Reserve(_ context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweepLocked(now)
+	if e := m.data[key]; e != nil && now.Before(e.expireAt) {
+		if e.fingerprint != fingerprint {
+			return nil, ErrMismatch
+		}
+		if e.rec == nil {
+			return nil, ErrInProgress
+		}
+		cp := *e.rec
+		return &cp, nil
+	}
+	m.data[key] = &entry{fingerprint: fingerprint, expireAt: now.Add(ttl)}
+	return nil, nil
+}
+
+func (m *MemStore) Complete(_ context.Context, key string, rec Record, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	e := m.data[key]
+	if e == nil {
+		e = &entry{fingerprint: rec.Fingerprint}
+		m.data[key] = e
+	}
+	if rec.Fingerprint == "" {
+		rec.Fingerprint = e.fingerprint
+	}
+	e.rec = &rec
+	e.expireAt = now.Add(ttl)
+	return nil
+}
+
+func (m *MemStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e := m.data[key]; e != nil && e.rec == nil {
+		delete(m.data, key)
+	}
+	return nil
+}
+
+func (m *MemStore) sweepLocked(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for k, e := range m.data {
+		if !now.Before(e.expireAt) {
+			delete(m.data, k)
+		}
+	}
+}
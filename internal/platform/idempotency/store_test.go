@@ -0,0 +1,45 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemStoreReserveCompleteRelease(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemStore()
+	key := Key("wallet.grant", "g1", "prod", "k1")
+
+	if rec, err := m.Reserve(ctx, key, "fp", time.Hour); err != nil || rec != nil {
+		t.Fatalf("first reserve = %v, %v", rec, err)
+	}
+	if _, err := m.Reserve(ctx, key, "fp", time.Hour); !errors.Is(err, ErrInProgress) {
+		t.Fatalf("concurrent duplicate err = %v", err)
+	}
+	if _, err := m.Reserve(ctx, key, "other", time.Hour); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("mismatched reuse err = %v", err)
+	}
+	if err := m.Complete(ctx, key, Record{Payload: []byte(`{"gems":1000}`)}, time.Hour); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	rec, err := m.Reserve(ctx, key, "fp", time.Hour)
+	if err != nil || rec == nil || string(rec.Payload) != `{"gems":1000}` {
+		t.Fatalf("retry = %v, %v", rec, err)
+	}
+
+	// A released reservation can be claimed again.
+	k2 := Key("wallet.grant", "g1", "prod", "k2")
+	_, _ = m.Reserve(ctx, k2, "fp", time.Hour)
+	_ = m.Release(ctx, k2)
+	if rec, err := m.Reserve(ctx, k2, "fp", time.Hour); err != nil || rec != nil {
+		t.Fatalf("reserve after release = %v, %v", rec, err)
+	}
+
+	// Expired keys run again.
+	m.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if rec, err := m.Reserve(ctx, key, "fp", time.Hour); err != nil || rec != nil {
+		t.Fatalf("reserve after ttl = %v, %v", rec, err)
+	}
+}
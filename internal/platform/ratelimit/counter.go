@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Counter counts requests per bucket in one-second windows.
+type Counter interface {
+	// Incr adds one request to bucket in the window starting at unix second sec and
+	// returns the window total.
+	Incr(ctx context.Context, bucket string, sec int64) (int64, error)
+	// Decr takes back a request counted by Incr in the same window.
+	Decr(ctx context.Context, bucket string, sec int64) error
+}
+
+type window struct {
+	sec int64
+	n   int64
+}
+
+// MemCounter keeps windows in memory for a single replica.
+type MemCounter struct {
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+func NewMemCounter() *MemCounter { return &MemCounter{windows: map[string]*window{}} }
+
+func (c *MemCounter) Incr(_ context.Context, bucket string, sec int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.windows[bucket]
+	if w == nil {
+		if len(c.windows) > 4096 {
+			c.sweep(sec)
+		}
+		w = &window{}
+		c.windows[bucket] = w
+	}
+	if w.sec != sec {
+		w.sec, w.n = sec, 0
+	}
+	w.n++
+	return w.n, nil
+}
+
+func (c *MemCounter) Decr(_ context.Context, bucket string, sec int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w := c.windows[bucket]; w != nil && w.sec == sec && w.n > 0 {
+		w.n--
+	}
+	return nil
+}
+
+func (c *MemCounter) sweep(sec int64) {
+	for k, w := range c.windows {
+		if w.sec < sec {
+			delete(c.windows, k)
+		}
+	}
+}
+
+// RedisCounter shares windows between replicas through Redis.
+type RedisCounter struct {
+	rdb    redis.UniversalClient
+	prefix string
+}
+
+// NewRedisCounter creates a counter on rdb; keys are prefixed with prefix.
+func NewRedisCounter(rdb redis.UniversalClient, prefix string) *RedisCounter {
+	if prefix == "" {
+		prefix = "croupier:ratelimit:"
+	}
+	return &RedisCounter{rdb: rdb, prefix: prefix}
+}
+
+func (c *RedisCounter) Incr(ctx context.Context, bucket string, sec int64) (int64, error) {
+	key := c.prefix + bucket + ":" + strconv.FormatInt(sec, 10)
+	pipe := c.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 2*time.Second)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (c *RedisCounter) Decr(ctx context.Context, bucket string, sec int64) error {
+	return c.rdb.Decr(ctx, c.prefix+bucket+":"+strconv.FormatInt(sec, 10)).Err()
+}
@@ -0,0 +1,282 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule scopes. A rule applies to requests whose scope value equals Key; the key "*"
+// gives every distinct value its own budget.
+const (
+	ScopeFunction = "function" // key: function id
+	ScopeService  = "service"  // key: agent id
+	ScopeGame     = "game"     // key: game id or "game/env"
+	ScopeActor    = "actor"    // key: user name
+)
+
+// Wildcard makes a rule apply per distinct scope value.
+const Wildcard = "*"
+
+// Rule limits matching requests to LimitQPS per second. Match restricts the rule to
+// requests carrying all listed labels. Percent rolls the rule out gradually: service
+// rules scale their QPS, other rules apply to that share of requests sampled by trace.
+type Rule struct {
+	Scope    string
+	Key      string
+	LimitQPS int
+	Match    map[string]string
+	Percent  int
+}
+
+// Request describes an invocation to be checked.
+type Request struct {
+	FunctionID string
+	AgentID    string
+	GameID     string
+	Env        string
+	Actor      string
+	TraceID    string
+	// Labels are matched against Rule.Match, e.g. region/zone of the agent.
+	Labels map[string]string
+}
+
+func (r Request) label(name string) (string, bool) {
+	switch name {
+	case "function_id":
+		return r.FunctionID, r.FunctionID != ""
+	case "agent_id":
+		return r.AgentID, r.AgentID != ""
+	case "game_id":
+		return r.GameID, r.GameID != ""
+	case "env":
+		return r.Env, r.Env != ""
+	case "actor":
+		return r.Actor, r.Actor != ""
+	}
+	v, ok := r.Labels[name]
+	return v, ok
+}
+
+// Decision is the outcome of Allow. When Allowed is false, Rule is the rule that was
+// exceeded and RetryAfter the time until its window resets.
+type Decision struct {
+	Allowed    bool
+	Rule       Rule
+	RetryAfter time.Duration
+}
+
+// Stat holds live counters of a rule on this replica.
+type Stat struct {
+	Scope    string
+	Key      string
+	Allowed  int64
+	Rejected int64
+	// QPS is the number of requests the rule saw in the last full second.
+	QPS int64
+}
+
+type ruleStat struct {
+	allowed, rejected int64
+	sec, cur, last    int64
+}
+
+// Limiter applies rules to requests using fixed one-second windows kept in a Counter,
+// so replicas sharing a Redis counter share their budgets.
+type Limiter struct {
+	counter Counter
+	now     func() time.Time
+
+	mu    sync.RWMutex
+	rules []Rule
+	stats map[string]*ruleStat
+}
+
+// New creates a limiter; a nil counter keeps windows in memory.
+func New(counter Counter) *Limiter {
+	if counter == nil {
+		counter = NewMemCounter()
+	}
+	return &Limiter{counter: counter, now: time.Now, stats: map[string]*ruleStat{}}
+}
+
+// SetRules replaces the active rules. Counters of rules that remain are kept.
+func (l *Limiter) SetRules(rules []Rule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = append([]Rule(nil), rules...)
+	stats := make(map[string]*ruleStat, len(rules))
+	for _, r := range rules {
+		id := ruleID(r)
+		if st := l.stats[id]; st != nil {
+			stats[id] = st
+		} else {
+			stats[id] = &ruleStat{}
+		}
+	}
+	l.stats = stats
+}
+
+// Allow counts req against every matching rule and rejects it when any of them is over
+// its limit. A rejected request is taken back from the rules it was counted in, so it
+// uses up no budget. Counter failures let the request through.
+func (l *Limiter) Allow(ctx context.Context, req Request) (Decision, error) {
+	l.mu.RLock()
+	rules := l.rules
+	l.mu.RUnlock()
+	now := l.now()
+	sec := now.Unix()
+	var firstErr error
+	var counted []Rule
+	var buckets []string
+	for _, r := range rules {
+		value, ok := scopeValue(r, req)
+		if !ok || !matches(r, req) || !sampled(r, req) {
+			continue
+		}
+		limit := effectiveLimit(r)
+		if limit <= 0 {
+			continue
+		}
+		b := bucket(r, value)
+		n, err := l.counter.Incr(ctx, b, sec)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if n > int64(limit) {
+			for _, b := range append(buckets, b) {
+				if err := l.counter.Decr(ctx, b, sec); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			l.record(r, sec, false)
+			retry := time.Unix(sec+1, 0).Sub(now)
+			return Decision{Allowed: false, Rule: r, RetryAfter: retry}, firstErr
+		}
+		counted = append(counted, r)
+		buckets = append(buckets, b)
+	}
+	for _, r := range counted {
+		l.record(r, sec, true)
+	}
+	return Decision{Allowed: true}, firstErr
+}
+
+func (l *Limiter) record(r Rule, sec int64, allowed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.stats[ruleID(r)]
+	if st == nil {
+		return
+	}
+	if allowed {
+		st.allowed++
+	} else {
+		st.rejected++
+	}
+	st.roll(sec)
+	st.cur++
+}
+
+func (st *ruleStat) roll(sec int64) {
+	if st.sec == sec {
+		return
+	}
+	if st.sec == sec-1 {
+		st.last = st.cur
+	} else {
+		st.last = 0
+	}
+	st.sec, st.cur = sec, 0
+}
+
+// Stats returns counters of the active rules ordered by scope and key.
+func (l *Limiter) Stats() []Stat {
+	sec := l.now().Unix()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Stat, 0, len(l.rules))
+	for _, r := range l.rules {
+		st := l.stats[ruleID(r)]
+		if st == nil {
+			continue
+		}
+		st.roll(sec)
+		out = append(out, Stat{Scope: r.Scope, Key: r.Key, Allowed: st.allowed, Rejected: st.rejected, QPS: st.last})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Scope != out[j].Scope {
+			return out[i].Scope < out[j].Scope
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func ruleID(r Rule) string { return r.Scope + "|" + r.Key }
+
+func bucket(r Rule, value string) string { return r.Scope + "|" + r.Key + "|" + value }
+
+// scopeValue returns the request value the rule is keyed on and whether the rule
+// applies to the request.
+func scopeValue(r Rule, req Request) (string, bool) {
+	var value string
+	switch strings.ToLower(r.Scope) {
+	case ScopeFunction:
+		value = req.FunctionID
+	case ScopeService:
+		value = req.AgentID
+	case ScopeGame:
+		value = req.GameID
+		if r.Key != Wildcard && strings.Contains(r.Key, "/") {
+			value = req.GameID + "/" + req.Env
+		}
+	case ScopeActor:
+		value = req.Actor
+	default:
+		return "", false
+	}
+	if value == "" {
+		return "", false
+	}
+	if r.Key != Wildcard && r.Key != value {
+		return "", false
+	}
+	return value, true
+}
+
+func matches(r Rule, req Request) bool {
+	for k, want := range r.Match {
+		if have, ok := req.label(k); !ok || have != want {
+			return false
+		}
+	}
+	return true
+}
+
+// sampled reports whether req falls into the rollout share of a non-service rule.
+func sampled(r Rule, req Request) bool {
+	if r.Percent <= 0 || r.Percent >= 100 || strings.EqualFold(r.Scope, ScopeService) {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(req.TraceID))
+	return int(h.Sum32()%100) < r.Percent
+}
+
+// effectiveLimit scales service rules by their rollout percent.
+func effectiveLimit(r Rule) int {
+	if !strings.EqualFold(r.Scope, ScopeService) || r.Percent <= 0 || r.Percent >= 100 {
+		return r.LimitQPS
+	}
+	if eff := r.LimitQPS * r.Percent / 100; eff > 0 {
+		return eff
+	}
+	return 1
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterScopesAndMatch(t *testing.T) {
+	l := New(nil)
+	now := time.Unix(1700000000, 250*int64(time.Millisecond))
+	l.now = func() time.Time { return now }
+	l.SetRules([]Rule{
+		{Scope: ScopeFunction, Key: "player.ban", LimitQPS: 2, Match: map[string]string{"game_id": "g1"}},
+		{Scope: ScopeActor, Key: Wildcard, LimitQPS: 3},
+	})
+	ctx := context.Background()
+	req := Request{FunctionID: "player.ban", GameID: "g1", Actor: "alice"}
+	for i := 0; i < 2; i++ {
+		if d, _ := l.Allow(ctx, req); !d.Allowed {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	d, _ := l.Allow(ctx, req)
+	if d.Allowed || d.Rule.Scope != ScopeFunction || d.RetryAfter != 750*time.Millisecond {
+		t.Fatalf("expected function rule to reject with 750ms retry, got %+v", d)
+	}
+	// Another game does not match the function rule; the per-actor budget still applies.
+	other := Request{FunctionID: "player.ban", GameID: "g2", Actor: "bob"}
+	for i := 0; i < 3; i++ {
+		if d, _ := l.Allow(ctx, other); !d.Allowed {
+			t.Fatalf("bob request %d rejected", i)
+		}
+	}
+	if d, _ := l.Allow(ctx, other); d.Allowed || d.Rule.Scope != ScopeActor {
+		t.Fatalf("expected actor rule to reject, got %+v", d)
+	}
+	// A new window resets the budgets.
+	now = now.Add(time.Second)
+	if d, _ := l.Allow(ctx, req); !d.Allowed {
+		t.Fatalf("request in next window rejected: %+v", d)
+	}
+	stats := l.Stats()
+	if len(stats) != 2 || stats[1].Scope != ScopeFunction || stats[1].Rejected != 1 || stats[1].QPS != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLimiterServicePercentScalesQPS(t *testing.T) {
+	l := New(nil)
+	l.now = func() time.Time { return time.Unix(1700000000, 0) }
+	l.SetRules([]Rule{{Scope: ScopeService, Key: "agent-1", LimitQPS: 10, Percent: 20}})
+	ctx := context.Background()
+	req := Request{FunctionID: "f", AgentID: "agent-1"}
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if d, _ := l.Allow(ctx, req); d.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Fatalf("allowed %d requests, want 2", allowed)
+	}
+	if d, _ := l.Allow(ctx, Request{FunctionID: "f", AgentID: "agent-2"}); !d.Allowed {
+		t.Fatal("other agent should not be limited")
+	}
+}
+
+func TestLimiterRejectedRequestUsesNoBudget(t *testing.T) {
+	l := New(nil)
+	l.now = func() time.Time { return time.Unix(1700000000, 0) }
+	l.SetRules([]Rule{
+		{Scope: ScopeActor, Key: Wildcard, LimitQPS: 3},
+		{Scope: ScopeFunction, Key: "player.ban", LimitQPS: 1},
+	})
+	ctx := context.Background()
+	ban := Request{FunctionID: "player.ban", Actor: "alice"}
+	if d, _ := l.Allow(ctx, ban); !d.Allowed {
+		t.Fatalf("first ban rejected: %+v", d)
+	}
+	// Rejected by the function rule, these must not count against alice.
+	for i := 0; i < 5; i++ {
+		if d, _ := l.Allow(ctx, ban); d.Allowed || d.Rule.Scope != ScopeFunction {
+			t.Fatalf("ban %d: expected function rule to reject, got %+v", i, d)
+		}
+	}
+	kick := Request{FunctionID: "player.kick", Actor: "alice"}
+	for i := 0; i < 2; i++ {
+		if d, _ := l.Allow(ctx, kick); !d.Allowed {
+			t.Fatalf("kick %d rejected: %+v", i, d)
+		}
+	}
+	if d, _ := l.Allow(ctx, kick); d.Allowed || d.Rule.Scope != ScopeActor {
+		t.Fatalf("expected actor rule to reject, got %+v", d)
+	}
+	for _, st := range l.Stats() {
+		if st.Scope == ScopeActor && (st.Allowed != 3 || st.Rejected != 1) {
+			t.Fatalf("actor stats %+v, want 3 allowed and 1 rejected", st)
+		}
+	}
+}
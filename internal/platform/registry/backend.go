@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// backendTimeout bounds each backend call made on behalf of an agent or handler.
+const backendTimeout = 5 * time.Second
+
+// Backend shares agent sessions and provider capabilities between server
+// replicas, so that an invocation routed by any replica finds the agent that
+// registered with another.
+type Backend interface {
+	// PutAgent stores a, replacing any previous session of the agent.
+	PutAgent(ctx context.Context, a *AgentSession) error
+	// TouchAgent extends the live session sessionID of agentID to expireAt. It
+	// fails with ErrUnknownSession for replaced or expired sessions.
+	TouchAgent(ctx context.Context, agentID, sessionID string, now, expireAt time.Time) error
+	// DeleteExpired removes agentID if its session expired before now. It
+	// reports whether this call removed it, so only one replica announces it.
+	DeleteExpired(ctx context.Context, agentID string, now time.Time) (bool, error)
+	ListAgents(ctx context.Context) ([]*AgentSession, error)
+	PutProviderCaps(ctx context.Context, c ProviderCaps) error
+	ListProviderCaps(ctx context.Context) ([]ProviderCaps, error)
+	// Watch returns a channel signalled after other replicas change the
+	// registry until ctx is done, or nil if the backend can only be polled.
+	Watch(ctx context.Context) <-chan struct{}
+	Close() error
+}
+
+// NewSharedStore returns a store writing through to b. Run keeps it in step
+// with the changes other replicas make.
+func NewSharedStore(b Backend) *Store {
+	s := NewStore()
+	s.backend = b
+	return s
+}
+
+func backendContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), backendTimeout)
+}
+
+// Sync replaces the local view with the backend state.
+func (s *Store) Sync(ctx context.Context) error {
+	if s.backend == nil {
+		return nil
+	}
+	agents, err := s.backend.ListAgents(ctx)
+	if err != nil {
+		return err
+	}
+	caps, err := s.backend.ListProviderCaps(ctx)
+	if err != nil {
+		return err
+	}
+	am := make(map[string]*AgentSession, len(agents))
+	for _, a := range agents {
+		am[a.AgentID] = a
+	}
+	cm := make(map[string]ProviderCaps, len(caps))
+	for _, c := range caps {
+		cm[c.ID] = c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, a := range am {
+		if cur := s.agents[id]; cur == nil || cur.SessionID != a.SessionID {
+			s.emit(agentEvent(EventAgentUpserted, a))
+		}
+	}
+	for id, a := range s.agents {
+		if am[id] == nil {
+			s.emit(agentEvent(EventAgentExpired, a))
+		}
+	}
+	for id, c := range cm {
+		if cur, ok := s.provCaps[id]; !ok || !cur.UpdatedAt.Equal(c.UpdatedAt) {
+			s.emit(Event{Type: EventProviderCapsChanged, ProviderID: id})
+		}
+	}
+	s.agents, s.provCaps = am, cm
+	s.functionsChangedLocked()
+	return nil
+}
+
+// Run syncs the store whenever the backend reports a change, and every
+// interval in case a notification was missed, until ctx is done. It returns
+// at once for stores without a backend.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	if s.backend == nil {
+		return
+	}
+	changes := s.backend.Watch(ctx)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		sctx, cancel := context.WithTimeout(ctx, backendTimeout)
+		if err := s.Sync(sctx); err != nil && ctx.Err() == nil {
+			slog.Error("registry: sync", "error", err)
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-changes:
+		}
+	}
+}
+
+// MemBackend is a Backend in process memory. Stores sharing one behave like
+// replicas sharing Redis or a database.
+type MemBackend struct {
+	mu       sync.Mutex
+	agents   map[string]AgentSession
+	provCaps map[string]ProviderCaps
+	watchers map[chan struct{}]struct{}
+}
+
+func NewMemBackend() *MemBackend {
+	return &MemBackend{
+		agents:   map[string]AgentSession{},
+		provCaps: map[string]ProviderCaps{},
+		watchers: map[chan struct{}]struct{}{},
+	}
+}
+
+func (b *MemBackend) PutAgent(_ context.Context, a *AgentSession) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.agents[a.AgentID] = *a
+	b.notify()
+	return nil
+}
+
+func (b *MemBackend) TouchAgent(_ context.Context, agentID, sessionID string, now, expireAt time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, ok := b.agents[agentID]
+	if !ok || a.SessionID != sessionID || (!a.ExpireAt.IsZero() && now.After(a.ExpireAt)) {
+		return ErrUnknownSession
+	}
+	a.ExpireAt, a.LastSeen = expireAt, now
+	b.agents[agentID] = a
+	return nil
+}
+
+func (b *MemBackend) DeleteExpired(_ context.Context, agentID string, now time.Time) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, ok := b.agents[agentID]
+	if !ok || a.ExpireAt.IsZero() || !now.After(a.ExpireAt) {
+		return false, nil
+	}
+	delete(b.agents, agentID)
+	b.notify()
+	return true, nil
+}
+
+func (b *MemBackend) ListAgents(context.Context) ([]*AgentSession, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]*AgentSession, 0, len(b.agents))
+	for _, a := range b.agents {
+		a := a
+		out = append(out, &a)
+	}
+	return out, nil
+}
+
+func (b *MemBackend) PutProviderCaps(_ context.Context, c ProviderCaps) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.provCaps[c.ID] = c
+	b.notify()
+	return nil
+}
+
+func (b *MemBackend) ListProviderCaps(context.Context) ([]ProviderCaps, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]ProviderCaps, 0, len(b.provCaps))
+	for _, c := range b.provCaps {
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (b *MemBackend) Watch(ctx context.Context) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.watchers[ch] = struct{}{}
+	b.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.watchers, ch)
+		b.mu.Unlock()
+	}()
+	return ch
+}
+
+func (b *MemBackend) Close() error { return nil }
+
+// notify signals every watcher without blocking; callers hold b.mu.
+func (b *MemBackend) notify() {
+	for ch := range b.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
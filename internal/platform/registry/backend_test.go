@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSharedStoreReplicas(t *testing.T) {
+	b := NewMemBackend()
+	r1, r2 := NewSharedStore(b), NewSharedStore(b)
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := r1.UpsertAgent(&AgentSession{AgentID: "a1", SessionID: "s1", RPCAddr: "10.0.0.1:9000", ExpireAt: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a := r2.AgentsUnsafe()["a1"]; a == nil || a.RPCAddr != "10.0.0.1:9000" {
+		t.Fatalf("replica 2 sees %+v", a)
+	}
+
+	// Heartbeats may land on any replica; stale sessions are refused by all.
+	if err := r2.Heartbeat("a1", "s1", now.Add(50*time.Second), now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("heartbeat via replica 2: %v", err)
+	}
+	if err := r1.Heartbeat("a1", "old", now, now.Add(time.Minute)); err != ErrUnknownSession {
+		t.Fatalf("stale heartbeat = %v, want ErrUnknownSession", err)
+	}
+
+	// Replica 1 still holds the old expiry; it must not evict the live agent.
+	if gone := r1.EvictExpired(now.Add(90 * time.Second)); len(gone) != 0 {
+		t.Fatalf("evicted live agent: %+v", gone)
+	}
+	if _, ok := r1.AgentsUnsafe()["a1"]; !ok {
+		t.Fatal("replica 1 lost the live agent")
+	}
+
+	// Once it expires, exactly one replica announces it.
+	later := now.Add(3 * time.Minute)
+	n := len(r1.EvictExpired(later)) + len(r2.EvictExpired(later))
+	if n != 1 {
+		t.Fatalf("agent announced offline %d times, want 1", n)
+	}
+}
+
+func TestSharedStoreFollowsChanges(t *testing.T) {
+	b := NewMemBackend()
+	r1, r2 := NewSharedStore(b), NewSharedStore(b)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r2.Run(ctx, time.Hour)
+
+	if err := r1.UpsertProviderCaps(ProviderCaps{ID: "p1", Manifest: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r2.ListProviderCaps()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("replica 2 did not pick up the change")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,210 @@
+package registry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisGrace keeps agent keys this long past their session expiry, so that a
+// replica evicts the agent and announces it offline before Redis drops it. The
+// key TTL only matters when no replica is left to do so.
+const redisGrace = time.Minute
+
+// Each agent is a hash with the session fields kept apart from the JSON of the
+// rest, so heartbeats and eviction can check and update them atomically.
+var (
+	touchScript = redis.NewScript(`
+local h = redis.call('HMGET', KEYS[1], 'session', 'expire')
+if not h[1] or h[1] ~= ARGV[1] then return 0 end
+local exp = tonumber(h[2]) or 0
+if exp > 0 and tonumber(ARGV[2]) > exp then return 0 end
+redis.call('HSET', KEYS[1], 'expire', ARGV[3], 'seen', ARGV[2])
+redis.call('PEXPIREAT', KEYS[1], ARGV[4])
+return 1`)
+	evictScript = redis.NewScript(`
+local exp = tonumber(redis.call('HGET', KEYS[1], 'expire') or '0') or 0
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  redis.call('SREM', KEYS[2], ARGV[2])
+  return 0
+end
+if exp == 0 or tonumber(ARGV[1]) <= exp then return 0 end
+redis.call('DEL', KEYS[1])
+redis.call('SREM', KEYS[2], ARGV[2])
+return 1`)
+)
+
+// RedisBackend shares the registry through Redis: a hash per agent expiring
+// with its session, a set indexing them, a hash of provider capabilities and a
+// pub/sub channel announcing changes. With Redis Cluster, use a prefix with a
+// hash tag such as "{croupier:registry}:" so the scripts' keys share a slot.
+type RedisBackend struct {
+	rdb      redis.UniversalClient
+	prefix   string
+	instance string
+}
+
+// NewRedisBackend creates a backend on rdb; keys are prefixed with prefix.
+func NewRedisBackend(rdb redis.UniversalClient, prefix string) *RedisBackend {
+	if prefix == "" {
+		prefix = "croupier:registry:"
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return &RedisBackend{rdb: rdb, prefix: prefix, instance: hex.EncodeToString(b)}
+}
+
+func (r *RedisBackend) agentKey(id string) string { return r.prefix + "agent:" + id }
+func (r *RedisBackend) indexKey() string          { return r.prefix + "agents" }
+func (r *RedisBackend) providersKey() string      { return r.prefix + "providers" }
+func (r *RedisBackend) channel() string           { return r.prefix + "changes" }
+
+func (r *RedisBackend) PutAgent(ctx context.Context, a *AgentSession) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	key := r.agentKey(a.AgentID)
+	pipe := r.rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "data", data, "session", a.SessionID, "expire", unixMilli(a.ExpireAt), "seen", unixMilli(a.LastSeen))
+	if !a.ExpireAt.IsZero() {
+		pipe.PExpireAt(ctx, key, a.ExpireAt.Add(redisGrace))
+	}
+	pipe.SAdd(ctx, r.indexKey(), a.AgentID)
+	pipe.Publish(ctx, r.channel(), r.instance)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisBackend) TouchAgent(ctx context.Context, agentID, sessionID string, now, expireAt time.Time) error {
+	ok, err := touchScript.Run(ctx, r.rdb, []string{r.agentKey(agentID)},
+		sessionID, unixMilli(now), unixMilli(expireAt), unixMilli(expireAt.Add(redisGrace))).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrUnknownSession
+	}
+	return nil
+}
+
+func (r *RedisBackend) DeleteExpired(ctx context.Context, agentID string, now time.Time) (bool, error) {
+	ok, err := evictScript.Run(ctx, r.rdb, []string{r.agentKey(agentID), r.indexKey()}, unixMilli(now), agentID).Int()
+	if err != nil || ok == 0 {
+		return false, err
+	}
+	return true, r.rdb.Publish(ctx, r.channel(), r.instance).Err()
+}
+
+func (r *RedisBackend) ListAgents(ctx context.Context) ([]*AgentSession, error) {
+	ids, err := r.rdb.SMembers(ctx, r.indexKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HMGet(ctx, r.agentKey(id), "data", "expire", "seen")
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]*AgentSession, 0, len(ids))
+	for _, cmd := range cmds {
+		v := cmd.Val()
+		data, _ := v[0].(string)
+		if data == "" {
+			// Dropped by its TTL; eviction cleans up the index.
+			continue
+		}
+		var a AgentSession
+		if err := json.Unmarshal([]byte(data), &a); err != nil {
+			continue
+		}
+		a.ExpireAt = fromUnixMilli(v[1])
+		a.LastSeen = fromUnixMilli(v[2])
+		out = append(out, &a)
+	}
+	return out, nil
+}
+
+func (r *RedisBackend) PutProviderCaps(ctx context.Context, c ProviderCaps) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	pipe := r.rdb.TxPipeline()
+	pipe.HSet(ctx, r.providersKey(), c.ID, data)
+	pipe.Publish(ctx, r.channel(), r.instance)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisBackend) ListProviderCaps(ctx context.Context) ([]ProviderCaps, error) {
+	m, err := r.rdb.HGetAll(ctx, r.providersKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ProviderCaps, 0, len(m))
+	for _, v := range m {
+		var c ProviderCaps
+		if err := json.Unmarshal([]byte(v), &c); err == nil {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// Watch subscribes to the change channel, skipping this backend's own messages.
+func (r *RedisBackend) Watch(ctx context.Context) <-chan struct{} {
+	out := make(chan struct{}, 1)
+	sub := r.rdb.Subscribe(ctx, r.channel())
+	go func() {
+		defer sub.Close()
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-msgs:
+				if !ok {
+					return
+				}
+				if m.Payload == r.instance {
+					continue
+				}
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func (r *RedisBackend) Close() error { return r.rdb.Close() }
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(v any) time.Time {
+	s, _ := v.(string)
+	ms, _ := strconv.ParseInt(s, 10, 64)
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrUnknownSession is returned for heartbeats of sessions the registry does
+// not hold: never issued, replaced by a newer registration, or expired.
+var ErrUnknownSession = errors.New("unknown or expired agent session")
+
+// Heartbeat extends the session of agentID to expireAt. It fails with
+// ErrUnknownSession unless sessionID is the agent's live session. On a shared
+// store the backend decides, since the agent may have registered elsewhere.
+func (s *Store) Heartbeat(agentID, sessionID string, now, expireAt time.Time) error {
+	if sessionID == "" {
+		return ErrUnknownSession
+	}
+	if s.backend != nil {
+		ctx, cancel := backendContext()
+		defer cancel()
+		if err := s.backend.TouchAgent(ctx, agentID, sessionID, now, expireAt); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.agents[agentID]
+	if s.backend != nil {
+		// An agent registered through another replica arrives with the next sync.
+		if a != nil && a.SessionID == sessionID {
+			a.ExpireAt = expireAt
+			a.LastSeen = now
+		}
+		return nil
+	}
+	if a == nil || a.SessionID != sessionID || (!a.ExpireAt.IsZero() && now.After(a.ExpireAt)) {
+		return ErrUnknownSession
+	}
+	a.ExpireAt = expireAt
+	a.LastSeen = now
+	return nil
+}
+
+// EvictExpired removes agents whose session expired before now and returns them.
+// On a shared store only the replica whose delete wins returns an agent, and
+// agents another replica kept alive are refreshed instead of removed.
+func (s *Store) EvictExpired(now time.Time) []*AgentSession {
+	s.mu.RLock()
+	var expired []*AgentSession
+	for _, a := range s.agents {
+		if !a.ExpireAt.IsZero() && now.After(a.ExpireAt) {
+			expired = append(expired, a)
+		}
+	}
+	s.mu.RUnlock()
+	if len(expired) == 0 {
+		return nil
+	}
+	out := expired
+	if s.backend != nil {
+		ctx, cancel := backendContext()
+		defer cancel()
+		out = nil
+		for _, a := range expired {
+			ok, err := s.backend.DeleteExpired(ctx, a.AgentID, now)
+			if err != nil {
+				slog.Error("registry: evict agent", "agent_id", a.AgentID, "error", err)
+			}
+			if ok {
+				out = append(out, a)
+			}
+		}
+		if len(out) < len(expired) {
+			defer func() {
+				if err := s.Sync(ctx); err != nil {
+					slog.Error("registry: sync after eviction", "error", err)
+				}
+			}()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := out[:0]
+	for _, a := range out {
+		if cur := s.agents[a.AgentID]; cur != nil && cur.SessionID == a.SessionID {
+			delete(s.agents, a.AgentID)
+			s.emit(agentEvent(EventAgentExpired, a))
+		} else if s.backend == nil {
+			// Registered again or evicted by another caller meanwhile.
+			continue
+		}
+		removed = append(removed, a)
+	}
+	s.functionsChangedLocked()
+	return removed
+}
+
+// RunEviction evicts expired agents every interval until ctx is done, passing
+// each to offline.
+func (s *Store) RunEviction(ctx context.Context, interval time.Duration, offline func(*AgentSession)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			for _, a := range s.EvictExpired(now) {
+				if offline != nil {
+					offline(a)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeatRequiresLiveSession(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+	s.UpsertAgent(&AgentSession{AgentID: "a1", SessionID: "s1", ExpireAt: now.Add(time.Minute)})
+
+	if err := s.Heartbeat("a1", "s1", now, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	for _, c := range []struct{ agent, session string }{{"a1", "old"}, {"a1", ""}, {"a2", "s1"}} {
+		if err := s.Heartbeat(c.agent, c.session, now, now.Add(time.Minute)); err != ErrUnknownSession {
+			t.Errorf("heartbeat %s/%q = %v, want ErrUnknownSession", c.agent, c.session, err)
+		}
+	}
+	if err := s.Heartbeat("a1", "s1", now.Add(3*time.Minute), now.Add(4*time.Minute)); err != ErrUnknownSession {
+		t.Errorf("heartbeat after expiry = %v, want ErrUnknownSession", err)
+	}
+}
+
+func TestEvictExpired(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+	s.UpsertAgent(&AgentSession{AgentID: "dead", ExpireAt: now.Add(-time.Second)})
+	s.UpsertAgent(&AgentSession{AgentID: "live", ExpireAt: now.Add(time.Minute)})
+
+	gone := s.EvictExpired(now)
+	if len(gone) != 1 || gone[0].AgentID != "dead" {
+		t.Fatalf("evicted %+v, want dead", gone)
+	}
+	if _, ok := s.AgentsUnsafe()["live"]; !ok || len(s.AgentsUnsafe()) != 1 {
+		t.Fatalf("agents left: %v", s.AgentsUnsafe())
+	}
+}
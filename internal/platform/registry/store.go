@@ -20,23 +20,47 @@ type AgentSession struct {
     GameID   string
     Env      string
     RPCAddr  string
+    // EdgeAddr is the edge holding the tunnel of an agent that accepts no
+    // inbound calls; calls to the agent go through it.
+    EdgeAddr string
     Version  string
     Region   string
     Zone     string
     Labels   map[string]string
     Functions map[string]FunctionMeta
     ExpireAt time.Time
+    // SessionID is issued on registration; heartbeats must present it.
+    SessionID    string
+    RegisteredAt time.Time
+    LastSeen     time.Time
 }
 
-// Store keeps lightweight agent registry state in-memory.
+// Store keeps lightweight agent registry state in-memory. A store created with
+// NewSharedStore writes through to a Backend and keeps this state as a local view
+// of it, so the read paths stay lock-and-map lookups on every replica.
 type Store struct {
     mu     sync.RWMutex
     agents map[string]*AgentSession // agent_id -> session
     // provider capabilities (language-agnostic manifest uploaded via HTTP or Control)
     provCaps map[string]ProviderCaps // provider_id -> caps (latest)
+    backend  Backend
+    funcs    map[string]bool // function ids enabled on some agent
+
+    // change events for watchers
+    evMu   sync.Mutex
+    rev    uint64
+    events []Event
+    wakers map[chan struct{}]struct{}
 }
 
-func NewStore() *Store { return &Store{agents: map[string]*AgentSession{}, provCaps: map[string]ProviderCaps{}} }
+func NewStore() *Store {
+    return &Store{
+        agents:   map[string]*AgentSession{},
+        provCaps: map[string]ProviderCaps{},
+        funcs:    map[string]bool{},
+        wakers:   map[chan struct{}]struct{}{},
+    }
+}
 
 // Mu exposes the lock for read/update operations when callers need batch views.
 func (s *Store) Mu() *sync.RWMutex { return &s.mu }
@@ -45,21 +69,38 @@ func (s *Store) Mu() *sync.RWMutex { return &s.mu }
 func (s *Store) AgentsUnsafe() map[string]*AgentSession { return s.agents }
 
 // UpsertAgent inserts or updates an agent session by AgentID.
-func (s *Store) UpsertAgent(a *AgentSession) {
-    if a == nil || a.AgentID == "" { return }
-    s.mu.Lock()
-    defer s.mu.Unlock()
-    cur := s.agents[a.AgentID]
-    if cur == nil {
-        s.agents[a.AgentID] = a
-        return
+func (s *Store) UpsertAgent(a *AgentSession) error {
+    if a == nil || a.AgentID == "" { return nil }
+    s.mu.RLock()
+    next := mergeAgent(s.agents[a.AgentID], a)
+    s.mu.RUnlock()
+    if s.backend != nil {
+        ctx, cancel := backendContext()
+        defer cancel()
+        if err := s.backend.PutAgent(ctx, next); err != nil { return err }
     }
+    s.mu.Lock()
+    s.agents[a.AgentID] = next
+    s.emit(agentEvent(EventAgentUpserted, next))
+    s.functionsChangedLocked()
+    s.mu.Unlock()
+    return nil
+}
+
+// mergeAgent returns a copy of cur updated with the fields set on a.
+func mergeAgent(cur, a *AgentSession) *AgentSession {
+    if cur == nil { return a }
+    m := *cur
     // merge minimal fields
-    cur.GameID, cur.Env, cur.RPCAddr, cur.Version = a.GameID, a.Env, a.RPCAddr, a.Version
-    cur.Region, cur.Zone = a.Region, a.Zone
-    if a.Labels != nil { cur.Labels = a.Labels }
-    if a.Functions != nil { cur.Functions = a.Functions }
-    if !a.ExpireAt.IsZero() { cur.ExpireAt = a.ExpireAt }
+    m.GameID, m.Env, m.RPCAddr, m.Version = a.GameID, a.Env, a.RPCAddr, a.Version
+    m.Region, m.Zone, m.EdgeAddr = a.Region, a.Zone, a.EdgeAddr
+    if a.Labels != nil { m.Labels = a.Labels }
+    if a.Functions != nil { m.Functions = a.Functions }
+    if !a.ExpireAt.IsZero() { m.ExpireAt = a.ExpireAt }
+    if a.SessionID != "" { m.SessionID = a.SessionID }
+    if !a.RegisteredAt.IsZero() { m.RegisteredAt = a.RegisteredAt }
+    if !a.LastSeen.IsZero() { m.LastSeen = a.LastSeen }
+    return &m
 }
 
 // ProviderCaps represents a provider manifest snapshot registered at runtime.
@@ -73,11 +114,18 @@ type ProviderCaps struct {
 }
 
 // UpsertProviderCaps inserts or updates provider capabilities by provider ID.
-func (s *Store) UpsertProviderCaps(c ProviderCaps) {
-    if c.ID == "" || len(c.Manifest) == 0 { return }
-    s.mu.Lock(); defer s.mu.Unlock()
+func (s *Store) UpsertProviderCaps(c ProviderCaps) error {
+    if c.ID == "" || len(c.Manifest) == 0 { return nil }
     c.UpdatedAt = time.Now()
+    if s.backend != nil {
+        ctx, cancel := backendContext()
+        defer cancel()
+        if err := s.backend.PutProviderCaps(ctx, c); err != nil { return err }
+    }
+    s.mu.Lock(); defer s.mu.Unlock()
     s.provCaps[c.ID] = c
+    s.emit(Event{Type: EventProviderCapsChanged, ProviderID: c.ID})
+    return nil
 }
 
 // ListProviderCaps returns a snapshot of provider capabilities.
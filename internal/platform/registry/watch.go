@@ -0,0 +1,170 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// EventType names a registry change.
+type EventType string
+
+const (
+	EventAgentUpserted       EventType = "agent_upserted"
+	EventAgentExpired        EventType = "agent_expired"
+	EventProviderCapsChanged EventType = "provider_caps_changed"
+	EventFunctionEnabled     EventType = "function_enabled"
+	EventFunctionDisabled    EventType = "function_disabled"
+	EventAssignmentsChanged  EventType = "assignments_changed"
+)
+
+// eventLogSize is how many events are kept for watchers resuming from a revision.
+const eventLogSize = 1024
+
+// ErrCompacted is returned when watching from a revision the store no longer
+// holds, including revisions of an earlier server run. The client lists the
+// registry again and watches from the current revision.
+var ErrCompacted = errors.New("registry revision compacted")
+
+// Event is a change to the registry. A function is enabled once some agent
+// offers it enabled and disabled once none does.
+type Event struct {
+	// Revision increases by one per event. It is local to this store: replicas
+	// sharing a backend number their events independently.
+	Revision   uint64
+	Type       EventType
+	At         time.Time
+	AgentID    string
+	GameID     string
+	Env        string
+	ProviderID string
+	FunctionID string
+}
+
+// Revision returns the revision of the latest event.
+func (s *Store) Revision() uint64 {
+	s.evMu.Lock()
+	defer s.evMu.Unlock()
+	return s.rev
+}
+
+// Publish records a change made outside the store, such as function assignments.
+func (s *Store) Publish(ev Event) { s.emit(ev) }
+
+// emit assigns ev the next revision and wakes the watchers.
+func (s *Store) emit(ev Event) {
+	s.evMu.Lock()
+	defer s.evMu.Unlock()
+	s.rev++
+	ev.Revision = s.rev
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	s.events = append(s.events, ev)
+	if len(s.events) > 2*eventLogSize {
+		s.events = append([]Event(nil), s.events[len(s.events)-eventLogSize:]...)
+	}
+	for ch := range s.wakers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// eventsAfter returns the events following revision rev, or ErrCompacted if
+// some of them were dropped.
+func (s *Store) eventsAfter(rev uint64) ([]Event, error) {
+	s.evMu.Lock()
+	defer s.evMu.Unlock()
+	if rev > s.rev {
+		return nil, ErrCompacted
+	}
+	if rev == s.rev {
+		return nil, nil
+	}
+	if len(s.events) == 0 || rev+1 < s.events[0].Revision {
+		return nil, ErrCompacted
+	}
+	i := int(rev + 1 - s.events[0].Revision)
+	return append([]Event(nil), s.events[i:]...), nil
+}
+
+// Watch streams the events after revision after until ctx is done; after 0
+// starts at the current revision. The channel is closed when ctx is done or
+// the watcher falls so far behind that events were dropped; resuming from the
+// last revision received then fails with ErrCompacted.
+func (s *Store) Watch(ctx context.Context, after uint64) (<-chan Event, error) {
+	wake := make(chan struct{}, 1)
+	s.evMu.Lock()
+	if after == 0 {
+		after = s.rev
+	}
+	s.evMu.Unlock()
+	if _, err := s.eventsAfter(after); err != nil {
+		return nil, err
+	}
+	s.evMu.Lock()
+	s.wakers[wake] = struct{}{}
+	s.evMu.Unlock()
+
+	out := make(chan Event, 64)
+	go func() {
+		defer close(out)
+		defer func() {
+			s.evMu.Lock()
+			delete(s.wakers, wake)
+			s.evMu.Unlock()
+		}()
+		rev := after
+		for {
+			evs, err := s.eventsAfter(rev)
+			if err != nil {
+				return
+			}
+			for _, ev := range evs {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+				rev = ev.Revision
+			}
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// agentEvent describes a change to agent a.
+func agentEvent(t EventType, a *AgentSession) Event {
+	return Event{Type: t, AgentID: a.AgentID, GameID: a.GameID, Env: a.Env}
+}
+
+// functionsChangedLocked emits the functions that became available or
+// unavailable since the last call. Callers hold s.mu for writing.
+func (s *Store) functionsChangedLocked() {
+	now := map[string]bool{}
+	for _, a := range s.agents {
+		for fid, meta := range a.Functions {
+			if meta.Enabled {
+				now[fid] = true
+			}
+		}
+	}
+	for fid := range now {
+		if !s.funcs[fid] {
+			s.emit(Event{Type: EventFunctionEnabled, FunctionID: fid})
+		}
+	}
+	for fid := range s.funcs {
+		if !now[fid] {
+			s.emit(Event{Type: EventFunctionDisabled, FunctionID: fid})
+		}
+	}
+	s.funcs = now
+}
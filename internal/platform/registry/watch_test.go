@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatchEvents(t *testing.T) {
+	s := NewStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := s.Watch(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.UpsertAgent(&AgentSession{AgentID: "a1", GameID: "g1", SessionID: "s1", ExpireAt: now.Add(time.Second),
+		Functions: map[string]FunctionMeta{"player.ban": {Enabled: true}, "player.kick": {}}})
+	s.EvictExpired(now.Add(2 * time.Second))
+
+	want := []Event{
+		{Revision: 1, Type: EventAgentUpserted, AgentID: "a1", GameID: "g1"},
+		{Revision: 2, Type: EventFunctionEnabled, FunctionID: "player.ban"},
+		{Revision: 3, Type: EventAgentExpired, AgentID: "a1", GameID: "g1"},
+		{Revision: 4, Type: EventFunctionDisabled, FunctionID: "player.ban"},
+	}
+	for _, w := range want {
+		select {
+		case ev := <-events:
+			ev.At = time.Time{}
+			if ev != w {
+				t.Fatalf("event = %+v, want %+v", ev, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", w)
+		}
+	}
+
+	// Resuming replays what followed the revision.
+	resumed, err := s.Watch(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := <-resumed; ev.Revision != 3 {
+		t.Fatalf("resumed at %d, want 3", ev.Revision)
+	}
+	if _, err := s.Watch(ctx, 99); err != ErrCompacted {
+		t.Fatalf("watch from a future revision = %v, want ErrCompacted", err)
+	}
+}
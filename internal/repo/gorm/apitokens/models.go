@@ -0,0 +1,52 @@
+package apitokensgorm
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ServiceAccountRecord persists a service account; lists are stored comma-separated.
+type ServiceAccountRecord struct {
+	Name        string `gorm:"primaryKey;size:64"`
+	Description string `gorm:"size:256"`
+	Perms       string `gorm:"type:text"`
+	Scopes      string `gorm:"type:text"`
+	Disabled    bool
+	CreatedBy   string `gorm:"size:64"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TableName returns the table name for ServiceAccountRecord model
+func (ServiceAccountRecord) TableName() string {
+	return "service_accounts"
+}
+
+// APITokenRecord persists an API token by the hash of its secret; zero times are
+// stored as NULL.
+type APITokenRecord struct {
+	ID         string `gorm:"primaryKey;size:32"`
+	Account    string `gorm:"index;size:64;not null"`
+	Name       string `gorm:"size:128"`
+	Hash       string `gorm:"size:64;not null"`
+	Perms      string `gorm:"type:text"`
+	Scopes     string `gorm:"type:text"`
+	AllowedIPs string `gorm:"type:text"`
+	ExpiresAt  *time.Time
+	CreatedBy  string    `gorm:"size:64"`
+	CreatedAt  time.Time `gorm:"index"`
+	LastUsedAt *time.Time
+	LastUsedIP string `gorm:"size:64"`
+	RevokedAt  *time.Time
+	RevokedBy  string `gorm:"size:64"`
+}
+
+// TableName returns the table name for APITokenRecord model
+func (APITokenRecord) TableName() string {
+	return "api_tokens"
+}
+
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&ServiceAccountRecord{}, &APITokenRecord{})
+}
@@ -0,0 +1,223 @@
+package apitokensgorm
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/apitoken"
+	"gorm.io/gorm"
+)
+
+// Repo implements apitoken.Store on top of gorm (SQLite/Postgres).
+type Repo struct{ db *gorm.DB }
+
+var _ apitoken.Store = (*Repo)(nil)
+
+func New(db *gorm.DB) *Repo { return &Repo{db: db} }
+
+func (r *Repo) CreateAccount(ctx context.Context, a *apitoken.Account) error {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&ServiceAccountRecord{}).Where("name = ?", a.Name).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return apitoken.ErrExists
+	}
+	return r.db.WithContext(ctx).Create(toAccountRecord(a)).Error
+}
+
+func (r *Repo) GetAccount(ctx context.Context, name string) (*apitoken.Account, error) {
+	var recs []ServiceAccountRecord
+	if err := r.db.WithContext(ctx).Where("name = ?", name).Limit(1).Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, apitoken.ErrNotFound
+	}
+	return fromAccountRecord(&recs[0]), nil
+}
+
+func (r *Repo) ListAccounts(ctx context.Context) ([]*apitoken.Account, error) {
+	var recs []ServiceAccountRecord
+	if err := r.db.WithContext(ctx).Order("name").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*apitoken.Account, 0, len(recs))
+	for i := range recs {
+		out = append(out, fromAccountRecord(&recs[i]))
+	}
+	return out, nil
+}
+
+func (r *Repo) UpdateAccount(ctx context.Context, a *apitoken.Account) error {
+	res := r.db.WithContext(ctx).Model(&ServiceAccountRecord{}).Where("name = ?", a.Name).Select("*").Updates(toAccountRecord(a))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apitoken.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) DeleteAccount(ctx context.Context, name string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("name = ?", name).Delete(&ServiceAccountRecord{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return apitoken.ErrNotFound
+		}
+		return tx.Where("account = ?", name).Delete(&APITokenRecord{}).Error
+	})
+}
+
+func (r *Repo) CreateToken(ctx context.Context, t *apitoken.Token) error {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&ServiceAccountRecord{}).Where("name = ?", t.Account).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return apitoken.ErrNotFound
+	}
+	if err := r.db.WithContext(ctx).Model(&APITokenRecord{}).Where("id = ?", t.ID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return apitoken.ErrExists
+	}
+	return r.db.WithContext(ctx).Create(toTokenRecord(t)).Error
+}
+
+func (r *Repo) GetToken(ctx context.Context, id string) (*apitoken.Token, error) {
+	var recs []APITokenRecord
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Limit(1).Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, apitoken.ErrNotFound
+	}
+	return fromTokenRecord(&recs[0]), nil
+}
+
+func (r *Repo) ListTokens(ctx context.Context, account string) ([]*apitoken.Token, error) {
+	var recs []APITokenRecord
+	if err := r.db.WithContext(ctx).Where("account = ?", account).Order("created_at DESC").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*apitoken.Token, 0, len(recs))
+	for i := range recs {
+		out = append(out, fromTokenRecord(&recs[i]))
+	}
+	return out, nil
+}
+
+func (r *Repo) UpdateToken(ctx context.Context, t *apitoken.Token) error {
+	res := r.db.WithContext(ctx).Model(&APITokenRecord{}).Where("id = ?", t.ID).Select("*").Updates(toTokenRecord(t))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apitoken.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) TouchToken(ctx context.Context, id string, at time.Time, ip string) error {
+	res := r.db.WithContext(ctx).Model(&APITokenRecord{}).Where("id = ?", id).
+		Updates(map[string]any{"last_used_at": at, "last_used_ip": ip})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apitoken.ErrNotFound
+	}
+	return nil
+}
+
+func toAccountRecord(a *apitoken.Account) *ServiceAccountRecord {
+	return &ServiceAccountRecord{
+		Name:        a.Name,
+		Description: a.Description,
+		Perms:       strings.Join(a.Perms, ","),
+		Scopes:      strings.Join(a.Scopes, ","),
+		Disabled:    a.Disabled,
+		CreatedBy:   a.CreatedBy,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+}
+
+func fromAccountRecord(rec *ServiceAccountRecord) *apitoken.Account {
+	return &apitoken.Account{
+		Name:        rec.Name,
+		Description: rec.Description,
+		Perms:       splitList(rec.Perms),
+		Scopes:      splitList(rec.Scopes),
+		Disabled:    rec.Disabled,
+		CreatedBy:   rec.CreatedBy,
+		CreatedAt:   rec.CreatedAt,
+		UpdatedAt:   rec.UpdatedAt,
+	}
+}
+
+func toTokenRecord(t *apitoken.Token) *APITokenRecord {
+	return &APITokenRecord{
+		ID:         t.ID,
+		Account:    t.Account,
+		Name:       t.Name,
+		Hash:       t.Hash,
+		Perms:      strings.Join(t.Perms, ","),
+		Scopes:     strings.Join(t.Scopes, ","),
+		AllowedIPs: strings.Join(t.AllowedIPs, ","),
+		ExpiresAt:  timePtr(t.ExpiresAt),
+		CreatedBy:  t.CreatedBy,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: timePtr(t.LastUsedAt),
+		LastUsedIP: t.LastUsedIP,
+		RevokedAt:  timePtr(t.RevokedAt),
+		RevokedBy:  t.RevokedBy,
+	}
+}
+
+func fromTokenRecord(rec *APITokenRecord) *apitoken.Token {
+	return &apitoken.Token{
+		ID:         rec.ID,
+		Account:    rec.Account,
+		Name:       rec.Name,
+		Hash:       rec.Hash,
+		Perms:      splitList(rec.Perms),
+		Scopes:     splitList(rec.Scopes),
+		AllowedIPs: splitList(rec.AllowedIPs),
+		ExpiresAt:  timeVal(rec.ExpiresAt),
+		CreatedBy:  rec.CreatedBy,
+		CreatedAt:  rec.CreatedAt,
+		LastUsedAt: timeVal(rec.LastUsedAt),
+		LastUsedIP: rec.LastUsedIP,
+		RevokedAt:  timeVal(rec.RevokedAt),
+		RevokedBy:  rec.RevokedBy,
+	}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeVal(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -4,7 +4,6 @@ import (
     "context"
 
     dom "github.com/cuihairu/croupier/internal/ports"
-    "gorm.io/gorm"
 )
 
 // PortRepo adapts *Repo to the ports.GamesRepository interface.
@@ -24,7 +23,11 @@ func (p *PortRepo) Create(ctx context.Context, g *dom.Game) error {
 }
 func (p *PortRepo) Update(ctx context.Context, g *dom.Game) error {
     if g == nil { return nil }
-    m := &Game{ Model: gorm.Model{ID: g.ID}, Name: g.Name, Icon: g.Icon, Description: g.Description, Enabled: g.Enabled, AliasName: g.AliasName, Homepage: g.Homepage, Status: g.Status, GameType: g.GameType, GenreCode: g.GenreCode }
+    // Load the stored row so that Save keeps its creation time and env list.
+    m, err := p.r.Get(ctx, g.ID)
+    if err != nil { return err }
+    m.Name, m.Icon, m.Description, m.Enabled, m.AliasName = g.Name, g.Icon, g.Description, g.Enabled, g.AliasName
+    m.Homepage, m.Status, m.GameType, m.GenreCode = g.Homepage, g.Status, g.GameType, g.GenreCode
     if len(g.Envs) > 0 { m.SetEnvList(g.Envs) }
     return p.r.Update(ctx, m)
 }
@@ -0,0 +1,59 @@
+package jobsgorm
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobRecord persists a job; ID is assigned by the server and AgentJobID is the
+// id handed out by agent AgentID.
+type JobRecord struct {
+	ID             string `gorm:"primaryKey;size:128"`
+	FunctionID     string `gorm:"index;size:128"`
+	GameID         string `gorm:"index;size:64"`
+	Env            string `gorm:"size:64"`
+	Actor          string `gorm:"index;size:64"`
+	IdempotencyKey string `gorm:"size:128"`
+	AgentID        string `gorm:"index:idx_job_agent;size:128"`
+	AgentJobID     string `gorm:"index:idx_job_agent;size:128"`
+	AgentAddr      string `gorm:"size:256"`
+	EdgeAddr       string `gorm:"size:256"`
+	TraceID        string `gorm:"size:64"`
+	State          string `gorm:"index;size:16"`
+	Progress       int32
+	Result         []byte
+	Error          string `gorm:"type:text"`
+	TimeoutMs      int64
+	Deadline       *time.Time
+	StartedAt      *time.Time
+	EndedAt        *time.Time
+	CreatedAt      time.Time `gorm:"index"`
+	UpdatedAt      time.Time
+}
+
+// TableName returns the table name for JobRecord model
+func (JobRecord) TableName() string {
+	return "job_records"
+}
+
+// JobEventRecord is one entry of a job's event log.
+type JobEventRecord struct {
+	ID        uint   `gorm:"primaryKey"`
+	JobID     string `gorm:"uniqueIndex:idx_job_event_seq;size:128;not null"`
+	Seq       int64  `gorm:"uniqueIndex:idx_job_event_seq;not null"`
+	Type      string `gorm:"size:16"`
+	Message   string `gorm:"type:text"`
+	Progress  int32
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// TableName returns the table name for JobEventRecord model
+func (JobEventRecord) TableName() string {
+	return "job_event_records"
+}
+
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&JobRecord{}, &JobEventRecord{})
+}
@@ -0,0 +1,237 @@
+package jobsgorm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/jobs"
+	"gorm.io/gorm"
+)
+
+// appendAttempts bounds how often AppendEvent retries when another replica took
+// the same sequence number.
+const appendAttempts = 5
+
+// Repo implements jobs.Store on top of gorm (SQLite/Postgres).
+type Repo struct {
+	db *gorm.DB
+	// appendMu serializes appends of this replica, which would otherwise race for
+	// the next sequence number.
+	appendMu sync.Mutex
+}
+
+var _ jobs.Store = (*Repo)(nil)
+
+func New(db *gorm.DB) *Repo { return &Repo{db: db} }
+
+func (r *Repo) Create(ctx context.Context, job *jobs.Job) error {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&JobRecord{}).Where("id = ?", job.ID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return jobs.ErrExists
+	}
+	return r.db.WithContext(ctx).Create(toRecord(job)).Error
+}
+
+func (r *Repo) Get(ctx context.Context, id string) (*jobs.Job, error) {
+	var recs []JobRecord
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Limit(1).Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, jobs.ErrNotFound
+	}
+	return fromRecord(&recs[0]), nil
+}
+
+func (r *Repo) Update(ctx context.Context, job *jobs.Job) error {
+	res := r.db.WithContext(ctx).Model(&JobRecord{}).Where("id = ?", job.ID).Select("*").Omit("created_at").Updates(toRecord(job))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return jobs.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) List(ctx context.Context, opts jobs.ListOptions) ([]*jobs.Job, int64, error) {
+	q := r.db.WithContext(ctx).Model(&JobRecord{})
+	if opts.FunctionID != "" {
+		q = q.Where("function_id = ?", opts.FunctionID)
+	}
+	if opts.GameID != "" {
+		q = q.Where("game_id = ?", opts.GameID)
+	}
+	if opts.Env != "" {
+		q = q.Where("env = ?", opts.Env)
+	}
+	if opts.Actor != "" {
+		q = q.Where("actor = ?", opts.Actor)
+	}
+	if len(opts.States) > 0 {
+		states := make([]string, 0, len(opts.States))
+		for _, s := range opts.States {
+			states = append(states, string(s))
+		}
+		q = q.Where("state IN ?", states)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	q = q.Order("created_at DESC").Order("id DESC")
+	if opts.Limit > 0 {
+		q = q.Limit(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		q = q.Offset(opts.Offset)
+	}
+	var recs []JobRecord
+	if err := q.Find(&recs).Error; err != nil {
+		return nil, 0, err
+	}
+	out := make([]*jobs.Job, 0, len(recs))
+	for i := range recs {
+		out = append(out, fromRecord(&recs[i]))
+	}
+	return out, total, nil
+}
+
+// AppendEvent numbers ev after the last event of its job. Sequence numbers are
+// unique per job, so an append that lost the race to another replica is retried.
+func (r *Repo) AppendEvent(ctx context.Context, ev *jobs.Event) error {
+	r.appendMu.Lock()
+	defer r.appendMu.Unlock()
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	var err error
+	for i := 0; i < appendAttempts; i++ {
+		if err = r.appendEvent(ctx, ev); err == nil || errors.Is(err, jobs.ErrNotFound) || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (r *Repo) appendEvent(ctx context.Context, ev *jobs.Event) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&JobRecord{}).Where("id = ?", ev.JobID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return jobs.ErrNotFound
+		}
+		var maxSeq int64
+		if err := tx.Model(&JobEventRecord{}).Where("job_id = ?", ev.JobID).Select("COALESCE(MAX(seq), 0)").Scan(&maxSeq).Error; err != nil {
+			return err
+		}
+		rec := &JobEventRecord{
+			JobID:     ev.JobID,
+			Seq:       maxSeq + 1,
+			Type:      ev.Type,
+			Message:   ev.Message,
+			Progress:  ev.Progress,
+			Payload:   ev.Payload,
+			CreatedAt: ev.Time,
+		}
+		if err := tx.Create(rec).Error; err != nil {
+			return err
+		}
+		ev.Seq = rec.Seq
+		return nil
+	})
+}
+
+func (r *Repo) Events(ctx context.Context, jobID string, afterSeq int64) ([]jobs.Event, error) {
+	if _, err := r.Get(ctx, jobID); err != nil {
+		return nil, err
+	}
+	var recs []JobEventRecord
+	if err := r.db.WithContext(ctx).Where("job_id = ? AND seq > ?", jobID, afterSeq).Order("seq ASC").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]jobs.Event, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, jobs.Event{
+			JobID:    rec.JobID,
+			Seq:      rec.Seq,
+			Type:     rec.Type,
+			Message:  rec.Message,
+			Progress: rec.Progress,
+			Payload:  rec.Payload,
+			Time:     rec.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+func toRecord(j *jobs.Job) *JobRecord {
+	return &JobRecord{
+		ID:             j.ID,
+		FunctionID:     j.FunctionID,
+		GameID:         j.GameID,
+		Env:            j.Env,
+		Actor:          j.Actor,
+		IdempotencyKey: j.IdempotencyKey,
+		AgentID:        j.AgentID,
+		AgentJobID:     j.AgentJobID,
+		AgentAddr:      j.AgentAddr,
+		EdgeAddr:       j.EdgeAddr,
+		TraceID:        j.TraceID,
+		State:          string(j.State),
+		Progress:       j.Progress,
+		Result:         j.Result,
+		Error:          j.Error,
+		TimeoutMs:      j.Timeout.Milliseconds(),
+		Deadline:       timePtr(j.Deadline),
+		StartedAt:      timePtr(j.StartedAt),
+		EndedAt:        timePtr(j.EndedAt),
+		CreatedAt:      j.CreatedAt,
+	}
+}
+
+func fromRecord(rec *JobRecord) *jobs.Job {
+	return &jobs.Job{
+		ID:             rec.ID,
+		FunctionID:     rec.FunctionID,
+		GameID:         rec.GameID,
+		Env:            rec.Env,
+		Actor:          rec.Actor,
+		IdempotencyKey: rec.IdempotencyKey,
+		AgentID:        rec.AgentID,
+		AgentJobID:     rec.AgentJobID,
+		AgentAddr:      rec.AgentAddr,
+		EdgeAddr:       rec.EdgeAddr,
+		TraceID:        rec.TraceID,
+		State:          jobs.State(rec.State),
+		Progress:       rec.Progress,
+		Result:         rec.Result,
+		Error:          rec.Error,
+		Timeout:        time.Duration(rec.TimeoutMs) * time.Millisecond,
+		Deadline:       timeVal(rec.Deadline),
+		StartedAt:      timeVal(rec.StartedAt),
+		EndedAt:        timeVal(rec.EndedAt),
+		CreatedAt:      rec.CreatedAt,
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeVal(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -0,0 +1,119 @@
+package jobsgorm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/db"
+	"github.com/cuihairu/croupier/internal/jobs"
+)
+
+func newTestRepo(t *testing.T) *Repo {
+	t.Helper()
+	gdb, err := db.Open("file:" + filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AutoMigrate(gdb); err != nil {
+		t.Fatal(err)
+	}
+	return New(gdb)
+}
+
+func TestCreateKeepsAgentJobsApart(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	// Both agents hand out the same job id.
+	a := &jobs.Job{ID: jobs.NewID(), AgentID: "a1", AgentJobID: "1", FunctionID: "f", State: jobs.StateRunning, CreatedAt: time.Now()}
+	b := &jobs.Job{ID: jobs.NewID(), AgentID: "a2", AgentJobID: "1", FunctionID: "f", State: jobs.StateRunning, CreatedAt: time.Now()}
+	for _, j := range []*jobs.Job{a, b} {
+		if err := r.Create(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Create(ctx, a); !errors.Is(err, jobs.ErrExists) {
+		t.Fatalf("duplicate create: %v, want ErrExists", err)
+	}
+	got, err := r.Get(ctx, b.ID)
+	if err != nil || got.AgentID != "a2" || got.AgentJobID != "1" {
+		t.Fatalf("get %s = %+v, %v", b.ID, got, err)
+	}
+	if _, err := r.Get(ctx, "job-missing"); !errors.Is(err, jobs.ErrNotFound) {
+		t.Fatalf("get missing: %v, want ErrNotFound", err)
+	}
+}
+
+func TestAppendEventOrdersConcurrentAppends(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	job := &jobs.Job{ID: jobs.NewID(), State: jobs.StateRunning, CreatedAt: time.Now()}
+	if err := r.Create(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- r.AppendEvent(ctx, &jobs.Event{JobID: job.ID, Type: jobs.EventLog})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	evs, err := r.Events(ctx, job.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != n {
+		t.Fatalf("%d events, want %d", len(evs), n)
+	}
+	for i, ev := range evs {
+		if ev.Seq != int64(i+1) {
+			t.Fatalf("event %d has seq %d", i, ev.Seq)
+		}
+	}
+	if err := r.AppendEvent(ctx, &jobs.Event{JobID: "job-missing", Type: jobs.EventLog}); !errors.Is(err, jobs.ErrNotFound) {
+		t.Fatalf("append to missing job: %v, want ErrNotFound", err)
+	}
+}
+
+func TestRecoverFromRepo(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	now := time.Now()
+	running := &jobs.Job{ID: jobs.NewID(), State: jobs.StateRunning, Timeout: time.Hour}
+	expired := &jobs.Job{ID: jobs.NewID(), State: jobs.StateRunning, CreatedAt: now.Add(-2 * time.Hour), Timeout: time.Hour}
+	done := &jobs.Job{ID: jobs.NewID(), State: jobs.StateSucceeded}
+	first := jobs.NewEngine(r)
+	for _, j := range []*jobs.Job{running, expired, done} {
+		if err := first.Create(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first.Close()
+
+	// A restarted server sees the same records.
+	e := jobs.NewEngine(r)
+	defer e.Close()
+	active, err := e.Recover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != running.ID {
+		t.Fatalf("recovered %+v, want only %s", active, running.ID)
+	}
+	if got, err := r.Get(ctx, expired.ID); err != nil || got.State != jobs.StateTimedOut {
+		t.Fatalf("expired job = %+v, %v, want timed_out", got, err)
+	}
+}
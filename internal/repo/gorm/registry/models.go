@@ -0,0 +1,42 @@
+package registrygorm
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AgentRecord holds a registered agent. The session fields are columns so that
+// heartbeats and eviction are single conditional statements; the rest of the
+// session is kept as JSON in Data.
+type AgentRecord struct {
+	AgentID   string `gorm:"primaryKey;size:128"`
+	SessionID string `gorm:"size:64"`
+	Data      []byte
+	ExpireAt  time.Time `gorm:"index"`
+	LastSeen  time.Time
+}
+
+// TableName returns the table name for AgentRecord model
+func (AgentRecord) TableName() string {
+	return "registry_agents"
+}
+
+// ProviderCapsRecord holds the latest manifest uploaded by a provider.
+type ProviderCapsRecord struct {
+	ID        string `gorm:"primaryKey;size:128"`
+	Version   string `gorm:"size:64"`
+	Lang      string `gorm:"size:32"`
+	SDK       string `gorm:"size:64"`
+	Manifest  []byte
+	UpdatedAt time.Time
+}
+
+// TableName returns the table name for ProviderCapsRecord model
+func (ProviderCapsRecord) TableName() string {
+	return "registry_provider_caps"
+}
+
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&AgentRecord{}, &ProviderCapsRecord{})
+}
@@ -0,0 +1,85 @@
+package registrygorm
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repo implements registry.Backend on top of gorm (SQLite/Postgres). It has no
+// change notification; replicas pick up each other's changes by polling.
+type Repo struct{ db *gorm.DB }
+
+var _ registry.Backend = (*Repo)(nil)
+
+func New(db *gorm.DB) *Repo { return &Repo{db: db} }
+
+func (r *Repo) PutAgent(ctx context.Context, a *registry.AgentSession) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	rec := AgentRecord{AgentID: a.AgentID, SessionID: a.SessionID, Data: data, ExpireAt: a.ExpireAt, LastSeen: a.LastSeen}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&rec).Error
+}
+
+func (r *Repo) TouchAgent(ctx context.Context, agentID, sessionID string, now, expireAt time.Time) error {
+	res := r.db.WithContext(ctx).Model(&AgentRecord{}).
+		Where("agent_id = ? AND session_id = ? AND expire_at >= ?", agentID, sessionID, now).
+		Updates(map[string]any{"expire_at": expireAt, "last_seen": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return registry.ErrUnknownSession
+	}
+	return nil
+}
+
+func (r *Repo) DeleteExpired(ctx context.Context, agentID string, now time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Where("agent_id = ? AND expire_at < ?", agentID, now).Delete(&AgentRecord{})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *Repo) ListAgents(ctx context.Context) ([]*registry.AgentSession, error) {
+	var recs []AgentRecord
+	if err := r.db.WithContext(ctx).Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*registry.AgentSession, 0, len(recs))
+	for i := range recs {
+		var a registry.AgentSession
+		if err := json.Unmarshal(recs[i].Data, &a); err != nil {
+			continue
+		}
+		a.ExpireAt, a.LastSeen = recs[i].ExpireAt, recs[i].LastSeen
+		out = append(out, &a)
+	}
+	return out, nil
+}
+
+func (r *Repo) PutProviderCaps(ctx context.Context, c registry.ProviderCaps) error {
+	rec := ProviderCapsRecord{ID: c.ID, Version: c.Version, Lang: c.Lang, SDK: c.SDK, Manifest: c.Manifest, UpdatedAt: c.UpdatedAt}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&rec).Error
+}
+
+func (r *Repo) ListProviderCaps(ctx context.Context) ([]registry.ProviderCaps, error) {
+	var recs []ProviderCapsRecord
+	if err := r.db.WithContext(ctx).Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]registry.ProviderCaps, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, registry.ProviderCaps{ID: rec.ID, Version: rec.Version, Lang: rec.Lang, SDK: rec.SDK, Manifest: rec.Manifest, UpdatedAt: rec.UpdatedAt})
+	}
+	return out, nil
+}
+
+func (r *Repo) Watch(context.Context) <-chan struct{} { return nil }
+
+// Close leaves the database open; it belongs to the server.
+func (r *Repo) Close() error { return nil }
@@ -0,0 +1,31 @@
+package sessionsgorm
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SessionRecord persists a login session; zero times are stored as NULL.
+type SessionRecord struct {
+	ID          string `gorm:"primaryKey;size:64"`
+	Username    string `gorm:"index;size:64;not null"`
+	RefreshHash string `gorm:"size:64"`
+	MFAAt       *time.Time
+	IP          string    `gorm:"size:64"`
+	UserAgent   string    `gorm:"size:256"`
+	CreatedAt   time.Time `gorm:"index"`
+	LastSeenAt  time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+	RevokedAt   *time.Time
+	RevokedBy   string `gorm:"size:64"`
+}
+
+// TableName returns the table name for SessionRecord model
+func (SessionRecord) TableName() string {
+	return "session_records"
+}
+
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&SessionRecord{})
+}
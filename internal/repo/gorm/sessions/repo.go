@@ -0,0 +1,125 @@
+package sessionsgorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/session"
+	"gorm.io/gorm"
+)
+
+// Repo implements session.Store on top of gorm (SQLite/Postgres).
+type Repo struct{ db *gorm.DB }
+
+var _ session.Store = (*Repo)(nil)
+
+func New(db *gorm.DB) *Repo { return &Repo{db: db} }
+
+func (r *Repo) Create(ctx context.Context, s *session.Session) error {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&SessionRecord{}).Where("id = ?", s.ID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return session.ErrExists
+	}
+	return r.db.WithContext(ctx).Create(toRecord(s)).Error
+}
+
+func (r *Repo) Get(ctx context.Context, id string) (*session.Session, error) {
+	var recs []SessionRecord
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Limit(1).Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, session.ErrNotFound
+	}
+	return fromRecord(&recs[0]), nil
+}
+
+func (r *Repo) Update(ctx context.Context, s *session.Session) error {
+	res := r.db.WithContext(ctx).Model(&SessionRecord{}).Where("id = ?", s.ID).Select("*").Updates(toRecord(s))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return session.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) List(ctx context.Context, opts session.ListOptions) ([]*session.Session, error) {
+	q := r.db.WithContext(ctx).Model(&SessionRecord{})
+	if opts.Username != "" {
+		q = q.Where("username = ?", opts.Username)
+	}
+	if !opts.ActiveAt.IsZero() {
+		q = q.Where("revoked_at IS NULL AND expires_at > ?", opts.ActiveAt)
+	}
+	var recs []SessionRecord
+	if err := q.Order("created_at DESC").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*session.Session, 0, len(recs))
+	for i := range recs {
+		out = append(out, fromRecord(&recs[i]))
+	}
+	return out, nil
+}
+
+func (r *Repo) RevokeUser(ctx context.Context, username, by string, at time.Time) (int, error) {
+	res := r.db.WithContext(ctx).Model(&SessionRecord{}).
+		Where("username = ? AND revoked_at IS NULL AND expires_at > ?", username, at).
+		Updates(map[string]any{"revoked_at": at, "revoked_by": by})
+	return int(res.RowsAffected), res.Error
+}
+
+func (r *Repo) DeleteExpired(ctx context.Context, t time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", t).Delete(&SessionRecord{}).Error
+}
+
+func toRecord(s *session.Session) *SessionRecord {
+	return &SessionRecord{
+		ID:          s.ID,
+		Username:    s.Username,
+		RefreshHash: s.RefreshHash,
+		MFAAt:       timePtr(s.MFAAt),
+		IP:          s.IP,
+		UserAgent:   s.UserAgent,
+		CreatedAt:   s.CreatedAt,
+		LastSeenAt:  s.LastSeenAt,
+		ExpiresAt:   s.ExpiresAt,
+		RevokedAt:   timePtr(s.RevokedAt),
+		RevokedBy:   s.RevokedBy,
+	}
+}
+
+func fromRecord(rec *SessionRecord) *session.Session {
+	return &session.Session{
+		ID:          rec.ID,
+		Username:    rec.Username,
+		RefreshHash: rec.RefreshHash,
+		MFAAt:       timeVal(rec.MFAAt),
+		IP:          rec.IP,
+		UserAgent:   rec.UserAgent,
+		CreatedAt:   rec.CreatedAt,
+		LastSeenAt:  rec.LastSeenAt,
+		ExpiresAt:   rec.ExpiresAt,
+		RevokedAt:   timeVal(rec.RevokedAt),
+		RevokedBy:   rec.RevokedBy,
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeVal(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -16,6 +16,13 @@ type UserAccount struct {
 	PasswordHash string `gorm:"size:255"` // bcrypt hash
 	Active       bool   `gorm:"default:true"`
 	OTPSecret    string `gorm:"size:64"`
+	// OTPPending holds a TOTP secret awaiting its first code during enrollment.
+	OTPPending string `gorm:"size:64"`
+	// RecoveryCodes holds the hashes of unused MFA recovery codes, comma separated.
+	RecoveryCodes string `gorm:"type:text"`
+	// ExternalID links the account to an identity provider as "<issuer>#<subject>";
+	// empty for local accounts.
+	ExternalID string `gorm:"size:255;index"`
 }
 
 // TableName returns the table name for UserAccount model
@@ -19,8 +19,24 @@ func (r *Repo) CreateUser(ctx context.Context, u *UserAccount) error {
 func (r *Repo) UpdateUser(ctx context.Context, u *UserAccount) error {
 	return r.db.WithContext(ctx).Save(u).Error
 }
+// DeleteUser removes the user together with its role links and game scopes. The row
+// is deleted permanently so the username can be reused.
 func (r *Repo) DeleteUser(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&UserAccount{}, id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range []any{&UserRoleRecord{}, &UserGameScope{}, &UserGameEnvScope{}} {
+			if err := tx.Where("user_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&UserAccount{}, id).Error
+	})
+}
+func (r *Repo) GetUser(ctx context.Context, id uint) (*UserAccount, error) {
+	var ur UserAccount
+	if err := r.db.WithContext(ctx).First(&ur, id).Error; err != nil {
+		return nil, err
+	}
+	return &ur, nil
 }
 func (r *Repo) GetUserByUsername(ctx context.Context, username string) (*UserAccount, error) {
 	var ur UserAccount
@@ -48,6 +64,28 @@ func (r *Repo) SetPassword(ctx context.Context, userID uint, plain string) error
 	return r.db.WithContext(ctx).Model(&UserAccount{}).Where("id = ?", userID).Update("password_hash", string(h)).Error
 }
 
+// SetOTP stores the MFA columns of a user.
+func (r *Repo) SetOTP(ctx context.Context, userID uint, secret, pending, recoveryCodes string) error {
+	return r.db.WithContext(ctx).Model(&UserAccount{}).Where("id = ?", userID).Updates(map[string]any{
+		"otp_secret":     secret,
+		"otp_pending":    pending,
+		"recovery_codes": recoveryCodes,
+	}).Error
+}
+
+// SwapRecoveryCodes replaces the recovery codes of a user with next only if they are
+// still old, and reports whether they were replaced.
+func (r *Repo) SwapRecoveryCodes(ctx context.Context, userID uint, old, next string) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&UserAccount{}).Where("id = ? AND recovery_codes = ?", userID, old).
+		Update("recovery_codes", next)
+	return res.RowsAffected > 0, res.Error
+}
+
+// SetExternalID links a user to an identity provider subject.
+func (r *Repo) SetExternalID(ctx context.Context, userID uint, externalID string) error {
+	return r.db.WithContext(ctx).Model(&UserAccount{}).Where("id = ?", userID).Update("external_id", externalID).Error
+}
+
 func (r *Repo) Verify(ctx context.Context, username, plain string) (*UserAccount, error) {
 	u, err := r.GetUserByUsername(ctx, username)
 	if err != nil {
@@ -69,8 +107,35 @@ func (r *Repo) Verify(ctx context.Context, username, plain string) (*UserAccount
 func (r *Repo) CreateRole(ctx context.Context, role *RoleRecord) error {
 	return r.db.WithContext(ctx).Create(role).Error
 }
+func (r *Repo) UpdateRole(ctx context.Context, role *RoleRecord) error {
+	return r.db.WithContext(ctx).Save(role).Error
+}
+
+// DeleteRole removes the role, its permissions and its user links. The row is deleted
+// permanently so the name can be reused.
 func (r *Repo) DeleteRole(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&RoleRecord{}, id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range []any{&UserRoleRecord{}, &RolePermRecord{}} {
+			if err := tx.Where("role_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&RoleRecord{}, id).Error
+	})
+}
+func (r *Repo) GetRole(ctx context.Context, id uint) (*RoleRecord, error) {
+	var role RoleRecord
+	if err := r.db.WithContext(ctx).First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+func (r *Repo) GetRoleByName(ctx context.Context, name string) (*RoleRecord, error) {
+	var role RoleRecord
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
 }
 func (r *Repo) ListRoles(ctx context.Context) ([]*RoleRecord, error) {
 	var arr []*RoleRecord
@@ -87,7 +152,7 @@ func (r *Repo) RemoveUserRole(ctx context.Context, userID, roleID uint) error {
 }
 func (r *Repo) ListUserRoles(ctx context.Context, userID uint) ([]*RoleRecord, error) {
 	var roles []*RoleRecord
-	if err := r.db.WithContext(ctx).Raw("SELECT r.* FROM role_records r JOIN user_role_records ur ON r.id=ur.role_id WHERE ur.user_id=?", userID).Scan(&roles).Error; err != nil {
+	if err := r.db.WithContext(ctx).Raw("SELECT r.* FROM role_records r JOIN user_role_records ur ON r.id=ur.role_id WHERE ur.user_id=? AND ur.deleted_at IS NULL AND r.deleted_at IS NULL", userID).Scan(&roles).Error; err != nil {
 		return nil, err
 	}
 	return roles, nil
@@ -98,6 +163,46 @@ func (r *Repo) GrantRolePerm(ctx context.Context, roleID uint, perm string) erro
 func (r *Repo) RevokeRolePerm(ctx context.Context, roleID uint, perm string) error {
 	return r.db.WithContext(ctx).Where("role_id=? AND perm=?", roleID, perm).Delete(&RolePermRecord{}).Error
 }
+// ReplaceUserRoles replaces all role links of the user with roleIDs.
+func (r *Repo) ReplaceUserRoles(ctx context.Context, userID uint, roleIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id=?", userID).Delete(&UserRoleRecord{}).Error; err != nil {
+			return err
+		}
+		seen := map[uint]struct{}{}
+		for _, id := range roleIDs {
+			if _, ok := seen[id]; ok || id == 0 {
+				continue
+			}
+			seen[id] = struct{}{}
+			if err := tx.Create(&UserRoleRecord{UserID: userID, RoleID: id}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceRolePerms replaces all permissions of the role with perms.
+func (r *Repo) ReplaceRolePerms(ctx context.Context, roleID uint, perms []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id=?", roleID).Delete(&RolePermRecord{}).Error; err != nil {
+			return err
+		}
+		seen := map[string]struct{}{}
+		for _, p := range perms {
+			p = strings.TrimSpace(p)
+			if _, ok := seen[p]; ok || p == "" {
+				continue
+			}
+			seen[p] = struct{}{}
+			if err := tx.Create(&RolePermRecord{RoleID: roleID, Perm: p}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 func (r *Repo) ListRolePerms(ctx context.Context, roleID uint) ([]string, error) {
 	var perms []string
 	if err := r.db.WithContext(ctx).Model(&RolePermRecord{}).Where("role_id=?", roleID).Pluck("perm", &perms).Error; err != nil {
@@ -112,7 +217,7 @@ func (r *Repo) BuildPolicySnapshot(ctx context.Context) (map[string][]string, er
 		Perm string
 	}
 	var rows []row
-	if err := r.db.WithContext(ctx).Raw("SELECT r.name as name, rp.perm as perm FROM role_records r JOIN role_perm_records rp ON r.id = rp.role_id").Scan(&rows).Error; err != nil {
+	if err := r.db.WithContext(ctx).Raw("SELECT r.name as name, rp.perm as perm FROM role_records r JOIN role_perm_records rp ON r.id = rp.role_id WHERE r.deleted_at IS NULL AND rp.deleted_at IS NULL").Scan(&rows).Error; err != nil {
 		return nil, err
 	}
 	out := map[string][]string{}
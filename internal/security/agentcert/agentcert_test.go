@@ -0,0 +1,245 @@
+package agentcert_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/devcert"
+	"github.com/cuihairu/croupier/internal/platform/control"
+	"github.com/cuihairu/croupier/internal/platform/tlsutil"
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	serverv1 "github.com/cuihairu/croupier/pkg/pb/croupier/server/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func TestIdentityAllows(t *testing.T) {
+	id := agentcert.Identity{AgentID: "a1", Games: []string{"g1", "g2/prod"}}
+	cases := []struct {
+		game, env string
+		want      bool
+	}{
+		{"g1", "", true},
+		{"g1", "dev", true},
+		{"g2", "prod", true},
+		{"g2", "dev", false},
+		{"g3", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		if got := id.Allows(c.game, c.env); got != c.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", c.game, c.env, got, c.want)
+		}
+	}
+	if !(agentcert.Identity{AgentID: "a1", Games: []string{agentcert.AnyGame}}).Allows("", "") {
+		t.Error("AnyGame should allow agents without a game")
+	}
+	if err := id.Check("a2", "g1", ""); err != agentcert.ErrMismatch {
+		t.Errorf("Check other agent = %v, want ErrMismatch", err)
+	}
+}
+
+func TestIssueKeepsIssuerIdentity(t *testing.T) {
+	ca := newCA(t)
+	key, _, err := agentcert.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := agentcert.NewCSR(key, "someone-else")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := agentcert.ParseCSR(csr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, _, err := ca.Issue(req, agentcert.Identity{AgentID: "a1", Games: []string{"g1/prod"}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := agentcert.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := agentcert.FromCertificate(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.AgentID != "a1" || len(id.Games) != 1 || id.Games[0] != "g1/prod" {
+		t.Fatalf("identity = %+v", id)
+	}
+	if _, _, err := ca.Issue(req, agentcert.Identity{AgentID: "../a1"}, time.Hour); err == nil {
+		t.Fatal("expected invalid agent id to be refused")
+	}
+}
+
+func TestControlBindsRegistrationToCertificate(t *testing.T) {
+	dir := t.TempDir()
+	caCrt, caKey, err := devcert.EnsureDevCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvCrt, srvKey, err := devcert.EnsureServerCert(dir, caCrt, caKey, []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := agentcert.LoadCA(caCrt, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err := tlsutil.ServerTLS(srvCrt, srvKey, caCrt, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(creds))
+	ctrl := control.NewServer(nil)
+	ctrl.RequireAgentIdentity()
+	revoked := agentcert.RevocationList{Path: filepath.Join(dir, "revoked.txt")}
+	ctrl.SetRevocations(revoked)
+	serverv1.RegisterControlServiceServer(srv, ctrl)
+	agentcert.RegisterRenewServer(srv, ca, time.Hour, revoked)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	key, keyPEM, err := agentcert.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, _ := agentcert.NewCSR(key, "a1")
+	req, _ := agentcert.ParseCSR(csr)
+	certPEM, _, err := ca.Issue(req, agentcert.Identity{AgentID: "a1", Games: []string{"g1/prod"}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM, _ := os.ReadFile(caCrt)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pair},
+		RootCAs:      pool,
+		ServerName:   "localhost",
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	client := serverv1.NewControlServiceClient(cc)
+
+	if _, err := client.Register(ctx, &serverv1.RegisterRequest{AgentId: "a1", GameId: "g1", Env: "prod"}); err != nil {
+		t.Fatalf("register own identity: %v", err)
+	}
+	for _, in := range []*serverv1.RegisterRequest{
+		{AgentId: "a2", GameId: "g1", Env: "prod"},
+		{AgentId: "a1", GameId: "g2", Env: "prod"},
+		{AgentId: "a1", GameId: "g1", Env: "dev"},
+	} {
+		if _, err := client.Register(ctx, in); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("register %v: got %v, want PermissionDenied", in, err)
+		}
+	}
+	if _, err := client.Heartbeat(ctx, &serverv1.HeartbeatRequest{AgentId: "a2"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("heartbeat as other agent: got %v, want PermissionDenied", err)
+	}
+	if _, err := client.RegisterCapabilities(ctx, &serverv1.RegisterCapabilitiesRequest{Provider: &serverv1.ProviderMeta{Id: "a2"}}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("register capabilities as other agent: got %v, want PermissionDenied", err)
+	}
+
+	newKey, _, _ := agentcert.NewKey()
+	newCSR, _ := agentcert.NewCSR(newKey, "a1")
+	renewed, err := agentcert.Renew(ctx, cc, newCSR)
+	if err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	cert, err := agentcert.ParseCertificatePEM(renewed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := agentcert.FromCertificate(cert)
+	if err != nil || id.AgentID != "a1" || len(id.Games) != 1 || id.Games[0] != "g1/prod" {
+		t.Fatalf("renewed identity = %+v, %v", id, err)
+	}
+	if !newKey.PublicKey.Equal(cert.PublicKey) {
+		t.Fatal("renewed certificate is not for the new key")
+	}
+
+	if err := os.WriteFile(revoked.Path, []byte("# revoked agents\nagent:a1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agentcert.Renew(ctx, cc, newCSR); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("renew revoked certificate: got %v, want PermissionDenied", err)
+	}
+	if _, err := client.Register(ctx, &serverv1.RegisterRequest{AgentId: "a1", GameId: "g1", Env: "prod"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("register with revoked certificate: got %v, want PermissionDenied", err)
+	}
+	if _, err := client.Heartbeat(ctx, &serverv1.HeartbeatRequest{AgentId: "a1"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("heartbeat with revoked certificate: got %v, want PermissionDenied", err)
+	}
+}
+
+func TestRevocationList(t *testing.T) {
+	ca := newCA(t)
+	key, _, _ := agentcert.NewKey()
+	csr, _ := agentcert.NewCSR(key, "a1")
+	req, _ := agentcert.ParseCSR(csr)
+	certPEM, _, err := ca.Issue(req, agentcert.Identity{AgentID: "a1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := agentcert.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := agentcert.RevocationList{Path: filepath.Join(t.TempDir(), "revoked.txt")}
+	cases := []struct {
+		content string
+		want    bool
+	}{
+		{"", false},
+		{"agent:a2\n# agent:a1\n", false},
+		{"agent:a1\n", true},
+		{strings.ToUpper(cert.SerialNumber.Text(16)) + "\n", true},
+	}
+	if revoked, err := list.Revoked(cert); err != nil || revoked {
+		t.Fatalf("missing list: %v, %v", revoked, err)
+	}
+	for _, c := range cases {
+		if err := os.WriteFile(list.Path, []byte(c.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if revoked, err := list.Revoked(cert); err != nil || revoked != c.want {
+			t.Errorf("list %q: revoked = %v, %v, want %v", c.content, revoked, err, c.want)
+		}
+	}
+}
+
+func newCA(t *testing.T) *agentcert.CA {
+	t.Helper()
+	caCrt, caKey, err := devcert.EnsureDevCA(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := agentcert.LoadCA(caCrt, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
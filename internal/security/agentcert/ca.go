@@ -0,0 +1,159 @@
+package agentcert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"time"
+)
+
+// clockSkew backdates certificates so hosts with slightly late clocks accept them.
+const clockSkew = 5 * time.Minute
+
+var validAgentID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// CA signs agent certificates.
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+	// PEM is the CA certificate handed to agents with their certificates.
+	PEM []byte
+}
+
+// LoadCA reads a CA certificate and its PKCS#1, PKCS#8 or EC private key.
+func LoadCA(certFile, keyFile string) (*CA, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca key: %w", err)
+	}
+	cb, _ := pem.Decode(certPEM)
+	if cb == nil {
+		return nil, errors.New("ca: invalid pem")
+	}
+	cert, err := x509.ParseCertificate(cb.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ca: %w", err)
+	}
+	if !cert.IsCA {
+		return nil, errors.New("ca: certificate is not a CA")
+	}
+	key, err := parseKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("ca key: %w", err)
+	}
+	return &CA{Cert: cert, Key: key, PEM: pem.EncodeToMemory(cb)}, nil
+}
+
+func parseKey(b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("invalid pem")
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported key type")
+	}
+	return s, nil
+}
+
+// Issue signs a certificate for the key of csr carrying id, valid for ttl. Only
+// the public key is taken from the request; the identity is the caller's decision.
+func (ca *CA) Issue(csr *x509.CertificateRequest, id Identity, ttl time.Duration) ([]byte, time.Time, error) {
+	if !validAgentID.MatchString(id.AgentID) {
+		return nil, time.Time{}, fmt.Errorf("invalid agent id %q", id.AgentID)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("csr signature: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	now := time.Now()
+	notAfter := now.Add(ttl)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: id.AgentID, OrganizationalUnit: []string{"croupier-agent"}},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		URIs:                  id.URIs(),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, csr.PublicKey, ca.Key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), notAfter, nil
+}
+
+// ParseCSR reads a PEM or DER certificate request.
+func ParseCSR(b []byte) (*x509.CertificateRequest, error) {
+	if block, _ := pem.Decode(b); block != nil {
+		if block.Type != "CERTIFICATE REQUEST" {
+			return nil, fmt.Errorf("unexpected pem block %q", block.Type)
+		}
+		b = block.Bytes
+	}
+	return x509.ParseCertificateRequest(b)
+}
+
+// NewKey returns a new agent key and its PEM encoding.
+func NewKey() (*ecdsa.PrivateKey, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// NewCSR returns a PEM certificate request for key naming agentID.
+func NewCSR(key crypto.Signer, agentID string) ([]byte, error) {
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: agentID},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+// ParseCertificatePEM reads the first certificate of a PEM bundle.
+func ParseCertificatePEM(b []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no certificate in pem")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
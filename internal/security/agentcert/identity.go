@@ -0,0 +1,121 @@
+// Package agentcert binds agent identities to client certificates issued by the
+// Croupier CA. A certificate names one agent id and the games, or game envs, the
+// agent may serve; the control plane trusts those over what an agent announces.
+package agentcert
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// URIs in certificates: spiffe://croupier/agent/<id> and
+// spiffe://croupier/game/<game>[/<env>], "*" as game allowing every game.
+const (
+	uriScheme    = "spiffe"
+	uriTrustHost = "croupier"
+	agentPath    = "/agent/"
+	gamePath     = "/game/"
+	AnyGame      = "*"
+)
+
+var (
+	ErrNoIdentity = errors.New("no verified agent certificate")
+	// ErrMismatch is returned when an agent announces an id or game its
+	// certificate does not grant.
+	ErrMismatch = errors.New("agent identity does not match certificate")
+)
+
+// Identity is what a certificate grants an agent.
+type Identity struct {
+	AgentID string
+	// Games lists "game" or "game/env" entries; AnyGame allows all games.
+	Games []string
+}
+
+// URIs encodes the identity as certificate URI SANs.
+func (id Identity) URIs() []*url.URL {
+	out := []*url.URL{{Scheme: uriScheme, Host: uriTrustHost, Path: agentPath + id.AgentID}}
+	for _, g := range id.Games {
+		out = append(out, &url.URL{Scheme: uriScheme, Host: uriTrustHost, Path: gamePath + g})
+	}
+	return out
+}
+
+// Allows reports whether the agent may serve gameID/env. Agents registering
+// without a game serve every game and so need AnyGame.
+func (id Identity) Allows(gameID, env string) bool {
+	for _, g := range id.Games {
+		if g == AnyGame {
+			return true
+		}
+		if gameID == "" {
+			continue
+		}
+		game, genv, scoped := strings.Cut(g, "/")
+		if game == gameID && (!scoped || genv == env) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check verifies that an agent announcing agentID for gameID/env is the one the
+// certificate names.
+func (id Identity) Check(agentID, gameID, env string) error {
+	if agentID != id.AgentID {
+		return ErrMismatch
+	}
+	if !id.Allows(gameID, env) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// FromCertificate reads the identity of an agent certificate.
+func FromCertificate(cert *x509.Certificate) (*Identity, error) {
+	id := &Identity{}
+	for _, u := range cert.URIs {
+		if u.Scheme != uriScheme || u.Host != uriTrustHost {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(u.Path, agentPath):
+			id.AgentID = strings.TrimPrefix(u.Path, agentPath)
+		case strings.HasPrefix(u.Path, gamePath):
+			id.Games = append(id.Games, strings.TrimPrefix(u.Path, gamePath))
+		}
+	}
+	if id.AgentID == "" {
+		return nil, ErrNoIdentity
+	}
+	return id, nil
+}
+
+// FromContext returns the identity of the verified client certificate of a gRPC
+// call. Without mTLS there is none.
+func FromContext(ctx context.Context) (*Identity, error) {
+	cert, err := PeerCertificate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return FromCertificate(cert)
+}
+
+// PeerCertificate returns the verified client certificate of a gRPC call.
+func PeerCertificate(ctx context.Context) (*x509.Certificate, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoIdentity
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, ErrNoIdentity
+	}
+	return info.State.VerifiedChains[0][0], nil
+}
@@ -0,0 +1,122 @@
+package agentcert
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	serverv1 "github.com/cuihairu/croupier/pkg/pb/croupier/server/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Revocations tells whether a certificate must no longer be trusted.
+type Revocations interface {
+	Revoked(cert *x509.Certificate) (bool, error)
+}
+
+// RevocationList is a file of revoked certificates, one per line: the hex serial
+// number of a certificate, or "agent:<id>" for every certificate of an agent. Blank
+// lines and lines starting with # are ignored. The file is read on every check so
+// edits apply at once; a missing file revokes nothing.
+type RevocationList struct {
+	Path string
+}
+
+func (l RevocationList) Revoked(cert *x509.Certificate) (bool, error) {
+	f, err := os.Open(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	serial := strings.ToLower(cert.SerialNumber.Text(16))
+	agent := ""
+	if id, err := FromCertificate(cert); err == nil {
+		agent = id.AgentID
+	}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if id, ok := strings.CutPrefix(line, "agent:"); ok {
+			if agent != "" && strings.TrimSpace(id) == agent {
+				return true, nil
+			}
+			continue
+		}
+		if strings.TrimLeft(strings.ToLower(strings.ReplaceAll(line, ":", "")), "0") == strings.TrimLeft(serial, "0") {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
+
+type renewer struct {
+	serverv1.UnimplementedCertificateServiceServer
+	ca      *CA
+	ttl     time.Duration
+	revoked Revocations
+}
+
+// Renew reissues the caller's certificate for a new key. The identity is copied
+// from the certificate the call was authenticated with, so agents can only
+// extend what they already hold, and revoked certificates cannot be extended.
+func (r *renewer) Renew(ctx context.Context, in *serverv1.RenewCertificateRequest) (*serverv1.RenewCertificateResponse, error) {
+	peer, err := PeerCertificate(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	id, err := FromCertificate(peer)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if r.revoked != nil {
+		revoked, err := r.revoked.Revoked(peer)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "revocation list: %v", err)
+		}
+		if revoked {
+			return nil, status.Errorf(codes.PermissionDenied, "certificate of agent %q is revoked", id.AgentID)
+		}
+	}
+	csr, err := ParseCSR(in.GetCsrPem())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "csr: %v", err)
+	}
+	cert, _, err := r.ca.Issue(csr, *id, r.ttl)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "issue: %v", err)
+	}
+	return &serverv1.RenewCertificateResponse{CertificatePem: cert}, nil
+}
+
+// RegisterRenewServer serves certificate renewal for agents on s, signing with ca.
+// s must require and verify client certificates. Certificates listed by revoked,
+// if not nil, are refused.
+func RegisterRenewServer(s *grpc.Server, ca *CA, ttl time.Duration, revoked Revocations) {
+	serverv1.RegisterCertificateServiceServer(s, &renewer{ca: ca, ttl: ttl, revoked: revoked})
+}
+
+// Renew asks the server behind cc to sign csrPEM for the identity of the client
+// certificate cc was dialed with.
+func Renew(ctx context.Context, cc grpc.ClientConnInterface, csrPEM []byte) ([]byte, error) {
+	out, err := serverv1.NewCertificateServiceClient(cc).Renew(ctx, &serverv1.RenewCertificateRequest{CsrPem: csrPEM})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.GetCertificatePem()) == 0 {
+		return nil, fmt.Errorf("renew: empty certificate")
+	}
+	return out.GetCertificatePem(), nil
+}
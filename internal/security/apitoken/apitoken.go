@@ -0,0 +1,255 @@
+// Package apitoken manages service accounts and their long-lived API tokens for
+// automation. Tokens are stored hashed and carry a lookup id in clear, so a token
+// can be identified from its prefix without storing the secret.
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/rbac"
+)
+
+// Prefix starts every API token: "crp_<id>_<secret>".
+const Prefix = "crp_"
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already exists")
+	// ErrNotWithin is returned for token permissions or scopes beyond the account.
+	ErrNotWithin = errors.New("not within account grants")
+)
+
+// Account is a non-human principal. Perms are bare permissions ("*" for all);
+// Scopes limit them to games ("game") or game envs ("game/env"), none meaning all.
+type Account struct {
+	Name        string
+	Description string
+	Perms       []string
+	Scopes      []string
+	Disabled    bool
+	CreatedBy   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Token is an API token of an account. Empty Perms or Scopes inherit those of the
+// account; AllowedIPs lists addresses or CIDRs the token may be used from, none
+// meaning any. A zero ExpiresAt never expires.
+type Token struct {
+	ID         string
+	Account    string
+	Name       string
+	Hash       string
+	Perms      []string
+	Scopes     []string
+	AllowedIPs []string
+	ExpiresAt  time.Time
+	CreatedBy  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	LastUsedIP string
+	RevokedAt  time.Time
+	RevokedBy  string
+}
+
+// Active reports whether t is neither revoked nor expired at now.
+func (t *Token) Active(now time.Time) bool {
+	return t.RevokedAt.IsZero() && (t.ExpiresAt.IsZero() || now.Before(t.ExpiresAt))
+}
+
+// AllowsIP reports whether the token may be used from ip.
+func (t *Token) AllowsIP(ip string) bool {
+	if len(t.AllowedIPs) == 0 {
+		return true
+	}
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return false
+	}
+	for _, a := range t.AllowedIPs {
+		if _, n, err := net.ParseCIDR(a); err == nil {
+			if n.Contains(addr) {
+				return true
+			}
+		} else if allowed := net.ParseIP(a); allowed != nil && allowed.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether hash is the hash of the token secret.
+func (t *Token) Match(hash string) bool {
+	return t.Hash != "" && subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) == 1
+}
+
+// Store persists accounts and tokens.
+type Store interface {
+	CreateAccount(ctx context.Context, a *Account) error
+	GetAccount(ctx context.Context, name string) (*Account, error)
+	ListAccounts(ctx context.Context) ([]*Account, error)
+	UpdateAccount(ctx context.Context, a *Account) error
+	// DeleteAccount removes the account with its tokens.
+	DeleteAccount(ctx context.Context, name string) error
+	CreateToken(ctx context.Context, t *Token) error
+	GetToken(ctx context.Context, id string) (*Token, error)
+	// ListTokens returns the tokens of account, newest first.
+	ListTokens(ctx context.Context, account string) ([]*Token, error)
+	UpdateToken(ctx context.Context, t *Token) error
+	// TouchToken records that the token was used at at from ip.
+	TouchToken(ctx context.Context, id string, at time.Time, ip string) error
+}
+
+// NewToken returns a token for a new id and the hash of its secret to store.
+func NewToken() (plain, id, hash string) {
+	id, secret := randomHex(6), randomHex(32)
+	return Prefix + id + "_" + secret, id, hashSecret(secret)
+}
+
+// IsToken reports whether s looks like an API token rather than a JWT.
+func IsToken(s string) bool { return strings.HasPrefix(s, Prefix) }
+
+// ParseToken splits a token into its id and the hash of its secret.
+func ParseToken(s string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), Prefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, hashSecret(secret), true
+}
+
+// NormalizePerms trims and dedupes perms. Scopes are set apart, so perms may not
+// carry one.
+func NormalizePerms(perms []string) ([]string, error) {
+	out := normalize(perms)
+	for _, p := range out {
+		if strings.Contains(p, rbac.ScopeSep) {
+			return nil, fmt.Errorf("permission %q: use scopes to limit games", p)
+		}
+	}
+	return out, nil
+}
+
+// NormalizeScopes trims and dedupes "game" and "game/env" scopes.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	out := normalize(scopes)
+	for _, s := range out {
+		game, env, hasEnv := strings.Cut(s, "/")
+		if game == "" || (hasEnv && (env == "" || strings.Contains(env, "/"))) || strings.Contains(s, rbac.ScopeSep) {
+			return nil, fmt.Errorf("scope %q: want game or game/env", s)
+		}
+	}
+	return out, nil
+}
+
+// NormalizeIPs trims allowlist entries and checks they are addresses or CIDRs.
+func NormalizeIPs(ips []string) ([]string, error) {
+	out := normalize(ips)
+	for _, ip := range out {
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return nil, fmt.Errorf("ip %q: want an address or CIDR", ip)
+			}
+		}
+	}
+	return out, nil
+}
+
+// Within checks that perms and scopes narrow those of a; empty ones inherit.
+func Within(a *Account, perms, scopes []string) error {
+	held := rbac.NewPolicy()
+	for _, p := range a.Perms {
+		held.Grant("user:account", p)
+	}
+	for _, p := range perms {
+		if !rbac.Allowed(held, "account", nil, p) {
+			return fmt.Errorf("%w: permission %s", ErrNotWithin, p)
+		}
+	}
+	if len(a.Scopes) == 0 {
+		return nil
+	}
+	for _, s := range scopes {
+		if !scopeWithin(s, a.Scopes) {
+			return fmt.Errorf("%w: scope %s", ErrNotWithin, s)
+		}
+	}
+	return nil
+}
+
+func scopeWithin(s string, scopes []string) bool {
+	game, _, _ := strings.Cut(s, "/")
+	for _, o := range scopes {
+		if o == s || o == game {
+			return true
+		}
+	}
+	return false
+}
+
+// Grants returns the RBAC grants of t: its permissions qualified by each of its
+// scopes. Permissions or scopes the account no longer holds are dropped, so
+// narrowing an account narrows its tokens.
+func Grants(a *Account, t *Token) []string {
+	perms, scopes := t.Perms, t.Scopes
+	if len(perms) == 0 {
+		perms = a.Perms
+	}
+	if len(scopes) == 0 {
+		scopes = a.Scopes
+	}
+	var out []string
+	for _, p := range perms {
+		if Within(a, []string{p}, nil) != nil {
+			continue
+		}
+		if len(scopes) == 0 {
+			out = append(out, p)
+			continue
+		}
+		for _, s := range scopes {
+			if len(a.Scopes) > 0 && !scopeWithin(s, a.Scopes) {
+				continue
+			}
+			game, env, _ := strings.Cut(s, "/")
+			out = append(out, rbac.Scoped(p, game, env))
+		}
+	}
+	return out
+}
+
+func normalize(in []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
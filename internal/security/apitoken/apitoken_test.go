@@ -0,0 +1,131 @@
+package apitoken
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	plain, id, hash := NewToken()
+	if !IsToken(plain) {
+		t.Fatalf("token %q lacks prefix", plain)
+	}
+	gotID, got, ok := ParseToken(plain)
+	if !ok || gotID != id {
+		t.Fatalf("ParseToken(%q) = %q, %v", plain, gotID, ok)
+	}
+	tok := &Token{ID: id, Hash: hash}
+	if !tok.Match(got) {
+		t.Fatal("token does not match its hash")
+	}
+	other, _, _ := NewToken()
+	if _, h, _ := ParseToken(other); tok.Match(h) {
+		t.Fatal("another token matched")
+	}
+	for _, bad := range []string{"crp_", "crp_abc", "eyJhbGciOi.x.y", "crp__secret"} {
+		if _, _, ok := ParseToken(bad); ok {
+			t.Fatalf("parsed %q", bad)
+		}
+	}
+}
+
+func TestTokenActiveAndIPs(t *testing.T) {
+	now := time.Now()
+	tok := &Token{AllowedIPs: []string{"10.0.0.0/8", "192.168.1.5"}}
+	if !tok.Active(now) {
+		t.Fatal("token without expiry inactive")
+	}
+	tok.ExpiresAt = now.Add(-time.Second)
+	if tok.Active(now) {
+		t.Fatal("expired token active")
+	}
+	for ip, want := range map[string]bool{"10.1.2.3": true, "192.168.1.5": true, "192.168.1.6": false, "junk": false} {
+		if got := tok.AllowsIP(ip); got != want {
+			t.Fatalf("AllowsIP(%s) = %v", ip, got)
+		}
+	}
+}
+
+func TestGrants(t *testing.T) {
+	acct := &Account{Name: "ci", Perms: []string{"functions:invoke", "games:read"}, Scopes: []string{"game1", "game2/prod"}}
+	cases := []struct {
+		perms, scopes []string
+		want          []string
+		err           bool
+	}{
+		{want: []string{"functions:invoke@game1", "functions:invoke@game2/prod", "games:read@game1", "games:read@game2/prod"}},
+		{perms: []string{"games:read"}, scopes: []string{"game1/dev"}, want: []string{"games:read@game1/dev"}},
+		{perms: []string{"user:write"}, scopes: []string{"game1"}, err: true},
+		{scopes: []string{"game2/dev"}, err: true},
+		{scopes: []string{"game2"}, err: true},
+	}
+	for i, c := range cases {
+		err := Within(acct, c.perms, c.scopes)
+		if c.err {
+			if !errors.Is(err, ErrNotWithin) {
+				t.Fatalf("case %d: Within = %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if got := Grants(acct, &Token{Perms: c.perms, Scopes: c.scopes}); !slices.Equal(got, c.want) {
+			t.Fatalf("case %d: Grants = %v", i, got)
+		}
+	}
+	// Narrowing the account narrows existing tokens.
+	tok := &Token{Perms: []string{"functions:invoke", "games:read"}, Scopes: []string{"game1"}}
+	acct.Perms = []string{"games:read"}
+	if got := Grants(acct, tok); !slices.Equal(got, []string{"games:read@game1"}) {
+		t.Fatalf("Grants after narrowing = %v", got)
+	}
+	if err := Within(&Account{Perms: []string{"*"}}, []string{"anything"}, []string{"g"}); err != nil {
+		t.Fatalf("wildcard account: %v", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if _, err := NormalizePerms([]string{"player.ban@game1"}); err == nil {
+		t.Fatal("scoped permission accepted")
+	}
+	if got, _ := NormalizeScopes([]string{" game1 ", "game1", "game2/prod"}); !slices.Equal(got, []string{"game1", "game2/prod"}) {
+		t.Fatalf("NormalizeScopes = %v", got)
+	}
+	for _, bad := range []string{"/prod", "game/", "a/b/c"} {
+		if _, err := NormalizeScopes([]string{bad}); err == nil {
+			t.Fatalf("scope %q accepted", bad)
+		}
+	}
+	if _, err := NormalizeIPs([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("bad CIDR accepted")
+	}
+}
+
+func TestMemStoreDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemStore()
+	if err := st.CreateToken(ctx, &Token{ID: "x", Account: "ci"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("token for unknown account: %v", err)
+	}
+	_ = st.CreateAccount(ctx, &Account{Name: "ci"})
+	now := time.Now()
+	_ = st.CreateToken(ctx, &Token{ID: "a", Account: "ci", CreatedAt: now})
+	_ = st.CreateToken(ctx, &Token{ID: "b", Account: "ci", CreatedAt: now.Add(time.Second)})
+	if err := st.TouchToken(ctx, "a", now, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	toks, _ := st.ListTokens(ctx, "ci")
+	if len(toks) != 2 || toks[0].ID != "b" || toks[1].LastUsedIP != "10.0.0.1" {
+		t.Fatalf("tokens = %+v", toks)
+	}
+	if err := st.DeleteAccount(ctx, "ci"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.GetToken(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("token outlived its account: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package apitoken
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemStore is an in-memory Store for tests and single-process development.
+type MemStore struct {
+	mu       sync.RWMutex
+	accounts map[string]*Account
+	tokens   map[string]*Token
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{accounts: map[string]*Account{}, tokens: map[string]*Token{}}
+}
+
+func (m *MemStore) CreateAccount(_ context.Context, a *Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[a.Name]; ok {
+		return ErrExists
+	}
+	m.accounts[a.Name] = cloneAccount(a)
+	return nil
+}
+
+func (m *MemStore) GetAccount(_ context.Context, name string) (*Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a := m.accounts[name]
+	if a == nil {
+		return nil, ErrNotFound
+	}
+	return cloneAccount(a), nil
+}
+
+func (m *MemStore) ListAccounts(_ context.Context) ([]*Account, error) {
+	m.mu.RLock()
+	out := make([]*Account, 0, len(m.accounts))
+	for _, a := range m.accounts {
+		out = append(out, cloneAccount(a))
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *MemStore) UpdateAccount(_ context.Context, a *Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[a.Name]; !ok {
+		return ErrNotFound
+	}
+	m.accounts[a.Name] = cloneAccount(a)
+	return nil
+}
+
+func (m *MemStore) DeleteAccount(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m.accounts, name)
+	for id, t := range m.tokens {
+		if t.Account == name {
+			delete(m.tokens, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemStore) CreateToken(_ context.Context, t *Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[t.Account]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.tokens[t.ID]; ok {
+		return ErrExists
+	}
+	m.tokens[t.ID] = cloneToken(t)
+	return nil
+}
+
+func (m *MemStore) GetToken(_ context.Context, id string) (*Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t := m.tokens[id]
+	if t == nil {
+		return nil, ErrNotFound
+	}
+	return cloneToken(t), nil
+}
+
+func (m *MemStore) ListTokens(_ context.Context, account string) ([]*Token, error) {
+	m.mu.RLock()
+	out := make([]*Token, 0)
+	for _, t := range m.tokens {
+		if t.Account == account {
+			out = append(out, cloneToken(t))
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (m *MemStore) UpdateToken(_ context.Context, t *Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tokens[t.ID]; !ok {
+		return ErrNotFound
+	}
+	m.tokens[t.ID] = cloneToken(t)
+	return nil
+}
+
+func (m *MemStore) TouchToken(_ context.Context, id string, at time.Time, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.tokens[id]
+	if t == nil {
+		return ErrNotFound
+	}
+	t.LastUsedAt, t.LastUsedIP = at, ip
+	return nil
+}
+
+func cloneAccount(a *Account) *Account {
+	cp := *a
+	cp.Perms = append([]string(nil), a.Perms...)
+	cp.Scopes = append([]string(nil), a.Scopes...)
+	return &cp
+}
+
+func cloneToken(t *Token) *Token {
+	cp := *t
+	cp.Perms = append([]string(nil), t.Perms...)
+	cp.Scopes = append([]string(nil), t.Scopes...)
+	cp.AllowedIPs = append([]string(nil), t.AllowedIPs...)
+	return &cp
+}
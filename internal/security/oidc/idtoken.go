@@ -0,0 +1,147 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/token"
+)
+
+// clockSkew is tolerated between the provider and us when checking exp and iat.
+const clockSkew = time.Minute
+
+var ErrInvalidIDToken = errors.New("oidc: invalid id token")
+
+// IDToken is a verified ID token.
+type IDToken struct {
+	Issuer  string
+	Subject string
+	// AMR lists the authentication methods the provider used, e.g. "pwd", "mfa".
+	AMR []string
+	// Claims holds every claim of the token for claim and group mappings.
+	Claims map[string]any
+}
+
+// String returns a string claim, or "" if it is missing or not a string.
+func (t *IDToken) String(name string) string {
+	s, _ := t.Claims[name].(string)
+	return strings.TrimSpace(s)
+}
+
+// Strings returns a claim that is a string or a list of strings, as providers
+// differ in how they encode e.g. groups.
+func (t *IDToken) Strings(name string) []string {
+	switch v := t.Claims[name].(type) {
+	case string:
+		return dedupe(strings.Fields(strings.ReplaceAll(v, ",", " ")))
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return dedupe(out)
+	}
+	return nil
+}
+
+// MFA reports whether the provider says the user completed a second factor.
+func (t *IDToken) MFA() bool {
+	for _, m := range t.AMR {
+		switch m {
+		case "mfa", "otp", "hwk", "swk", "sms", "fpt":
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks the signature of raw against the provider keys, then its issuer,
+// audience, expiry and nonce.
+func (p *Provider) Verify(ctx context.Context, raw, nonce string) (*IDToken, error) {
+	keys, err := p.keySet(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]any{}
+	if _, err = keys.Decode(raw, &claims); errors.Is(err, token.ErrUnknownKey) {
+		if keys, err = p.keySet(ctx, true); err != nil {
+			return nil, err
+		}
+		_, err = keys.Decode(raw, &claims)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	var std struct {
+		Iss   string          `json:"iss"`
+		Sub   string          `json:"sub"`
+		Aud   json.RawMessage `json:"aud"`
+		Azp   string          `json:"azp"`
+		Exp   float64         `json:"exp"`
+		Iat   float64         `json:"iat"`
+		Nonce string          `json:"nonce"`
+		AMR   []string        `json:"amr"`
+	}
+	b, _ := json.Marshal(claims)
+	if err := json.Unmarshal(b, &std); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	now := time.Now()
+	switch {
+	case std.Iss != p.cfg.Issuer:
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, std.Iss)
+	case std.Sub == "":
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	case !audienceOK(std.Aud, std.Azp, p.cfg.ClientID):
+		return nil, fmt.Errorf("%w: audience", ErrInvalidIDToken)
+	case std.Exp == 0 || now.After(time.Unix(int64(std.Exp), 0).Add(clockSkew)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case std.Iat > 0 && time.Unix(int64(std.Iat), 0).After(now.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case subtle.ConstantTimeCompare([]byte(std.Nonce), []byte(nonce)) != 1:
+		return nil, fmt.Errorf("%w: nonce", ErrInvalidIDToken)
+	}
+	return &IDToken{Issuer: std.Iss, Subject: std.Sub, AMR: std.AMR, Claims: claims}, nil
+}
+
+// audienceOK requires clientID in aud; with several audiences the token must also
+// have been issued to clientID.
+func audienceOK(raw json.RawMessage, azp, clientID string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == clientID
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) != nil {
+		return false
+	}
+	for _, a := range many {
+		if a == clientID {
+			return len(many) == 1 || azp == clientID
+		}
+	}
+	return false
+}
+
+// NewState returns a random value for state, nonce or a PKCE verifier.
+func NewState() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the PKCE S256 challenge of verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
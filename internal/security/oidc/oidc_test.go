@@ -0,0 +1,105 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/cuihairu/croupier/internal/security/oidc"
+	"github.com/cuihairu/croupier/internal/security/oidc/oidctest"
+)
+
+func login(t *testing.T, idp *oidctest.Server, p *oidc.Provider, nonce string) (string, string) {
+	t.Helper()
+	verifier := oidc.NewState()
+	cb, err := idp.Login(p.AuthCodeURL("st", nonce, oidc.Challenge(verifier)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(cb)
+	if u.Query().Get("state") != "st" {
+		t.Fatalf("state not echoed: %s", cb)
+	}
+	return u.Query().Get("code"), verifier
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	idp, stop, err := oidctest.Start("croupier", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	idp.SetUser("bob", "bob@example.com", []string{"ops", "game1-ops"})
+	ctx := context.Background()
+	p, err := oidc.Discover(ctx, oidc.Config{Issuer: idp.Issuer, ClientID: "croupier", ClientSecret: "s3cret", RedirectURL: "http://app/cb", Scopes: []string{"email", "groups"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, verifier := login(t, idp, p, "n1")
+	raw, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := p.Verify(ctx, raw, "n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Subject != "sub-bob" || tok.String("preferred_username") != "bob" {
+		t.Fatalf("token = %+v", tok)
+	}
+	if g := tok.Strings("groups"); len(g) != 2 || g[1] != "game1-ops" {
+		t.Fatalf("groups = %v", g)
+	}
+	if _, err := p.Verify(ctx, raw, "other"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("wrong nonce: %v", err)
+	}
+
+	// Codes are single use and bound to the PKCE verifier.
+	if _, err := p.Exchange(ctx, code, verifier); err == nil {
+		t.Fatal("code redeemed twice")
+	}
+	code, _ = login(t, idp, p, "n2")
+	if _, err := p.Exchange(ctx, code, oidc.NewState()); err == nil {
+		t.Fatal("exchange with the wrong verifier")
+	}
+}
+
+func TestVerifyRejectsOtherAudience(t *testing.T) {
+	idp, stop, err := oidctest.Start("other-client", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	ctx := context.Background()
+	other, err := oidc.Discover(ctx, oidc.Config{Issuer: idp.Issuer, ClientID: "other-client", RedirectURL: "http://app/cb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, verifier := login(t, idp, other, "n")
+	raw, err := other.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A token issued to another client of the same provider must not log in here.
+	p, err := oidc.Discover(ctx, oidc.Config{Issuer: idp.Issuer, ClientID: "croupier"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Verify(ctx, raw, "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("Verify = %v", err)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	idp, stop, err := oidctest.Start("croupier", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	idp.Issuer = "https://elsewhere.example"
+	if _, err := oidc.Discover(context.Background(), oidc.Config{Issuer: idp.URL(), ClientID: "croupier"}); err == nil {
+		t.Fatal("accepted metadata for another issuer")
+	}
+}
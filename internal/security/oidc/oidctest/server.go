@@ -0,0 +1,240 @@
+// Package oidctest provides a minimal OpenID provider for tests and local
+// development. It signs in whoever asks, as the user configured with SetUser, so it
+// must never be exposed beyond a developer machine.
+package oidctest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/oidc"
+	"github.com/cuihairu/croupier/internal/security/token"
+)
+
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      map[string]any
+	expires     time.Time
+}
+
+// Server is a mock OpenID provider supporting discovery, the authorization code
+// flow with PKCE S256 and RS256 ID tokens.
+type Server struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// TTL is the lifetime of ID tokens; one hour by default.
+	TTL time.Duration
+
+	url  string
+	keys *token.Manager
+	mux  *http.ServeMux
+
+	mu     sync.Mutex
+	claims map[string]any
+	codes  map[string]*grant
+}
+
+// New returns a provider for issuer that accepts the client clientID. An empty
+// clientSecret accepts public clients.
+func New(issuer, clientID, clientSecret string) (*Server, error) {
+	k, err := token.GenerateKey("mock-1", token.RS256)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := token.NewKeySet(k.ID, k)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		url:          strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TTL:          time.Hour,
+		keys:         keys,
+		mux:          http.NewServeMux(),
+		codes:        map[string]*grant{},
+	}
+	s.SetUser("alice", "alice@example.com", nil)
+	s.mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	s.mux.HandleFunc("/authorize", s.authorize)
+	s.mux.HandleFunc("/token", s.token)
+	s.mux.HandleFunc("/jwks", s.jwks)
+	return s, nil
+}
+
+// Start serves a new provider on a local test server and returns it with the
+// function that stops it.
+func Start(clientID, clientSecret string) (*Server, func(), error) {
+	var s *Server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { s.ServeHTTP(w, r) }))
+	s, err := New(ts.URL, clientID, clientSecret)
+	if err != nil {
+		ts.Close()
+		return nil, nil, err
+	}
+	return s, ts.Close, nil
+}
+
+// URL is where the provider is served; Issuer may be changed to test clients.
+func (s *Server) URL() string { return s.url }
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) { s.mux.ServeHTTP(w, r) }
+
+// SetUser sets who the next logins sign in as.
+func (s *Server) SetUser(username, email string, groups []string) {
+	s.SetClaims(map[string]any{
+		"sub":                "sub-" + username,
+		"preferred_username": username,
+		"email":              email,
+		"name":               username,
+		"groups":             groups,
+	})
+}
+
+// SetClaims replaces the claims of the next logins. iss, aud, exp, iat and nonce
+// are always set by the provider.
+func (s *Server) SetClaims(claims map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims = claims
+}
+
+// Login runs the authorization step of a browser login to authURL, as produced by
+// oidc.Provider.AuthCodeURL, and returns the callback URL the browser would be
+// redirected to.
+func (s *Server) Login(authURL string) (string, error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", fmt.Errorf("authorize: %s", resp.Status)
+	}
+	return resp.Header.Get("Location"), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.Issuer,
+		"authorization_endpoint":                s.url + "/authorize",
+		"token_endpoint":                        s.url + "/token",
+		"jwks_uri":                              s.url + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{token.RS256},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect := q.Get("redirect_uri")
+	switch {
+	case q.Get("client_id") != s.ClientID:
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	case redirect == "" || q.Get("response_type") != "code":
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		http.Error(w, "pkce required", http.StatusBadRequest)
+		return
+	}
+	code := oidc.NewState()
+	s.mu.Lock()
+	s.codes[code] = &grant{
+		redirectURI: redirect,
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		claims:      s.claims,
+		expires:     time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+	u, err := url.Parse(redirect)
+	if err != nil {
+		http.Error(w, "bad redirect_uri", http.StatusBadRequest)
+		return
+	}
+	cb := u.Query()
+	cb.Set("code", code)
+	cb.Set("state", q.Get("state"))
+	u.RawQuery = cb.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != s.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(s.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	g := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if r.PostForm.Get("grant_type") != "authorization_code" || g == nil || time.Now().After(g.expires) ||
+		g.redirectURI != r.PostForm.Get("redirect_uri") || oidc.Challenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	now := time.Now()
+	claims := map[string]any{}
+	for k, v := range g.claims {
+		claims[k] = v
+	}
+	claims["iss"] = s.Issuer
+	claims["aud"] = s.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.TTL).Unix()
+	claims["nonce"] = g.nonce
+	idToken, err := s.keys.Encode(claims)
+	if err != nil {
+		tokenError(w, "server_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": oidc.NewState(),
+		"token_type":   "Bearer",
+		"expires_in":   int(s.TTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(s.keys.JWKS())
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
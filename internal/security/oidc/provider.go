@@ -0,0 +1,210 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE for
+// single sign-on: provider discovery, the authorization redirect, the code exchange
+// and ID token validation against the provider's JWKS.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/token"
+)
+
+// jwksRefreshInterval limits how often an unknown key id refetches the JWKS.
+const jwksRefreshInterval = time.Minute
+
+// Config describes the client registration at the provider.
+type Config struct {
+	// Issuer is the provider URL; discovery reads Issuer/.well-known/openid-configuration.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested in addition to "openid".
+	Scopes []string
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+// Provider is a discovered OpenID provider.
+type Provider struct {
+	cfg      Config
+	client   *http.Client
+	authURL  string
+	tokenURL string
+	jwksURL  string
+
+	mu        sync.Mutex
+	keys      *token.Manager
+	fetchedAt time.Time
+}
+
+type discovery struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+}
+
+// Discover reads the provider metadata of cfg.Issuer. The metadata must name the
+// same issuer, so a provider cannot claim to speak for another.
+func Discover(ctx context.Context, cfg Config) (*Provider, error) {
+	issuer := strings.TrimRight(strings.TrimSpace(cfg.Issuer), "/")
+	if issuer == "" || strings.TrimSpace(cfg.ClientID) == "" {
+		return nil, errors.New("oidc: issuer and client id required")
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	var d discovery
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", d.Issuer, issuer)
+	}
+	if d.AuthURL == "" || d.TokenURL == "" || d.JWKSURL == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+	cfg.Issuer = d.Issuer
+	return &Provider{cfg: cfg, client: client, authURL: d.AuthURL, tokenURL: d.TokenURL, jwksURL: d.JWKSURL}, nil
+}
+
+// Issuer returns the issuer as named by the provider.
+func (p *Provider) Issuer() string { return p.cfg.Issuer }
+
+// AuthCodeURL returns the authorization endpoint URL that starts a login. state and
+// nonce are echoed back in the callback and the ID token; challenge is the PKCE
+// S256 challenge of the verifier passed to Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, challenge string) string {
+	scopes := append([]string{"openid"}, p.cfg.Scopes...)
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(dedupe(scopes), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.authURL, "?") {
+		sep = "&"
+	}
+	return p.authURL + sep + q.Encode()
+}
+
+// Exchange redeems an authorization code and returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	var tr struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+		Desc    string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		return "", fmt.Errorf("oidc token exchange: %d %s %s", resp.StatusCode, tr.Error, tr.Desc)
+	}
+	if tr.IDToken == "" {
+		return "", errors.New("oidc token exchange: no id_token")
+	}
+	return tr.IDToken, nil
+}
+
+// keySet returns the provider keys, fetching them when missing or, with refresh,
+// when they are older than jwksRefreshInterval, e.g. after the provider rotated.
+func (p *Provider) keySet(ctx context.Context, refresh bool) (*token.Manager, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil && (!refresh || time.Since(p.fetchedAt) < jwksRefreshInterval) {
+		return p.keys, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc jwks: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	keys, err := token.ParseJWKS(body)
+	if err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	p.keys, p.fetchedAt = token.NewVerifier(keys...), time.Now()
+	return p.keys, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+func dedupe(in []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
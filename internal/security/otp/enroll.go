@@ -0,0 +1,58 @@
+package otp
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"net/url"
+	"strings"
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random 160-bit secret, base32 encoded without padding.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(b), nil
+}
+
+// URI returns the otpauth:// key URI authenticator apps import, usually from a QR
+// code, for account at issuer.
+func URI(issuer, account, secret string) string {
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	q := url.Values{}
+	q.Set("secret", secret)
+	if issuer != "" {
+		q.Set("issuer", issuer)
+	}
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", "6")
+	q.Set("period", "30")
+	return "otpauth://totp/" + url.PathEscape(label) + "?" + q.Encode()
+}
+
+// GenerateRecoveryCodes returns n single-use recovery codes formatted as
+// "xxxxx-xxxxx".
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	out := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(secretEncoding.EncodeToString(b))[:10]
+		out = append(out, s[:5]+"-"+s[5:])
+	}
+	return out, nil
+}
+
+// NormalizeRecoveryCode strips separators and case so codes compare as typed.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,49 @@
+package otp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPRoundTrip(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	code, err := GenerateTOTP(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, ok := MatchTOTP(secret, code, 1, now.Add(30*time.Second))
+	if !ok || step != now.Unix()/30 {
+		t.Fatalf("MatchTOTP = %d, %v", step, ok)
+	}
+	if _, ok := MatchTOTP(secret, code, 1, now.Add(2*time.Minute)); ok {
+		t.Fatal("code accepted outside skew")
+	}
+}
+
+func TestRFC6238Vector(t *testing.T) {
+	// RFC 6238 appendix B, SHA1 seed "12345678901234567890", truncated to 6 digits.
+	secret := secretEncoding.EncodeToString([]byte("12345678901234567890"))
+	code, err := GenerateTOTP(secret, time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Fatalf("GenerateTOTP = %q, %v", code, err)
+	}
+}
+
+func TestURIAndRecoveryCodes(t *testing.T) {
+	uri := URI("Croupier", "alice", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Croupier:alice?") || !strings.Contains(uri, "secret=ABC") {
+		t.Fatalf("URI = %s", uri)
+	}
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil || len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes = %v, %v", codes, err)
+	}
+	if NormalizeRecoveryCode(strings.ToUpper(codes[0])) != strings.ReplaceAll(codes[0], "-", "") {
+		t.Fatalf("normalize %s", codes[0])
+	}
+}
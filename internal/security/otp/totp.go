@@ -3,6 +3,7 @@ package otp
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/subtle"
 	"encoding/base32"
 	"encoding/binary"
 	"strconv"
@@ -13,25 +14,44 @@ import (
 // VerifyTOTP verifies an RFC 6238 TOTP code with 30s step and given skew steps.
 // secret can be base32 (no padding) as common authenticator apps export.
 func VerifyTOTP(secret string, code string, skew int) bool {
+	_, ok := MatchTOTP(secret, code, skew, time.Now())
+	return ok
+}
+
+// MatchTOTP is VerifyTOTP at time now and also returns the time step the code
+// matched, which callers can remember to reject a code that is used twice.
+func MatchTOTP(secret string, code string, skew int, now time.Time) (int64, bool) {
 	if len(code) < 6 || len(code) > 8 {
-		return false
+		return 0, false
 	}
-	// decode base32 (ignore padding and case)
-	s := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
-	s = strings.TrimSpace(s)
-	dec, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	dec, err := decodeSecret(secret)
 	if err != nil || len(dec) == 0 {
-		return false
+		return 0, false
 	}
-	now := time.Now().Unix()
-	step := now / 30
+	step := now.Unix() / 30
 	// check within [-skew, +skew]
 	for i := -skew; i <= skew; i++ {
-		if hotp(dec, uint64(step+int64(i)), 6) == code {
-			return true
+		if subtle.ConstantTimeCompare([]byte(hotp(dec, uint64(step+int64(i)), 6)), []byte(code)) == 1 {
+			return step + int64(i), true
 		}
 	}
-	return false
+	return 0, false
+}
+
+// GenerateTOTP returns the 6 digit code for secret at time t.
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	dec, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(dec, uint64(t.Unix()/30), 6), nil
+}
+
+// decodeSecret decodes a base32 secret, ignoring padding, spaces and case.
+func decodeSecret(secret string) ([]byte, error) {
+	s := strings.TrimSpace(strings.ToUpper(strings.ReplaceAll(secret, " ", "")))
+	s = strings.TrimRight(s, "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
 }
 
 func hotp(key []byte, counter uint64, digits int) string {
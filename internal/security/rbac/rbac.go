@@ -1,6 +1,9 @@
 package rbac
 
-import "net/http"
+import (
+	"net/http"
+	"sort"
+)
 
 // PolicyInterface defines the interface for authorization policies
 type PolicyInterface interface {
@@ -37,6 +40,18 @@ func (p *Policy) Can(user, perm string) bool {
 	return false
 }
 
+// Grants lists the permissions granted to user, sorted.
+func (p *Policy) Grants(user string) []string {
+	out := make([]string, 0, len(p.allow[user]))
+	for perm, ok := range p.allow[user] {
+		if ok {
+			out = append(out, perm)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
 // CanHTTP implements PolicyInterface for legacy compatibility
 func (p *Policy) CanHTTP(user string, roles []string, r *http.Request) bool {
 	// This is a simple implementation for the legacy policy
@@ -0,0 +1,112 @@
+package rbac
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Reloader holds the policy loaded from a config file and swaps it when the file, or
+// the Casbin model/policy files next to it, change. A failed reload keeps the
+// previous policy.
+type Reloader struct {
+	path string
+
+	mu     sync.RWMutex
+	policy PolicyInterface
+}
+
+// NewReloader loads the policy at path with LoadPolicyAuto.
+func NewReloader(path string) (*Reloader, error) {
+	r := &Reloader{path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Path returns the watched config file.
+func (r *Reloader) Path() string { return r.path }
+
+// Policy returns the current policy.
+func (r *Reloader) Policy() PolicyInterface {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.policy
+}
+
+// Can checks perm for user/roles against the current policy, see Allowed.
+func (r *Reloader) Can(user string, roles []string, perm string) bool {
+	return Allowed(r.Policy(), user, roles, perm)
+}
+
+// Reload loads the policy file again and swaps it in on success.
+func (r *Reloader) Reload() error {
+	p, err := LoadPolicyAuto(r.path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.policy = p
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the policy whenever its files change until ctx is done. The
+// directory is watched rather than the file so editors that replace the file by
+// rename are picked up; bursts of events are coalesced.
+func (r *Reloader) Watch(ctx context.Context) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(r.path)
+	if err := w.Add(dir); err != nil {
+		w.Close()
+		return err
+	}
+	watched := map[string]bool{
+		filepath.Clean(r.path):                true,
+		filepath.Join(dir, "rbac_model.conf"): true,
+		filepath.Join(dir, "rbac_policy.csv"): true,
+	}
+	go func() {
+		defer w.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if watched[filepath.Clean(ev.Name)] && ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					debounce = time.After(200 * time.Millisecond)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[RBAC] watch %s: %v", dir, err)
+			case <-debounce:
+				debounce = nil
+				if _, err := os.Stat(r.path); err != nil {
+					log.Printf("[RBAC] policy file unavailable, keeping previous policy: %v", err)
+					continue
+				}
+				if err := r.Reload(); err != nil {
+					log.Printf("[RBAC] reload %s failed, keeping previous policy: %v", r.path, err)
+					continue
+				}
+				log.Printf("[RBAC] policy reloaded from %s", r.path)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,89 @@
+package rbac
+
+import "strings"
+
+// ScopeSep separates a permission from its game/env scope, e.g. "player.ban@game1/prod".
+const ScopeSep = "@"
+
+// Scoped qualifies perm with a game and optional env. Without a game perm is returned
+// unchanged.
+func Scoped(perm, gameID, env string) string {
+	perm = strings.TrimSpace(perm)
+	gameID = strings.TrimSpace(gameID)
+	if perm == "" || gameID == "" {
+		return perm
+	}
+	if env = strings.TrimSpace(env); env != "" {
+		return perm + ScopeSep + gameID + "/" + env
+	}
+	return perm + ScopeSep + gameID
+}
+
+// Candidates lists the grants that satisfy perm, most specific first. A grant without
+// scope covers every game, "perm@game" covers every env of that game and "*" in place
+// of the permission covers all permissions within the scope:
+//
+//	player.ban@game1/prod -> player.ban@game1/prod, player.ban@game1, player.ban,
+//	                         *@game1/prod, *@game1, *
+func Candidates(perm string) []string {
+	base, scope, scoped := strings.Cut(perm, ScopeSep)
+	if !scoped || scope == "" {
+		if perm == "*" {
+			return []string{"*"}
+		}
+		return []string{perm, "*"}
+	}
+	scopes := []string{scope}
+	if game, _, ok := strings.Cut(scope, "/"); ok && game != "" {
+		scopes = append(scopes, game)
+	}
+	out := make([]string, 0, 2*len(scopes)+2)
+	for _, s := range scopes {
+		out = append(out, base+ScopeSep+s)
+	}
+	out = append(out, base)
+	for _, s := range scopes {
+		out = append(out, "*"+ScopeSep+s)
+	}
+	return append(out, "*")
+}
+
+// Subjects lists the policy subjects a user acts as: "user:<name>" and each role as
+// "role:<name>". Roles are also listed bare, as older policies grant them, but the
+// user never is, so a user named like a role does not get its grants. Roles spelled
+// "user:<name>" are dropped for the same reason.
+func Subjects(user string, roles []string) []string {
+	out := make([]string, 0, 1+2*len(roles))
+	if user != "" {
+		out = append(out, "user:"+user)
+	}
+	for _, r := range roles {
+		r = strings.TrimSpace(r)
+		if r == "" || strings.HasPrefix(r, "user:") {
+			continue
+		}
+		if strings.HasPrefix(r, "role:") {
+			out = append(out, r)
+			continue
+		}
+		out = append(out, r, "role:"+r)
+	}
+	return out
+}
+
+// Allowed reports whether any subject of user/roles holds a grant that satisfies perm
+// in p.
+func Allowed(p PolicyInterface, user string, roles []string, perm string) bool {
+	if p == nil {
+		return false
+	}
+	cands := Candidates(perm)
+	for _, sub := range Subjects(user, roles) {
+		for _, c := range cands {
+			if p.Can(sub, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package rbac
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAllowedScopes(t *testing.T) {
+	p := NewPolicy()
+	p.Grant("role:gm", "player.ban@game1/prod")
+	p.Grant("role:ops", "*@game2")
+	p.Grant("user:alice", "player.kick")
+	p.Grant("ops", "player.mute")
+
+	cases := []struct {
+		user  string
+		roles []string
+		perm  string
+		want  bool
+	}{
+		{"bob", []string{"gm"}, Scoped("player.ban", "game1", "prod"), true},
+		{"bob", []string{"gm"}, Scoped("player.ban", "game1", "test"), false},
+		{"bob", []string{"gm"}, "player.ban", false},
+		{"carol", []string{"ops"}, Scoped("player.ban", "game2", "prod"), true},
+		{"carol", []string{"ops"}, Scoped("player.ban", "game1", "prod"), false},
+		{"alice", nil, Scoped("player.kick", "game9", "dev"), true},
+		{"alice", nil, "player.ban", false},
+		{"dave", []string{"ops"}, "player.mute", true},
+		{"ops", nil, "player.mute", false},
+		{"ops", []string{"user:alice"}, "player.kick", false},
+	}
+	for _, c := range cases {
+		if got := Allowed(p, c.user, c.roles, c.perm); got != c.want {
+			t.Errorf("Allowed(%s %v %s) = %v, want %v", c.user, c.roles, c.perm, got, c.want)
+		}
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.json")
+	if err := os.WriteFile(path, []byte(`{"allow":{"role:gm":["player.ban"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := r.Watch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Can("bob", []string{"gm"}, "player.ban") || r.Can("bob", []string{"gm"}, "player.kick") {
+		t.Fatal("unexpected initial policy")
+	}
+	if err := os.WriteFile(path, []byte(`{"allow":{"role:gm":["player.kick"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for !r.Can("bob", []string{"gm"}, "player.kick") {
+		if time.Now().After(deadline) {
+			t.Fatal("policy not reloaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if r.Can("bob", []string{"gm"}, "player.ban") {
+		t.Fatal("old grant still active")
+	}
+}
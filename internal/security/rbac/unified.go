@@ -320,6 +320,12 @@ func (upe *UnifiedPolicyEngine) parseExpiryTime(expiryTime string, requestTime t
 }
 
 func (upe *UnifiedPolicyEngine) parseExpiryDuration(expiryTime string) time.Duration {
+	return ParseExpiryDuration(expiryTime)
+}
+
+// ParseExpiryDuration parses a two-person rule expiry such as "1h", "1 day" or any
+// time.ParseDuration value; it returns 0 when the value is not understood.
+func ParseExpiryDuration(expiryTime string) time.Duration {
 	switch expiryTime {
 	case "1h", "1 hour":
 		return time.Hour
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemStore is an in-memory Store for tests and single-process development.
+type MemStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{sessions: map[string]*Session{}}
+}
+
+func (m *MemStore) Create(_ context.Context, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[s.ID]; ok {
+		return ErrExists
+	}
+	cp := *s
+	m.sessions[s.ID] = &cp
+	return nil
+}
+
+func (m *MemStore) Get(_ context.Context, id string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s := m.sessions[id]
+	if s == nil {
+		return nil, ErrNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (m *MemStore) Update(_ context.Context, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[s.ID]; !ok {
+		return ErrNotFound
+	}
+	cp := *s
+	m.sessions[s.ID] = &cp
+	return nil
+}
+
+func (m *MemStore) List(_ context.Context, opts ListOptions) ([]*Session, error) {
+	m.mu.RLock()
+	out := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		if opts.Username != "" && s.Username != opts.Username {
+			continue
+		}
+		if !opts.ActiveAt.IsZero() && !s.Active(opts.ActiveAt) {
+			continue
+		}
+		cp := *s
+		out = append(out, &cp)
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (m *MemStore) RevokeUser(_ context.Context, username, by string, at time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, s := range m.sessions {
+		if s.Username == username && s.Active(at) {
+			s.RevokedAt, s.RevokedBy = at, by
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemStore) DeleteExpired(_ context.Context, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, s := range m.sessions {
+		if s.ExpiresAt.Before(t) {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Package session tracks server-side login sessions so tokens can be revoked
+// before they expire and refreshed with rotating refresh tokens.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("session not found")
+	ErrExists   = errors.New("session already exists")
+)
+
+// Session is one login. Access tokens carry its ID; the refresh token is only
+// stored as a hash and changes on every refresh.
+type Session struct {
+	ID          string
+	Username    string
+	RefreshHash string
+	// MFAAt is when the user last completed a second factor in this session.
+	MFAAt      time.Time
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+	RevokedBy  string
+}
+
+// Active reports whether the session is neither revoked nor expired at now.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt.IsZero() && now.Before(s.ExpiresAt)
+}
+
+// ListOptions filters List; zero values match everything.
+type ListOptions struct {
+	Username string
+	// ActiveAt keeps only sessions active at that time.
+	ActiveAt time.Time
+}
+
+// Store persists sessions.
+type Store interface {
+	Create(ctx context.Context, s *Session) error
+	Get(ctx context.Context, id string) (*Session, error)
+	Update(ctx context.Context, s *Session) error
+	// List returns matching sessions, newest first.
+	List(ctx context.Context, opts ListOptions) ([]*Session, error)
+	// RevokeUser revokes every active session of username and returns how many.
+	RevokeUser(ctx context.Context, username, by string, at time.Time) (int, error)
+	// DeleteExpired removes sessions that expired before t.
+	DeleteExpired(ctx context.Context, t time.Time) error
+}
+
+// Revoke marks s revoked by by at at; revoking twice keeps the first revocation.
+func Revoke(ctx context.Context, st Store, id, by string, at time.Time) (*Session, error) {
+	s, err := st.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.RevokedAt.IsZero() {
+		return s, nil
+	}
+	s.RevokedAt, s.RevokedBy = at, by
+	return s, st.Update(ctx, s)
+}
+
+// NewID returns a random session id.
+func NewID() string { return randomHex(16) }
+
+// NewRefreshToken returns a refresh token for the session and the hash to store.
+// The token is "<session id>.<secret>" so the session can be found without
+// storing the secret.
+func NewRefreshToken(sessionID string) (string, string) {
+	secret := randomHex(32)
+	return sessionID + "." + secret, hashSecret(secret)
+}
+
+// ParseRefreshToken splits a refresh token into the session id and the hash of
+// its secret.
+func ParseRefreshToken(tok string) (string, string, bool) {
+	id, secret, ok := strings.Cut(strings.TrimSpace(tok), ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, hashSecret(secret), true
+}
+
+// MatchRefresh reports whether hash is the current refresh token hash of s.
+func (s *Session) MatchRefresh(hash string) bool {
+	return s.RefreshHash != "" && subtle.ConstantTimeCompare([]byte(s.RefreshHash), []byte(hash)) == 1
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenRoundTrip(t *testing.T) {
+	tok, hash := NewRefreshToken("s1")
+	id, got, ok := ParseRefreshToken(tok)
+	if !ok || id != "s1" {
+		t.Fatalf("ParseRefreshToken(%q) = %q, %v", tok, id, ok)
+	}
+	s := &Session{ID: "s1", RefreshHash: hash}
+	if !s.MatchRefresh(got) {
+		t.Fatal("refresh token does not match its hash")
+	}
+	next, _ := NewRefreshToken("s1")
+	_, other, _ := ParseRefreshToken(next)
+	if s.MatchRefresh(other) {
+		t.Fatal("another refresh token matched")
+	}
+	if _, _, ok := ParseRefreshToken("garbage"); ok {
+		t.Fatal("parsed a token without secret")
+	}
+}
+
+func TestMemStoreRevoke(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemStore()
+	now := time.Now()
+	for _, s := range []*Session{
+		{ID: "a", Username: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "b", Username: "alice", CreatedAt: now.Add(time.Second), ExpiresAt: now.Add(time.Hour)},
+		{ID: "c", Username: "bob", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "old", Username: "alice", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+	} {
+		if err := st.Create(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	active, _ := st.List(ctx, ListOptions{Username: "alice", ActiveAt: now})
+	if len(active) != 2 || active[0].ID != "b" {
+		t.Fatalf("active sessions = %v", active)
+	}
+	if _, err := Revoke(ctx, st, "c", "admin", now); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := st.Get(ctx, "c"); s.Active(now) || s.RevokedBy != "admin" {
+		t.Fatalf("session c = %+v", s)
+	}
+	if n, _ := st.RevokeUser(ctx, "alice", "admin", now); n != 2 {
+		t.Fatalf("RevokeUser revoked %d", n)
+	}
+	if err := st.DeleteExpired(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Get(ctx, "old"); err != ErrNotFound {
+		t.Fatalf("expired session kept: %v", err)
+	}
+}
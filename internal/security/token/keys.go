@@ -0,0 +1,265 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Supported signing algorithms, as named in the JWT "alg" header.
+const (
+	HS256 = "HS256"
+	EdDSA = "EdDSA"
+	RS256 = "RS256"
+)
+
+// Key is a signing or verification key selected by the JWT "kid" header. HS256
+// keys hold a shared secret; EdDSA and RS256 keys hold a public key and, when they
+// can sign, the private key.
+type Key struct {
+	ID  string
+	Alg string
+
+	secret []byte
+	priv   crypto.Signer
+	pub    crypto.PublicKey
+}
+
+// NewHMACKey returns an HS256 key.
+func NewHMACKey(id, secret string) *Key {
+	return &Key{ID: id, Alg: HS256, secret: []byte(secret)}
+}
+
+// NewEd25519Key returns an EdDSA key signing with priv.
+func NewEd25519Key(id string, priv ed25519.PrivateKey) *Key {
+	return &Key{ID: id, Alg: EdDSA, priv: priv, pub: priv.Public()}
+}
+
+// NewRSAKey returns an RS256 key signing with priv.
+func NewRSAKey(id string, priv *rsa.PrivateKey) *Key {
+	return &Key{ID: id, Alg: RS256, priv: priv, pub: priv.Public()}
+}
+
+// NewPublicKey returns a verification-only key for an Ed25519 or RSA public key.
+func NewPublicKey(id string, pub crypto.PublicKey) (*Key, error) {
+	switch pub.(type) {
+	case ed25519.PublicKey:
+		return &Key{ID: id, Alg: EdDSA, pub: pub}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Alg: RS256, pub: pub}, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", pub)
+}
+
+// GenerateKey returns a new random key for alg.
+func GenerateKey(id, alg string) (*Key, error) {
+	switch alg {
+	case HS256:
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		return &Key{ID: id, Alg: HS256, secret: b}, nil
+	case EdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewEd25519Key(id, priv), nil
+	case RS256:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(id, priv), nil
+	}
+	return nil, fmt.Errorf("unsupported alg %q", alg)
+}
+
+// ParsePrivateKeyPEM parses a PKCS#8 Ed25519 or RSA private key, or a PKCS#1 RSA
+// private key.
+func ParsePrivateKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(id, priv), nil
+	}
+	raw, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch priv := raw.(type) {
+	case ed25519.PrivateKey:
+		return NewEd25519Key(id, priv), nil
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, priv), nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", raw)
+}
+
+// ParsePublicKeyPEM parses a PKIX Ed25519 or RSA public key.
+func ParsePublicKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewPublicKey(id, pub)
+}
+
+// LoadPrivateKeyFile reads a PEM private key, see ParsePrivateKeyPEM.
+func LoadPrivateKeyFile(id, path string) (*Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKeyPEM(id, b)
+}
+
+// LoadPublicKeyFile reads a PEM public key, see ParsePublicKeyPEM.
+func LoadPublicKeyFile(id, path string) (*Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKeyPEM(id, b)
+}
+
+// CanSign reports whether the key holds signing material.
+func (k *Key) CanSign() bool {
+	if k.Alg == HS256 {
+		return len(k.secret) > 0
+	}
+	return k.priv != nil
+}
+
+// Public returns the verification-only part of an asymmetric key, or nil for HS256.
+func (k *Key) Public() *Key {
+	if k.Alg == HS256 {
+		return nil
+	}
+	return &Key{ID: k.ID, Alg: k.Alg, pub: k.pub}
+}
+
+func (k *Key) sign(payload []byte) ([]byte, error) {
+	switch k.Alg {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(payload)
+		return mac.Sum(nil), nil
+	case EdDSA:
+		return k.priv.Sign(rand.Reader, payload, crypto.Hash(0))
+	case RS256:
+		sum := sha256.Sum256(payload)
+		return k.priv.Sign(rand.Reader, sum[:], crypto.SHA256)
+	}
+	return nil, fmt.Errorf("unsupported alg %q", k.Alg)
+}
+
+func (k *Key) verify(payload, sig []byte) bool {
+	switch k.Alg {
+	case HS256:
+		if len(k.secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(payload)
+		return hmac.Equal(mac.Sum(nil), sig)
+	case EdDSA:
+		pub, ok := k.pub.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, payload, sig)
+	case RS256:
+		pub, ok := k.pub.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		sum := sha256.Sum256(payload)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil
+	}
+	return false
+}
+
+// jwk is the JSON Web Key form of a public key (RFC 7517, RFC 8037).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+func (k *Key) jwk() (jwk, bool) {
+	switch pub := k.pub.(type) {
+	case ed25519.PublicKey:
+		return jwk{Kty: "OKP", Kid: k.ID, Alg: EdDSA, Use: "sig", Crv: "Ed25519", X: b64enc(pub)}, true
+	case *rsa.PublicKey:
+		return jwk{Kty: "RSA", Kid: k.ID, Alg: RS256, Use: "sig", N: b64enc(pub.N.Bytes()), E: b64enc(big.NewInt(int64(pub.E)).Bytes())}, true
+	}
+	return jwk{}, false
+}
+
+// ParseJWKS returns the verification keys of a JSON Web Key Set as served by
+// Manager.JWKS or an OIDC provider, so other services can verify tokens without the
+// signing keys. Encryption keys and unsupported key types are skipped.
+func ParseJWKS(data []byte) ([]*Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	out := make([]*Key, 0, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use == "enc" {
+			continue
+		}
+		switch {
+		case j.Kty == "OKP" && j.Crv == "Ed25519":
+			x, err := b64dec(j.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("jwk %s: bad x", j.Kid)
+			}
+			out = append(out, &Key{ID: j.Kid, Alg: EdDSA, pub: ed25519.PublicKey(x)})
+		case j.Kty == "RSA":
+			n, err := b64dec(j.N)
+			if err != nil {
+				return nil, fmt.Errorf("jwk %s: bad n", j.Kid)
+			}
+			e, err := b64dec(j.E)
+			if err != nil {
+				return nil, fmt.Errorf("jwk %s: bad e", j.Kid)
+			}
+			pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+			out = append(out, &Key{ID: j.Kid, Alg: RS256, pub: pub})
+		}
+		// Other key types, e.g. EC keys an identity provider publishes next to
+		// its RSA keys, cannot verify our algorithms and are skipped.
+	}
+	return out, nil
+}
+
+func b64enc(b []byte) string          { return base64.RawURLEncoding.EncodeToString(b) }
+func b64dec(s string) ([]byte, error) { return base64.RawURLEncoding.DecodeString(s) }
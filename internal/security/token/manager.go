@@ -0,0 +1,295 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoSigningKey = errors.New("no signing key")
+	ErrUnknownKey   = errors.New("unknown key id")
+)
+
+// Manager signs tokens with its active key and verifies tokens signed by any of
+// its keys, selected by the "kid" header. Keeping the previous keys after Rotate
+// lets tokens they signed stay valid until they expire.
+type Manager struct {
+	mu     sync.RWMutex
+	keys   map[string]*Key
+	active *Key
+}
+
+// NewManager returns a manager signing HS256 with secret and no key id, as tokens
+// were issued before keys had ids.
+func NewManager(secret string) *Manager {
+	m, _ := NewKeySet("", NewHMACKey("", secret))
+	return m
+}
+
+// NewKeySet returns a manager for keys that signs with the key named active. An
+// empty active id with no key of that id gives a verification-only manager.
+func NewKeySet(active string, keys ...*Key) (*Manager, error) {
+	m := &Manager{keys: map[string]*Key{}}
+	for _, k := range keys {
+		if _, dup := m.keys[k.ID]; dup {
+			return nil, errors.New("duplicate key id " + k.ID)
+		}
+		m.keys[k.ID] = k
+	}
+	if k, ok := m.keys[active]; ok {
+		if !k.CanSign() {
+			return nil, errors.New("active key " + active + " cannot sign")
+		}
+		m.active = k
+	} else if active != "" {
+		return nil, ErrUnknownKey
+	}
+	return m, nil
+}
+
+// NewVerifier returns a manager that only verifies tokens, e.g. with keys from
+// ParseJWKS.
+func NewVerifier(keys ...*Key) *Manager {
+	m := &Manager{keys: map[string]*Key{}}
+	for _, k := range keys {
+		m.keys[k.ID] = k
+	}
+	return m
+}
+
+// Rotate makes k the signing key; keys signed with the previous key still verify.
+func (m *Manager) Rotate(k *Key) error {
+	if !k.CanSign() {
+		return errors.New("key " + k.ID + " cannot sign")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[k.ID] = k
+	m.active = k
+	return nil
+}
+
+// RemoveKey drops a verification key; tokens it signed no longer verify. The
+// active key cannot be removed.
+func (m *Manager) RemoveKey(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active != nil && m.active.ID == id {
+		return errors.New("cannot remove the active key")
+	}
+	delete(m.keys, id)
+	return nil
+}
+
+// ActiveKeyID returns the id of the signing key.
+func (m *Manager) ActiveKeyID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.active == nil {
+		return ""
+	}
+	return m.active.ID
+}
+
+// KeyIDs returns the ids of all verification keys, sorted.
+func (m *Manager) KeyIDs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(m.keys))
+	for id := range m.keys {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// JWKS returns the public keys as a JSON Web Key Set. HS256 keys are secret and
+// never included.
+func (m *Manager) JWKS() []byte {
+	m.mu.RLock()
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: []jwk{}}
+	for _, k := range m.keys {
+		if j, ok := k.jwk(); ok {
+			set.Keys = append(set.Keys, j)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	b, _ := json.Marshal(set)
+	return b
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+type claims struct {
+	Sub   string   `json:"sub"`
+	Roles []string `json:"roles"`
+	Exp   int64    `json:"exp"`
+	Iat   int64    `json:"iat,omitempty"`
+	Jti   string   `json:"jti,omitempty"`
+	Sid   string   `json:"sid,omitempty"`
+	MFA   int64    `json:"mfa_at,omitempty"`
+	Scope string   `json:"scope,omitempty"`
+}
+
+// Claims describes a verified token.
+type Claims struct {
+	Subject string
+	Roles   []string
+	// ID is the unique token id (jti), assigned by Issue.
+	ID string
+	// SessionID ties the token to a server-side session that can be revoked.
+	SessionID string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// MFAAt is when the subject last completed a second factor; zero if never.
+	MFAAt time.Time
+	// Scope restricts what the token may be used for; empty means unrestricted.
+	Scope string
+	// KeyID names the key that signed the token.
+	KeyID string
+}
+
+func (m *Manager) Sign(username string, roles []string, ttl time.Duration) (string, error) {
+	return m.Issue(Claims{Subject: username, Roles: roles}, ttl)
+}
+
+// Issue signs c with the active key for ttl from now. ID, IssuedAt and ExpiresAt
+// are set by Issue.
+func (m *Manager) Issue(c Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	raw := claims{
+		Sub:   c.Subject,
+		Roles: c.Roles,
+		Exp:   now.Add(ttl).Unix(),
+		Iat:   now.Unix(),
+		Jti:   newID(),
+		Sid:   c.SessionID,
+		Scope: c.Scope,
+	}
+	if !c.MFAAt.IsZero() {
+		raw.MFA = c.MFAAt.Unix()
+	}
+	return m.Encode(raw)
+}
+
+// Encode signs arbitrary claims v with the active key. Unlike Issue it adds no
+// claims, e.g. for tokens of other protocols such as OIDC ID tokens.
+func (m *Manager) Encode(v any) (string, error) {
+	m.mu.RLock()
+	k := m.active
+	m.mu.RUnlock()
+	if k == nil {
+		return "", ErrNoSigningKey
+	}
+	h, _ := json.Marshal(header{Alg: k.Alg, Typ: "JWT", Kid: k.ID})
+	cb, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload := b64enc(h) + "." + b64enc(cb)
+	sig, err := k.sign([]byte(payload))
+	if err != nil {
+		return "", err
+	}
+	return payload + "." + b64enc(sig), nil
+}
+
+func (m *Manager) Verify(tok string) (string, []string, error) {
+	c, err := m.Parse(tok)
+	if err != nil {
+		return "", nil, err
+	}
+	return c.Subject, c.Roles, nil
+}
+
+// Parse verifies tok and returns its claims. Tokens signed before issue times were
+// recorded have a zero IssuedAt.
+func (m *Manager) Parse(tok string) (*Claims, error) {
+	var c claims
+	kid, err := m.Decode(tok, &c)
+	if err != nil {
+		return nil, err
+	}
+	if c.Exp > 0 && time.Now().Unix() > c.Exp {
+		return nil, errors.New("expired")
+	}
+	out := &Claims{
+		Subject:   c.Sub,
+		Roles:     c.Roles,
+		ID:        c.Jti,
+		SessionID: c.Sid,
+		ExpiresAt: time.Unix(c.Exp, 0),
+		Scope:     c.Scope,
+		KeyID:     kid,
+	}
+	if c.Iat > 0 {
+		out.IssuedAt = time.Unix(c.Iat, 0)
+	}
+	if c.MFA > 0 {
+		out.MFAAt = time.Unix(c.MFA, 0)
+	}
+	return out, nil
+}
+
+// Decode verifies the signature of tok with the key named by its "kid" header and
+// unmarshals the payload into v, returning the key id. The header alg must match
+// the key, so a public key is never used as an HMAC secret. Decode does not check
+// any claims, including expiry; tokens of other issuers, such as OIDC ID tokens,
+// are checked by the caller.
+func (m *Manager) Decode(tok string, v any) (string, error) {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		return "", errors.New("bad token")
+	}
+	hb, err := b64dec(parts[0])
+	if err != nil {
+		return "", err
+	}
+	var h header
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return "", err
+	}
+	m.mu.RLock()
+	k := m.keys[h.Kid]
+	m.mu.RUnlock()
+	if k == nil {
+		return "", ErrUnknownKey
+	}
+	if h.Alg != k.Alg {
+		return "", errors.New("alg mismatch")
+	}
+	got, err := b64dec(parts[2])
+	if err != nil {
+		return "", err
+	}
+	if !k.verify([]byte(parts[0]+"."+parts[1]), got) {
+		return "", errors.New("bad signature")
+	}
+	cb, err := b64dec(parts[1])
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(cb, v); err != nil {
+		return "", err
+	}
+	return h.Kid, nil
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,108 @@
+package token
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateKeepsOldKeys(t *testing.T) {
+	m, err := NewKeySet("k1", NewHMACKey("k1", "secret-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := m.Issue(Claims{Subject: "alice", SessionID: "s1"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := GenerateKey("k2", EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Rotate(k2); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := m.Issue(Claims{Subject: "alice"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range []string{old, fresh} {
+		if _, err := m.Parse(tok); err != nil {
+			t.Fatalf("parse after rotate: %v", err)
+		}
+	}
+	c, _ := m.Parse(old)
+	if c.KeyID != "k1" || c.SessionID != "s1" || c.ID == "" {
+		t.Fatalf("claims = %+v", c)
+	}
+	if err := m.RemoveKey("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(old); err != ErrUnknownKey {
+		t.Fatalf("parse with removed key: %v", err)
+	}
+	if err := m.RemoveKey("k2"); err == nil {
+		t.Fatal("removed the active key")
+	}
+}
+
+func TestVerifierFromJWKS(t *testing.T) {
+	for _, alg := range []string{EdDSA, RS256} {
+		k, err := GenerateKey("a1", alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := NewKeySet("a1", k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tok, err := m.Issue(Claims{Subject: "bob", Roles: []string{"ops"}}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, err := ParseJWKS(m.JWKS())
+		if err != nil || len(keys) != 1 {
+			t.Fatalf("%s: ParseJWKS = %v, %v", alg, keys, err)
+		}
+		v := NewVerifier(keys...)
+		c, err := v.Parse(tok)
+		if err != nil || c.Subject != "bob" {
+			t.Fatalf("%s: verify = %+v, %v", alg, c, err)
+		}
+		if _, err := v.Issue(Claims{Subject: "bob"}, time.Minute); err != ErrNoSigningKey {
+			t.Fatalf("%s: verifier issued a token: %v", alg, err)
+		}
+	}
+}
+
+func TestHMACKeysStayOutOfJWKS(t *testing.T) {
+	m := NewManager("dev-secret")
+	if got := string(m.JWKS()); got != `{"keys":[]}` {
+		t.Fatalf("JWKS = %s", got)
+	}
+}
+
+func TestAlgMismatchRejected(t *testing.T) {
+	k, _ := GenerateKey("k", EdDSA)
+	m, _ := NewKeySet("k", k)
+	tok, _ := m.Issue(Claims{Subject: "eve"}, time.Minute)
+	parts := strings.Split(tok, ".")
+	parts[0] = b64enc([]byte(`{"alg":"HS256","typ":"JWT","kid":"k"}`))
+	if _, err := m.Parse(strings.Join(parts, ".")); err == nil {
+		t.Fatal("accepted token with a different alg")
+	}
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	k, _ := GenerateKey("p", EdDSA)
+	der, err := x509.MarshalPKCS8PrivateKey(k.priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePrivateKeyPEM("p", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil || parsed.Alg != EdDSA || !parsed.CanSign() {
+		t.Fatalf("ParsePrivateKeyPEM = %+v, %v", parsed, err)
+	}
+}
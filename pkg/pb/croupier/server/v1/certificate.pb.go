@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: croupier/server/v1/certificate.proto
+
+package serverv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type RenewCertificateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CsrPem        []byte                 `protobuf:"bytes,1,opt,name=csr_pem,json=csrPem,proto3" json:"csr_pem,omitempty"` // PEM CSR for the agent's new key
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenewCertificateRequest) Reset() {
+	*x = RenewCertificateRequest{}
+	mi := &file_croupier_server_v1_certificate_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenewCertificateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenewCertificateRequest) ProtoMessage() {}
+
+func (x *RenewCertificateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_croupier_server_v1_certificate_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenewCertificateRequest.ProtoReflect.Descriptor instead.
+func (*RenewCertificateRequest) Descriptor() ([]byte, []int) {
+	return file_croupier_server_v1_certificate_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RenewCertificateRequest) GetCsrPem() []byte {
+	if x != nil {
+		return x.CsrPem
+	}
+	return nil
+}
+
+type RenewCertificateResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	CertificatePem []byte                 `protobuf:"bytes,1,opt,name=certificate_pem,json=certificatePem,proto3" json:"certificate_pem,omitempty"` // PEM certificate signed by the Croupier CA
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RenewCertificateResponse) Reset() {
+	*x = RenewCertificateResponse{}
+	mi := &file_croupier_server_v1_certificate_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenewCertificateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenewCertificateResponse) ProtoMessage() {}
+
+func (x *RenewCertificateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_croupier_server_v1_certificate_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenewCertificateResponse.ProtoReflect.Descriptor instead.
+func (*RenewCertificateResponse) Descriptor() ([]byte, []int) {
+	return file_croupier_server_v1_certificate_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RenewCertificateResponse) GetCertificatePem() []byte {
+	if x != nil {
+		return x.CertificatePem
+	}
+	return nil
+}
+
+var File_croupier_server_v1_certificate_proto protoreflect.FileDescriptor
+
+const file_croupier_server_v1_certificate_proto_rawDesc = "" +
+	"\n" +
+	"$croupier/server/v1/certificate.proto\x12\x12croupier.server.v1\"2\n" +
+	"\x17RenewCertificateRequest\x12\x17\n" +
+	"\acsr_pem\x18\x01 \x01(\fR\x06csrPem\"C\n" +
+	"\x18RenewCertificateResponse\x12'\n" +
+	"\x0fcertificate_pem\x18\x01 \x01(\fR\x0ecertificatePem2x\n" +
+	"\x12CertificateService\x12b\n" +
+	"\x05Renew\x12+.croupier.server.v1.RenewCertificateRequest\x1a,.croupier.server.v1.RenewCertificateResponseBAZ?github.com/cuihairu/croupier/pkg/pb/croupier/server/v1;serverv1b\x06proto3"
+
+var (
+	file_croupier_server_v1_certificate_proto_rawDescOnce sync.Once
+	file_croupier_server_v1_certificate_proto_rawDescData []byte
+)
+
+func file_croupier_server_v1_certificate_proto_rawDescGZIP() []byte {
+	file_croupier_server_v1_certificate_proto_rawDescOnce.Do(func() {
+		file_croupier_server_v1_certificate_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_croupier_server_v1_certificate_proto_rawDesc), len(file_croupier_server_v1_certificate_proto_rawDesc)))
+	})
+	return file_croupier_server_v1_certificate_proto_rawDescData
+}
+
+var file_croupier_server_v1_certificate_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_croupier_server_v1_certificate_proto_goTypes = []any{
+	(*RenewCertificateRequest)(nil),  // 0: croupier.server.v1.RenewCertificateRequest
+	(*RenewCertificateResponse)(nil), // 1: croupier.server.v1.RenewCertificateResponse
+}
+var file_croupier_server_v1_certificate_proto_depIdxs = []int32{
+	0, // 0: croupier.server.v1.CertificateService.Renew:input_type -> croupier.server.v1.RenewCertificateRequest
+	1, // 1: croupier.server.v1.CertificateService.Renew:output_type -> croupier.server.v1.RenewCertificateResponse
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_croupier_server_v1_certificate_proto_init() }
+func file_croupier_server_v1_certificate_proto_init() {
+	if File_croupier_server_v1_certificate_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_croupier_server_v1_certificate_proto_rawDesc), len(file_croupier_server_v1_certificate_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_croupier_server_v1_certificate_proto_goTypes,
+		DependencyIndexes: file_croupier_server_v1_certificate_proto_depIdxs,
+		MessageInfos:      file_croupier_server_v1_certificate_proto_msgTypes,
+	}.Build()
+	File_croupier_server_v1_certificate_proto = out.File
+	file_croupier_server_v1_certificate_proto_goTypes = nil
+	file_croupier_server_v1_certificate_proto_depIdxs = nil
+}
@@ -160,12 +160,16 @@ func (x *FunctionDescriptor) GetPermissions() *v1.PermissionSpec {
 // Agent Registration Request
 type RegisterRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	AgentId       string                 `protobuf:"bytes,1,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"` // agent unique id
-	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`                // agent version
-	Functions     []*FunctionDescriptor  `protobuf:"bytes,3,rep,name=functions,proto3" json:"functions,omitempty"`            // summarized function list
-	RpcAddr       string                 `protobuf:"bytes,4,opt,name=rpc_addr,json=rpcAddr,proto3" json:"rpc_addr,omitempty"` // agent's reachable gRPC address (DEV ONLY)
-	GameId        string                 `protobuf:"bytes,5,opt,name=game_id,json=gameId,proto3" json:"game_id,omitempty"`    // game scope (required for multi-game)
-	Env           string                 `protobuf:"bytes,6,opt,name=env,proto3" json:"env,omitempty"`                        // environment (optional: prod/stage/test)
+	AgentId       string                 `protobuf:"bytes,1,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`                                                          // agent unique id
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`                                                                         // agent version
+	Functions     []*FunctionDescriptor  `protobuf:"bytes,3,rep,name=functions,proto3" json:"functions,omitempty"`                                                                     // summarized function list
+	RpcAddr       string                 `protobuf:"bytes,4,opt,name=rpc_addr,json=rpcAddr,proto3" json:"rpc_addr,omitempty"`                                                          // agent's reachable gRPC address (DEV ONLY)
+	GameId        string                 `protobuf:"bytes,5,opt,name=game_id,json=gameId,proto3" json:"game_id,omitempty"`                                                             // game scope (required for multi-game)
+	Env           string                 `protobuf:"bytes,6,opt,name=env,proto3" json:"env,omitempty"`                                                                                 // environment (optional: prod/stage/test)
+	Region        string                 `protobuf:"bytes,7,opt,name=region,proto3" json:"region,omitempty"`                                                                           // deployment region, e.g. "ap-east-1"
+	Zone          string                 `protobuf:"bytes,8,opt,name=zone,proto3" json:"zone,omitempty"`                                                                               // availability zone within the region
+	Labels        map[string]string      `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // free-form labels for routing and dashboards
+	EdgeAddr      string                 `protobuf:"bytes,10,opt,name=edge_addr,json=edgeAddr,proto3" json:"edge_addr,omitempty"`                                                      // edge holding the agent's tunnel when the agent accepts no inbound calls
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -242,11 +246,39 @@ func (x *RegisterRequest) GetEnv() string {
 	return ""
 }
 
+func (x *RegisterRequest) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetEdgeAddr() string {
+	if x != nil {
+		return x.EdgeAddr
+	}
+	return ""
+}
+
 // Agent Registration Response
 type RegisterResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	ExpireAt      int64                  `protobuf:"varint,2,opt,name=expire_at,json=expireAt,proto3" json:"expire_at,omitempty"` // epoch seconds
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"` // echoed on every heartbeat; a new one per registration
+	ExpireAt      int64                  `protobuf:"varint,2,opt,name=expire_at,json=expireAt,proto3" json:"expire_at,omitempty"`   // epoch seconds
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -348,9 +380,11 @@ func (x *HeartbeatRequest) GetSessionId() string {
 	return ""
 }
 
-// Agent Heartbeat Response
+// Agent Heartbeat Response. An unknown or expired session fails with NOT_FOUND
+// and the agent registers again.
 type HeartbeatResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExpireAt      int64                  `protobuf:"varint,1,opt,name=expire_at,json=expireAt,proto3" json:"expire_at,omitempty"` // epoch seconds
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -385,6 +419,13 @@ func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
 	return file_croupier_server_v1_control_proto_rawDescGZIP(), []int{4}
 }
 
+func (x *HeartbeatResponse) GetExpireAt() int64 {
+	if x != nil {
+		return x.ExpireAt
+	}
+	return 0
+}
+
 // Provider capabilities (manifest) — language-agnostic declaration
 type ProviderMeta struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -588,6 +629,162 @@ func (x *ListFunctionsSummaryResponse) GetFunctions() []*FunctionDescriptor {
 	return nil
 }
 
+// Watch Request. Events after from_revision are replayed first; 0 starts at the
+// current revision. A revision the server no longer holds fails with OUT_OF_RANGE
+// and the client lists again before watching.
+type WatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FromRevision  uint64                 `protobuf:"varint,1,opt,name=from_revision,json=fromRevision,proto3" json:"from_revision,omitempty"`
+	Types         []string               `protobuf:"bytes,2,rep,name=types,proto3" json:"types,omitempty"` // event types to receive; empty for all
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_croupier_server_v1_control_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_croupier_server_v1_control_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_croupier_server_v1_control_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchRequest) GetFromRevision() uint64 {
+	if x != nil {
+		return x.FromRevision
+	}
+	return 0
+}
+
+func (x *WatchRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+// Registry change event
+type WatchEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revision      uint64                 `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"` // increasing per server; resume from the last one seen
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`          // agent_upserted|agent_expired|provider_caps_changed|function_enabled|function_disabled|assignments_changed
+	Ts            int64                  `protobuf:"varint,3,opt,name=ts,proto3" json:"ts,omitempty"`             // epoch milliseconds
+	AgentId       string                 `protobuf:"bytes,4,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`
+	GameId        string                 `protobuf:"bytes,5,opt,name=game_id,json=gameId,proto3" json:"game_id,omitempty"`
+	Env           string                 `protobuf:"bytes,6,opt,name=env,proto3" json:"env,omitempty"`
+	ProviderId    string                 `protobuf:"bytes,7,opt,name=provider_id,json=providerId,proto3" json:"provider_id,omitempty"`
+	FunctionId    string                 `protobuf:"bytes,8,opt,name=function_id,json=functionId,proto3" json:"function_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEvent) Reset() {
+	*x = WatchEvent{}
+	mi := &file_croupier_server_v1_control_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEvent) ProtoMessage() {}
+
+func (x *WatchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_croupier_server_v1_control_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEvent.ProtoReflect.Descriptor instead.
+func (*WatchEvent) Descriptor() ([]byte, []int) {
+	return file_croupier_server_v1_control_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchEvent) GetRevision() uint64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *WatchEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetTs() int64 {
+	if x != nil {
+		return x.Ts
+	}
+	return 0
+}
+
+func (x *WatchEvent) GetAgentId() string {
+	if x != nil {
+		return x.AgentId
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetGameId() string {
+	if x != nil {
+		return x.GameId
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetEnv() string {
+	if x != nil {
+		return x.Env
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetProviderId() string {
+	if x != nil {
+		return x.ProviderId
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetFunctionId() string {
+	if x != nil {
+		return x.FunctionId
+	}
+	return ""
+}
+
 var File_croupier_server_v1_control_proto protoreflect.FileDescriptor
 
 const file_croupier_server_v1_control_proto_rawDesc = "" +
@@ -605,14 +802,21 @@ const file_croupier_server_v1_control_proto_rawDesc = "" +
 	"\asummary\x18\x15 \x01(\v2\x1c.croupier.common.v1.I18nTextR\asummary\x12\x12\n" +
 	"\x04tags\x18\x16 \x03(\tR\x04tags\x12,\n" +
 	"\x04menu\x18\x17 \x01(\v2\x18.croupier.common.v1.MenuR\x04menu\x12D\n" +
-	"\vpermissions\x18\x18 \x01(\v2\".croupier.common.v1.PermissionSpecR\vpermissions\"\xd2\x01\n" +
+	"\vpermissions\x18\x18 \x01(\v2\".croupier.common.v1.PermissionSpecR\vpermissions\"\x9f\x03\n" +
 	"\x0fRegisterRequest\x12\x19\n" +
 	"\bagent_id\x18\x01 \x01(\tR\aagentId\x12\x18\n" +
 	"\aversion\x18\x02 \x01(\tR\aversion\x12D\n" +
 	"\tfunctions\x18\x03 \x03(\v2&.croupier.server.v1.FunctionDescriptorR\tfunctions\x12\x19\n" +
 	"\brpc_addr\x18\x04 \x01(\tR\arpcAddr\x12\x17\n" +
 	"\agame_id\x18\x05 \x01(\tR\x06gameId\x12\x10\n" +
-	"\x03env\x18\x06 \x01(\tR\x03env\"N\n" +
+	"\x03env\x18\x06 \x01(\tR\x03env\x12\x16\n" +
+	"\x06region\x18\a \x01(\tR\x06region\x12\x12\n" +
+	"\x04zone\x18\b \x01(\tR\x04zone\x12G\n" +
+	"\x06labels\x18\t \x03(\v2/.croupier.server.v1.RegisterRequest.LabelsEntryR\x06labels\x12\x1b\n" +
+	"\tedge_addr\x18\n \x01(\tR\bedgeAddr\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"N\n" +
 	"\x10RegisterResponse\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1b\n" +
@@ -620,8 +824,9 @@ const file_croupier_server_v1_control_proto_rawDesc = "" +
 	"\x10HeartbeatRequest\x12\x19\n" +
 	"\bagent_id\x18\x01 \x01(\tR\aagentId\x12\x1d\n" +
 	"\n" +
-	"session_id\x18\x02 \x01(\tR\tsessionId\"\x13\n" +
-	"\x11HeartbeatResponse\"^\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\"0\n" +
+	"\x11HeartbeatResponse\x12\x1b\n" +
+	"\texpire_at\x18\x01 \x01(\x03R\bexpireAt\"^\n" +
 	"\fProviderMeta\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
 	"\aversion\x18\x02 \x01(\tR\aversion\x12\x12\n" +
@@ -632,12 +837,28 @@ const file_croupier_server_v1_control_proto_rawDesc = "" +
 	"\x10manifest_json_gz\x18\x02 \x01(\fR\x0emanifestJsonGz\"\x1e\n" +
 	"\x1cRegisterCapabilitiesResponse\"d\n" +
 	"\x1cListFunctionsSummaryResponse\x12D\n" +
-	"\tfunctions\x18\x01 \x03(\v2&.croupier.server.v1.FunctionDescriptorR\tfunctions2\x9e\x03\n" +
+	"\tfunctions\x18\x01 \x03(\v2&.croupier.server.v1.FunctionDescriptorR\tfunctions\"I\n" +
+	"\fWatchRequest\x12#\n" +
+	"\rfrom_revision\x18\x01 \x01(\x04R\ffromRevision\x12\x14\n" +
+	"\x05types\x18\x02 \x03(\tR\x05types\"\xd4\x01\n" +
+	"\n" +
+	"WatchEvent\x12\x1a\n" +
+	"\brevision\x18\x01 \x01(\x04R\brevision\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x0e\n" +
+	"\x02ts\x18\x03 \x01(\x03R\x02ts\x12\x19\n" +
+	"\bagent_id\x18\x04 \x01(\tR\aagentId\x12\x17\n" +
+	"\agame_id\x18\x05 \x01(\tR\x06gameId\x12\x10\n" +
+	"\x03env\x18\x06 \x01(\tR\x03env\x12\x1f\n" +
+	"\vprovider_id\x18\a \x01(\tR\n" +
+	"providerId\x12\x1f\n" +
+	"\vfunction_id\x18\b \x01(\tR\n" +
+	"functionId2\xeb\x03\n" +
 	"\x0eControlService\x12`\n" +
 	"\x14ListFunctionsSummary\x12\x16.google.protobuf.Empty\x1a0.croupier.server.v1.ListFunctionsSummaryResponse\x12U\n" +
 	"\bRegister\x12#.croupier.server.v1.RegisterRequest\x1a$.croupier.server.v1.RegisterResponse\x12X\n" +
 	"\tHeartbeat\x12$.croupier.server.v1.HeartbeatRequest\x1a%.croupier.server.v1.HeartbeatResponse\x12y\n" +
-	"\x14RegisterCapabilities\x12/.croupier.server.v1.RegisterCapabilitiesRequest\x1a0.croupier.server.v1.RegisterCapabilitiesResponseB\xd1\x01\n" +
+	"\x14RegisterCapabilities\x12/.croupier.server.v1.RegisterCapabilitiesRequest\x1a0.croupier.server.v1.RegisterCapabilitiesResponse\x12K\n" +
+	"\x05Watch\x12 .croupier.server.v1.WatchRequest\x1a\x1e.croupier.server.v1.WatchEvent0\x01B\xd1\x01\n" +
 	"\x16com.croupier.server.v1B\fControlProtoP\x01Z?github.com/cuihairu/croupier/pkg/pb/croupier/server/v1;serverv1\xa2\x02\x03CSX\xaa\x02\x12Croupier.Server.V1\xca\x02\x12Croupier\\Server\\V1\xe2\x02\x1eCroupier\\Server\\V1\\GPBMetadata\xea\x02\x14Croupier::Server::V1b\x06proto3"
 
 var (
@@ -652,7 +873,7 @@ func file_croupier_server_v1_control_proto_rawDescGZIP() []byte {
 	return file_croupier_server_v1_control_proto_rawDescData
 }
 
-var file_croupier_server_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_croupier_server_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_croupier_server_v1_control_proto_goTypes = []any{
 	(*FunctionDescriptor)(nil),           // 0: croupier.server.v1.FunctionDescriptor
 	(*RegisterRequest)(nil),              // 1: croupier.server.v1.RegisterRequest
@@ -663,32 +884,38 @@ var file_croupier_server_v1_control_proto_goTypes = []any{
 	(*RegisterCapabilitiesRequest)(nil),  // 6: croupier.server.v1.RegisterCapabilitiesRequest
 	(*RegisterCapabilitiesResponse)(nil), // 7: croupier.server.v1.RegisterCapabilitiesResponse
 	(*ListFunctionsSummaryResponse)(nil), // 8: croupier.server.v1.ListFunctionsSummaryResponse
-	(*v1.I18NText)(nil),                  // 9: croupier.common.v1.I18nText
-	(*v1.Menu)(nil),                      // 10: croupier.common.v1.Menu
-	(*v1.PermissionSpec)(nil),            // 11: croupier.common.v1.PermissionSpec
-	(*emptypb.Empty)(nil),                // 12: google.protobuf.Empty
+	(*WatchRequest)(nil),                 // 9: croupier.server.v1.WatchRequest
+	(*WatchEvent)(nil),                   // 10: croupier.server.v1.WatchEvent
+	nil,                                  // 11: croupier.server.v1.RegisterRequest.LabelsEntry
+	(*v1.I18NText)(nil),                  // 12: croupier.common.v1.I18nText
+	(*v1.Menu)(nil),                      // 13: croupier.common.v1.Menu
+	(*v1.PermissionSpec)(nil),            // 14: croupier.common.v1.PermissionSpec
+	(*emptypb.Empty)(nil),                // 15: google.protobuf.Empty
 }
 var file_croupier_server_v1_control_proto_depIdxs = []int32{
-	9,  // 0: croupier.server.v1.FunctionDescriptor.display_name:type_name -> croupier.common.v1.I18nText
-	9,  // 1: croupier.server.v1.FunctionDescriptor.summary:type_name -> croupier.common.v1.I18nText
-	10, // 2: croupier.server.v1.FunctionDescriptor.menu:type_name -> croupier.common.v1.Menu
-	11, // 3: croupier.server.v1.FunctionDescriptor.permissions:type_name -> croupier.common.v1.PermissionSpec
+	12, // 0: croupier.server.v1.FunctionDescriptor.display_name:type_name -> croupier.common.v1.I18nText
+	12, // 1: croupier.server.v1.FunctionDescriptor.summary:type_name -> croupier.common.v1.I18nText
+	13, // 2: croupier.server.v1.FunctionDescriptor.menu:type_name -> croupier.common.v1.Menu
+	14, // 3: croupier.server.v1.FunctionDescriptor.permissions:type_name -> croupier.common.v1.PermissionSpec
 	0,  // 4: croupier.server.v1.RegisterRequest.functions:type_name -> croupier.server.v1.FunctionDescriptor
-	5,  // 5: croupier.server.v1.RegisterCapabilitiesRequest.provider:type_name -> croupier.server.v1.ProviderMeta
-	0,  // 6: croupier.server.v1.ListFunctionsSummaryResponse.functions:type_name -> croupier.server.v1.FunctionDescriptor
-	12, // 7: croupier.server.v1.ControlService.ListFunctionsSummary:input_type -> google.protobuf.Empty
-	1,  // 8: croupier.server.v1.ControlService.Register:input_type -> croupier.server.v1.RegisterRequest
-	3,  // 9: croupier.server.v1.ControlService.Heartbeat:input_type -> croupier.server.v1.HeartbeatRequest
-	6,  // 10: croupier.server.v1.ControlService.RegisterCapabilities:input_type -> croupier.server.v1.RegisterCapabilitiesRequest
-	8,  // 11: croupier.server.v1.ControlService.ListFunctionsSummary:output_type -> croupier.server.v1.ListFunctionsSummaryResponse
-	2,  // 12: croupier.server.v1.ControlService.Register:output_type -> croupier.server.v1.RegisterResponse
-	4,  // 13: croupier.server.v1.ControlService.Heartbeat:output_type -> croupier.server.v1.HeartbeatResponse
-	7,  // 14: croupier.server.v1.ControlService.RegisterCapabilities:output_type -> croupier.server.v1.RegisterCapabilitiesResponse
-	11, // [11:15] is the sub-list for method output_type
-	7,  // [7:11] is the sub-list for method input_type
-	7,  // [7:7] is the sub-list for extension type_name
-	7,  // [7:7] is the sub-list for extension extendee
-	0,  // [0:7] is the sub-list for field type_name
+	11, // 5: croupier.server.v1.RegisterRequest.labels:type_name -> croupier.server.v1.RegisterRequest.LabelsEntry
+	5,  // 6: croupier.server.v1.RegisterCapabilitiesRequest.provider:type_name -> croupier.server.v1.ProviderMeta
+	0,  // 7: croupier.server.v1.ListFunctionsSummaryResponse.functions:type_name -> croupier.server.v1.FunctionDescriptor
+	15, // 8: croupier.server.v1.ControlService.ListFunctionsSummary:input_type -> google.protobuf.Empty
+	1,  // 9: croupier.server.v1.ControlService.Register:input_type -> croupier.server.v1.RegisterRequest
+	3,  // 10: croupier.server.v1.ControlService.Heartbeat:input_type -> croupier.server.v1.HeartbeatRequest
+	6,  // 11: croupier.server.v1.ControlService.RegisterCapabilities:input_type -> croupier.server.v1.RegisterCapabilitiesRequest
+	9,  // 12: croupier.server.v1.ControlService.Watch:input_type -> croupier.server.v1.WatchRequest
+	8,  // 13: croupier.server.v1.ControlService.ListFunctionsSummary:output_type -> croupier.server.v1.ListFunctionsSummaryResponse
+	2,  // 14: croupier.server.v1.ControlService.Register:output_type -> croupier.server.v1.RegisterResponse
+	4,  // 15: croupier.server.v1.ControlService.Heartbeat:output_type -> croupier.server.v1.HeartbeatResponse
+	7,  // 16: croupier.server.v1.ControlService.RegisterCapabilities:output_type -> croupier.server.v1.RegisterCapabilitiesResponse
+	10, // 17: croupier.server.v1.ControlService.Watch:output_type -> croupier.server.v1.WatchEvent
+	13, // [13:18] is the sub-list for method output_type
+	8,  // [8:13] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_croupier_server_v1_control_proto_init() }
@@ -702,7 +929,7 @@ func file_croupier_server_v1_control_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_croupier_server_v1_control_proto_rawDesc), len(file_croupier_server_v1_control_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -24,6 +24,7 @@ const (
 	ControlService_Register_FullMethodName             = "/croupier.server.v1.ControlService/Register"
 	ControlService_Heartbeat_FullMethodName            = "/croupier.server.v1.ControlService/Heartbeat"
 	ControlService_RegisterCapabilities_FullMethodName = "/croupier.server.v1.ControlService/RegisterCapabilities"
+	ControlService_Watch_FullMethodName                = "/croupier.server.v1.ControlService/Watch"
 )
 
 // ControlServiceClient is the client API for ControlService service.
@@ -40,6 +41,8 @@ type ControlServiceClient interface {
 	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
 	// Provider capabilities registration
 	RegisterCapabilities(ctx context.Context, in *RegisterCapabilitiesRequest, opts ...grpc.CallOption) (*RegisterCapabilitiesResponse, error)
+	// Registry and function catalog changes, resumable by revision
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchEvent], error)
 }
 
 type controlServiceClient struct {
@@ -90,6 +93,25 @@ func (c *controlServiceClient) RegisterCapabilities(ctx context.Context, in *Reg
 	return out, nil
 }
 
+func (c *controlServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ControlService_ServiceDesc.Streams[0], ControlService_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, WatchEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ControlService_WatchClient = grpc.ServerStreamingClient[WatchEvent]
+
 // ControlServiceServer is the server API for ControlService service.
 // All implementations must embed UnimplementedControlServiceServer
 // for forward compatibility.
@@ -104,6 +126,8 @@ type ControlServiceServer interface {
 	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
 	// Provider capabilities registration
 	RegisterCapabilities(context.Context, *RegisterCapabilitiesRequest) (*RegisterCapabilitiesResponse, error)
+	// Registry and function catalog changes, resumable by revision
+	Watch(*WatchRequest, grpc.ServerStreamingServer[WatchEvent]) error
 	mustEmbedUnimplementedControlServiceServer()
 }
 
@@ -126,6 +150,9 @@ func (UnimplementedControlServiceServer) Heartbeat(context.Context, *HeartbeatRe
 func (UnimplementedControlServiceServer) RegisterCapabilities(context.Context, *RegisterCapabilitiesRequest) (*RegisterCapabilitiesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RegisterCapabilities not implemented")
 }
+func (UnimplementedControlServiceServer) Watch(*WatchRequest, grpc.ServerStreamingServer[WatchEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
 func (UnimplementedControlServiceServer) mustEmbedUnimplementedControlServiceServer() {}
 func (UnimplementedControlServiceServer) testEmbeddedByValue()                        {}
 
@@ -219,6 +246,17 @@ func _ControlService_RegisterCapabilities_Handler(srv interface{}, ctx context.C
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ControlService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlServiceServer).Watch(m, &grpc.GenericServerStream[WatchRequest, WatchEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ControlService_WatchServer = grpc.ServerStreamingServer[WatchEvent]
+
 // ControlService_ServiceDesc is the grpc.ServiceDesc for ControlService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -243,6 +281,12 @@ var ControlService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ControlService_RegisterCapabilities_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _ControlService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "croupier/server/v1/control.proto",
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 
@@ -25,6 +26,13 @@ func main() {
 	ctx := svc.NewServiceContext(c)
 	handler.RegisterHandlers(server, ctx)
 
+	stopUpstream, err := ctx.StartUpstream(context.Background())
+	if err != nil {
+		fmt.Printf("Upstream disabled: %v\n", err)
+	} else {
+		defer stopUpstream()
+	}
+
 	fmt.Printf("Starting server at %s:%d...\n", c.Host, c.Port)
 	server.Start()
 }
\ No newline at end of file
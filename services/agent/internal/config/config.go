@@ -24,6 +24,12 @@ type Config struct {
 		Region    string            `json:",optional"`
 		Zone      string            `json:",optional"`
 		Labels    map[string]string `json:",optional"`
+		// RPCAddr is where the server calls the agent, over TLS with the agent
+		// certificate. Agents without one (Server.Insecure) are called on LocalAddr.
+		RPCAddr string `json:",default=127.0.0.1:19092"`
+		// EdgeAddr makes an agent that accepts no inbound calls keep a tunnel to
+		// the edge there instead; the server calls it through the edge.
+		EdgeAddr string `json:",optional"`
 	} `json:",optional"`
 
 	GRPC struct {
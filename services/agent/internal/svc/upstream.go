@@ -0,0 +1,116 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime/debug"
+
+	agentapp "github.com/cuihairu/croupier/internal/app/agent"
+	"github.com/zeromicro/go-zero/core/logx"
+	"google.golang.org/grpc"
+)
+
+// StartUpstream serves the local control and function services on Agent.LocalAddr
+// and registers the agent with the server at Server.Addr, reporting the game,
+// env, region, zone and labels of the Agent config. The control channel uses
+// the agent certificate of Server.TLSCertFile unless Server.Insecure is set; with
+// the certificate the server calls the agent over TLS on Agent.RPCAddr. With
+// Agent.EdgeAddr the agent accepts no inbound calls: it keeps a tunnel to that
+// edge, with the same certificate, and the server calls it through the edge. The
+// returned func stops the gRPC servers and the tunnel.
+func (s *ServiceContext) StartUpstream(ctx context.Context) (func(), error) {
+	c := s.Config
+	agentID := c.Agent.ID
+	if agentID == "" {
+		agentID, _ = os.Hostname()
+	}
+	app := agentapp.New(c.Server.Addr, agentID)
+	meta := agentapp.Metadata{
+		GameID:  c.Agent.GameID,
+		Env:     c.Agent.Env,
+		RPCAddr: c.Agent.LocalAddr,
+		Version: buildVersion(),
+		Region:  c.Agent.Region,
+		Zone:    c.Agent.Zone,
+		Labels:  c.Agent.Labels,
+	}
+	switch {
+	case c.Server.Insecure:
+		logx.Errorf("server.insecure is set: the control channel to %s is not authenticated", c.Server.Addr)
+	case c.Server.TLSCertFile != "" && c.Server.TLSKeyFile != "" && c.Server.CAFile != "":
+		// the server and the edge are each verified for the host they are dialed on
+		if err := app.UseTLS(agentapp.TLSConfig{
+			CertFile: c.Server.TLSCertFile,
+			KeyFile:  c.Server.TLSKeyFile,
+			CAFile:   c.Server.CAFile,
+		}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("agent certificate required: set Server.TLSCertFile, TLSKeyFile and CAFile, or Server.Insecure")
+	}
+
+	srv, err := serveGRPC(c.Agent.LocalAddr, app)
+	if err != nil {
+		return nil, err
+	}
+	stop := srv.GracefulStop
+	switch creds := app.ServerCredentials(); {
+	case c.Agent.EdgeAddr != "":
+		tctx, cancel := context.WithCancel(ctx)
+		go func() {
+			_ = app.RunTunnel(tctx, c.Agent.EdgeAddr, c.Agent.GameID, c.Agent.Env)
+		}()
+		meta.RPCAddr, meta.EdgeAddr = "", c.Agent.EdgeAddr
+		stop = func() {
+			cancel()
+			srv.GracefulStop()
+		}
+	case creds != nil:
+		rpc, err := serveGRPC(c.Agent.RPCAddr, app, grpc.Creds(creds))
+		if err != nil {
+			srv.Stop()
+			return nil, err
+		}
+		meta.RPCAddr = c.Agent.RPCAddr
+		stop = func() {
+			rpc.GracefulStop()
+			srv.GracefulStop()
+		}
+	}
+	app.SetMetadata(meta)
+	if err := app.Run(ctx); err != nil {
+		stop()
+		return nil, err
+	}
+	if meta.EdgeAddr != "" {
+		logx.Infof("agent %s serving on %s, called through edge %s, registering with %s", agentID, c.Agent.LocalAddr, meta.EdgeAddr, c.Server.Addr)
+	} else {
+		logx.Infof("agent %s serving on %s, called on %s, registering with %s", agentID, c.Agent.LocalAddr, meta.RPCAddr, c.Server.Addr)
+	}
+	return stop, nil
+}
+
+func serveGRPC(addr string, app *agentapp.App, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(opts...)
+	app.RegisterGRPC(srv)
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			logx.Errorf("agent grpc server on %s stopped: %v", addr, err)
+		}
+	}()
+	return srv, nil
+}
+
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
@@ -2,29 +2,35 @@ package main
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cuihairu/croupier/internal/analytics/ingestauth"
 	"github.com/cuihairu/croupier/internal/analytics/mq"
+	"github.com/cuihairu/croupier/internal/analytics/schema"
+	"github.com/redis/go-redis/v9"
 )
 
-// KISS: 极简 Ingestion 服务，仅实现签名校验 + Redis Streams 写入。
+// KISS: 极简 Ingestion 服务，仅实现签名校验 + 事件校验 + MQ 写入。
 // 通过环境变量配置，避免引入额外依赖或复杂性。
 
+// maxBodyBytes 限制单个请求体大小。
+const maxBodyBytes = 4 << 20
+
 type server struct {
-	q         mq.Queue
-	secret    string
-	allowSkew time.Duration
+	q       mq.Queue
+	auth    *ingestauth.Verifier
+	spec    *schema.Spec
+	invalid schema.Mode
 }
 
 func main() {
@@ -32,10 +38,17 @@ func main() {
 	q := mq.NewFromEnv()
 	defer func() { _ = q.Close() }()
 
+	// 签名密钥：ANALYTICS_INGEST_KEYS 指向按游戏/密钥 id 划分的密钥文件(支持轮换与吊销，变更后自动重载)；
+	// ANALYTICS_INGEST_SECRET 为兼容旧客户端的全局密钥(不带 X-Key-Id)。
 	secret := strings.TrimSpace(os.Getenv("ANALYTICS_INGEST_SECRET"))
-	if secret == "" {
-		log.Println("[ingest] WARN: ANALYTICS_INGEST_SECRET not set; requests will be rejected")
+	keys, err := ingestauth.NewKeyring(strings.TrimSpace(os.Getenv("ANALYTICS_INGEST_KEYS")), secret)
+	if err != nil {
+		log.Fatalf("[ingest] load keys: %v", err)
+	}
+	if keys.Len() == 0 {
+		log.Println("[ingest] WARN: neither ANALYTICS_INGEST_KEYS nor ANALYTICS_INGEST_SECRET set; requests will be rejected")
 	}
+	go keys.Watch(context.Background(), 10*time.Second)
 	allowSkew := 300 * time.Second
 	if v := strings.TrimSpace(os.Getenv("ANALYTICS_INGEST_SKEW")); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
@@ -43,7 +56,38 @@ func main() {
 		}
 	}
 
-	s := &server{q: q, secret: secret, allowSkew: allowSkew}
+	// 事件规范：校验事件 id / 必填属性 / 类型 / 枚举，不合规事件进入死信流
+	specPath := strings.TrimSpace(os.Getenv("ANALYTICS_EVENTS_SPEC"))
+	if specPath == "" {
+		specPath = "configs/analytics/events.yaml"
+	}
+	spec, err := schema.Load(specPath)
+	if err != nil {
+		log.Fatalf("[ingest] load event spec: %v", err)
+	}
+	invalid, err := schema.ParseMode(strings.TrimSpace(os.Getenv("ANALYTICS_INGEST_INVALID")))
+	if err != nil {
+		log.Fatalf("[ingest] %v", err)
+	}
+
+	// nonce 缓存与密钥配额：默认进程内存；多实例部署设置 ANALYTICS_INGEST_REDIS_URL 共享
+	auth := &ingestauth.Verifier{Keys: keys, Skew: allowSkew}
+	if u := strings.TrimSpace(os.Getenv("ANALYTICS_INGEST_REDIS_URL")); u != "" {
+		opt, err := redis.ParseURL(u)
+		if err != nil {
+			log.Fatalf("[ingest] ANALYTICS_INGEST_REDIS_URL: %v", err)
+		}
+		rdb := redis.NewClient(opt)
+		defer rdb.Close()
+		auth.Nonces = ingestauth.NewRedisNonceCache(rdb, "")
+		auth.Counter = ingestauth.NewRedisCounter(rdb, "")
+	} else {
+		size, _ := strconv.Atoi(strings.TrimSpace(os.Getenv("ANALYTICS_INGEST_NONCE_CACHE")))
+		auth.Nonces = ingestauth.NewMemNonceCache(size)
+		auth.Counter = ingestauth.NewMemCounter()
+	}
+
+	s := &server{q: q, auth: auth, spec: spec, invalid: invalid}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -63,53 +107,105 @@ func main() {
 	}
 }
 
-// authMiddleware 校验时间戳/nonce/签名，防止重放。签名: base64(HMAC_SHA256(secret, ts + "\n" + nonce + "\n" + sha256(body))).
+type ctxKey struct{}
+
+// authMiddleware 校验 X-Key-Id 选定的密钥签名、时间戳与 nonce(防重放)，并按密钥计入配额。
+// 签名: base64(HMAC_SHA256(secret, ts + "\n" + nonce + "\n" + sha256(body)))；
+// 不带 X-Key-Id 的请求使用 ANALYTICS_INGEST_SECRET(旧版全局密钥)。
 func (s *server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if s.secret == "" {
+		if s.auth.Keys.Len() == 0 {
 			respondJSON(w, http.StatusForbidden, map[string]string{"error": "ingest_disabled"})
 			return
 		}
-		tsStr := strings.TrimSpace(r.Header.Get("X-Timestamp"))
-		nonce := strings.TrimSpace(r.Header.Get("X-Nonce"))
-		sig := strings.TrimSpace(r.Header.Get("X-Signature"))
-		if tsStr == "" || nonce == "" || sig == "" {
+		req := ingestauth.Request{
+			KeyID:     strings.TrimSpace(r.Header.Get("X-Key-Id")),
+			Timestamp: strings.TrimSpace(r.Header.Get("X-Timestamp")),
+			Nonce:     strings.TrimSpace(r.Header.Get("X-Nonce")),
+			Signature: strings.TrimSpace(r.Header.Get("X-Signature")),
+		}
+		if req.Timestamp == "" || req.Nonce == "" || req.Signature == "" {
 			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing_auth_headers"})
 			return
 		}
-		ts, err := strconv.ParseInt(tsStr, 10, 64)
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
 		if err != nil {
-			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "bad_timestamp"})
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "read_body_failed"})
 			return
 		}
-		now := time.Now().Unix()
-		if delta := time.Duration(abs64(now-ts)) * time.Second; delta > s.allowSkew {
-			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "timestamp_skew"})
+		if len(body) > maxBodyBytes {
+			respondJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "body_too_large"})
 			return
 		}
-		// hash body
-		body, err := io.ReadAll(r.Body)
+		req.Body = body
+		key, err := s.auth.Verify(r.Context(), req)
 		if err != nil {
-			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "read_body_failed"})
-			return
-		}
-		sum := sha256.Sum256(body)
-		sumHex := hex.EncodeToString(sum[:])
-		msg := tsStr + "\n" + nonce + "\n" + sumHex
-		mac := hmac.New(sha256.New, []byte(s.secret))
-		_, _ = mac.Write([]byte(msg))
-		expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-		if !hmac.Equal([]byte(expected), []byte(sig)) {
-			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "bad_signature"})
+			var qe *ingestauth.QuotaError
+			switch {
+			case errors.As(err, &qe):
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(qe.RetryAfter.Seconds()))))
+				respondJSON(w, http.StatusTooManyRequests, map[string]string{"error": "quota_exceeded", "quota": qe.Quota})
+			case errors.Is(err, ingestauth.ErrBadTimestamp):
+				respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "bad_timestamp"})
+			case errors.Is(err, ingestauth.ErrTimestampSkew):
+				respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "timestamp_skew"})
+			case errors.Is(err, ingestauth.ErrUnknownKey):
+				respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unknown_key"})
+			case errors.Is(err, ingestauth.ErrKeyRevoked):
+				respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "key_revoked"})
+			case errors.Is(err, ingestauth.ErrKeyExpired):
+				respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "key_expired"})
+			case errors.Is(err, ingestauth.ErrBadSignature):
+				respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "bad_signature"})
+			case errors.Is(err, ingestauth.ErrReplayed):
+				respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "replayed_nonce"})
+			case errors.Is(err, ingestauth.ErrNonceCacheFull):
+				w.Header().Set("Retry-After", "1")
+				respondJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "nonce_cache_full"})
+			default:
+				log.Printf("[ingest] auth: %v", err)
+				respondJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "auth_unavailable"})
+			}
 			return
 		}
 		// Put back body for next handler
 		r.Body = io.NopCloser(bytes.NewReader(body))
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, key)))
 	})
 }
 
-// ingestEvents 接收通用事件数组，写入 MQ: analytics:events
+// bindGame 将事件绑定到签名密钥所属的游戏：缺省 game_id/env 由密钥补齐，声明其它游戏的事件使整个请求被拒绝。
+func bindGame(r *http.Request, arr []map[string]any) bool {
+	key, _ := r.Context().Value(ctxKey{}).(ingestauth.Key)
+	if key.GameID == "" {
+		return true
+	}
+	for _, e := range arr {
+		if e == nil {
+			continue
+		}
+		game, _ := schema.Envelope(e, "game_id").(string)
+		env, _ := schema.Envelope(e, "env").(string)
+		if game == "" {
+			game = key.GameID
+		}
+		if env == "" {
+			env = key.Env
+		}
+		if !key.Allows(game, env) {
+			return false
+		}
+		e["game_id"] = game
+		if env != "" {
+			e["env"] = env
+		}
+	}
+	return true
+}
+
+// ingestEvents 接收通用事件数组，按事件规范校验并规范化后写入 MQ: analytics:events；
+// 不合规事件按 ANALYTICS_INGEST_INVALID 写入死信流(quarantine)或直接丢弃(reject)。
+// 响应返回逐条的接收/拒绝统计；整批均不合规时返回 422。
 func (s *server) ingestEvents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -121,13 +217,21 @@ func (s *server) ingestEvents(w http.ResponseWriter, r *http.Request) {
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_payload"})
 		return
 	}
-	for _, e := range arr {
-		if err := s.q.PublishEvent(e); err != nil {
-			respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "queue_write_failed"})
-			return
-		}
+	if !bindGame(r, arr) {
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "game_mismatch"})
+		return
 	}
-	w.WriteHeader(http.StatusAccepted)
+	rep, err := schema.Ingest(s.spec, s.q, s.invalid, arr)
+	if err != nil {
+		log.Printf("[ingest] queue write: %v", err)
+		respondJSON(w, http.StatusInternalServerError, map[string]any{"error": "queue_write_failed", "accepted": rep.Accepted, "rejected": rep.Rejected})
+		return
+	}
+	code := http.StatusAccepted
+	if rep.Accepted == 0 && rep.Rejected > 0 {
+		code = http.StatusUnprocessableEntity
+	}
+	respondJSON(w, code, rep)
 }
 
 // ingestPayments 接收支付事件数组，写入 MQ: analytics:payments
@@ -142,6 +246,10 @@ func (s *server) ingestPayments(w http.ResponseWriter, r *http.Request) {
 		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_payload"})
 		return
 	}
+	if !bindGame(r, arr) {
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "game_mismatch"})
+		return
+	}
 	for _, e := range arr {
 		if err := s.q.PublishPayment(e); err != nil {
 			respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "queue_write_failed"})
@@ -153,13 +261,6 @@ func (s *server) ingestPayments(w http.ResponseWriter, r *http.Request) {
 
 // Helpers
 
-func abs64(x int64) int64 {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
 func respondJSON(w http.ResponseWriter, code int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -8,6 +8,7 @@ import (
 	"github.com/cuihairu/croupier/services/edge/internal/handler"
 	"github.com/cuihairu/croupier/services/edge/internal/svc"
 	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/rest"
 )
 
@@ -25,6 +26,12 @@ func main() {
 	ctx := svc.NewServiceContext(c)
 	handler.RegisterHandlers(server, ctx)
 
+	grpcServer, err := ctx.StartGRPC()
+	if err != nil {
+		logx.Must(fmt.Errorf("start edge grpc: %w", err))
+	}
+	defer grpcServer.GracefulStop()
+
 	fmt.Printf("Starting edge server at %s:%d...\n", c.Host, c.Port)
 	server.Start()
 }
\ No newline at end of file
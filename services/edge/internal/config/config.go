@@ -21,6 +21,12 @@ type Config struct {
 		Labels map[string]string `json:",optional"`
 	} `json:",optional"`
 
+	// GRPC is where agents open their tunnels and the server calls the agents
+	// behind them, over mTLS with the TLS certificate when TLS.Enabled.
+	GRPC struct {
+		Addr string `json:",default=0.0.0.0:9443"`
+	} `json:",optional"`
+
 	Tunnel struct {
 		MaxTunnels  int   `json:",default=1000"`
 		Timeout     int64 `json:",default=300000"`
@@ -48,12 +54,6 @@ type Config struct {
 		Path    string `json:",default=/metrics"`
 	} `json:",optional"`
 
-	Log struct {
-		ServiceName string `json:",default=croupier-edge"`
-		Mode        string `json:",default=console"`
-		Level       string `json:",default=info"`
-	} `json:",optional"`
-
 	TLS struct {
 		Enabled            bool   `json:",default=false"`
 		CertFile           string `json:",optional"`
@@ -0,0 +1,41 @@
+package svc
+
+import (
+	"net"
+
+	"github.com/cuihairu/croupier/internal/platform/tlsutil"
+	"github.com/zeromicro/go-zero/core/logx"
+	"google.golang.org/grpc"
+)
+
+// StartGRPC serves the edge tunnel, function and job services on GRPC.Addr.
+// With TLS.Enabled callers must present a certificate of the CA in TLS.CAFile:
+// agents are bound to the identity of theirs, and only the server may call the
+// agents behind the edge.
+func (s *ServiceContext) StartGRPC() (*grpc.Server, error) {
+	addr := s.Config.GRPC.Addr
+	var opts []grpc.ServerOption
+	if s.Config.TLS.Enabled {
+		creds, err := tlsutil.ServerTLS(s.Config.TLS.CertFile, s.Config.TLS.KeyFile, s.Config.TLS.CAFile, true)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+		s.Edge.RequireAgentIdentity()
+	} else {
+		logx.Errorf("tls is disabled: edge gRPC on %s accepts unauthenticated agents and callers", addr)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(opts...)
+	s.Edge.RegisterGRPC(srv)
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			logx.Errorf("edge grpc server stopped: %v", err)
+		}
+	}()
+	logx.Infof("edge grpc listening on %s", addr)
+	return srv, nil
+}
@@ -7,12 +7,14 @@ import (
 	"sync"
 	"time"
 
+	edgeapp "github.com/cuihairu/croupier/internal/app/edge"
 	"github.com/cuihairu/croupier/services/edge/internal/config"
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
 type ServiceContext struct {
 	Config       config.Config
+	Edge         *edgeapp.App
 	TunnelMgr    *TunnelManager
 	ProxyMgr     *ProxyManager
 	LoadBalancer *LoadBalancer
@@ -74,6 +76,7 @@ func NewServiceContext(c config.Config) *ServiceContext {
 
 	return &ServiceContext{
 		Config:       c,
+		Edge:         edgeapp.New(nil),
 		TunnelMgr:    NewTunnelManager(c.Tunnel.MaxTunnels),
 		ProxyMgr:     NewProxyManager(c.Proxy.MaxConnections),
 		LoadBalancer: NewLoadBalancer(c.LoadBalancer.Strategy),
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 
@@ -30,6 +31,15 @@ func main() {
 	ctx := svc.NewServiceContext(c)
 	handler.RegisterHandlers(server, ctx)
 
+	grpcServer, err := ctx.StartControlServer()
+	if err != nil {
+		fmt.Printf("Control server disabled: %v\n", err)
+	} else if grpcServer != nil {
+		defer grpcServer.GracefulStop()
+	}
+	ctx.ResumeJobs(context.Background())
+	ctx.ResumeApprovals(context.Background())
+
 	fmt.Printf("Starting server at %s:%d...\n", c.Host, c.Port)
 	server.Start()
 }
@@ -19,6 +19,9 @@ type Config struct {
 	Storage     StorageConfig     `json:"storage" yaml:"storage"`
 	CroupierLog CroupierLogConfig `json:"croupier_log" yaml:"croupier_log"`
 	Metrics     MetricsConfig     `json:"metrics" yaml:"metrics"`
+	Audit       AuditConfig       `json:"audit,optional" yaml:"audit,optional"`
+	RateLimit   RateLimitConfig   `json:"rate_limit,optional" yaml:"rate_limit,optional"`
+	Analytics   AnalyticsConfig   `json:"analytics,optional" yaml:"analytics,optional"`
 	Profiles    map[string]ProfileConfig `json:"profiles" yaml:"profiles"`
 }
 
@@ -28,7 +31,17 @@ type ServerConfig struct {
 	Cert     string          `json:"cert,optional" yaml:"cert,optional"`
 	Key      string          `json:"key,optional" yaml:"key,optional"`
 	CA       string          `json:"ca,optional" yaml:"ca,optional"`
-	Database DatabaseConfig  `json:"db" yaml:"db"`
+	// CAKey signs agent certificates; with Cert, Key and CA all empty a
+	// development CA is generated under data/certs.
+	CAKey        string         `json:"ca_key,optional" yaml:"ca_key,optional"`
+	AgentCertTTL string         `json:"agent_cert_ttl,optional" yaml:"agent_cert_ttl,optional"`
+	// AgentRevocations lists revoked agent certificates, by hex serial or
+	// "agent:<id>", which can no longer be renewed.
+	AgentRevocations string `json:"agent_revocations,optional" yaml:"agent_revocations,optional"`
+	// AgentInsecure calls agents without TLS, for agents run with Server.Insecure.
+	// Otherwise agent certificates are verified against CA.
+	AgentInsecure bool           `json:"agent_insecure,optional" yaml:"agent_insecure,optional"`
+	Database      DatabaseConfig `json:"db" yaml:"db"`
 }
 
 type DatabaseConfig struct {
@@ -40,13 +53,111 @@ type RegistryConfig struct {
 	AssignmentsPath      string `json:"assignments_path,optional" yaml:"assignments_path,optional"`
 	AnalyticsFiltersPath string `json:"analytics_filters_path,optional" yaml:"analytics_filters_path,optional"`
 	RateLimitsPath       string `json:"rate_limits_path,optional" yaml:"rate_limits_path,optional"`
+	// AgentSessionTTL is how long an agent stays registered without a heartbeat.
+	AgentSessionTTL string `json:"agent_session_ttl,optional" yaml:"agent_session_ttl,optional"`
+	// Backend shares registered agents between server replicas: "memory" (the
+	// default, one replica only), "redis" using RedisURL, or "sql" using Server.DB.
+	Backend  string `json:"backend,optional" yaml:"backend,optional"`
+	RedisURL string `json:"redis_url,optional" yaml:"redis_url,optional"`
+	// SyncInterval is how often a replica reloads the shared registry, bounding
+	// how late it sees changes whose notification it missed.
+	SyncInterval string `json:"sync_interval,optional" yaml:"sync_interval,optional"`
 }
 
 type AuthConfig struct {
-	JWTSecret    string `json:"jwt_secret,optional" yaml:"jwt_secret,optional"`
-	RBACConfig   string `json:"rbac_config,optional" yaml:"rbac_config,optional"`
-	UsersConfig  string `json:"users_config,optional" yaml:"users_config,optional"`
-	GamesConfig  string `json:"games_config,optional" yaml:"games_config,optional"`
+	JWTSecret   string             `json:"jwt_secret,optional" yaml:"jwt_secret,optional"`
+	RBACConfig  string             `json:"rbac_config,optional" yaml:"rbac_config,optional"`
+	UsersConfig string             `json:"users_config,optional" yaml:"users_config,optional"`
+	GamesConfig string             `json:"games_config,optional" yaml:"games_config,optional"`
+	MFA         MFAConfig          `json:"mfa,optional" yaml:"mfa,optional"`
+	AccessTTL   string             `json:"access_ttl,optional" yaml:"access_ttl,optional"`
+	RefreshTTL  string             `json:"refresh_ttl,optional" yaml:"refresh_ttl,optional"`
+	ActiveKey   string             `json:"active_key,optional" yaml:"active_key,optional"`
+	SigningKeys []SigningKeyConfig `json:"signing_keys,optional" yaml:"signing_keys,optional"`
+	OIDC        OIDCConfig         `json:"oidc,optional" yaml:"oidc,optional"`
+	// TrustedProxies lists proxy addresses or CIDRs whose X-Forwarded-For is
+	// believed when checking API token IP allowlists.
+	TrustedProxies []string `json:"trusted_proxies,optional" yaml:"trusted_proxies,optional"`
+}
+
+// OIDCConfig configures single sign-on with an OpenID Connect provider. Users are
+// provisioned at their first login; on every login their roles and game/env grants
+// are replaced by the RoleMappings matching their ID token, plus DefaultRoles. A
+// login matching no mapping and without DefaultRoles is refused, as are emails
+// outside AllowedDomains when set. LinkExisting lets the first SSO login take over
+// a local account of the same username. With Enforce set, password login is only
+// allowed for LocalUsers, e.g. a break-glass admin. After login the browser is sent
+// to PostLoginRedirect, with the tokens in the URL fragment, unless the login named
+// a redirect of its own.
+type OIDCConfig struct {
+	Enabled           bool              `json:"enabled,optional" yaml:"enabled,optional"`
+	Issuer            string            `json:"issuer,optional" yaml:"issuer,optional"`
+	ClientID          string            `json:"client_id,optional" yaml:"client_id,optional"`
+	ClientSecret      string            `json:"client_secret,optional" yaml:"client_secret,optional"`
+	RedirectURL       string            `json:"redirect_url,optional" yaml:"redirect_url,optional"`
+	Scopes            []string          `json:"scopes,optional" yaml:"scopes,optional"`
+	UsernameClaim     string            `json:"username_claim,optional" yaml:"username_claim,optional"`
+	GroupsClaim       string            `json:"groups_claim,optional" yaml:"groups_claim,optional"`
+	AllowedDomains    []string          `json:"allowed_domains,optional" yaml:"allowed_domains,optional"`
+	LinkExisting      bool              `json:"link_existing,optional" yaml:"link_existing,optional"`
+	DefaultRoles      []string          `json:"default_roles,optional" yaml:"default_roles,optional"`
+	RoleMappings      []OIDCRoleMapping `json:"role_mappings,optional" yaml:"role_mappings,optional"`
+	Enforce           bool              `json:"enforce,optional" yaml:"enforce,optional"`
+	LocalUsers        []string          `json:"local_users,optional" yaml:"local_users,optional"`
+	PostLoginRedirect string            `json:"post_login_redirect,optional" yaml:"post_login_redirect,optional"`
+}
+
+// OIDCRoleMapping grants Roles and Games to users whose GroupsClaim contains Group,
+// or whose Claim equals Value. Games entries are "game" for all of its envs or
+// "game/env"; users matching no mapping with games may access all games.
+type OIDCRoleMapping struct {
+	Group string   `json:"group,optional" yaml:"group,optional"`
+	Claim string   `json:"claim,optional" yaml:"claim,optional"`
+	Value string   `json:"value,optional" yaml:"value,optional"`
+	Roles []string `json:"roles,optional" yaml:"roles,optional"`
+	Games []string `json:"games,optional" yaml:"games,optional"`
+}
+
+// SigningKeyConfig is a token key selected by its ID in the "kid" header. HS256 keys
+// take a Secret, EdDSA and RS256 keys a PEM PrivateKey; a retired key can keep only
+// its PublicKey so tokens it signed verify until they expire.
+type SigningKeyConfig struct {
+	ID         string `json:"id" yaml:"id"`
+	Alg        string `json:"alg,optional" yaml:"alg,optional"`
+	Secret     string `json:"secret,optional" yaml:"secret,optional"`
+	PrivateKey string `json:"private_key,optional" yaml:"private_key,optional"`
+	PublicKey  string `json:"public_key,optional" yaml:"public_key,optional"`
+}
+
+// MFAConfig configures TOTP multi-factor login. Users holding any of RequiredRoles
+// or RequiredPerms must enroll before they get a full session; step-up
+// verifications of high-risk invocations stay valid for StepUpTTL.
+type MFAConfig struct {
+	Issuer        string   `json:"issuer,optional" yaml:"issuer,optional"`
+	RequiredRoles []string `json:"required_roles,optional" yaml:"required_roles,optional"`
+	RequiredPerms []string `json:"required_perms,optional" yaml:"required_perms,optional"`
+	StepUpTTL     string   `json:"step_up_ttl,optional" yaml:"step_up_ttl,optional"`
+}
+
+// AuditConfig configures the hash-chained audit log and its segment rotation.
+type AuditConfig struct {
+	Path      string `json:"path,optional" yaml:"path,optional"`
+	MaxSizeMB int64  `json:"max_size_mb,optional" yaml:"max_size_mb,optional"`
+	MaxAge    string `json:"max_age,optional" yaml:"max_age,optional"`
+}
+
+// RateLimitConfig configures invocation rate limiting. With RedisURL set, rate-limit
+// windows are shared between server replicas.
+type RateLimitConfig struct {
+	RedisURL string `json:"redis_url,optional" yaml:"redis_url,optional"`
+}
+
+// AnalyticsConfig sets how ingested analytics events are checked. Events not
+// matching EventsSpec are written to the dead-letter stream when Invalid is
+// "quarantine" (the default) and dropped when it is "reject".
+type AnalyticsConfig struct {
+	EventsSpec string `json:"events_spec,optional" yaml:"events_spec,optional"`
+	Invalid    string `json:"invalid,optional" yaml:"invalid,optional"`
 }
 
 type DescriptorConfig struct {
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func AgentCertificateIssueHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.AgentCertificateIssueRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+		l := logic.NewAgentCertificateIssueLogic(ctx, svcCtx)
+		resp, err := l.AgentCertificateIssue(&req)
+		if err != nil {
+			if errors.Is(err, svc.ErrNoAgentCA) {
+				httpx.WriteJsonCtx(ctx, w, http.StatusServiceUnavailable, map[string]string{"message": err.Error()})
+				return
+			}
+			writeUsersError(ctx, w, err)
+			return
+		}
+		httpx.OkJsonCtx(ctx, w, resp)
+	}
+}
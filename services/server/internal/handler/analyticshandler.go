@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/cuihairu/croupier/services/server/internal/logic"
@@ -258,12 +259,20 @@ func AnalyticsIngestHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 			return
 		}
 		l := logic.NewAnalyticsIngestLogic(r.Context(), svcCtx)
-		if err := l.AnalyticsIngest(&req); err != nil {
+		resp, err := l.AnalyticsIngest(&req)
+		if err != nil {
+			if errors.Is(err, logic.ErrUnavailable) {
+				httpx.WriteJsonCtx(r.Context(), w, http.StatusServiceUnavailable, map[string]string{"message": err.Error()})
+				return
+			}
 			httpx.ErrorCtx(r.Context(), w, err)
 			return
 		}
-		w.WriteHeader(http.StatusAccepted)
-		httpx.OkJsonCtx(r.Context(), w, map[string]bool{"ok": true})
+		code := http.StatusAccepted
+		if resp.Accepted == 0 && resp.Rejected > 0 {
+			code = http.StatusUnprocessableEntity
+		}
+		httpx.WriteJsonCtx(r.Context(), w, code, resp)
 	}
 }
 
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func ApiTokenCreateHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.ApiTokenCreateRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+		l := logic.NewApiTokenCreateLogic(ctx, svcCtx)
+		resp, err := l.ApiTokenCreate(&req)
+		if err != nil {
+			writeUsersError(ctx, w, err)
+			return
+		}
+		httpx.OkJsonCtx(ctx, w, resp)
+	}
+}
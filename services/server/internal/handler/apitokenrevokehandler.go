@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func ApiTokenRevokeHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.ApiTokenIdRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+		l := logic.NewApiTokenRevokeLogic(ctx, svcCtx)
+		if err := l.ApiTokenRevoke(&req); err != nil {
+			writeUsersError(ctx, w, err)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
@@ -14,12 +14,16 @@ import (
 
 func ApprovalApproveHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
 		var req types.ApprovalApproveRequest
 		if err := httpx.Parse(r, &req); err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
 			return
 		}
-		l := logic.NewApprovalApproveLogic(r.Context(), svcCtx)
+		l := logic.NewApprovalApproveLogic(ctx, svcCtx)
 		resp, err := l.ApprovalApprove(&req)
 		if err != nil {
 			writeApprovalsError(r.Context(), w, err)
@@ -14,12 +14,16 @@ import (
 
 func ApprovalGetHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
 		var req types.ApprovalGetRequest
 		if err := httpx.Parse(r, &req); err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
 			return
 		}
-		l := logic.NewApprovalGetLogic(r.Context(), svcCtx)
+		l := logic.NewApprovalGetLogic(ctx, svcCtx)
 		resp, err := l.ApprovalGet(&req)
 		if err != nil {
 			writeApprovalsError(r.Context(), w, err)
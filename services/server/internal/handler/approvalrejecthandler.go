@@ -14,12 +14,16 @@ import (
 
 func ApprovalRejectHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
 		var req types.ApprovalRejectRequest
 		if err := httpx.Parse(r, &req); err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
 			return
 		}
-		l := logic.NewApprovalRejectLogic(r.Context(), svcCtx)
+		l := logic.NewApprovalRejectLogic(ctx, svcCtx)
 		resp, err := l.ApprovalReject(&req)
 		if err != nil {
 			writeApprovalsError(r.Context(), w, err)
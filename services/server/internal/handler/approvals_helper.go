@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
@@ -13,8 +14,14 @@ func writeApprovalsError(ctx context.Context, w http.ResponseWriter, err error)
 	switch {
 	case errors.Is(err, logic.ErrInvalidRequest):
 		httpx.WriteJsonCtx(ctx, w, http.StatusBadRequest, map[string]string{"message": "invalid request"})
+	case errors.Is(err, logic.ErrForbidden):
+		httpx.WriteJsonCtx(ctx, w, http.StatusForbidden, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrConflict):
+		httpx.WriteJsonCtx(ctx, w, http.StatusConflict, map[string]string{"message": err.Error()})
 	case errors.Is(err, logic.ErrNotFound):
 		httpx.WriteJsonCtx(ctx, w, http.StatusNotFound, map[string]string{"message": "not found"})
+	case errors.Is(err, svc.ErrMaintenance), errors.Is(err, logic.ErrStepUpRequired):
+		writeInvokeError(ctx, w, err)
 	case errors.Is(err, logic.ErrUnavailable):
 		httpx.WriteJsonCtx(ctx, w, http.StatusServiceUnavailable, map[string]string{"message": "service unavailable"})
 	default:
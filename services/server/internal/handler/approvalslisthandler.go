@@ -14,12 +14,16 @@ import (
 
 func ApprovalsListHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
 		var req types.ApprovalsListRequest
 		if err := httpx.Parse(r, &req); err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
 			return
 		}
-		l := logic.NewApprovalsListLogic(r.Context(), svcCtx)
+		l := logic.NewApprovalsListLogic(ctx, svcCtx)
 		resp, err := l.ApprovalsList(&req)
 		if err != nil {
 			writeApprovalsError(r.Context(), w, err)
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+// AuthJWKSHandler publishes the public token verification keys, so edge services
+// and agents can verify access tokens without sharing a secret.
+func AuthJWKSHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mgr := svcCtx.JWTManager()
+		if mgr == nil {
+			httpx.WriteJsonCtx(r.Context(), w, http.StatusServiceUnavailable, map[string]string{"message": "auth disabled"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_, _ = w.Write(mgr.JWKS())
+	}
+}
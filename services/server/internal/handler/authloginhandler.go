@@ -33,6 +33,10 @@ func AuthLoginHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 				httpx.WriteJsonCtx(r.Context(), w, http.StatusTooManyRequests, map[string]string{"message": "too many login attempts"})
 			case logic.ErrUnauthorized:
 				httpx.WriteJsonCtx(r.Context(), w, http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+			case logic.ErrMFARequired:
+				httpx.WriteJsonCtx(r.Context(), w, http.StatusUnauthorized, map[string]any{"message": "mfa required", "mfa_required": true})
+			case logic.ErrSSORequired:
+				httpx.WriteJsonCtx(r.Context(), w, http.StatusForbidden, map[string]any{"message": "sso required", "sso_required": true})
 			default:
 				httpx.ErrorCtx(r.Context(), w, err)
 			}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+)
+
+func AuthLogoutHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		l := logic.NewAuthLogoutLogic(ctx, svcCtx)
+		if err := l.AuthLogout(); err != nil {
+			writeMFAError(ctx, w, err)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
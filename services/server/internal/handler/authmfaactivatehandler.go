@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func AuthMFAActivateHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := identifyMFA(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.MfaCodeRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+		l := logic.NewMFAActivateLogic(r.Context(), svcCtx, id)
+		resp, err := l.MFAActivate(&req, clientIP(r))
+		if err != nil {
+			writeMFAError(r.Context(), w, err)
+			return
+		}
+		httpx.OkJsonCtx(r.Context(), w, resp)
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func AuthMFAStatusHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := identifyMFA(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		l := logic.NewMFAStatusLogic(r.Context(), svcCtx, id)
+		resp, err := l.MFAStatus()
+		if err != nil {
+			writeMFAError(r.Context(), w, err)
+			return
+		}
+		httpx.OkJsonCtx(r.Context(), w, resp)
+	}
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func AuthOIDCCallbackHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.AuthOidcCallbackRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		l := logic.NewAuthOIDCCallbackLogic(r.Context(), svcCtx)
+		resp, redirect, err := l.AuthOIDCCallback(&req, clientIP(r), r.Header.Get("User-Agent"))
+		if err != nil {
+			writeSSOError(r.Context(), w, err)
+			return
+		}
+		if redirect == "" {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, redirect+"#"+loginFragment(resp), http.StatusFound)
+	}
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func AuthOIDCLoginHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.AuthOidcLoginRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		l := logic.NewAuthOIDCLoginLogic(r.Context(), svcCtx)
+		u, err := l.AuthOIDCLogin(&req)
+		if err != nil {
+			writeSSOError(r.Context(), w, err)
+			return
+		}
+		http.Redirect(w, r, u, http.StatusFound)
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authenticateInvoke authenticates the caller and returns a context carrying actor,
+// roles, session and the time of its last MFA verification.
+func authenticateInvoke(w http.ResponseWriter, r *http.Request, svcCtx *svc.ServiceContext) (context.Context, bool) {
+	id, ok := svcCtx.Identify(r)
+	if !ok || id.EnrollOnly {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+		return nil, false
+	}
+	ctx := svc.WithMFAAt(svc.WithRoles(svc.WithActor(r.Context(), id.User), id.Roles), id.MFAAt)
+	return svc.WithSessionID(ctx, id.SessionID), true
+}
+
+func writeInvokeError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, logic.ErrInvalidRequest):
+		httpx.WriteJsonCtx(ctx, w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrForbidden):
+		httpx.WriteJsonCtx(ctx, w, http.StatusForbidden, map[string]string{"message": "forbidden"})
+	case errors.Is(err, logic.ErrStepUpRequired):
+		httpx.WriteJsonCtx(ctx, w, http.StatusForbidden, map[string]any{"message": err.Error(), "mfa_required": true})
+	case errors.Is(err, logic.ErrConflict):
+		httpx.WriteJsonCtx(ctx, w, http.StatusConflict, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrNotFound), errors.Is(err, svc.ErrJobNotFound):
+		httpx.WriteJsonCtx(ctx, w, http.StatusNotFound, map[string]string{"message": "not found"})
+	case errors.Is(err, svc.ErrRateLimited):
+		var rl *svc.RateLimitError
+		retry := 1
+		if errors.As(err, &rl) {
+			retry = int(math.Ceil(rl.RetryAfter.Seconds()))
+			if retry < 1 {
+				retry = 1
+			}
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		httpx.WriteJsonCtx(ctx, w, http.StatusTooManyRequests, map[string]any{"message": err.Error(), "retry_after": retry})
+	case errors.Is(err, svc.ErrMaintenance):
+		var me *svc.MaintenanceError
+		body := map[string]any{"message": err.Error()}
+		if errors.As(err, &me) {
+			retry := int(math.Ceil(me.RetryAfter(time.Now()).Seconds()))
+			if retry < 1 {
+				retry = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			body["window_id"] = me.Window.ID
+			body["ends_at"] = me.Window.End.UTC().Format(time.RFC3339)
+		}
+		httpx.WriteJsonCtx(ctx, w, http.StatusServiceUnavailable, body)
+	case errors.Is(err, svc.ErrNoAgentAvailable):
+		httpx.WriteJsonCtx(ctx, w, http.StatusServiceUnavailable, map[string]string{"message": err.Error()})
+	default:
+		if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+			code := http.StatusBadGateway
+			switch st.Code() {
+			case codes.DeadlineExceeded:
+				code = http.StatusGatewayTimeout
+			case codes.InvalidArgument:
+				code = http.StatusBadRequest
+			case codes.NotFound:
+				code = http.StatusNotFound
+			}
+			httpx.WriteJsonCtx(ctx, w, code, map[string]string{"message": st.Message()})
+			return
+		}
+		httpx.ErrorCtx(ctx, w, err)
+	}
+}
@@ -8,17 +8,27 @@ import (
 
 	"github.com/cuihairu/croupier/services/server/internal/logic"
 	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
 func JobResultHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		l := logic.NewJobResultLogic(r.Context(), svcCtx)
-		resp, err := l.JobResult()
-		if err != nil {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.JobResultRequest
+		if err := httpx.Parse(r, &req); err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
-		} else {
-			httpx.OkJsonCtx(r.Context(), w, resp)
+			return
+		}
+		l := logic.NewJobResultLogic(ctx, svcCtx)
+		resp, err := l.JobResult(&req)
+		if err != nil {
+			writeInvokeError(r.Context(), w, err)
+			return
 		}
+		httpx.OkJsonCtx(r.Context(), w, resp)
 	}
-}
\ No newline at end of file
+}
@@ -8,17 +8,32 @@ import (
 
 	"github.com/cuihairu/croupier/services/server/internal/logic"
 	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
 func JobStartHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		l := logic.NewJobStartLogic(r.Context(), svcCtx)
-		resp, err := l.JobStart()
-		if err != nil {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.InvokeRequest
+		if err := httpx.Parse(r, &req); err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
-		} else {
-			httpx.OkJsonCtx(r.Context(), w, resp)
+			return
+		}
+		req.GameId, req.Env = resolveAnalyticsScope(r, req.GameId, req.Env)
+		l := logic.NewJobStartLogic(ctx, svcCtx)
+		resp, err := l.JobStart(&req)
+		if err != nil {
+			writeInvokeError(r.Context(), w, err)
+			return
+		}
+		if resp.ApprovalId != "" {
+			httpx.WriteJsonCtx(r.Context(), w, http.StatusAccepted, resp)
+			return
 		}
+		httpx.OkJsonCtx(r.Context(), w, resp)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+// identifyMFA authenticates the caller of an MFA endpoint; unlike authenticateInvoke
+// it accepts sessions limited to MFA enrollment.
+func identifyMFA(w http.ResponseWriter, r *http.Request, svcCtx *svc.ServiceContext) (*svc.Identity, bool) {
+	id, ok := svcCtx.Identify(r)
+	if !ok {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+		return nil, false
+	}
+	return id, true
+}
+
+func writeMFAError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, logic.ErrInvalidRequest):
+		httpx.WriteJsonCtx(ctx, w, http.StatusBadRequest, map[string]string{"message": "invalid request"})
+	case errors.Is(err, logic.ErrUnauthorized):
+		httpx.WriteJsonCtx(ctx, w, http.StatusUnauthorized, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrConflict):
+		httpx.WriteJsonCtx(ctx, w, http.StatusConflict, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrLoginRateLimit):
+		httpx.WriteJsonCtx(ctx, w, http.StatusTooManyRequests, map[string]string{"message": "too many attempts"})
+	case errors.Is(err, logic.ErrAuthDisabled):
+		httpx.WriteJsonCtx(ctx, w, http.StatusServiceUnavailable, map[string]string{"message": "auth disabled"})
+	default:
+		httpx.ErrorCtx(ctx, w, err)
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+// registryWatchPing keeps idle registry watches alive through proxies.
+const registryWatchPing = 30 * time.Second
+
+// RegistryWatchHandler streams registry changes as server-sent events. Each event
+// carries its revision as id so reconnecting clients resume via Last-Event-ID; a
+// revision the server no longer holds answers 410 and the client lists again.
+func RegistryWatchHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.RegistryWatchRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+		if last := strings.TrimSpace(r.Header.Get("Last-Event-ID")); last != "" {
+			if rev, err := strconv.ParseUint(last, 10, 64); err == nil && rev > req.After {
+				req.After = rev
+			}
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			httpx.WriteJsonCtx(r.Context(), w, http.StatusInternalServerError, map[string]string{"message": "streaming unsupported"})
+			return
+		}
+		l := logic.NewRegistryWatchLogic(ctx, svcCtx)
+		events, err := l.RegistryWatch(&req)
+		switch {
+		case errors.Is(err, logic.ErrRevisionGone):
+			httpx.WriteJsonCtx(r.Context(), w, http.StatusGone, map[string]string{"message": err.Error()})
+			return
+		case errors.Is(err, logic.ErrUnavailable):
+			httpx.WriteJsonCtx(r.Context(), w, http.StatusServiceUnavailable, map[string]string{"message": err.Error()})
+			return
+		case err != nil:
+			writeInvokeError(r.Context(), w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		ping := time.NewTicker(registryWatchPing)
+		defer ping.Stop()
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(ev)
+				if err != nil {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Revision, ev.Type, data); err != nil {
+					return
+				}
+			case <-ping.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
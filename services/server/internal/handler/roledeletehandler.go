@@ -8,17 +8,26 @@ import (
 
 	"github.com/cuihairu/croupier/services/server/internal/logic"
 	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
 func RoleDeleteHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		l := logic.NewRoleDeleteLogic(r.Context(), svcCtx)
-		resp, err := l.RoleDelete()
-		if err != nil {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.RoleIdRequest
+		if err := httpx.Parse(r, &req); err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+		l := logic.NewRoleDeleteLogic(ctx, svcCtx)
+		if err := l.RoleDelete(&req); err != nil {
+			writeUsersError(ctx, w, err)
 		} else {
-			httpx.OkJsonCtx(r.Context(), w, resp)
+			w.WriteHeader(http.StatusNoContent)
 		}
 	}
-}
\ No newline at end of file
+}
@@ -13,12 +13,16 @@ import (
 
 func RolesListHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		l := logic.NewRolesListLogic(r.Context(), svcCtx)
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		l := logic.NewRolesListLogic(ctx, svcCtx)
 		resp, err := l.RolesList()
 		if err != nil {
-			httpx.ErrorCtx(r.Context(), w, err)
+			writeUsersError(ctx, w, err)
 		} else {
-			httpx.OkJsonCtx(r.Context(), w, resp)
+			httpx.OkJsonCtx(ctx, w, resp)
 		}
 	}
-}
\ No newline at end of file
+}
@@ -5,6 +5,7 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/cuihairu/croupier/services/server/internal/svc"
 
@@ -149,11 +150,56 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/api/auth/login",
 				Handler: AuthLoginHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/auth/oidc/login",
+				Handler: AuthOIDCLoginHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/auth/oidc/callback",
+				Handler: AuthOIDCCallbackHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/auth/refresh",
+				Handler: AuthRefreshHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/auth/logout",
+				Handler: AuthLogoutHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/auth/jwks",
+				Handler: AuthJWKSHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodGet,
 				Path:    "/api/auth/me",
 				Handler: AuthMeHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/auth/mfa",
+				Handler: AuthMFAStatusHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/auth/mfa/enroll",
+				Handler: AuthMFAEnrollHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/auth/mfa/activate",
+				Handler: AuthMFAActivateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/auth/mfa/verify",
+				Handler: AuthMFAVerifyHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodGet,
 				Path:    "/api/support/tickets",
@@ -234,6 +280,141 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/api/support/feedback/:id",
 				Handler: SupportFeedbackDeleteHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/users",
+				Handler: UsersListHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/users",
+				Handler: UserCreateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/api/users/:id",
+				Handler: UserUpdateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/api/users/:id",
+				Handler: UserDeleteHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/users/:id/password",
+				Handler: UserPasswordResetHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/api/users/:id/mfa",
+				Handler: UserMFAResetHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/api/users/:id/sessions",
+				Handler: UserSessionsRevokeHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/sessions",
+				Handler: SessionsListHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/api/sessions/:id",
+				Handler: SessionRevokeHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/service-accounts",
+				Handler: ServiceAccountsListHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/service-accounts",
+				Handler: ServiceAccountCreateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/api/service-accounts/:name",
+				Handler: ServiceAccountUpdateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/api/service-accounts/:name",
+				Handler: ServiceAccountDeleteHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/service-accounts/:name/tokens",
+				Handler: ApiTokensListHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/service-accounts/:name/tokens",
+				Handler: ApiTokenCreateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/api/service-accounts/:name/tokens/:id",
+				Handler: ApiTokenRevokeHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/agent-certificates",
+				Handler: AgentCertificateIssueHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/users/:id/games",
+				Handler: UserGamesHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/api/users/:id/games",
+				Handler: UserGamesUpdateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/users/:id/games/:game_id/envs",
+				Handler: UserGameEnvsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/api/users/:id/games/:game_id/envs",
+				Handler: UserGameEnvsUpdateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/roles",
+				Handler: RolesListHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/roles",
+				Handler: RoleCreateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/api/roles/:id",
+				Handler: RoleUpdateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/api/roles/:id",
+				Handler: RoleDeleteHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/api/roles/:id/perms",
+				Handler: RolePermissionsUpdateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/audit",
+				Handler: AuditHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodGet,
 				Path:    "/api/approvals",
@@ -669,6 +850,26 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/api/descriptors",
 				Handler: DescriptorsHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/invoke",
+				Handler: InvokeHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/start_job",
+				Handler: JobStartHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/api/cancel_job",
+				Handler: JobCancelHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/job_result",
+				Handler: JobResultHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodGet,
 				Path:    "/api/ui_schema",
@@ -766,4 +967,22 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 			},
 		},
 	)
+
+	// Server-sent event streams outlive the default request timeout; clients resume
+	// with Last-Event-ID when the connection is cut.
+	server.AddRoutes(
+		[]rest.Route{
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/stream_job",
+				Handler: StreamJobHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/api/registry/watch",
+				Handler: RegistryWatchHandler(serverCtx),
+			},
+		},
+		rest.WithTimeout(30*time.Minute),
+	)
 }
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func ServiceAccountsListHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		l := logic.NewServiceAccountsListLogic(ctx, svcCtx)
+		resp, err := l.ServiceAccountsList()
+		if err != nil {
+			writeUsersError(ctx, w, err)
+			return
+		}
+		httpx.OkJsonCtx(ctx, w, resp)
+	}
+}
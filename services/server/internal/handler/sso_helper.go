@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func writeSSOError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, logic.ErrInvalidRequest):
+		httpx.WriteJsonCtx(ctx, w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrUnauthorized):
+		httpx.WriteJsonCtx(ctx, w, http.StatusUnauthorized, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrForbidden):
+		httpx.WriteJsonCtx(ctx, w, http.StatusForbidden, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrUnavailable), errors.Is(err, logic.ErrAuthDisabled):
+		httpx.WriteJsonCtx(ctx, w, http.StatusServiceUnavailable, map[string]string{"message": err.Error()})
+	default:
+		httpx.ErrorCtx(ctx, w, err)
+	}
+}
+
+// loginFragment encodes a login response for the URL fragment of the post-login
+// redirect; fragments are not sent to servers or logged in access logs.
+func loginFragment(resp *types.AuthLoginResponse) string {
+	v := url.Values{}
+	v.Set("token", resp.Token)
+	if resp.RefreshToken != "" {
+		v.Set("refresh_token", resp.RefreshToken)
+	}
+	v.Set("expires_in", strconv.FormatInt(resp.ExpiresIn, 10))
+	if resp.MfaEnrollRequired {
+		v.Set("mfa_enroll_required", "true")
+	}
+	return v.Encode()
+}
@@ -4,21 +4,62 @@
 package handler
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/cuihairu/croupier/services/server/internal/logic"
 	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
 	"github.com/zeromicro/go-zero/rest/httpx"
 )
 
+// StreamJobHandler streams job events as server-sent events. Each event carries the
+// log sequence as its id so reconnecting clients resume via Last-Event-ID.
 func StreamJobHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		l := logic.NewStreamJobLogic(r.Context(), svcCtx)
-		resp, err := l.StreamJob()
-		if err != nil {
+		ctx, ok := authenticateInvoke(w, r, svcCtx)
+		if !ok {
+			return
+		}
+		var req types.JobStreamRequest
+		if err := httpx.Parse(r, &req); err != nil {
 			httpx.ErrorCtx(r.Context(), w, err)
-		} else {
-			httpx.OkJsonCtx(r.Context(), w, resp)
+			return
+		}
+		if last := strings.TrimSpace(r.Header.Get("Last-Event-ID")); last != "" {
+			if seq, err := strconv.ParseInt(last, 10, 64); err == nil && seq > req.After {
+				req.After = seq
+			}
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			httpx.WriteJsonCtx(r.Context(), w, http.StatusInternalServerError, map[string]string{"message": "streaming unsupported"})
+			return
+		}
+		l := logic.NewStreamJobLogic(ctx, svcCtx)
+		events, err := l.StreamJob(&req)
+		if err != nil {
+			writeInvokeError(r.Context(), w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		for ev := range events {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
 		}
 	}
-}
\ No newline at end of file
+}
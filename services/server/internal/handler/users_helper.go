@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cuihairu/croupier/services/server/internal/logic"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func writeUsersError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, logic.ErrInvalidRequest):
+		httpx.WriteJsonCtx(ctx, w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrForbidden):
+		httpx.WriteJsonCtx(ctx, w, http.StatusForbidden, map[string]string{"message": "forbidden"})
+	case errors.Is(err, logic.ErrNotFound):
+		httpx.WriteJsonCtx(ctx, w, http.StatusNotFound, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrConflict):
+		httpx.WriteJsonCtx(ctx, w, http.StatusConflict, map[string]string{"message": err.Error()})
+	case errors.Is(err, logic.ErrUnavailable):
+		httpx.WriteJsonCtx(ctx, w, http.StatusServiceUnavailable, map[string]string{"message": "users repository unavailable"})
+	default:
+		httpx.ErrorCtx(ctx, w, err)
+	}
+}
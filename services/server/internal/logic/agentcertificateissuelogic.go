@@ -0,0 +1,87 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	"github.com/cuihairu/croupier/internal/security/apitoken"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const agentCertifyPermission = "agents:certify"
+
+type AgentCertificateIssueLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewAgentCertificateIssueLogic(ctx context.Context, svcCtx *svc.ServiceContext) *AgentCertificateIssueLogic {
+	return &AgentCertificateIssueLogic{Logger: logx.WithContext(ctx), ctx: ctx, svcCtx: svcCtx}
+}
+
+// AgentCertificateIssue signs the CSR of a new agent with the Croupier CA. The
+// certificate names the agent id and games given here, which the control plane
+// then enforces; the caller must hold agents:certify in each of those games.
+func (l *AgentCertificateIssueLogic) AgentCertificateIssue(req *types.AgentCertificateIssueRequest) (*types.AgentCertificateIssueResponse, error) {
+	actor, roles := svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx)
+	agentID := strings.TrimSpace(req.AgentId)
+	if agentID == "" || strings.TrimSpace(req.Csr) == "" {
+		return nil, fmt.Errorf("%w: agent_id and csr are required", ErrInvalidRequest)
+	}
+	var games []string
+	anyGame := false
+	for _, g := range req.Games {
+		if g = strings.TrimSpace(g); g == agentcert.AnyGame {
+			anyGame = true
+		} else {
+			games = append(games, g)
+		}
+	}
+	games, err := apitoken.NormalizeScopes(games)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	switch {
+	case anyGame:
+		if !l.svcCtx.EnforcePermission(actor, roles, agentCertifyPermission) {
+			return nil, ErrForbidden
+		}
+		games = []string{agentcert.AnyGame}
+	case len(games) == 0:
+		return nil, fmt.Errorf("%w: games are required, %q for all games", ErrInvalidRequest, agentcert.AnyGame)
+	}
+	for _, g := range games {
+		game, env, _ := strings.Cut(g, "/")
+		if !anyGame && !l.svcCtx.EnforceScopedPermission(actor, roles, agentCertifyPermission, game, env) {
+			return nil, ErrForbidden
+		}
+	}
+	ca, err := l.svcCtx.AgentCA()
+	if err != nil {
+		return nil, err
+	}
+	csr, err := agentcert.ParseCSR([]byte(req.Csr))
+	if err != nil {
+		return nil, fmt.Errorf("%w: csr: %v", ErrInvalidRequest, err)
+	}
+	certPEM, expires, err := ca.Issue(csr, agentcert.Identity{AgentID: agentID, Games: games}, l.svcCtx.AgentCertTTL())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	l.svcCtx.Audit("agent_cert_issue", actor, "agent:"+agentID, map[string]string{
+		"games":      strings.Join(games, ","),
+		"expires_at": expires.UTC().Format(time.RFC3339),
+	})
+	return &types.AgentCertificateIssueResponse{
+		Certificate: string(certPEM),
+		Ca:          string(ca.PEM),
+		ExpiresAt:   expires.UTC().Format(time.RFC3339),
+	}, nil
+}
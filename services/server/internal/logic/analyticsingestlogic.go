@@ -2,7 +2,9 @@ package logic
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/cuihairu/croupier/internal/analytics/schema"
 	"github.com/cuihairu/croupier/services/server/internal/svc"
 	"github.com/cuihairu/croupier/services/server/internal/types"
 
@@ -23,20 +25,28 @@ func NewAnalyticsIngestLogic(ctx context.Context, svcCtx *svc.ServiceContext) *A
 	}
 }
 
-func (l *AnalyticsIngestLogic) AnalyticsIngest(req *types.AnalyticsIngestRequest) error {
+// AnalyticsIngest checks each event against the analytics event spec and
+// publishes the valid ones normalized; invalid events are quarantined or
+// dropped as configured and reported by their index in the batch.
+func (l *AnalyticsIngestLogic) AnalyticsIngest(req *types.AnalyticsIngestRequest) (*types.AnalyticsIngestResponse, error) {
 	queue := l.svcCtx.AnalyticsQueue()
 	if queue == nil {
-		return nil
+		return &types.AnalyticsIngestResponse{}, nil
 	}
-	for _, evt := range req.Events {
-		if evt == nil {
-			continue
-		}
-		if err := queue.PublishEvent(evt); err != nil {
-			return err
-		}
+	spec, mode := l.svcCtx.AnalyticsSpec()
+	if spec == nil {
+		return nil, fmt.Errorf("%w: analytics event spec not loaded", ErrUnavailable)
 	}
-	return nil
+	rep, err := schema.Ingest(spec, queue, mode, req.Events)
+	if err != nil {
+		l.Errorf("analytics ingest: accepted %d, rejected %d before queue error: %v", rep.Accepted, rep.Rejected, err)
+		return nil, err
+	}
+	resp := &types.AnalyticsIngestResponse{Accepted: rep.Accepted, Rejected: rep.Rejected}
+	for _, e := range rep.Errors {
+		resp.Errors = append(resp.Errors, types.AnalyticsIngestError{Index: e.Index, Reason: e.Reason, Field: e.Field, Detail: e.Detail})
+	}
+	return resp, nil
 }
 
 type AnalyticsPaymentsIngestLogic struct {
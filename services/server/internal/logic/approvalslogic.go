@@ -4,16 +4,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/cuihairu/croupier/internal/function/descriptor"
 	appr "github.com/cuihairu/croupier/internal/platform/approvals"
+	"github.com/cuihairu/croupier/internal/security/rbac"
 	"github.com/cuihairu/croupier/services/server/internal/svc"
 	"github.com/cuihairu/croupier/services/server/internal/types"
 
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
+const (
+	approvalsReadPermission    = "approvals:read"
+	approvalsApprovePermission = "approvals:approve"
+)
+
 type ApprovalsListLogic struct {
 	logx.Logger
 	ctx    context.Context
@@ -33,6 +42,9 @@ func (l *ApprovalsListLogic) ApprovalsList(req *types.ApprovalsListRequest) (*ty
 	if store == nil {
 		return nil, ErrUnavailable
 	}
+	if !l.svcCtx.EnforcePermission(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), approvalsReadPermission) {
+		return nil, ErrForbidden
+	}
 	if req == nil {
 		req = &types.ApprovalsListRequest{}
 	}
@@ -91,14 +103,31 @@ func (l *ApprovalGetLogic) ApprovalGet(req *types.ApprovalGetRequest) (*types.Ap
 	if store == nil {
 		return nil, ErrUnavailable
 	}
+	if !l.svcCtx.EnforcePermission(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), approvalsReadPermission) {
+		return nil, ErrForbidden
+	}
 	if req == nil || strings.TrimSpace(req.Id) == "" {
 		return nil, ErrInvalidRequest
 	}
 	approval, err := store.Get(strings.TrimSpace(req.Id))
 	if err != nil {
-		return nil, ErrNotFound
+		return nil, approvalError(err)
 	}
 	detail := approvalDetailFromModel(approval)
+	history, err := store.History(approval.ID)
+	if err != nil {
+		return nil, approvalError(err)
+	}
+	for _, ev := range history {
+		detail.History = append(detail.History, types.ApprovalEvent{
+			Action: ev.Action,
+			Actor:  ev.Actor,
+			Role:   ev.Role,
+			State:  ev.State,
+			Detail: ev.Detail,
+			At:     formatTime(ev.At),
+		})
+	}
 	return detail, nil
 }
 
@@ -116,20 +145,54 @@ func NewApprovalApproveLogic(ctx context.Context, svcCtx *svc.ServiceContext) *A
 	}
 }
 
-func (l *ApprovalApproveLogic) ApprovalApprove(req *types.ApprovalApproveRequest) (*types.ApprovalSummary, error) {
+// ApprovalApprove records the caller's vote. The vote that reaches the threshold
+// dispatches the held call and returns the approval with its outcome.
+func (l *ApprovalApproveLogic) ApprovalApprove(req *types.ApprovalApproveRequest) (*types.ApprovalDetailResponse, error) {
 	store := l.svcCtx.ApprovalsStore()
 	if store == nil {
 		return nil, ErrUnavailable
 	}
+	actor, roles := svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx)
 	if req == nil || strings.TrimSpace(req.Id) == "" {
 		return nil, ErrInvalidRequest
 	}
-	approval, err := store.Approve(strings.TrimSpace(req.Id))
+	// The vote that reaches the threshold dispatches the call, so it must pass the
+	// maintenance gate first; earlier votes are always accepted.
+	pending, err := store.Get(strings.TrimSpace(req.Id))
 	if err != nil {
-		return nil, ErrNotFound
+		return nil, approvalError(err)
 	}
-	summary := approvalSummaryFromModel(approval)
-	return &summary, nil
+	if !l.svcCtx.EnforceScopedPermission(actor, roles, approvalsApprovePermission, pending.GameID, pending.Env) {
+		return nil, ErrForbidden
+	}
+	// Approving a function that requires MFA needs the same step-up as calling it.
+	if err := checkStepUp(l.ctx, l.svcCtx, l.svcCtx.FunctionDescriptor(pending.FunctionID)); err != nil {
+		return nil, err
+	}
+	if threshold := pending.Threshold; pending.State == appr.StatePending && len(pending.Votes)+1 >= max(threshold, 1) {
+		in := &svc.InvokeInput{FunctionID: pending.FunctionID, GameID: pending.GameID, Env: pending.Env}
+		if err := checkMaintenance(l.ctx, l.svcCtx, l.svcCtx.FunctionDescriptor(pending.FunctionID), in); err != nil {
+			return nil, err
+		}
+	}
+	approval, err := store.Approve(pending.ID, actor, roles)
+	if err != nil {
+		return nil, approvalError(err)
+	}
+	l.svcCtx.Audit("approval_approve", actor, approval.FunctionID, approvalAuditMeta(approval))
+	if approval.State == appr.StateApproved {
+		timeout := invokeTimeout(l.svcCtx.FunctionDescriptor(approval.FunctionID))
+		done, err := l.svcCtx.DispatchApproval(l.ctx, approval, timeout)
+		if err != nil {
+			l.Errorf("dispatch approval %s: %v", approval.ID, err)
+			return nil, approvalError(err)
+		}
+		if done.Error != "" {
+			l.Errorf("approval %s: %s failed: %s", done.ID, done.FunctionID, done.Error)
+		}
+		approval = done
+	}
+	return approvalDetailFromModel(approval), nil
 }
 
 type ApprovalRejectLogic struct {
@@ -151,13 +214,22 @@ func (l *ApprovalRejectLogic) ApprovalReject(req *types.ApprovalRejectRequest) (
 	if store == nil {
 		return nil, ErrUnavailable
 	}
+	actor, roles := svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx)
 	if req == nil || strings.TrimSpace(req.Id) == "" {
 		return nil, ErrInvalidRequest
 	}
-	approval, err := store.Reject(strings.TrimSpace(req.Id), strings.TrimSpace(req.Reason))
+	pending, err := store.Get(strings.TrimSpace(req.Id))
+	if err != nil {
+		return nil, approvalError(err)
+	}
+	if !l.svcCtx.EnforceScopedPermission(actor, roles, approvalsApprovePermission, pending.GameID, pending.Env) {
+		return nil, ErrForbidden
+	}
+	approval, err := store.Reject(pending.ID, actor, strings.TrimSpace(req.Reason))
 	if err != nil {
-		return nil, ErrNotFound
+		return nil, approvalError(err)
 	}
+	l.svcCtx.Audit("approval_reject", actor, approval.FunctionID, approvalAuditMeta(approval))
 	summary := approvalSummaryFromModel(approval)
 	return &summary, nil
 }
@@ -166,6 +238,10 @@ func approvalSummaryFromModel(a *appr.Approval) types.ApprovalSummary {
 	if a == nil {
 		return types.ApprovalSummary{}
 	}
+	approvers := make([]string, 0, len(a.Votes))
+	for _, v := range a.Votes {
+		approvers = append(approvers, v.Approver)
+	}
 	return types.ApprovalSummary{
 		Id:              a.ID,
 		CreatedAt:       a.CreatedAt.Format(time.RFC3339),
@@ -180,19 +256,79 @@ func approvalSummaryFromModel(a *appr.Approval) types.ApprovalSummary {
 		State:           a.State,
 		Mode:            a.Mode,
 		Reason:          a.Reason,
+		Threshold:       a.Threshold,
+		ApproverRoles:   a.ApproverRoles,
+		ApprovedBy:      approvers,
+		RejectedBy:      a.RejectedBy,
+		ExpiresAt:       formatTime(a.ExpiresAt),
+		ExecutedAt:      formatTime(a.ExecutedAt),
+		JobId:           a.JobID,
+		Error:           a.Error,
 	}
 }
 
 func approvalDetailFromModel(a *appr.Approval) *types.ApprovalDetailResponse {
 	summary := approvalSummaryFromModel(a)
 	preview := ""
+	var result interface{}
 	if a != nil {
 		preview = summarizePayload(a.Payload)
+		result = decodeInvokePayload(a.Result)
 	}
 	return &types.ApprovalDetailResponse{
 		ApprovalSummary: summary,
 		PayloadPreview:  preview,
+		Result:          result,
+	}
+}
+
+// approvalAuditMeta describes an approval decision for the audit log.
+func approvalAuditMeta(a *appr.Approval) map[string]string {
+	return map[string]string{
+		"game_id":     a.GameID,
+		"env":         a.Env,
+		"approval_id": a.ID,
+		"requester":   a.Actor,
+		"state":       a.State,
+	}
+}
+
+// approvalError maps approval store errors to logic errors.
+func approvalError(err error) error {
+	switch {
+	case errors.Is(err, appr.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, appr.ErrSelfApproval), errors.Is(err, appr.ErrApproverRole):
+		return fmt.Errorf("%w: %v", ErrForbidden, err)
+	case errors.Is(err, appr.ErrNotPending), errors.Is(err, appr.ErrDuplicateVote), errors.Is(err, appr.ErrExpired),
+		errors.Is(err, appr.ErrConflict):
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	return err
+}
+
+// approvalPolicy reads the descriptor auth.two_person_rule, which is either a bool
+// or a rbac.TwoPersonRulePolicy object.
+func approvalPolicy(desc *descriptor.Descriptor) (svc.ApprovalPolicy, bool) {
+	if desc == nil || desc.Auth == nil {
+		return svc.ApprovalPolicy{}, false
+	}
+	switch v := desc.Auth["two_person_rule"].(type) {
+	case bool:
+		return svc.ApprovalPolicy{Threshold: 1}, v
+	case map[string]any:
+		var rule rbac.TwoPersonRulePolicy
+		b, err := json.Marshal(v)
+		if err != nil || json.Unmarshal(b, &rule) != nil || !rule.Required {
+			return svc.ApprovalPolicy{}, false
+		}
+		return svc.ApprovalPolicy{
+			Threshold:     rule.Threshold,
+			ApproverRoles: rule.Approvers,
+			Expiry:        rbac.ParseExpiryDuration(rule.ExpiryTime),
+		}, true
 	}
+	return svc.ApprovalPolicy{}, false
 }
 
 func summarizePayload(payload []byte) string {
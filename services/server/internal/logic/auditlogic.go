@@ -0,0 +1,108 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/audit/chain"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const auditReadPermission = "audit:read"
+
+type AuditLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewAuditLogic(ctx context.Context, svcCtx *svc.ServiceContext) *AuditLogic {
+	return &AuditLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// Audit lists audit events newest first. Paging uses page/size, or limit/offset when
+// no page is given.
+func (l *AuditLogic) Audit(req *types.AuditListRequest) (*types.AuditListResponse, error) {
+	if !l.svcCtx.EnforcePermission(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), auditReadPermission) {
+		return nil, ErrForbidden
+	}
+	path := l.svcCtx.AuditPath()
+	if path == "" {
+		return nil, ErrUnavailable
+	}
+	if req == nil {
+		req = &types.AuditListRequest{}
+	}
+	filter := chain.Filter{
+		Actor:  strings.TrimSpace(req.Actor),
+		Target: strings.TrimSpace(req.Target),
+		GameID: strings.TrimSpace(req.GameId),
+		Env:    strings.TrimSpace(req.Env),
+		IP:     strings.TrimSpace(req.Ip),
+	}
+	for _, k := range strings.Split(req.Kind+","+req.Kinds, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			filter.Kinds = append(filter.Kinds, k)
+		}
+	}
+	var err error
+	if filter.Since, err = parseAuditTime(req.Start); err != nil {
+		return nil, err
+	}
+	if filter.Until, err = parseAuditTime(req.End); err != nil {
+		return nil, err
+	}
+	offset, limit := req.Offset, req.Limit
+	if req.Page > 0 || limit <= 0 {
+		size := req.Size
+		if size <= 0 {
+			size = 20
+		}
+		page := req.Page
+		if page <= 0 {
+			page = 1
+		}
+		offset, limit = (page-1)*size, size
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	events, total, err := chain.Query(path, filter, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	resp := &types.AuditListResponse{Events: make([]types.AuditEvent, 0, len(events)), Total: total}
+	for _, ev := range events {
+		resp.Events = append(resp.Events, types.AuditEvent{
+			Time:   ev.Time.Format(time.RFC3339Nano),
+			Kind:   ev.Kind,
+			Actor:  ev.Actor,
+			Target: ev.Target,
+			Meta:   ev.Meta,
+			Prev:   ev.Prev,
+			Hash:   ev.Hash,
+		})
+	}
+	return resp, nil
+}
+
+func parseAuditTime(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid time %q", ErrInvalidRequest, v)
+	}
+	return t, nil
+}
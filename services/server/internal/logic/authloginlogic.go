@@ -16,6 +16,10 @@ var (
 	ErrAuthDisabled   = errors.New("auth disabled")
 	ErrLoginRateLimit = errors.New("login rate limited")
 	ErrUnauthorized   = errors.New("unauthorized")
+	// ErrMFARequired asks the client to repeat the login with an otp_code.
+	ErrMFARequired = errors.New("mfa required")
+	// ErrSSORequired refuses password logins of users that must sign in with SSO.
+	ErrSSORequired = errors.New("sso required")
 )
 
 type AuthLoginLogic struct {
@@ -34,8 +38,7 @@ func NewAuthLoginLogic(ctx context.Context, svcCtx *svc.ServiceContext) *AuthLog
 
 func (l *AuthLoginLogic) AuthLogin(req *types.AuthLoginRequest, ip, userAgent string) (*types.AuthLoginResponse, error) {
 	repo := l.svcCtx.UserRepository()
-	jwtMgr := l.svcCtx.JWTManager()
-	if repo == nil || jwtMgr == nil {
+	if repo == nil || l.svcCtx.JWTManager() == nil {
 		return nil, ErrAuthDisabled
 	}
 	username := strings.TrimSpace(req.Username)
@@ -46,24 +49,68 @@ func (l *AuthLoginLogic) AuthLogin(req *types.AuthLoginRequest, ip, userAgent st
 	if !l.svcCtx.AllowLogin(ip, username) {
 		return nil, ErrLoginRateLimit
 	}
+	if !l.svcCtx.PasswordLoginAllowed(username) {
+		return nil, ErrSSORequired
+	}
 	user, err := repo.Verify(l.ctx, username, password)
 	if err != nil {
+		l.svcCtx.Audit("login_failed", username, "", map[string]string{"ip": ip, "ua": userAgent})
 		return nil, ErrUnauthorized
 	}
 	roles, err := repo.ListUserRoles(l.ctx, user.ID)
 	if err != nil {
 		return nil, err
 	}
-	token, err := jwtMgr.Sign(user.Username, roles, 8*time.Hour)
+	meta := map[string]string{"ip": ip, "ua": userAgent}
+	var mfaAt time.Time
+	enrollOnly := false
+	// Enrolled users need a second factor; users that must use MFA but have not
+	// enrolled yet get a session that can only enroll.
+	mfa, err := repo.GetMFA(l.ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case mfa.Enrolled():
+		if strings.TrimSpace(req.OtpCode) == "" {
+			return nil, ErrMFARequired
+		}
+		method, err := l.svcCtx.VerifyMFA(l.ctx, user.ID, req.OtpCode)
+		if err != nil {
+			l.svcCtx.Audit("mfa_failed", user.Username, "", meta)
+			return nil, ErrUnauthorized
+		}
+		mfaAt = time.Now()
+		meta["mfa"] = method
+	case l.svcCtx.MFAMandated(user.Username, roles):
+		enrollOnly = true
+		meta["mfa"] = "enroll_required"
+	}
+	return startLogin(l.ctx, l.svcCtx, user.Username, roles, mfaAt, enrollOnly, ip, userAgent, "login", meta)
+}
+
+// startLogin opens a session for an authenticated user and audits the login as
+// kind. A session limited to MFA enrollment gets a token of that scope only.
+func startLogin(ctx context.Context, svcCtx *svc.ServiceContext, username string, roles []string, mfaAt time.Time, enrollOnly bool, ip, userAgent, kind string, meta map[string]string) (*types.AuthLoginResponse, error) {
+	sess, refresh, err := svcCtx.StartSession(ctx, username, ip, userAgent, mfaAt, enrollOnly)
 	if err != nil {
 		return nil, err
 	}
-	resp := &types.AuthLoginResponse{
-		Token: token,
-		User: types.AuthUserInfo{
-			Username: user.Username,
-			Roles:    roles,
-		},
+	scope := ""
+	if enrollOnly {
+		scope = svc.MFAEnrollScope
+	}
+	tok, ttl, err := svcCtx.IssueAccessToken(sess, roles, scope)
+	if err != nil {
+		return nil, err
 	}
-	return resp, nil
+	meta["session_id"] = sess.ID
+	svcCtx.Audit(kind, username, "", meta)
+	return &types.AuthLoginResponse{
+		Token:             tok,
+		RefreshToken:      refresh,
+		ExpiresIn:         int64(ttl.Seconds()),
+		User:              types.AuthUserInfo{Username: username, Roles: roles},
+		MfaEnrollRequired: enrollOnly,
+	}, nil
 }
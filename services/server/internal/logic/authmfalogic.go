@@ -0,0 +1,204 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// mfaSession carries what every MFA logic needs. The identity may be a session
+// limited to MFA enrollment.
+type mfaSession struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+	id     *svc.Identity
+}
+
+func newMFASession(ctx context.Context, svcCtx *svc.ServiceContext, id *svc.Identity) mfaSession {
+	return mfaSession{Logger: logx.WithContext(ctx), ctx: ctx, svcCtx: svcCtx, id: id}
+}
+
+// user returns the active account of the session.
+func (l mfaSession) user() (*svc.UserRecord, error) {
+	if l.id == nil {
+		return nil, ErrUnauthorized
+	}
+	u, err := l.svcCtx.UserRepository().GetUserByUsername(l.ctx, l.id.User)
+	if err != nil {
+		if errors.Is(err, svc.ErrUserNotFound) {
+			return nil, ErrUnauthorized
+		}
+		return nil, err
+	}
+	if !u.Active {
+		return nil, ErrUnauthorized
+	}
+	return u, nil
+}
+
+// verified records a completed second factor in the caller's session and returns
+// a new access token. An enrollment session is upgraded to a full session and also
+// gets a refresh token.
+func (l mfaSession) verified(u *svc.UserRecord, now time.Time) (string, string, error) {
+	if l.svcCtx.JWTManager() == nil {
+		return "", "", ErrAuthDisabled
+	}
+	sess, err := l.svcCtx.ActiveSession(l.ctx, l.id.SessionID, u.Username)
+	if err != nil {
+		if errors.Is(err, svc.ErrSessionInvalid) {
+			return "", "", ErrUnauthorized
+		}
+		return "", "", err
+	}
+	roles, err := l.svcCtx.UserRepository().ListUserRoles(l.ctx, u.ID)
+	if err != nil {
+		return "", "", err
+	}
+	refresh := ""
+	if l.id.EnrollOnly {
+		refresh, err = l.svcCtx.UpgradeSession(l.ctx, sess, now)
+	} else {
+		err = l.svcCtx.MarkSessionMFA(l.ctx, sess, now)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	tok, _, err := l.svcCtx.IssueAccessToken(sess, roles, "")
+	return tok, refresh, err
+}
+
+// mfaError maps MFA errors of the service context to logic errors.
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, svc.ErrMFAInvalidCode):
+		return fmt.Errorf("%w: invalid code", ErrUnauthorized)
+	case errors.Is(err, svc.ErrMFAAlreadyEnrolled):
+		return fmt.Errorf("%w: mfa already enrolled", ErrConflict)
+	case errors.Is(err, svc.ErrMFANotEnrolled):
+		return fmt.Errorf("%w: mfa not enrolled", ErrConflict)
+	}
+	return err
+}
+
+type MFAStatusLogic struct{ mfaSession }
+
+func NewMFAStatusLogic(ctx context.Context, svcCtx *svc.ServiceContext, id *svc.Identity) *MFAStatusLogic {
+	return &MFAStatusLogic{newMFASession(ctx, svcCtx, id)}
+}
+
+func (l *MFAStatusLogic) MFAStatus() (*types.MfaStatusResponse, error) {
+	u, err := l.user()
+	if err != nil {
+		return nil, err
+	}
+	st, err := l.svcCtx.UserRepository().GetMFA(l.ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &types.MfaStatusResponse{
+		Enrolled:          st.Enrolled(),
+		Pending:           !st.Enrolled() && st.Pending != "",
+		Required:          l.svcCtx.MFAMandated(l.id.User, l.id.Roles),
+		RecoveryCodesLeft: len(st.RecoveryCodes),
+	}, nil
+}
+
+type MFAEnrollLogic struct{ mfaSession }
+
+func NewMFAEnrollLogic(ctx context.Context, svcCtx *svc.ServiceContext, id *svc.Identity) *MFAEnrollLogic {
+	return &MFAEnrollLogic{newMFASession(ctx, svcCtx, id)}
+}
+
+// MFAEnroll starts enrollment with a new secret, replacing any pending one. It takes
+// effect once confirmed by MFAActivate.
+func (l *MFAEnrollLogic) MFAEnroll() (*types.MfaEnrollResponse, error) {
+	u, err := l.user()
+	if err != nil {
+		return nil, err
+	}
+	secret, uri, err := l.svcCtx.BeginMFAEnrollment(l.ctx, u)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+	return &types.MfaEnrollResponse{Secret: secret, OtpauthUri: uri}, nil
+}
+
+type MFAActivateLogic struct{ mfaSession }
+
+func NewMFAActivateLogic(ctx context.Context, svcCtx *svc.ServiceContext, id *svc.Identity) *MFAActivateLogic {
+	return &MFAActivateLogic{newMFASession(ctx, svcCtx, id)}
+}
+
+// MFAActivate confirms enrollment with a first code and returns the recovery codes,
+// which are shown only once, and a verified session.
+func (l *MFAActivateLogic) MFAActivate(req *types.MfaCodeRequest, ip string) (*types.MfaActivateResponse, error) {
+	if req == nil || strings.TrimSpace(req.Code) == "" {
+		return nil, ErrInvalidRequest
+	}
+	u, err := l.user()
+	if err != nil {
+		return nil, err
+	}
+	if !l.svcCtx.AllowLogin(ip, u.Username) {
+		return nil, ErrLoginRateLimit
+	}
+	codes, err := l.svcCtx.ConfirmMFAEnrollment(l.ctx, u.ID, strings.TrimSpace(req.Code))
+	if err != nil {
+		if errors.Is(err, svc.ErrMFAInvalidCode) {
+			l.svcCtx.Audit("mfa_failed", u.Username, "", map[string]string{"ip": ip, "stage": "enroll"})
+		}
+		return nil, mfaError(err)
+	}
+	l.svcCtx.Audit("mfa_enroll", u.Username, "", map[string]string{"ip": ip})
+	tok, refresh, err := l.verified(u, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &types.MfaActivateResponse{Token: tok, RefreshToken: refresh, RecoveryCodes: codes}, nil
+}
+
+type MFAVerifyLogic struct{ mfaSession }
+
+func NewMFAVerifyLogic(ctx context.Context, svcCtx *svc.ServiceContext, id *svc.Identity) *MFAVerifyLogic {
+	return &MFAVerifyLogic{newMFASession(ctx, svcCtx, id)}
+}
+
+// MFAVerify is the step-up check: a valid TOTP or recovery code returns a session
+// that satisfies functions requiring MFA for the step-up TTL.
+func (l *MFAVerifyLogic) MFAVerify(req *types.MfaCodeRequest, ip string) (*types.MfaVerifyResponse, error) {
+	if req == nil || strings.TrimSpace(req.Code) == "" {
+		return nil, ErrInvalidRequest
+	}
+	u, err := l.user()
+	if err != nil {
+		return nil, err
+	}
+	if !l.svcCtx.AllowLogin(ip, u.Username) {
+		return nil, ErrLoginRateLimit
+	}
+	method, err := l.svcCtx.VerifyMFA(l.ctx, u.ID, req.Code)
+	if err != nil {
+		if errors.Is(err, svc.ErrMFAInvalidCode) {
+			l.svcCtx.Audit("mfa_failed", u.Username, "", map[string]string{"ip": ip, "stage": "step_up"})
+		}
+		return nil, mfaError(err)
+	}
+	now := time.Now()
+	l.svcCtx.Audit("mfa_verify", u.Username, "", map[string]string{"ip": ip, "method": method})
+	tok, _, err := l.verified(u, now)
+	if err != nil {
+		return nil, err
+	}
+	return &types.MfaVerifyResponse{
+		Token:        tok,
+		MfaExpiresAt: now.Add(l.svcCtx.StepUpTTL()).UTC().Format(time.RFC3339),
+	}, nil
+}
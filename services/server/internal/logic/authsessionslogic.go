@@ -0,0 +1,196 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/session"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type AuthRefreshLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewAuthRefreshLogic(ctx context.Context, svcCtx *svc.ServiceContext) *AuthRefreshLogic {
+	return &AuthRefreshLogic{Logger: logx.WithContext(ctx), ctx: ctx, svcCtx: svcCtx}
+}
+
+// AuthRefresh exchanges a refresh token for a new access token and rotates the
+// refresh token. Presenting a rotated-out refresh token revokes the session.
+func (l *AuthRefreshLogic) AuthRefresh(req *types.AuthRefreshRequest, ip string) (*types.AuthRefreshResponse, error) {
+	repo := l.svcCtx.UserRepository()
+	if repo == nil || l.svcCtx.JWTManager() == nil {
+		return nil, ErrAuthDisabled
+	}
+	if req == nil || strings.TrimSpace(req.RefreshToken) == "" {
+		return nil, ErrInvalidRequest
+	}
+	sess, refresh, err := l.svcCtx.RefreshSession(l.ctx, strings.TrimSpace(req.RefreshToken))
+	switch {
+	case errors.Is(err, svc.ErrRefreshReused):
+		l.svcCtx.Audit("session_reuse", sess.Username, sess.Username, map[string]string{"ip": ip, "session_id": sess.ID})
+		return nil, ErrUnauthorized
+	case errors.Is(err, svc.ErrSessionInvalid):
+		return nil, ErrUnauthorized
+	case err != nil:
+		return nil, err
+	}
+	user, err := repo.GetUserByUsername(l.ctx, sess.Username)
+	if err != nil || !user.Active {
+		if err != nil && !errors.Is(err, svc.ErrUserNotFound) {
+			return nil, err
+		}
+		l.svcCtx.RevokeSession(l.ctx, sess.ID, "system")
+		return nil, ErrUnauthorized
+	}
+	roles, err := repo.ListUserRoles(l.ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	tok, ttl, err := l.svcCtx.IssueAccessToken(sess, roles, "")
+	if err != nil {
+		return nil, err
+	}
+	return &types.AuthRefreshResponse{Token: tok, RefreshToken: refresh, ExpiresIn: int64(ttl.Seconds())}, nil
+}
+
+// sessionAdmin carries what the session logics need; the caller is taken from the
+// context.
+type sessionAdmin struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func newSessionAdmin(ctx context.Context, svcCtx *svc.ServiceContext) sessionAdmin {
+	return sessionAdmin{Logger: logx.WithContext(ctx), ctx: ctx, svcCtx: svcCtx}
+}
+
+func (l sessionAdmin) revoke(sess *session.Session) error {
+	actor := svc.ActorFromContext(l.ctx)
+	if _, err := l.svcCtx.RevokeSession(l.ctx, sess.ID, actor); err != nil {
+		return err
+	}
+	l.svcCtx.Audit("session_revoke", actor, sess.Username, map[string]string{"session_id": sess.ID})
+	return nil
+}
+
+type AuthLogoutLogic struct{ sessionAdmin }
+
+func NewAuthLogoutLogic(ctx context.Context, svcCtx *svc.ServiceContext) *AuthLogoutLogic {
+	return &AuthLogoutLogic{newSessionAdmin(ctx, svcCtx)}
+}
+
+// AuthLogout revokes the session of the caller, so its refresh token and access
+// tokens stop working.
+func (l *AuthLogoutLogic) AuthLogout() error {
+	id := svc.SessionIDFromContext(l.ctx)
+	if id == "" {
+		return ErrUnauthorized
+	}
+	sess, err := l.svcCtx.ActiveSession(l.ctx, id, svc.ActorFromContext(l.ctx))
+	if err != nil {
+		if errors.Is(err, svc.ErrSessionInvalid) {
+			return ErrUnauthorized
+		}
+		return err
+	}
+	return l.revoke(sess)
+}
+
+type SessionsListLogic struct{ sessionAdmin }
+
+func NewSessionsListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SessionsListLogic {
+	return &SessionsListLogic{newSessionAdmin(ctx, svcCtx)}
+}
+
+// SessionsList lists active sessions, newest first, or all retained ones with
+// req.All. Callers with user:read see every user's sessions; others only their own.
+func (l *SessionsListLogic) SessionsList(req *types.SessionsListRequest) (*types.SessionsListResponse, error) {
+	actor, roles := svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx)
+	opts := session.ListOptions{Username: strings.TrimSpace(req.Username)}
+	switch {
+	case opts.Username != "" && opts.Username != actor:
+		if !l.svcCtx.EnforcePermission(actor, roles, userReadPermission) {
+			return nil, ErrForbidden
+		}
+	case opts.Username == "" && !l.svcCtx.HasPermission(actor, roles, userReadPermission):
+		opts.Username = actor
+	}
+	now := time.Now()
+	if !req.All {
+		opts.ActiveAt = now
+	}
+	list, err := l.svcCtx.Sessions().List(l.ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	current := svc.SessionIDFromContext(l.ctx)
+	out := make([]types.SessionInfo, 0, len(list))
+	for _, s := range list {
+		out = append(out, sessionInfo(s, now, current))
+	}
+	return &types.SessionsListResponse{Sessions: out}, nil
+}
+
+type SessionRevokeLogic struct{ sessionAdmin }
+
+func NewSessionRevokeLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SessionRevokeLogic {
+	return &SessionRevokeLogic{newSessionAdmin(ctx, svcCtx)}
+}
+
+// SessionRevoke revokes one session. Users may revoke their own sessions; revoking
+// another user's needs user:write.
+func (l *SessionRevokeLogic) SessionRevoke(req *types.SessionIdRequest) error {
+	if req == nil || strings.TrimSpace(req.Id) == "" {
+		return ErrInvalidRequest
+	}
+	actor := svc.ActorFromContext(l.ctx)
+	sess, err := l.svcCtx.Sessions().Get(l.ctx, strings.TrimSpace(req.Id))
+	if err != nil && !errors.Is(err, session.ErrNotFound) {
+		return err
+	}
+	if err == nil && sess.Username == actor {
+		return l.revoke(sess)
+	}
+	// Do not tell other users which session ids exist.
+	if !l.svcCtx.EnforcePermission(actor, svc.RolesFromContext(l.ctx), userWritePermission) {
+		return ErrForbidden
+	}
+	if err != nil {
+		return ErrNotFound
+	}
+	return l.revoke(sess)
+}
+
+func sessionInfo(s *session.Session, now time.Time, current string) types.SessionInfo {
+	info := types.SessionInfo{
+		Id:         s.ID,
+		Username:   s.Username,
+		Ip:         s.IP,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt.UTC().Format(time.RFC3339),
+		LastSeenAt: s.LastSeenAt.UTC().Format(time.RFC3339),
+		ExpiresAt:  s.ExpiresAt.UTC().Format(time.RFC3339),
+		RevokedBy:  s.RevokedBy,
+		Active:     s.Active(now),
+		Current:    s.ID == current,
+	}
+	if !s.MFAAt.IsZero() {
+		info.MfaAt = s.MFAAt.UTC().Format(time.RFC3339)
+	}
+	if !s.RevokedAt.IsZero() {
+		info.RevokedAt = s.RevokedAt.UTC().Format(time.RFC3339)
+	}
+	return info
+}
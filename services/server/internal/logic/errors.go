@@ -6,6 +6,13 @@ var (
 	ErrAgentNotFound   = errors.New("agent not found")
 	ErrRateRuleInvalid = errors.New("invalid rate limit rule")
 	ErrInvalidRequest  = errors.New("invalid request")
+	ErrForbidden       = errors.New("forbidden")
 	ErrNotFound        = errors.New("not found")
 	ErrUnavailable     = errors.New("service unavailable")
+	ErrConflict        = errors.New("conflict")
+	// ErrStepUpRequired asks the caller to verify a second factor and retry.
+	ErrStepUpRequired = errors.New("mfa step-up required")
+	// ErrRevisionGone is returned when a watch resumes from a revision the server
+	// no longer holds; the client lists again and watches from the new revision.
+	ErrRevisionGone = errors.New("revision no longer available")
 )
@@ -12,8 +12,6 @@ import (
 	"github.com/cuihairu/croupier/services/server/internal/types"
 
 	"github.com/zeromicro/go-zero/core/logx"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type FunctionInstancesLogic struct {
@@ -72,20 +70,21 @@ func (l *FunctionInstancesLogic) FunctionInstances(req *types.FunctionInstancesR
 	store.Mu().RUnlock()
 
 	for _, ag := range agents {
+		// agents behind an edge accept no inbound calls
+		if ag.rpcAddr == "" {
+			continue
+		}
 		if req.FunctionId != "" {
 			if _, ok := ag.functions[req.FunctionId]; !ok {
 				continue
 			}
 		}
-		dialCtx, cancel := context.WithTimeout(l.ctx, 5*time.Second)
-		conn, err := grpc.DialContext(dialCtx, ag.rpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-		cancel()
+		conn, err := l.svcCtx.AgentConn(l.ctx, ag.id, ag.rpcAddr)
 		if err != nil {
 			logx.WithContext(l.ctx).Errorf("dial agent %s: %v", ag.id, err)
 			continue
 		}
 		func() {
-			defer conn.Close()
 			client := localv1.NewLocalControlServiceClient(conn)
 			callCtx, cancel := context.WithTimeout(l.ctx, 5*time.Second)
 			defer cancel()
@@ -17,6 +17,20 @@ func strFromMap(m map[string]any, key string) string {
 	return ""
 }
 
+func boolFromMap(m map[string]any, key string) bool {
+	if m == nil {
+		return false
+	}
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := parseBoolFilter(v)
+		return b
+	}
+	return false
+}
+
 func formatTime(t time.Time) string {
 	if t.IsZero() {
 		return ""
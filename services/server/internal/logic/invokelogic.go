@@ -0,0 +1,461 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/function/descriptor"
+	"github.com/cuihairu/croupier/internal/jobs"
+	"github.com/cuihairu/croupier/internal/platform/idempotency"
+	"github.com/cuihairu/croupier/internal/security/rbac"
+	"github.com/cuihairu/croupier/internal/validation"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const (
+	defaultInvokePermission = "function:invoke"
+	// maintenanceOverridePermission lets a user run command-mode functions while a
+	// block_writes maintenance window is active.
+	maintenanceOverridePermission = "maintenance:override"
+	// jobReadPermission and jobCancelPermission cover the jobs of other users;
+	// everyone may read and cancel their own.
+	jobReadPermission   = "job:read"
+	jobCancelPermission = "job:cancel"
+)
+
+type InvokeLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewInvokeLogic(ctx context.Context, svcCtx *svc.ServiceContext) *InvokeLogic {
+	return &InvokeLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *InvokeLogic) Invoke(req *types.InvokeRequest) (resp *types.InvokeResponse, err error) {
+	desc, in, err := authorizeInvocation(l.ctx, l.svcCtx, req)
+	if err != nil {
+		return nil, err
+	}
+	var rec *idempotency.Record
+	var replayed bool
+	defer func() {
+		l.svcCtx.Audit("invoke", in.Actor, in.FunctionID, invokeAuditMeta(in, rec, replayed, err))
+	}()
+	if err = prepareInvocation(l.ctx, l.svcCtx, desc, in, req); err != nil {
+		return nil, err
+	}
+	asJob := desc != nil && strings.EqualFold(strFromMap(desc.Semantics, "returns"), "job")
+	policy, held := approvalPolicy(desc)
+	rec, replayed, err = l.svcCtx.Idempotent(l.ctx, *in, func() (*idempotency.Record, error) {
+		if held {
+			mode := svc.ApprovalModeInvoke
+			if asJob {
+				mode = svc.ApprovalModeStartJob
+			}
+			a, err := l.svcCtx.RequestApproval(*in, mode, policy)
+			if err != nil {
+				return nil, err
+			}
+			return &idempotency.Record{ApprovalID: a.ID}, nil
+		}
+		if asJob {
+			job, err := l.svcCtx.StartFunctionJob(l.ctx, *in)
+			if err != nil {
+				return nil, err
+			}
+			return &idempotency.Record{JobID: job.ID, TraceID: job.TraceID}, nil
+		}
+		res, err := l.svcCtx.InvokeFunction(l.ctx, *in)
+		if err != nil {
+			return nil, err
+		}
+		return &idempotency.Record{Payload: res.Payload, AgentID: res.AgentID, TraceID: res.TraceID}, nil
+	})
+	if err != nil {
+		l.Errorf("invoke %s: %v", in.FunctionID, err)
+		return nil, idempotencyError(err)
+	}
+	return &types.InvokeResponse{
+		Payload:    decodeInvokePayload(rec.Payload),
+		JobId:      rec.JobID,
+		AgentId:    rec.AgentID,
+		TraceId:    rec.TraceID,
+		ApprovalId: rec.ApprovalID,
+		Replayed:   replayed,
+	}, nil
+}
+
+type JobStartLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewJobStartLogic(ctx context.Context, svcCtx *svc.ServiceContext) *JobStartLogic {
+	return &JobStartLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *JobStartLogic) JobStart(req *types.InvokeRequest) (resp *types.JobStartResponse, err error) {
+	desc, in, err := authorizeInvocation(l.ctx, l.svcCtx, req)
+	if err != nil {
+		return nil, err
+	}
+	var rec *idempotency.Record
+	var replayed bool
+	defer func() {
+		l.svcCtx.Audit("start_job", in.Actor, in.FunctionID, invokeAuditMeta(in, rec, replayed, err))
+	}()
+	if err = prepareInvocation(l.ctx, l.svcCtx, desc, in, req); err != nil {
+		return nil, err
+	}
+	policy, held := approvalPolicy(desc)
+	rec, replayed, err = l.svcCtx.Idempotent(l.ctx, *in, func() (*idempotency.Record, error) {
+		if held {
+			a, err := l.svcCtx.RequestApproval(*in, svc.ApprovalModeStartJob, policy)
+			if err != nil {
+				return nil, err
+			}
+			return &idempotency.Record{ApprovalID: a.ID}, nil
+		}
+		job, err := l.svcCtx.StartFunctionJob(l.ctx, *in)
+		if err != nil {
+			return nil, err
+		}
+		return &idempotency.Record{JobID: job.ID, TraceID: job.TraceID}, nil
+	})
+	if err != nil {
+		l.Errorf("start job %s: %v", in.FunctionID, err)
+		return nil, idempotencyError(err)
+	}
+	if rec.JobID == "" && rec.ApprovalID == "" {
+		return nil, fmt.Errorf("%w: idempotency key was used for a synchronous call", ErrConflict)
+	}
+	return &types.JobStartResponse{JobId: rec.JobID, ApprovalId: rec.ApprovalID, Replayed: replayed}, nil
+}
+
+type JobCancelLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewJobCancelLogic(ctx context.Context, svcCtx *svc.ServiceContext) *JobCancelLogic {
+	return &JobCancelLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *JobCancelLogic) JobCancel(req *types.JobCancelRequest) (*types.JobResultResponse, error) {
+	if req == nil || strings.TrimSpace(req.JobId) == "" {
+		return nil, ErrInvalidRequest
+	}
+	job, ok := l.svcCtx.JobInfo(req.JobId)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !l.svcCtx.CanAccessJob(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), job, jobCancelPermission) {
+		return nil, ErrForbidden
+	}
+	job, err := l.svcCtx.CancelFunctionJob(l.ctx, job.ID)
+	if err != nil {
+		l.Errorf("cancel job %s: %v", req.JobId, err)
+		return nil, err
+	}
+	l.svcCtx.Audit("cancel_job", svc.ActorFromContext(l.ctx), job.FunctionID, map[string]string{
+		"game_id": job.GameID,
+		"env":     job.Env,
+		"job_id":  job.ID,
+	})
+	return jobResultFromInfo(job), nil
+}
+
+type JobResultLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewJobResultLogic(ctx context.Context, svcCtx *svc.ServiceContext) *JobResultLogic {
+	return &JobResultLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *JobResultLogic) JobResult(req *types.JobResultRequest) (*types.JobResultResponse, error) {
+	if req == nil || strings.TrimSpace(req.Id) == "" {
+		return nil, ErrInvalidRequest
+	}
+	job, ok := l.svcCtx.JobInfo(req.Id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !l.svcCtx.CanAccessJob(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), job, jobReadPermission) {
+		return nil, ErrForbidden
+	}
+	return jobResultFromInfo(job), nil
+}
+
+// authorizeInvocation resolves the function and checks that the caller may invoke
+// it in the requested game/env. Requests refused here are not audited; everything
+// after it is, with its outcome.
+func authorizeInvocation(ctx context.Context, svcCtx *svc.ServiceContext, req *types.InvokeRequest) (*descriptor.Descriptor, *svc.InvokeInput, error) {
+	if req == nil || strings.TrimSpace(req.FunctionId) == "" {
+		return nil, nil, ErrInvalidRequest
+	}
+	fid := strings.TrimSpace(req.FunctionId)
+	desc := svcCtx.FunctionDescriptor(fid)
+	if desc == nil && !svcCtx.FunctionRegistered(fid) {
+		return nil, nil, ErrNotFound
+	}
+	actor := svc.ActorFromContext(ctx)
+	gameID, env := strings.TrimSpace(req.GameId), strings.TrimSpace(req.Env)
+	if !svcCtx.EnforceScopedPermission(actor, svc.RolesFromContext(ctx), invokePermission(desc), gameID, env) {
+		return nil, nil, ErrForbidden
+	}
+	// Users limited to some games must name one; unscoped calls reach every game.
+	if !svcCtx.UserCanAccessGame(ctx, actor, gameID, env) {
+		return nil, nil, fmt.Errorf("%w: game %q is not granted", ErrForbidden, gameID)
+	}
+	return desc, &svc.InvokeInput{
+		FunctionID:      fid,
+		GameID:          gameID,
+		Env:             env,
+		Actor:           actor,
+		IdempotencyKey:  strings.TrimSpace(req.IdempotencyKey),
+		Route:           strings.TrimSpace(req.Route),
+		TargetServiceID: strings.TrimSpace(req.TargetServiceId),
+		HashKey:         strings.TrimSpace(req.HashKey),
+		Payload:         []byte("{}"),
+	}, nil
+}
+
+// prepareInvocation checks step-up and maintenance for an authorized call and
+// validates the payload against the descriptor params schema.
+func prepareInvocation(ctx context.Context, svcCtx *svc.ServiceContext, desc *descriptor.Descriptor, in *svc.InvokeInput, req *types.InvokeRequest) error {
+	if err := checkStepUp(ctx, svcCtx, desc); err != nil {
+		return err
+	}
+	if req.Payload != nil {
+		b, err := json.Marshal(req.Payload)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		in.Payload = b
+	}
+	if desc != nil {
+		if len(desc.Params) > 0 {
+			if err := validation.ValidateJSON(desc.Params, in.Payload); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+			}
+		}
+		if boolFromMap(desc.Semantics, "idempotency_key") && in.IdempotencyKey == "" {
+			return fmt.Errorf("%w: idempotency_key required for %s", ErrInvalidRequest, in.FunctionID)
+		}
+		if in.Route == "" {
+			in.Route = strFromMap(desc.Semantics, "route")
+		}
+		in.Timeout = invokeTimeout(desc)
+	}
+	return checkMaintenance(ctx, svcCtx, desc, in)
+}
+
+// checkMaintenance rejects command-mode calls into a game/env under a block_writes
+// maintenance window. Query-mode functions are not affected; users holding
+// maintenance:override may proceed and are audited.
+func checkMaintenance(ctx context.Context, svcCtx *svc.ServiceContext, desc *descriptor.Descriptor, in *svc.InvokeInput) error {
+	if desc != nil && strings.EqualFold(strFromMap(desc.Semantics, "mode"), "query") {
+		return nil
+	}
+	w := svcCtx.WriteBlockingMaintenance(in.GameID, in.Env, time.Now())
+	if w == nil {
+		return nil
+	}
+	// Lacking the override is the normal case here, not a denial worth counting.
+	if !svcCtx.HasPermission(svc.ActorFromContext(ctx), svc.RolesFromContext(ctx), rbac.Scoped(maintenanceOverridePermission, in.GameID, in.Env)) {
+		return &svc.MaintenanceError{Window: *w}
+	}
+	svcCtx.Audit("maintenance_override", svc.ActorFromContext(ctx), in.FunctionID, map[string]string{
+		"game_id":   in.GameID,
+		"env":       in.Env,
+		"window_id": w.ID,
+	})
+	return nil
+}
+
+// checkStepUp rejects calls of functions whose risk policy requires MFA unless the
+// caller verified a second factor within the step-up TTL.
+func checkStepUp(ctx context.Context, svcCtx *svc.ServiceContext, desc *descriptor.Descriptor) error {
+	if !requiresMFA(desc) || svcCtx.MFARecent(svc.MFAAtFromContext(ctx)) {
+		return nil
+	}
+	return ErrStepUpRequired
+}
+
+// requiresMFA reads the descriptor risk policy, "auth": {"risk": {"requires_mfa":
+// true}}; critical risk always requires MFA.
+func requiresMFA(desc *descriptor.Descriptor) bool {
+	if desc == nil {
+		return false
+	}
+	if boolFromMap(desc.Auth, "requires_mfa") {
+		return true
+	}
+	risk, ok := desc.Auth["risk"].(map[string]any)
+	if !ok {
+		return false
+	}
+	return boolFromMap(risk, "requires_mfa") || strings.EqualFold(strFromMap(risk, "level"), "critical")
+}
+
+// invokeTimeout returns the descriptor semantics timeout, or zero for the default.
+func invokeTimeout(desc *descriptor.Descriptor) time.Duration {
+	if desc == nil {
+		return 0
+	}
+	if d, err := time.ParseDuration(strFromMap(desc.Semantics, "timeout")); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// invokeAuditMeta describes an invocation and its outcome for the audit log; rec is
+// nil when the call failed before it was dispatched.
+func invokeAuditMeta(in *svc.InvokeInput, rec *idempotency.Record, replayed bool, err error) map[string]string {
+	meta := map[string]string{"game_id": in.GameID, "env": in.Env, "outcome": "ok"}
+	if err != nil {
+		meta["outcome"] = "error"
+		meta["error"] = err.Error()
+	}
+	if in.IdempotencyKey != "" {
+		meta["idempotency_key"] = in.IdempotencyKey
+	}
+	if rec == nil {
+		return meta
+	}
+	meta["trace_id"] = rec.TraceID
+	if rec.JobID != "" {
+		meta["job_id"] = rec.JobID
+	}
+	if rec.ApprovalID != "" {
+		meta["approval_id"] = rec.ApprovalID
+	}
+	if replayed {
+		meta["replayed"] = "true"
+	}
+	return meta
+}
+
+// idempotencyError maps duplicate-request errors to ErrConflict.
+func idempotencyError(err error) error {
+	if errors.Is(err, idempotency.ErrInProgress) || errors.Is(err, idempotency.ErrMismatch) || errors.Is(err, idempotency.ErrOutcomeUnknown) {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	return err
+}
+
+func invokePermission(desc *descriptor.Descriptor) string {
+	if desc != nil {
+		if perm := strings.TrimSpace(strFromMap(desc.Auth, "permission")); perm != "" {
+			return perm
+		}
+	}
+	return defaultInvokePermission
+}
+
+func decodeInvokePayload(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err == nil {
+		return v
+	}
+	return string(b)
+}
+
+func jobResultFromInfo(job *svc.JobInfo) *types.JobResultResponse {
+	return &types.JobResultResponse{
+		Id:       job.ID,
+		State:    job.State,
+		Progress: job.Progress,
+		Payload:  decodeInvokePayload(job.Result),
+		Error:    job.Error,
+	}
+}
+
+type StreamJobLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewStreamJobLogic(ctx context.Context, svcCtx *svc.ServiceContext) *StreamJobLogic {
+	return &StreamJobLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// StreamJob replays the job event log after req.After and follows new events until
+// the job finishes or the request context ends.
+func (l *StreamJobLogic) StreamJob(req *types.JobStreamRequest) (<-chan types.JobStreamEvent, error) {
+	if req == nil || strings.TrimSpace(req.Id) == "" {
+		return nil, ErrInvalidRequest
+	}
+	job, ok := l.svcCtx.JobInfo(strings.TrimSpace(req.Id))
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !l.svcCtx.CanAccessJob(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), job, jobReadPermission) {
+		return nil, ErrForbidden
+	}
+	events, err := l.svcCtx.JobEngine().Subscribe(l.ctx, job.ID, req.After)
+	if err != nil {
+		if errors.Is(err, jobs.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	out := make(chan types.JobStreamEvent)
+	go func() {
+		defer close(out)
+		for ev := range events {
+			item := types.JobStreamEvent{
+				Seq:      ev.Seq,
+				Type:     ev.Type,
+				Message:  ev.Message,
+				Progress: ev.Progress,
+				Payload:  decodeInvokePayload(ev.Payload),
+				Time:     ev.Time.Format(time.RFC3339Nano),
+			}
+			select {
+			case out <- item:
+			case <-l.ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,100 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/function/descriptor"
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+)
+
+func TestCheckMaintenanceOverride(t *testing.T) {
+	ctx := context.Background()
+	svcCtx := &svc.ServiceContext{}
+	now := time.Now()
+	if err := svcCtx.UpdateMaintenance([]svc.MaintenanceWindow{
+		{ID: "w1", GameID: "g1", Start: now.Add(-time.Hour), End: now.Add(time.Hour), BlockWrites: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svcCtx.UserRepository().CreateRole(ctx, &svc.RoleRecord{Name: "ops", Perms: []string{"maintenance:override@g1/prod"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svcCtx.RefreshRolePolicy(ctx); err != nil {
+		t.Fatal(err)
+	}
+	command := &descriptor.Descriptor{ID: "player.ban", Semantics: map[string]any{"mode": "command"}}
+	query := &descriptor.Descriptor{ID: "player.get", Semantics: map[string]any{"mode": "query"}}
+	in := &svc.InvokeInput{FunctionID: "player.ban", GameID: "g1", Env: "prod"}
+	gm := svc.WithRoles(svc.WithActor(ctx, "bob"), []string{"gm"})
+	ops := svc.WithRoles(svc.WithActor(ctx, "alice"), []string{"ops"})
+
+	if err := checkMaintenance(gm, svcCtx, query, in); err != nil {
+		t.Fatalf("query during maintenance: %v", err)
+	}
+	if err := checkMaintenance(gm, svcCtx, command, in); !errors.Is(err, svc.ErrMaintenance) {
+		t.Fatalf("command without override: %v, want ErrMaintenance", err)
+	}
+	if err := checkMaintenance(ops, svcCtx, command, in); err != nil {
+		t.Fatalf("command with override: %v", err)
+	}
+	// The override is scoped to prod.
+	if err := checkMaintenance(ops, svcCtx, command, &svc.InvokeInput{FunctionID: "player.ban", GameID: "g1", Env: "dev"}); !errors.Is(err, svc.ErrMaintenance) {
+		t.Fatalf("override outside its scope: %v, want ErrMaintenance", err)
+	}
+	m := svcCtx.MetricsSnapshot()
+	if m.RbacDenied != 0 {
+		t.Fatalf("maintenance checks counted %d RBAC denials", m.RbacDenied)
+	}
+	// Without an audit log the override's audit record shows up as a failure.
+	if m.AuditErrors != 1 {
+		t.Fatalf("override audited %d times, want 1", m.AuditErrors)
+	}
+}
+
+func TestInvokeAuditsFailures(t *testing.T) {
+	ctx := context.Background()
+	svcCtx := &svc.ServiceContext{RegistryStore: registry.NewStore()}
+	// The agent is unreachable, so every authorized call fails at dispatch.
+	if err := svcCtx.RegistryStore.UpsertAgent(&registry.AgentSession{
+		AgentID: "a1", GameID: "g1", RPCAddr: "127.0.0.1:1",
+		Functions: map[string]registry.FunctionMeta{"player.ban": {Enabled: true}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svcCtx.Config.Server.AgentInsecure = true
+	if err := svcCtx.UserRepository().CreateRole(ctx, &svc.RoleRecord{Name: "gm", Perms: []string{"function:invoke"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svcCtx.RefreshRolePolicy(ctx); err != nil {
+		t.Fatal(err)
+	}
+	req := &types.InvokeRequest{FunctionId: "player.ban", GameId: "g1"}
+	audits := func() int64 {
+		// Without an audit log every audit record shows up as a failure.
+		return svcCtx.MetricsSnapshot().AuditErrors
+	}
+
+	guest := svc.WithActor(ctx, "eve")
+	if _, err := NewInvokeLogic(guest, svcCtx).Invoke(req); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("invoke without permission: %v, want ErrForbidden", err)
+	}
+	if n := audits(); n != 0 {
+		t.Fatalf("unauthorized call audited %d times", n)
+	}
+
+	gm := svc.WithRoles(svc.WithActor(ctx, "bob"), []string{"gm"})
+	if _, err := NewInvokeLogic(gm, svcCtx).Invoke(req); err == nil {
+		t.Fatal("invoke reached an unreachable agent")
+	}
+	if _, err := NewJobStartLogic(gm, svcCtx).JobStart(req); err == nil {
+		t.Fatal("job started on an unreachable agent")
+	}
+	if n := audits(); n != 2 {
+		t.Fatalf("failed calls audited %d times, want 2", n)
+	}
+}
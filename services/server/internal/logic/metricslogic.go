@@ -55,5 +55,9 @@ func (l *MetricsLogic) Metrics() (string, error) {
 	fmt.Fprintf(&b, "# TYPE croupier_audit_errors_total counter\n")
 	fmt.Fprintf(&b, "croupier_audit_errors_total %d\n", snap.AuditErrors)
 
+	fmt.Fprintf(&b, "# HELP croupier_rate_limited_total Total number of invocations rejected by rate limits\n")
+	fmt.Fprintf(&b, "# TYPE croupier_rate_limited_total counter\n")
+	fmt.Fprintf(&b, "croupier_rate_limited_total %d\n", snap.RateLimited)
+
 	return b.String(), nil
 }
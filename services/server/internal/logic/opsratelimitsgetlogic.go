@@ -35,5 +35,16 @@ func (l *OpsRateLimitsGetLogic) OpsRateLimitsGet() (*types.RateLimitRulesRespons
 			Percent:  r.Percent,
 		})
 	}
-	return &types.RateLimitRulesResponse{Rules: out}, nil
+	stats := l.svcCtx.RateLimitStats()
+	counters := make([]types.RateLimitCounter, 0, len(stats))
+	for _, st := range stats {
+		counters = append(counters, types.RateLimitCounter{
+			Scope:    st.Scope,
+			Key:      st.Key,
+			Allowed:  st.Allowed,
+			Rejected: st.Rejected,
+			Qps:      st.QPS,
+		})
+	}
+	return &types.RateLimitRulesResponse{Rules: out, Counters: counters}, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 
+	"github.com/cuihairu/croupier/internal/platform/ratelimit"
 	"github.com/cuihairu/croupier/services/server/internal/svc"
 	"github.com/cuihairu/croupier/services/server/internal/types"
 
@@ -35,6 +36,11 @@ func (l *OpsRateLimitsUpdateLogic) OpsRateLimitsUpdate(req *types.RateLimitRules
 		if scope == "" || key == "" || r.LimitQPS <= 0 {
 			continue
 		}
+		switch scope {
+		case ratelimit.ScopeFunction, ratelimit.ScopeService, ratelimit.ScopeGame, ratelimit.ScopeActor:
+		default:
+			return nil, ErrRateRuleInvalid
+		}
 		percent := r.Percent
 		if percent <= 0 {
 			percent = 100
@@ -47,13 +47,15 @@ func (l *ProvidersCapabilitiesLogic) ProvidersCapabilities(req *types.ProvidersC
 	if store == nil {
 		return nil, errors.New("registry unavailable")
 	}
-	store.UpsertProviderCaps(registry.ProviderCaps{
+	if err := store.UpsertProviderCaps(registry.ProviderCaps{
 		ID:       req.Provider.Id,
 		Version:  req.Provider.Version,
 		Lang:     req.Provider.Lang,
 		SDK:      req.Provider.Sdk,
 		Manifest: append([]byte(nil), req.Manifest...),
-	})
+	}); err != nil {
+		return nil, fmt.Errorf("store provider capabilities: %w", err)
+	}
 	l.svcCtx.MergeProviderFunctions(req.Manifest)
 	return &types.ProvidersCapabilitiesResponse{Ok: true}, nil
 }
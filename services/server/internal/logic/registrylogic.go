@@ -49,6 +49,8 @@ func (l *RegistryLogic) Registry() (resp *types.RegistryResponse, err error) {
 	fnCountAll := map[string]map[string]int64{}
 	fnCountHealthy := map[string]map[string]int64{}
 
+	// Taken before the snapshot, so watching from it repeats rather than misses changes.
+	revision := regStore.Revision()
 	now := time.Now()
 	regStore.Mu().RLock()
 	for _, agent := range regStore.AgentsUnsafe() {
@@ -71,6 +73,11 @@ func (l *RegistryLogic) Registry() (resp *types.RegistryResponse, err error) {
 			Functions:    int64(len(agent.Functions)),
 			Healthy:      healthy,
 			ExpiresInSec: expiresIn,
+			Region:       agent.Region,
+			Zone:         agent.Zone,
+			Labels:       agent.Labels,
+			RegisteredAt: formatTime(agent.RegisteredAt),
+			LastSeen:     formatTime(agent.LastSeen),
 		})
 		if len(agent.Functions) == 0 {
 			continue
@@ -130,6 +137,7 @@ func (l *RegistryLogic) Registry() (resp *types.RegistryResponse, err error) {
 		Functions:   functions,
 		Assignments: assignments,
 		Coverage:    coverage,
+		Revision:    revision,
 	}, nil
 }
 
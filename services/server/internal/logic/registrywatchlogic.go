@@ -0,0 +1,78 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const registryReadPermission = "registry:read"
+
+type RegistryWatchLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRegistryWatchLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RegistryWatchLogic {
+	return &RegistryWatchLogic{Logger: logx.WithContext(ctx), ctx: ctx, svcCtx: svcCtx}
+}
+
+// RegistryWatch streams registry and function catalog changes after req.After,
+// or after the current revision when it is 0. Types narrows the stream to a
+// comma-separated list of event types.
+func (l *RegistryWatchLogic) RegistryWatch(req *types.RegistryWatchRequest) (<-chan types.RegistryWatchEvent, error) {
+	if !l.svcCtx.EnforcePermission(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), registryReadPermission) {
+		return nil, ErrForbidden
+	}
+	store := l.svcCtx.RegistryStore
+	if store == nil {
+		return nil, fmt.Errorf("%w: registry unavailable", ErrUnavailable)
+	}
+	want := map[string]bool{}
+	for _, t := range strings.Split(req.Types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			want[t] = true
+		}
+	}
+	events, err := store.Watch(l.ctx, req.After)
+	if errors.Is(err, registry.ErrCompacted) {
+		return nil, ErrRevisionGone
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan types.RegistryWatchEvent)
+	go func() {
+		defer close(out)
+		for ev := range events {
+			if len(want) > 0 && !want[string(ev.Type)] {
+				continue
+			}
+			item := types.RegistryWatchEvent{
+				Revision:   ev.Revision,
+				Type:       string(ev.Type),
+				Time:       ev.At.UTC().Format(time.RFC3339Nano),
+				AgentId:    ev.AgentID,
+				GameId:     ev.GameID,
+				Env:        ev.Env,
+				ProviderId: ev.ProviderID,
+				FunctionId: ev.FunctionID,
+			}
+			select {
+			case out <- item:
+			case <-l.ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
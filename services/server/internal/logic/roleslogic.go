@@ -0,0 +1,182 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+)
+
+const (
+	rolesReadPermission   = "roles:read"
+	rolesManagePermission = "roles:manage"
+)
+
+// roleError maps repository errors to logic errors.
+func roleError(err error) error {
+	switch {
+	case errors.Is(err, svc.ErrRoleNotFound):
+		return fmt.Errorf("%w: role", ErrNotFound)
+	case errors.Is(err, svc.ErrRoleExists):
+		return fmt.Errorf("%w: role name already taken", ErrConflict)
+	}
+	return err
+}
+
+// roleChanged audits a role change and reloads the role grants used by the authorizer.
+func (l userAdmin) roleChanged(kind string, role *svc.RoleRecord, meta map[string]string) {
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	meta["role_id"] = strconv.FormatUint(uint64(role.ID), 10)
+	l.svcCtx.Audit(kind, svc.ActorFromContext(l.ctx), role.Name, meta)
+	if err := l.svcCtx.RefreshRolePolicy(l.ctx); err != nil {
+		l.Errorf("refresh role permissions: %v", err)
+	}
+}
+
+func roleInfo(r *svc.RoleRecord) types.RoleInfo {
+	return types.RoleInfo{
+		Id:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Perms:       append([]string{}, r.Perms...),
+	}
+}
+
+type RolesListLogic struct{ userAdmin }
+
+func NewRolesListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RolesListLogic {
+	return &RolesListLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+func (l *RolesListLogic) RolesList() (*types.RolesListResponse, error) {
+	repo, err := l.repo(rolesReadPermission)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := repo.ListRoles(l.ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]types.RoleInfo, 0, len(roles))
+	for _, r := range roles {
+		out = append(out, roleInfo(r))
+	}
+	return &types.RolesListResponse{Roles: out}, nil
+}
+
+type RoleCreateLogic struct{ userAdmin }
+
+func NewRoleCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RoleCreateLogic {
+	return &RoleCreateLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+func (l *RoleCreateLogic) RoleCreate(req *types.RoleCreateRequest) (*types.RoleCreateResponse, error) {
+	repo, err := l.repo(rolesManagePermission)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || strings.TrimSpace(req.Name) == "" {
+		return nil, ErrInvalidRequest
+	}
+	role := &svc.RoleRecord{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Perms:       req.Perms,
+	}
+	if err := repo.CreateRole(l.ctx, role); err != nil {
+		return nil, roleError(err)
+	}
+	l.roleChanged("role_create", role, map[string]string{"perms": strings.Join(role.Perms, ",")})
+	return &types.RoleCreateResponse{Id: role.ID}, nil
+}
+
+type RoleUpdateLogic struct{ userAdmin }
+
+func NewRoleUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RoleUpdateLogic {
+	return &RoleUpdateLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+// RoleUpdate renames a role or edits its description. Tokens carry role names, so
+// members keep the old name until they sign in again.
+func (l *RoleUpdateLogic) RoleUpdate(req *types.RoleUpdateRequest) error {
+	repo, err := l.repo(rolesManagePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 {
+		return ErrInvalidRequest
+	}
+	role, err := repo.GetRole(l.ctx, req.Id)
+	if err != nil {
+		return roleError(err)
+	}
+	meta := map[string]string{}
+	if name := strings.TrimSpace(req.Name); name != "" && name != role.Name {
+		meta["old_name"] = role.Name
+		role.Name = name
+	}
+	role.Description = strings.TrimSpace(req.Description)
+	if err := repo.UpdateRole(l.ctx, role); err != nil {
+		return roleError(err)
+	}
+	l.roleChanged("role_update", role, meta)
+	return nil
+}
+
+type RoleDeleteLogic struct{ userAdmin }
+
+func NewRoleDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RoleDeleteLogic {
+	return &RoleDeleteLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+func (l *RoleDeleteLogic) RoleDelete(req *types.RoleIdRequest) error {
+	repo, err := l.repo(rolesManagePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 {
+		return ErrInvalidRequest
+	}
+	role, err := repo.GetRole(l.ctx, req.Id)
+	if err != nil {
+		return roleError(err)
+	}
+	if err := repo.DeleteRole(l.ctx, role.ID); err != nil {
+		return roleError(err)
+	}
+	l.roleChanged("role_delete", role, nil)
+	return nil
+}
+
+type RolePermissionsUpdateLogic struct{ userAdmin }
+
+func NewRolePermissionsUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RolePermissionsUpdateLogic {
+	return &RolePermissionsUpdateLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+// RolePermissionsUpdate replaces the permissions of a role; they take effect on the
+// next request of every member.
+func (l *RolePermissionsUpdateLogic) RolePermissionsUpdate(req *types.RolePermsUpdateRequest) error {
+	repo, err := l.repo(rolesManagePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 {
+		return ErrInvalidRequest
+	}
+	role, err := repo.GetRole(l.ctx, req.Id)
+	if err != nil {
+		return roleError(err)
+	}
+	if err := repo.SetRolePerms(l.ctx, role.ID, req.Perms); err != nil {
+		return roleError(err)
+	}
+	l.roleChanged("role_set_perms", role, map[string]string{"perms": strings.Join(req.Perms, ",")})
+	return nil
+}
@@ -0,0 +1,404 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/apitoken"
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+var serviceAccountName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// serviceAccountAdmin carries what every service account and API token logic needs.
+type serviceAccountAdmin struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func newServiceAccountAdmin(ctx context.Context, svcCtx *svc.ServiceContext) serviceAccountAdmin {
+	return serviceAccountAdmin{Logger: logx.WithContext(ctx), ctx: ctx, svcCtx: svcCtx}
+}
+
+// store checks perm for the caller and returns the API token store.
+func (l serviceAccountAdmin) store(perm string) (apitoken.Store, error) {
+	if !l.svcCtx.EnforcePermission(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), perm) {
+		return nil, ErrForbidden
+	}
+	st := l.svcCtx.APITokens()
+	if st == nil {
+		return nil, ErrUnavailable
+	}
+	return st, nil
+}
+
+// mayGrant requires the caller to hold every grant it hands to automation, so
+// user:write alone cannot mint tokens more powerful than its holder.
+func (l serviceAccountAdmin) mayGrant(grants []string) error {
+	actor, roles := svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx)
+	for _, g := range grants {
+		if !l.svcCtx.HasPermission(actor, roles, g) {
+			return fmt.Errorf("%w: you do not hold %s", ErrForbidden, g)
+		}
+	}
+	return nil
+}
+
+func (l serviceAccountAdmin) audit(kind, account string, meta map[string]string) {
+	l.svcCtx.Audit(kind, svc.ActorFromContext(l.ctx), svc.ServiceAccountPrefix+account, meta)
+}
+
+// apiTokenError maps store errors to logic errors.
+func apiTokenError(err error) error {
+	switch {
+	case errors.Is(err, apitoken.ErrNotFound):
+		return fmt.Errorf("%w: service account or token", ErrNotFound)
+	case errors.Is(err, apitoken.ErrExists):
+		return fmt.Errorf("%w: service account already exists", ErrConflict)
+	case errors.Is(err, apitoken.ErrNotWithin):
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return err
+}
+
+type ServiceAccountsListLogic struct{ serviceAccountAdmin }
+
+func NewServiceAccountsListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ServiceAccountsListLogic {
+	return &ServiceAccountsListLogic{newServiceAccountAdmin(ctx, svcCtx)}
+}
+
+func (l *ServiceAccountsListLogic) ServiceAccountsList() (*types.ServiceAccountsListResponse, error) {
+	st, err := l.store(userReadPermission)
+	if err != nil {
+		return nil, err
+	}
+	list, err := st.ListAccounts(l.ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]types.ServiceAccountInfo, 0, len(list))
+	for _, a := range list {
+		out = append(out, serviceAccountInfo(a))
+	}
+	return &types.ServiceAccountsListResponse{Accounts: out}, nil
+}
+
+type ServiceAccountCreateLogic struct{ serviceAccountAdmin }
+
+func NewServiceAccountCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ServiceAccountCreateLogic {
+	return &ServiceAccountCreateLogic{newServiceAccountAdmin(ctx, svcCtx)}
+}
+
+// ServiceAccountCreate creates a service account holding perms within scopes; the
+// caller must hold them itself.
+func (l *ServiceAccountCreateLogic) ServiceAccountCreate(req *types.ServiceAccountCreateRequest) (*types.ServiceAccountInfo, error) {
+	st, err := l.store(userWritePermission)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || !serviceAccountName.MatchString(strings.TrimSpace(req.Name)) {
+		return nil, fmt.Errorf("%w: name must be lowercase letters, digits, '.', '_' or '-'", ErrInvalidRequest)
+	}
+	perms, err := apitoken.NormalizePerms(req.Perms)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if len(perms) == 0 {
+		return nil, fmt.Errorf("%w: perms required", ErrInvalidRequest)
+	}
+	scopes, err := apitoken.NormalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	now := time.Now()
+	acct := &apitoken.Account{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Perms:       perms,
+		Scopes:      scopes,
+		CreatedBy:   svc.ActorFromContext(l.ctx),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := l.mayGrant(apitoken.Grants(acct, &apitoken.Token{})); err != nil {
+		return nil, err
+	}
+	if err := st.CreateAccount(l.ctx, acct); err != nil {
+		return nil, apiTokenError(err)
+	}
+	l.audit("service_account_create", acct.Name, map[string]string{
+		"perms":  strings.Join(acct.Perms, ","),
+		"scopes": strings.Join(acct.Scopes, ","),
+	})
+	info := serviceAccountInfo(acct)
+	return &info, nil
+}
+
+type ServiceAccountUpdateLogic struct{ serviceAccountAdmin }
+
+func NewServiceAccountUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ServiceAccountUpdateLogic {
+	return &ServiceAccountUpdateLogic{newServiceAccountAdmin(ctx, svcCtx)}
+}
+
+// ServiceAccountUpdate changes the description, grants or state of an account.
+// Tokens follow at once: narrowing the account narrows them and disabling it stops
+// them.
+func (l *ServiceAccountUpdateLogic) ServiceAccountUpdate(req *types.ServiceAccountUpdateRequest) error {
+	st, err := l.store(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || strings.TrimSpace(req.Name) == "" {
+		return ErrInvalidRequest
+	}
+	acct, err := st.GetAccount(l.ctx, strings.TrimSpace(req.Name))
+	if err != nil {
+		return apiTokenError(err)
+	}
+	if req.Description != nil {
+		acct.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Perms != nil {
+		if acct.Perms, err = apitoken.NormalizePerms(req.Perms); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		if len(acct.Perms) == 0 {
+			return fmt.Errorf("%w: perms required", ErrInvalidRequest)
+		}
+	}
+	if req.Scopes != nil {
+		if acct.Scopes, err = apitoken.NormalizeScopes(req.Scopes); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+	if req.Disabled != nil {
+		acct.Disabled = *req.Disabled
+	}
+	if req.Perms != nil || req.Scopes != nil {
+		if err := l.mayGrant(apitoken.Grants(acct, &apitoken.Token{})); err != nil {
+			return err
+		}
+	}
+	acct.UpdatedAt = time.Now()
+	if err := st.UpdateAccount(l.ctx, acct); err != nil {
+		return apiTokenError(err)
+	}
+	l.audit("service_account_update", acct.Name, map[string]string{
+		"perms":    strings.Join(acct.Perms, ","),
+		"scopes":   strings.Join(acct.Scopes, ","),
+		"disabled": fmt.Sprint(acct.Disabled),
+	})
+	return nil
+}
+
+type ServiceAccountDeleteLogic struct{ serviceAccountAdmin }
+
+func NewServiceAccountDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ServiceAccountDeleteLogic {
+	return &ServiceAccountDeleteLogic{newServiceAccountAdmin(ctx, svcCtx)}
+}
+
+// ServiceAccountDelete deletes an account with all of its tokens.
+func (l *ServiceAccountDeleteLogic) ServiceAccountDelete(req *types.ServiceAccountNameRequest) error {
+	st, err := l.store(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || strings.TrimSpace(req.Name) == "" {
+		return ErrInvalidRequest
+	}
+	name := strings.TrimSpace(req.Name)
+	tokens, err := st.ListTokens(l.ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := st.DeleteAccount(l.ctx, name); err != nil {
+		return apiTokenError(err)
+	}
+	for _, t := range tokens {
+		l.svcCtx.ForgetAPIToken(t.ID)
+	}
+	l.audit("service_account_delete", name, map[string]string{"tokens": fmt.Sprint(len(tokens))})
+	return nil
+}
+
+type ApiTokensListLogic struct{ serviceAccountAdmin }
+
+func NewApiTokensListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ApiTokensListLogic {
+	return &ApiTokensListLogic{newServiceAccountAdmin(ctx, svcCtx)}
+}
+
+// ApiTokensList lists the tokens of an account, newest first, without secrets.
+func (l *ApiTokensListLogic) ApiTokensList(req *types.ServiceAccountNameRequest) (*types.ApiTokensListResponse, error) {
+	st, err := l.store(userReadPermission)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || strings.TrimSpace(req.Name) == "" {
+		return nil, ErrInvalidRequest
+	}
+	if _, err := st.GetAccount(l.ctx, strings.TrimSpace(req.Name)); err != nil {
+		return nil, apiTokenError(err)
+	}
+	list, err := st.ListTokens(l.ctx, strings.TrimSpace(req.Name))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	out := make([]types.ApiTokenInfo, 0, len(list))
+	for _, t := range list {
+		out = append(out, apiTokenInfo(t, now))
+	}
+	return &types.ApiTokensListResponse{Tokens: out}, nil
+}
+
+type ApiTokenCreateLogic struct{ serviceAccountAdmin }
+
+func NewApiTokenCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ApiTokenCreateLogic {
+	return &ApiTokenCreateLogic{newServiceAccountAdmin(ctx, svcCtx)}
+}
+
+// ApiTokenCreate issues a token for an account. The token is only returned here;
+// the server keeps its hash. The token may narrow the account grants but never
+// widen them, and the caller must hold what the token grants.
+func (l *ApiTokenCreateLogic) ApiTokenCreate(req *types.ApiTokenCreateRequest) (*types.ApiTokenCreateResponse, error) {
+	st, err := l.store(userWritePermission)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || strings.TrimSpace(req.Account) == "" || strings.TrimSpace(req.Name) == "" {
+		return nil, ErrInvalidRequest
+	}
+	acct, err := st.GetAccount(l.ctx, strings.TrimSpace(req.Account))
+	if err != nil {
+		return nil, apiTokenError(err)
+	}
+	now := time.Now()
+	plain, id, hash := apitoken.NewToken()
+	tok := &apitoken.Token{
+		ID:        id,
+		Account:   acct.Name,
+		Name:      strings.TrimSpace(req.Name),
+		Hash:      hash,
+		CreatedBy: svc.ActorFromContext(l.ctx),
+		CreatedAt: now,
+	}
+	if tok.Perms, err = apitoken.NormalizePerms(req.Perms); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if tok.Scopes, err = apitoken.NormalizeScopes(req.Scopes); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if tok.AllowedIPs, err = apitoken.NormalizeIPs(req.AllowedIps); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if v := strings.TrimSpace(req.ExpiresIn); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: expires_in must be a positive duration such as 720h", ErrInvalidRequest)
+		}
+		tok.ExpiresAt = now.Add(d)
+	}
+	if err := apitoken.Within(acct, tok.Perms, tok.Scopes); err != nil {
+		return nil, apiTokenError(err)
+	}
+	if err := l.mayGrant(apitoken.Grants(acct, tok)); err != nil {
+		return nil, err
+	}
+	if err := st.CreateToken(l.ctx, tok); err != nil {
+		return nil, apiTokenError(err)
+	}
+	meta := map[string]string{"token_id": tok.ID, "name": tok.Name}
+	if !tok.ExpiresAt.IsZero() {
+		meta["expires_at"] = tok.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	l.audit("api_token_create", acct.Name, meta)
+	return &types.ApiTokenCreateResponse{Token: plain, Info: apiTokenInfo(tok, now)}, nil
+}
+
+type ApiTokenRevokeLogic struct{ serviceAccountAdmin }
+
+func NewApiTokenRevokeLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ApiTokenRevokeLogic {
+	return &ApiTokenRevokeLogic{newServiceAccountAdmin(ctx, svcCtx)}
+}
+
+// ApiTokenRevoke revokes a token; it stays listed with who revoked it.
+func (l *ApiTokenRevokeLogic) ApiTokenRevoke(req *types.ApiTokenIdRequest) error {
+	st, err := l.store(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || strings.TrimSpace(req.Id) == "" {
+		return ErrInvalidRequest
+	}
+	tok, err := st.GetToken(l.ctx, strings.TrimSpace(req.Id))
+	if err != nil {
+		return apiTokenError(err)
+	}
+	if tok.Account != strings.TrimSpace(req.Account) {
+		return fmt.Errorf("%w: token", ErrNotFound)
+	}
+	if tok.RevokedAt.IsZero() {
+		tok.RevokedAt, tok.RevokedBy = time.Now(), svc.ActorFromContext(l.ctx)
+		if err := st.UpdateToken(l.ctx, tok); err != nil {
+			return apiTokenError(err)
+		}
+		l.audit("api_token_revoke", tok.Account, map[string]string{"token_id": tok.ID, "name": tok.Name})
+	}
+	l.svcCtx.ForgetAPIToken(tok.ID)
+	return nil
+}
+
+func serviceAccountInfo(a *apitoken.Account) types.ServiceAccountInfo {
+	return types.ServiceAccountInfo{
+		Name:        a.Name,
+		Description: a.Description,
+		Perms:       nonNil(a.Perms),
+		Scopes:      nonNil(a.Scopes),
+		Disabled:    a.Disabled,
+		CreatedBy:   a.CreatedBy,
+		CreatedAt:   a.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   a.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func apiTokenInfo(t *apitoken.Token, now time.Time) types.ApiTokenInfo {
+	info := types.ApiTokenInfo{
+		Id:         t.ID,
+		Prefix:     apitoken.Prefix + t.ID,
+		Account:    t.Account,
+		Name:       t.Name,
+		Perms:      nonNil(t.Perms),
+		Scopes:     nonNil(t.Scopes),
+		AllowedIps: nonNil(t.AllowedIPs),
+		CreatedBy:  t.CreatedBy,
+		CreatedAt:  t.CreatedAt.UTC().Format(time.RFC3339),
+		LastUsedIp: t.LastUsedIP,
+		RevokedBy:  t.RevokedBy,
+		Active:     t.Active(now),
+	}
+	if !t.ExpiresAt.IsZero() {
+		info.ExpiresAt = t.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if !t.LastUsedAt.IsZero() {
+		info.LastUsedAt = t.LastUsedAt.UTC().Format(time.RFC3339)
+	}
+	if !t.RevokedAt.IsZero() {
+		info.RevokedAt = t.RevokedAt.UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
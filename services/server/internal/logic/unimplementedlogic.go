@@ -28,20 +28,6 @@ func (l *unimplementedLogic) notImplemented(feature string) (*types.GenericOkRes
 	return nil, fmt.Errorf("%s endpoint is not implemented yet", feature)
 }
 
-type AuditLogic struct {
-	*unimplementedLogic
-}
-
-func NewAuditLogic(ctx context.Context, svcCtx *svc.ServiceContext) *AuditLogic {
-	return &AuditLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *AuditLogic) Audit() (*types.GenericOkResponse, error) {
-	return l.notImplemented("Audit")
-}
-
 type CertificateAddLogic struct {
 	*unimplementedLogic
 }
@@ -182,62 +168,6 @@ func (l *CertificateStatsLogic) CertificateStats() (*types.GenericOkResponse, er
 	return l.notImplemented("CertificateStats")
 }
 
-type InvokeLogic struct {
-	*unimplementedLogic
-}
-
-func NewInvokeLogic(ctx context.Context, svcCtx *svc.ServiceContext) *InvokeLogic {
-	return &InvokeLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *InvokeLogic) Invoke() (*types.GenericOkResponse, error) {
-	return l.notImplemented("Invoke")
-}
-
-type JobCancelLogic struct {
-	*unimplementedLogic
-}
-
-func NewJobCancelLogic(ctx context.Context, svcCtx *svc.ServiceContext) *JobCancelLogic {
-	return &JobCancelLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *JobCancelLogic) JobCancel() (*types.GenericOkResponse, error) {
-	return l.notImplemented("JobCancel")
-}
-
-type JobResultLogic struct {
-	*unimplementedLogic
-}
-
-func NewJobResultLogic(ctx context.Context, svcCtx *svc.ServiceContext) *JobResultLogic {
-	return &JobResultLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *JobResultLogic) JobResult() (*types.GenericOkResponse, error) {
-	return l.notImplemented("JobResult")
-}
-
-type JobStartLogic struct {
-	*unimplementedLogic
-}
-
-func NewJobStartLogic(ctx context.Context, svcCtx *svc.ServiceContext) *JobStartLogic {
-	return &JobStartLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *JobStartLogic) JobStart() (*types.GenericOkResponse, error) {
-	return l.notImplemented("JobStart")
-}
-
 type MessageReadLogic struct {
 	*unimplementedLogic
 }
@@ -294,76 +224,6 @@ func (l *MessagesUnreadCountLogic) MessagesUnreadCount() (*types.GenericOkRespon
 	return l.notImplemented("MessagesUnreadCount")
 }
 
-type RoleCreateLogic struct {
-	*unimplementedLogic
-}
-
-func NewRoleCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RoleCreateLogic {
-	return &RoleCreateLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *RoleCreateLogic) RoleCreate() (*types.GenericOkResponse, error) {
-	return l.notImplemented("RoleCreate")
-}
-
-type RoleDeleteLogic struct {
-	*unimplementedLogic
-}
-
-func NewRoleDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RoleDeleteLogic {
-	return &RoleDeleteLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *RoleDeleteLogic) RoleDelete() (*types.GenericOkResponse, error) {
-	return l.notImplemented("RoleDelete")
-}
-
-type RolePermissionsUpdateLogic struct {
-	*unimplementedLogic
-}
-
-func NewRolePermissionsUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RolePermissionsUpdateLogic {
-	return &RolePermissionsUpdateLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *RolePermissionsUpdateLogic) RolePermissionsUpdate() (*types.GenericOkResponse, error) {
-	return l.notImplemented("RolePermissionsUpdate")
-}
-
-type RolesListLogic struct {
-	*unimplementedLogic
-}
-
-func NewRolesListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RolesListLogic {
-	return &RolesListLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *RolesListLogic) RolesList() (*types.GenericOkResponse, error) {
-	return l.notImplemented("RolesList")
-}
-
-type RoleUpdateLogic struct {
-	*unimplementedLogic
-}
-
-func NewRoleUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RoleUpdateLogic {
-	return &RoleUpdateLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *RoleUpdateLogic) RoleUpdate() (*types.GenericOkResponse, error) {
-	return l.notImplemented("RoleUpdate")
-}
-
 type RootLogic struct {
 	*unimplementedLogic
 }
@@ -392,20 +252,6 @@ func (l *SignedUrlLogic) SignedUrl() (*types.GenericOkResponse, error) {
 	return l.notImplemented("SignedUrl")
 }
 
-type StreamJobLogic struct {
-	*unimplementedLogic
-}
-
-func NewStreamJobLogic(ctx context.Context, svcCtx *svc.ServiceContext) *StreamJobLogic {
-	return &StreamJobLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *StreamJobLogic) StreamJob() (*types.GenericOkResponse, error) {
-	return l.notImplemented("StreamJob")
-}
-
 type StreamMessagesLogic struct {
 	*unimplementedLogic
 }
@@ -420,128 +266,3 @@ func (l *StreamMessagesLogic) StreamMessages() (*types.GenericOkResponse, error)
 	return l.notImplemented("StreamMessages")
 }
 
-type UserCreateLogic struct {
-	*unimplementedLogic
-}
-
-func NewUserCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserCreateLogic {
-	return &UserCreateLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UserCreateLogic) UserCreate() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UserCreate")
-}
-
-type UserDeleteLogic struct {
-	*unimplementedLogic
-}
-
-func NewUserDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserDeleteLogic {
-	return &UserDeleteLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UserDeleteLogic) UserDelete() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UserDelete")
-}
-
-type UserGameEnvsLogic struct {
-	*unimplementedLogic
-}
-
-func NewUserGameEnvsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserGameEnvsLogic {
-	return &UserGameEnvsLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UserGameEnvsLogic) UserGameEnvs() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UserGameEnvs")
-}
-
-type UserGameEnvsUpdateLogic struct {
-	*unimplementedLogic
-}
-
-func NewUserGameEnvsUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserGameEnvsUpdateLogic {
-	return &UserGameEnvsUpdateLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UserGameEnvsUpdateLogic) UserGameEnvsUpdate() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UserGameEnvsUpdate")
-}
-
-type UserGamesLogic struct {
-	*unimplementedLogic
-}
-
-func NewUserGamesLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserGamesLogic {
-	return &UserGamesLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UserGamesLogic) UserGames() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UserGames")
-}
-
-type UserGamesUpdateLogic struct {
-	*unimplementedLogic
-}
-
-func NewUserGamesUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserGamesUpdateLogic {
-	return &UserGamesUpdateLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UserGamesUpdateLogic) UserGamesUpdate() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UserGamesUpdate")
-}
-
-type UserPasswordResetLogic struct {
-	*unimplementedLogic
-}
-
-func NewUserPasswordResetLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserPasswordResetLogic {
-	return &UserPasswordResetLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UserPasswordResetLogic) UserPasswordReset() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UserPasswordReset")
-}
-
-type UsersListLogic struct {
-	*unimplementedLogic
-}
-
-func NewUsersListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UsersListLogic {
-	return &UsersListLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UsersListLogic) UsersList() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UsersList")
-}
-
-type UserUpdateLogic struct {
-	*unimplementedLogic
-}
-
-func NewUserUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserUpdateLogic {
-	return &UserUpdateLogic{
-		unimplementedLogic: newUnimplementedLogic(ctx, svcCtx),
-	}
-}
-
-func (l *UserUpdateLogic) UserUpdate() (*types.GenericOkResponse, error) {
-	return l.notImplemented("UserUpdate")
-}
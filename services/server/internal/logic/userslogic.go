@@ -0,0 +1,504 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cuihairu/croupier/services/server/internal/svc"
+	"github.com/cuihairu/croupier/services/server/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const (
+	userReadPermission  = "user:read"
+	userWritePermission = "user:write"
+)
+
+// userAdmin carries what every user administration logic needs.
+type userAdmin struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func newUserAdmin(ctx context.Context, svcCtx *svc.ServiceContext) userAdmin {
+	return userAdmin{Logger: logx.WithContext(ctx), ctx: ctx, svcCtx: svcCtx}
+}
+
+// repo checks perm for the caller and returns the users repository.
+func (l userAdmin) repo(perm string) (svc.UserRepository, error) {
+	if !l.svcCtx.EnforcePermission(svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx), perm) {
+		return nil, ErrForbidden
+	}
+	repo := l.svcCtx.UserRepository()
+	if repo == nil {
+		return nil, ErrUnavailable
+	}
+	return repo, nil
+}
+
+// mayGrantRoles requires the caller to hold every permission of the roles it hands
+// out, so user:write alone cannot create or promote an admin.
+func (l userAdmin) mayGrantRoles(roles []string) error {
+	actor, callerRoles := svc.ActorFromContext(l.ctx), svc.RolesFromContext(l.ctx)
+	for _, role := range roles {
+		perms, ok, err := l.svcCtx.RoleGrants(l.ctx, role)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// The grants of the role are unknown; only full admins may hand it out.
+			perms = append(perms, "*")
+		}
+		for _, perm := range perms {
+			if !l.svcCtx.HasPermission(actor, callerRoles, perm) {
+				return fmt.Errorf("%w: role %s grants %s, which you do not hold", ErrForbidden, role, perm)
+			}
+		}
+	}
+	return nil
+}
+
+func (l userAdmin) audit(kind string, user *svc.UserRecord, meta map[string]string) {
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	meta["user_id"] = strconv.FormatUint(uint64(user.ID), 10)
+	l.svcCtx.Audit(kind, svc.ActorFromContext(l.ctx), user.Username, meta)
+}
+
+// userError maps repository errors to logic errors.
+func userError(err error) error {
+	switch {
+	case errors.Is(err, svc.ErrUserNotFound):
+		return fmt.Errorf("%w: user", ErrNotFound)
+	case errors.Is(err, svc.ErrRoleNotFound):
+		return fmt.Errorf("%w: unknown role", ErrInvalidRequest)
+	case errors.Is(err, svc.ErrUserExists):
+		return fmt.Errorf("%w: username already taken", ErrConflict)
+	}
+	return err
+}
+
+func userInfo(ctx context.Context, repo svc.UserRepository, u *svc.UserRecord) (types.UserInfo, error) {
+	roles, err := repo.ListUserRoles(ctx, u.ID)
+	if err != nil {
+		return types.UserInfo{}, err
+	}
+	mfa, err := repo.GetMFA(ctx, u.ID)
+	if err != nil {
+		return types.UserInfo{}, err
+	}
+	return types.UserInfo{
+		Id:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Email:       u.Email,
+		Phone:       u.Phone,
+		Active:      u.Active,
+		Roles:       roles,
+		MfaEnrolled: mfa.Enrolled(),
+		ExternalId:  u.ExternalID,
+	}, nil
+}
+
+type UsersListLogic struct{ userAdmin }
+
+func NewUsersListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UsersListLogic {
+	return &UsersListLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+func (l *UsersListLogic) UsersList() (*types.UsersListResponse, error) {
+	repo, err := l.repo(userReadPermission)
+	if err != nil {
+		return nil, err
+	}
+	users, err := repo.ListUsers(l.ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]types.UserInfo, 0, len(users))
+	for _, u := range users {
+		info, err := userInfo(l.ctx, repo, u)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, info)
+	}
+	return &types.UsersListResponse{Users: out}, nil
+}
+
+type UserCreateLogic struct{ userAdmin }
+
+func NewUserCreateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserCreateLogic {
+	return &UserCreateLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+func (l *UserCreateLogic) UserCreate(req *types.UserCreateRequest) (*types.UserCreateResponse, error) {
+	repo, err := l.repo(userWritePermission)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || strings.TrimSpace(req.Username) == "" {
+		return nil, ErrInvalidRequest
+	}
+	if svc.IsServiceAccount(strings.TrimSpace(req.Username)) {
+		return nil, fmt.Errorf("%w: username prefix %s is reserved for service accounts", ErrInvalidRequest, svc.ServiceAccountPrefix)
+	}
+	user := &svc.UserRecord{
+		Username:    strings.TrimSpace(req.Username),
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		Email:       strings.TrimSpace(req.Email),
+		Phone:       strings.TrimSpace(req.Phone),
+		Active:      req.Active == nil || *req.Active,
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = user.Username
+	}
+	roles := trimRoles(req.Roles)
+	if err := l.mayGrantRoles(roles); err != nil {
+		return nil, err
+	}
+	if err := repo.CreateUser(l.ctx, user, req.Password, roles); err != nil {
+		return nil, userError(err)
+	}
+	l.audit("user_create", user, map[string]string{"roles": strings.Join(roles, ",")})
+	return &types.UserCreateResponse{Id: user.ID}, nil
+}
+
+type UserUpdateLogic struct{ userAdmin }
+
+func NewUserUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserUpdateLogic {
+	return &UserUpdateLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+// UserUpdate edits the profile, active flag and roles of a user. Deactivating the
+// user or changing its roles revokes its sessions so the change applies at once.
+// Callers cannot change their own roles nor grant roles beyond their own permissions.
+func (l *UserUpdateLogic) UserUpdate(req *types.UserUpdateRequest) error {
+	repo, err := l.repo(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 {
+		return ErrInvalidRequest
+	}
+	user, err := repo.GetUser(l.ctx, req.Id)
+	if err != nil {
+		return userError(err)
+	}
+	wasActive := user.Active
+	var roles []string
+	rolesChanged := false
+	if req.Roles != nil {
+		roles = trimRoles(req.Roles)
+		current, err := repo.ListUserRoles(l.ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if rolesChanged = !sameRoles(current, roles); rolesChanged {
+			if strings.EqualFold(user.Username, svc.ActorFromContext(l.ctx)) {
+				return fmt.Errorf("%w: cannot change your own roles", ErrForbidden)
+			}
+			var added []string
+			for _, r := range roles {
+				if !slices.Contains(current, r) {
+					added = append(added, r)
+				}
+			}
+			if err := l.mayGrantRoles(added); err != nil {
+				return err
+			}
+		}
+	}
+	if v := strings.TrimSpace(req.DisplayName); v != "" {
+		user.DisplayName = v
+	}
+	user.Email = strings.TrimSpace(req.Email)
+	user.Phone = strings.TrimSpace(req.Phone)
+	if req.Active != nil {
+		if !*req.Active && strings.EqualFold(user.Username, svc.ActorFromContext(l.ctx)) {
+			return fmt.Errorf("%w: cannot deactivate yourself", ErrInvalidRequest)
+		}
+		user.Active = *req.Active
+	}
+	if err := repo.UpdateUser(l.ctx, user); err != nil {
+		return userError(err)
+	}
+	meta := map[string]string{"active": strconv.FormatBool(user.Active)}
+	revoke := wasActive && !user.Active
+	if rolesChanged {
+		if err := repo.SetUserRoles(l.ctx, user.ID, roles); err != nil {
+			return userError(err)
+		}
+		meta["roles"] = strings.Join(roles, ",")
+		revoke = true
+	}
+	if revoke {
+		l.svcCtx.RevokeSessions(l.ctx, user.Username, svc.ActorFromContext(l.ctx))
+	}
+	l.audit("user_update", user, meta)
+	return nil
+}
+
+type UserDeleteLogic struct{ userAdmin }
+
+func NewUserDeleteLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserDeleteLogic {
+	return &UserDeleteLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+func (l *UserDeleteLogic) UserDelete(req *types.UserIdRequest) error {
+	repo, err := l.repo(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 {
+		return ErrInvalidRequest
+	}
+	user, err := repo.GetUser(l.ctx, req.Id)
+	if err != nil {
+		return userError(err)
+	}
+	if strings.EqualFold(user.Username, svc.ActorFromContext(l.ctx)) {
+		return fmt.Errorf("%w: cannot delete yourself", ErrInvalidRequest)
+	}
+	if err := repo.DeleteUser(l.ctx, user.ID); err != nil {
+		return userError(err)
+	}
+	l.svcCtx.RevokeSessions(l.ctx, user.Username, svc.ActorFromContext(l.ctx))
+	l.audit("user_delete", user, nil)
+	return nil
+}
+
+type UserPasswordResetLogic struct{ userAdmin }
+
+func NewUserPasswordResetLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserPasswordResetLogic {
+	return &UserPasswordResetLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+// UserPasswordReset sets a new password and revokes the user's sessions.
+func (l *UserPasswordResetLogic) UserPasswordReset(req *types.UserPasswordResetRequest) error {
+	repo, err := l.repo(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 || strings.TrimSpace(req.Password) == "" {
+		return ErrInvalidRequest
+	}
+	user, err := repo.GetUser(l.ctx, req.Id)
+	if err != nil {
+		return userError(err)
+	}
+	if err := repo.SetPassword(l.ctx, user.ID, req.Password); err != nil {
+		return userError(err)
+	}
+	l.svcCtx.RevokeSessions(l.ctx, user.Username, svc.ActorFromContext(l.ctx))
+	l.audit("user_set_password", user, nil)
+	return nil
+}
+
+type UserMFAResetLogic struct{ userAdmin }
+
+func NewUserMFAResetLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserMFAResetLogic {
+	return &UserMFAResetLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+// UserMFAReset removes the user's MFA enrollment, e.g. after a lost device, and
+// revokes its sessions. Users required to use MFA must enroll again at next login.
+func (l *UserMFAResetLogic) UserMFAReset(req *types.UserIdRequest) error {
+	repo, err := l.repo(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 {
+		return ErrInvalidRequest
+	}
+	user, err := repo.GetUser(l.ctx, req.Id)
+	if err != nil {
+		return userError(err)
+	}
+	if err := l.svcCtx.ResetMFA(l.ctx, user.ID); err != nil {
+		return userError(err)
+	}
+	l.svcCtx.RevokeSessions(l.ctx, user.Username, svc.ActorFromContext(l.ctx))
+	l.audit("mfa_reset", user, nil)
+	return nil
+}
+
+type UserGamesLogic struct{ userAdmin }
+
+func NewUserGamesLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserGamesLogic {
+	return &UserGamesLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+// UserGames lists the games the user is limited to; an empty list means all games.
+func (l *UserGamesLogic) UserGames(req *types.UserIdRequest) (*types.UserGamesResponse, error) {
+	repo, err := l.repo(userReadPermission)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || req.Id == 0 {
+		return nil, ErrInvalidRequest
+	}
+	if _, err := repo.GetUser(l.ctx, req.Id); err != nil {
+		return nil, userError(err)
+	}
+	ids, err := repo.ListUserGameIDs(l.ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(ids)
+	return &types.UserGamesResponse{GameIds: append([]uint{}, ids...)}, nil
+}
+
+type UserGamesUpdateLogic struct{ userAdmin }
+
+func NewUserGamesUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserGamesUpdateLogic {
+	return &UserGamesUpdateLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+func (l *UserGamesUpdateLogic) UserGamesUpdate(req *types.UserGamesUpdateRequest) error {
+	repo, err := l.repo(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 {
+		return ErrInvalidRequest
+	}
+	user, err := repo.GetUser(l.ctx, req.Id)
+	if err != nil {
+		return userError(err)
+	}
+	if games := l.svcCtx.GamesRepository(); games != nil {
+		for _, gid := range req.GameIds {
+			if _, err := games.Get(l.ctx, gid); err != nil {
+				return fmt.Errorf("%w: unknown game %d", ErrInvalidRequest, gid)
+			}
+		}
+	}
+	if err := repo.ReplaceUserGameIDs(l.ctx, user.ID, req.GameIds); err != nil {
+		return userError(err)
+	}
+	ids := make([]string, 0, len(req.GameIds))
+	for _, gid := range req.GameIds {
+		ids = append(ids, strconv.FormatUint(uint64(gid), 10))
+	}
+	l.audit("user_set_games", user, map[string]string{"game_ids": strings.Join(ids, ",")})
+	return nil
+}
+
+type UserGameEnvsLogic struct{ userAdmin }
+
+func NewUserGameEnvsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserGameEnvsLogic {
+	return &UserGameEnvsLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+// UserGameEnvs lists the envs of a game the user is limited to; empty means all envs.
+func (l *UserGameEnvsLogic) UserGameEnvs(req *types.UserGameEnvsRequest) (*types.UserGameEnvsResponse, error) {
+	repo, err := l.repo(userReadPermission)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || req.Id == 0 || req.GameId == 0 {
+		return nil, ErrInvalidRequest
+	}
+	if _, err := repo.GetUser(l.ctx, req.Id); err != nil {
+		return nil, userError(err)
+	}
+	envs, err := repo.ListUserGameEnvs(l.ctx, req.Id, req.GameId)
+	if err != nil {
+		return nil, err
+	}
+	return &types.UserGameEnvsResponse{Envs: append([]string{}, envs...)}, nil
+}
+
+type UserGameEnvsUpdateLogic struct{ userAdmin }
+
+func NewUserGameEnvsUpdateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserGameEnvsUpdateLogic {
+	return &UserGameEnvsUpdateLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+func (l *UserGameEnvsUpdateLogic) UserGameEnvsUpdate(req *types.UserGameEnvsUpdateRequest) error {
+	repo, err := l.repo(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 || req.GameId == 0 {
+		return ErrInvalidRequest
+	}
+	user, err := repo.GetUser(l.ctx, req.Id)
+	if err != nil {
+		return userError(err)
+	}
+	if games := l.svcCtx.GamesRepository(); games != nil {
+		game, err := games.Get(l.ctx, req.GameId)
+		if err != nil {
+			return fmt.Errorf("%w: unknown game %d", ErrInvalidRequest, req.GameId)
+		}
+		for _, env := range req.Envs {
+			if env = strings.TrimSpace(env); env != "" && !slices.Contains(game.Envs, env) {
+				return fmt.Errorf("%w: unknown env %s", ErrInvalidRequest, env)
+			}
+		}
+	}
+	if err := repo.ReplaceUserGameEnvs(l.ctx, user.ID, req.GameId, req.Envs); err != nil {
+		return userError(err)
+	}
+	l.audit("user_set_game_envs", user, map[string]string{
+		"game_id": strconv.FormatUint(uint64(req.GameId), 10),
+		"envs":    strings.Join(req.Envs, ","),
+	})
+	return nil
+}
+
+func trimRoles(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, r := range in {
+		if r = strings.TrimSpace(r); r != "" && !slices.Contains(out, r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, r := range a {
+		if !slices.Contains(b, r) {
+			return false
+		}
+	}
+	return true
+}
+
+type UserSessionsRevokeLogic struct{ userAdmin }
+
+func NewUserSessionsRevokeLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UserSessionsRevokeLogic {
+	return &UserSessionsRevokeLogic{newUserAdmin(ctx, svcCtx)}
+}
+
+// UserSessionsRevoke signs the user out everywhere, e.g. when offboarding an
+// operator, without waiting for its tokens to expire.
+func (l *UserSessionsRevokeLogic) UserSessionsRevoke(req *types.UserIdRequest) error {
+	repo, err := l.repo(userWritePermission)
+	if err != nil {
+		return err
+	}
+	if req == nil || req.Id == 0 {
+		return ErrInvalidRequest
+	}
+	user, err := repo.GetUser(l.ctx, req.Id)
+	if err != nil {
+		return userError(err)
+	}
+	n := l.svcCtx.RevokeSessions(l.ctx, user.Username, svc.ActorFromContext(l.ctx))
+	l.audit("session_revoke_all", user, map[string]string{"count": strconv.Itoa(n)})
+	return nil
+}
@@ -0,0 +1,191 @@
+package svc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/devcert"
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+)
+
+const (
+	defaultAgentCertTTL = 30 * 24 * time.Hour
+	// devCertDir holds the generated CA and server certificate when no TLS
+	// material is configured.
+	devCertDir = "data/certs"
+)
+
+// ErrNoAgentCA is returned when the control plane has no CA key to sign with.
+var ErrNoAgentCA = errors.New("no CA key configured for agent certificates")
+
+// controlTLSFiles is the TLS material of the agent-facing control server.
+type controlTLSFiles struct {
+	Cert, Key, CA string
+	// agentCA signs agent certificates; nil without a CA key.
+	agentCA *agentcert.CA
+}
+
+// controlTLSConfig resolves Server.Cert/Key/CA/CAKey once, generating a
+// development CA and server certificate when none are configured.
+func (s *ServiceContext) controlTLSConfig() (*controlTLSFiles, error) {
+	s.controlTLSOnce.Do(func() {
+		c := s.Config.Server
+		files := &controlTLSFiles{Cert: c.Cert, Key: c.Key, CA: c.CA}
+		caKey := c.CAKey
+		if c.Cert == "" && c.Key == "" && c.CA == "" {
+			ca, key, err := devcert.EnsureDevCA(devCertDir)
+			if err != nil {
+				s.controlTLSErr = err
+				return
+			}
+			cert, certKey, err := devcert.EnsureServerCert(devCertDir, ca, key, controlHosts(c.Addr))
+			if err != nil {
+				s.controlTLSErr = err
+				return
+			}
+			files = &controlTLSFiles{Cert: cert, Key: certKey, CA: ca}
+			caKey = key
+		}
+		if files.Cert == "" || files.Key == "" || files.CA == "" {
+			s.controlTLSErr = errors.New("server.cert, server.key and server.ca must be set together")
+			return
+		}
+		if caKey != "" {
+			ca, err := agentcert.LoadCA(files.CA, caKey)
+			if err != nil {
+				s.controlTLSErr = err
+				return
+			}
+			files.agentCA = ca
+		}
+		s.controlTLS = files
+	})
+	return s.controlTLS, s.controlTLSErr
+}
+
+// AgentCA returns the CA signing agent certificates.
+func (s *ServiceContext) AgentCA() (*agentcert.CA, error) {
+	files, err := s.controlTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if files.agentCA == nil {
+		return nil, ErrNoAgentCA
+	}
+	return files.agentCA, nil
+}
+
+// agentTLSConfig verifies that agents present a certificate issued by the
+// Croupier CA to agentID. Agent certificates name the agent in a URI rather than a
+// host, so the chain and the agent identity are checked in VerifyConnection
+// instead of the usual host name verification.
+func (s *ServiceContext) agentTLSConfig(agentID string) (*tls.Config, error) {
+	files, err := s.controlTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	roots, err := loadCertPool(files.CA)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return verifyAgentCert(cs.PeerCertificates, roots, agentID)
+		},
+	}, nil
+}
+
+// edgeTLSConfig authenticates the server to the edges holding agent tunnels with
+// the server certificate, and verifies that an edge presents a certificate of the
+// Croupier CA for the host it is dialed on.
+func (s *ServiceContext) edgeTLSConfig() (*tls.Config, error) {
+	files, err := s.controlTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	roots, err := loadCertPool(files.CA)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		return nil, fmt.Errorf("load keypair: %w", err)
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	caPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ca: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("append ca %s: invalid pem", path)
+	}
+	return roots, nil
+}
+
+// verifyAgentCert checks that certs chain up to roots and are issued to agentID.
+func verifyAgentCert(certs []*x509.Certificate, roots *x509.CertPool, agentID string) error {
+	if len(certs) == 0 {
+		return agentcert.ErrNoIdentity
+	}
+	inter := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		inter.AddCert(c)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: inter,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		return fmt.Errorf("agent certificate: %w", err)
+	}
+	id, err := agentcert.FromCertificate(certs[0])
+	if err != nil {
+		return err
+	}
+	if id.AgentID != agentID {
+		return fmt.Errorf("agent certificate is issued to %q, not %q", id.AgentID, agentID)
+	}
+	return nil
+}
+
+// AgentCertTTL is the lifetime of issued and renewed agent certificates.
+func (s *ServiceContext) AgentCertTTL() time.Duration {
+	return parseTTL(s.Config.Server.AgentCertTTL, defaultAgentCertTTL)
+}
+
+// agentRevocations returns the list of Server.AgentRevocations, or nil when unset.
+func (s *ServiceContext) agentRevocations() agentcert.Revocations {
+	path := strings.TrimSpace(s.Config.Server.AgentRevocations)
+	if path == "" {
+		return nil
+	}
+	return agentcert.RevocationList{Path: ResolveWorkspacePath(path)}
+}
+
+// controlHosts are the names the generated server certificate is valid for.
+func controlHosts(addr string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if h, _, err := net.SplitHostPort(addr); err == nil && h != "" && h != "0.0.0.0" && h != "::" {
+		hosts = append(hosts, h)
+	}
+	if h, err := os.Hostname(); err == nil && strings.TrimSpace(h) != "" {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
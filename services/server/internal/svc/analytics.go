@@ -0,0 +1,32 @@
+package svc
+
+import (
+	"strings"
+
+	"github.com/cuihairu/croupier/internal/analytics/schema"
+	"github.com/cuihairu/croupier/services/server/internal/config"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const defaultAnalyticsSpec = "configs/analytics/events.yaml"
+
+// loadAnalyticsSpec loads the event specification. A spec that fails to load
+// is logged and left nil, which makes ingestion refuse events rather than
+// accept them unchecked.
+func loadAnalyticsSpec(c config.AnalyticsConfig) (*schema.Spec, schema.Mode) {
+	mode, err := schema.ParseMode(strings.TrimSpace(c.Invalid))
+	if err != nil {
+		logx.Errorf("analytics: %v; quarantining invalid events", err)
+		mode = schema.ModeQuarantine
+	}
+	path := strings.TrimSpace(c.EventsSpec)
+	if path == "" {
+		path = defaultAnalyticsSpec
+	}
+	spec, err := schema.Load(ResolveWorkspacePath(path))
+	if err != nil {
+		logx.Errorf("analytics: load event spec: %v", err)
+		return nil, mode
+	}
+	return spec, mode
+}
@@ -0,0 +1,183 @@
+package svc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	appr "github.com/cuihairu/croupier/internal/platform/approvals"
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// Approval modes record how the held call is dispatched once approved.
+const (
+	ApprovalModeInvoke   = "invoke"
+	ApprovalModeStartJob = "start_job"
+)
+
+// defaultApprovalExpiry applies when a two-person rule sets no expiry.
+const defaultApprovalExpiry = 24 * time.Hour
+
+// approvalDispatchGrace is how long an approval may stay approved while its call is
+// dispatched before it is considered stranded by a server that stopped.
+const approvalDispatchGrace = 10 * time.Minute
+
+// errApprovalStranded is recorded on approvals whose dispatch was interrupted.
+const errApprovalStranded = "dispatch interrupted: the server stopped before recording the outcome; check the effect of the call before requesting it again"
+
+// newApprovalsStore persists approvals in gdb when available, in memory otherwise.
+// With a database configured the server does not start on a volatile queue: a
+// failed migration is fatal.
+func newApprovalsStore(gdb *gorm.DB) appr.Store {
+	if gdb == nil {
+		return appr.NewMemStore()
+	}
+	store, err := appr.NewGormStore(gdb)
+	if err != nil {
+		logx.Must(fmt.Errorf("migrate approval tables: %w", err))
+	}
+	return store
+}
+
+// ApprovalPolicy is the two-person rule of a function.
+type ApprovalPolicy struct {
+	Threshold     int
+	ApproverRoles []string
+	Expiry        time.Duration
+}
+
+// RequestApproval parks an invocation as a pending approval instead of dispatching it.
+func (s *ServiceContext) RequestApproval(in InvokeInput, mode string, policy ApprovalPolicy) (*appr.Approval, error) {
+	if s.approvals == nil {
+		return nil, fmt.Errorf("approvals store unavailable")
+	}
+	expiry := policy.Expiry
+	if expiry <= 0 {
+		expiry = defaultApprovalExpiry
+	}
+	threshold := policy.Threshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	now := time.Now()
+	a := &appr.Approval{
+		ID:              newApprovalID(),
+		State:           appr.StatePending,
+		FunctionID:      in.FunctionID,
+		GameID:          in.GameID,
+		Env:             in.Env,
+		Actor:           in.Actor,
+		Mode:            mode,
+		IdempotencyKey:  in.IdempotencyKey,
+		Route:           in.Route,
+		TargetServiceID: in.TargetServiceID,
+		HashKey:         in.HashKey,
+		Payload:         in.Payload,
+		Threshold:       threshold,
+		ApproverRoles:   append([]string(nil), policy.ApproverRoles...),
+		ExpiresAt:       now.Add(expiry),
+		CreatedAt:       now,
+	}
+	if err := s.approvals.Create(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func newApprovalID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "apr-" + hex.EncodeToString(b)
+}
+
+// ResumeApprovals watches for approvals left approved without an outcome, as when
+// the server stopped while dispatching them, until ctx is done. The call may or may
+// not have run, so it is not dispatched again; the approval is marked failed instead
+// so that it no longer looks in flight.
+func (s *ServiceContext) ResumeApprovals(ctx context.Context) {
+	if s.approvals == nil {
+		return
+	}
+	go func() {
+		t := time.NewTicker(time.Minute)
+		defer t.Stop()
+		for {
+			s.failStrandedApprovals(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// failStrandedApprovals marks failed the approvals approved longer than
+// approvalDispatchGrace before now. Complete only applies to approved records, so
+// an approval whose dispatch finishes meanwhile on another replica is left alone.
+func (s *ServiceContext) failStrandedApprovals(now time.Time) {
+	var stranded []string
+	for page := 1; ; page++ {
+		list, total, err := s.approvals.List(appr.Filter{State: appr.StateApproved}, appr.Page{Page: page, Size: 100, Sort: "updated_at asc"})
+		if err != nil {
+			logx.Errorf("list approved approvals: %v", err)
+			return
+		}
+		for _, a := range list {
+			if now.Sub(a.UpdatedAt) >= approvalDispatchGrace {
+				stranded = append(stranded, a.ID)
+			}
+		}
+		if len(list) == 0 || page*100 >= total {
+			break
+		}
+	}
+	for _, id := range stranded {
+		if _, err := s.approvals.Complete(id, appr.Outcome{Error: errApprovalStranded}); err != nil {
+			logx.Errorf("fail stranded approval %s: %v", id, err)
+			continue
+		}
+		logx.Errorf("approval %s: %s", id, errApprovalStranded)
+	}
+}
+
+// DispatchApproval runs the call held by an approved approval on behalf of its
+// requester and links the outcome back to the record. The store only lets the vote
+// that reached the threshold observe the approved state, so this runs once per record.
+// The call is detached from ctx: an approver dropping the request must not abort it
+// and leave the record approved without an outcome.
+func (s *ServiceContext) DispatchApproval(ctx context.Context, a *appr.Approval, timeout time.Duration) (*appr.Approval, error) {
+	ctx = context.WithoutCancel(ctx)
+	in := InvokeInput{
+		FunctionID:      a.FunctionID,
+		GameID:          a.GameID,
+		Env:             a.Env,
+		Actor:           a.Actor,
+		IdempotencyKey:  a.IdempotencyKey,
+		Route:           a.Route,
+		TargetServiceID: a.TargetServiceID,
+		HashKey:         a.HashKey,
+		Payload:         a.Payload,
+		Timeout:         timeout,
+	}
+	var out appr.Outcome
+	if a.Mode == ApprovalModeStartJob {
+		job, err := s.StartFunctionJob(ctx, in)
+		if err != nil {
+			out.Error = err.Error()
+		} else {
+			out.JobID = job.ID
+		}
+	} else {
+		res, err := s.InvokeFunction(ctx, in)
+		if err != nil {
+			out.Error = err.Error()
+		} else {
+			out.Result = res.Payload
+		}
+	}
+	return s.approvals.Complete(a.ID, out)
+}
@@ -0,0 +1,46 @@
+package svc
+
+import (
+	"testing"
+	"time"
+
+	appr "github.com/cuihairu/croupier/internal/platform/approvals"
+)
+
+func TestFailStrandedApprovals(t *testing.T) {
+	store := appr.NewMemStore()
+	s := &ServiceContext{approvals: store}
+	create := func() string {
+		t.Helper()
+		a := &appr.Approval{ID: newApprovalID(), Actor: "alice", FunctionID: "f", Threshold: 1, ExpiresAt: time.Now().Add(time.Hour)}
+		if err := store.Create(a); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Approve(a.ID, "bob", nil); err != nil {
+			t.Fatal(err)
+		}
+		return a.ID
+	}
+	stranded, recent := create(), create()
+	if stranded == recent {
+		t.Fatalf("two approvals got id %s", stranded)
+	}
+
+	// Only approvals approved longer than the grace period ago are failed.
+	s.failStrandedApprovals(time.Now().Add(approvalDispatchGrace / 2))
+	for _, id := range []string{stranded, recent} {
+		if a, _ := store.Get(id); a.State != appr.StateApproved {
+			t.Fatalf("approval %s is %s within the grace period", id, a.State)
+		}
+	}
+	if _, err := store.Complete(recent, appr.Outcome{Result: []byte("ok")}); err != nil {
+		t.Fatal(err)
+	}
+	s.failStrandedApprovals(time.Now().Add(approvalDispatchGrace))
+	if a, _ := store.Get(stranded); a.State != appr.StateFailed || a.Error != errApprovalStranded {
+		t.Fatalf("stranded approval is %s with error %q, want failed", a.State, a.Error)
+	}
+	if a, _ := store.Get(recent); a.State != appr.StateExecuted {
+		t.Fatalf("executed approval is %s", a.State)
+	}
+}
@@ -1,16 +1,32 @@
 package svc
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/cuihairu/croupier/internal/security/rbac"
 	"github.com/cuihairu/croupier/internal/security/token"
+	"github.com/cuihairu/croupier/services/server/internal/config"
+	"github.com/zeromicro/go-zero/core/logx"
 )
 
-// Authenticator validates requests and returns user + roles info.
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	User  string
+	Roles []string
+	// MFAAt is when the caller last completed a TOTP challenge; zero if never.
+	MFAAt time.Time
+	// EnrollOnly marks a session that may only be used to enroll in MFA.
+	EnrollOnly bool
+	SessionID  string
+	ExpiresAt  time.Time
+}
+
+// Authenticator validates requests and returns the caller identity.
 type Authenticator interface {
-	Authenticate(r *http.Request) (string, []string, bool)
+	Identify(r *http.Request) (*Identity, bool)
 }
 
 type Authorizer interface {
@@ -19,39 +35,87 @@ type Authorizer interface {
 
 type noopAuthenticator struct{}
 
-func (n *noopAuthenticator) Authenticate(r *http.Request) (string, []string, bool) {
-	return "dev", []string{"admin"}, true
+// Identify treats every request as a verified dev admin, since there is no second
+// factor without auth.
+func (n *noopAuthenticator) Identify(r *http.Request) (*Identity, bool) {
+	now := time.Now()
+	return &Identity{User: "dev", Roles: []string{"admin"}, MFAAt: now, ExpiresAt: now.Add(time.Hour)}, true
 }
 
-func newJWTAuthenticator(secret string) (Authenticator, error) {
-	secret = strings.TrimSpace(secret)
-	if secret == "" {
-		return nil, fmt.Errorf("jwt secret empty")
+// newAuthorizer loads the RBAC policy named by Auth.RBACConfig and watches it for
+// changes. Without a usable policy only the admin roles are granted anything.
+func newAuthorizer(c config.Config) Authorizer {
+	path := ResolveWorkspacePath(c.Auth.RBACConfig)
+	if path == "" {
+		logx.Errorf("auth.rbac_config not set; only admin roles are authorized")
+		return adminOnlyAuthorizer()
+	}
+	r, err := rbac.NewReloader(path)
+	if err != nil {
+		logx.Errorf("load rbac policy %s: %v; only admin roles are authorized", path, err)
+		return adminOnlyAuthorizer()
+	}
+	if err := r.Watch(context.Background()); err != nil {
+		logx.Errorf("watch rbac policy %s: %v", path, err)
 	}
-	return &jwtAuthenticator{manager: token.NewManager(secret)}, nil
+	return r
 }
 
-type noopAuthorizer struct{}
+type policyAuthorizer struct {
+	policy rbac.PolicyInterface
+}
+
+func adminOnlyAuthorizer() Authorizer {
+	p := rbac.NewPolicy()
+	p.Grant("role:admin", "*")
+	p.Grant("role:super_admin", "*")
+	return &policyAuthorizer{policy: p}
+}
 
-func newNoopRBAC() Authorizer { return &noopAuthorizer{} }
+func (a *policyAuthorizer) Can(user string, roles []string, perm string) bool {
+	return rbac.Allowed(a.policy, user, roles, perm)
+}
 
-func (n *noopAuthorizer) Can(user string, roles []string, perm string) bool { return true }
+// bearerToken returns the token of the Authorization header.
+func bearerToken(r *http.Request) string {
+	if authz := r.Header.Get("Authorization"); strings.HasPrefix(authz, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
+	}
+	if r.Method == http.MethodGet && r.Header.Get("Accept") == "text/event-stream" {
+		// EventSource cannot set headers; SSE clients pass the token in the query.
+		return strings.TrimSpace(r.URL.Query().Get("token"))
+	}
+	return ""
+}
 
+// jwtAuthenticator verifies bearer tokens; active reports whether the server-side
+// session of a token may still be used.
 type jwtAuthenticator struct {
 	manager *token.Manager
+	active  func(sessionID, user string) bool
 }
 
-func (j *jwtAuthenticator) Authenticate(r *http.Request) (string, []string, bool) {
-	authz := r.Header.Get("Authorization")
-	if strings.HasPrefix(authz, "Bearer ") && j.manager != nil {
-		tokenStr := strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
-		if tokenStr == "" {
-			return "", nil, false
-		}
-		user, roles, err := j.manager.Verify(tokenStr)
-		if err == nil {
-			return user, roles, true
-		}
+func (j *jwtAuthenticator) Identify(r *http.Request) (*Identity, bool) {
+	if j.manager == nil {
+		return nil, false
+	}
+	tokenStr := bearerToken(r)
+	if tokenStr == "" {
+		return nil, false
+	}
+	claims, err := j.manager.Parse(tokenStr)
+	if err != nil {
+		return nil, false
+	}
+	if j.active != nil && !j.active(claims.SessionID, claims.Subject) {
+		return nil, false
 	}
-	return "", nil, false
+	return &Identity{
+		User:       claims.Subject,
+		Roles:      claims.Roles,
+		MFAAt:      claims.MFAAt,
+		EnrollOnly: claims.Scope == MFAEnrollScope,
+		SessionID:  claims.SessionID,
+		ExpiresAt:  claims.ExpiresAt,
+	}, true
 }
@@ -0,0 +1,75 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/platform/control"
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	"github.com/cuihairu/croupier/internal/platform/tlsutil"
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	serverv1 "github.com/cuihairu/croupier/pkg/pb/croupier/server/v1"
+	"github.com/zeromicro/go-zero/core/logx"
+	"google.golang.org/grpc"
+)
+
+// agentEvictInterval is how often expired agent sessions are swept.
+const agentEvictInterval = 5 * time.Second
+
+// StartControlServer serves the agent-facing ControlService on Server.Addr so that
+// agents can register into RegistryStore. It returns nil when no address is configured.
+// Agents must present a certificate of the Croupier CA, and may only register the
+// agent id and games it names; revoked certificates are refused. With a CA key
+// agents renew their certificate over the same channel.
+func (s *ServiceContext) StartControlServer() (*grpc.Server, error) {
+	addr := strings.TrimSpace(s.Config.Server.Addr)
+	if addr == "" {
+		return nil, nil
+	}
+	files, err := s.controlTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("control tls: %w", err)
+	}
+	creds, err := tlsutil.ServerTLS(files.Cert, files.Key, files.CA, true)
+	if err != nil {
+		return nil, fmt.Errorf("control tls: %w", err)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(grpc.Creds(creds))
+	ctrl := control.NewServer(s.RegistryStore)
+	ctrl.RequireAgentIdentity()
+	ctrl.SetRevocations(s.agentRevocations())
+	ctrl.SetSessionTTL(parseTTL(s.Config.Registry.AgentSessionTTL, control.DefaultSessionTTL))
+	serverv1.RegisterControlServiceServer(srv, ctrl)
+	if files.agentCA != nil {
+		agentcert.RegisterRenewServer(srv, files.agentCA, s.AgentCertTTL(), s.agentRevocations())
+	} else {
+		logx.Infof("agent certificate renewal disabled: %v", ErrNoAgentCA)
+	}
+	go s.RegistryStore.RunEviction(context.Background(), agentEvictInterval, s.agentOffline)
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			logx.Errorf("control server stopped: %v", err)
+		}
+	}()
+	logx.Infof("control server listening on %s (mTLS)", addr)
+	return srv, nil
+}
+
+// agentOffline records an agent evicted for missing its heartbeats.
+func (s *ServiceContext) agentOffline(a *registry.AgentSession) {
+	s.closeAgentConns(a.AgentID)
+	logx.Infof("agent %s offline (game %s env %s, last seen %s)", a.AgentID, a.GameID, a.Env, a.LastSeen.Format(time.RFC3339))
+	s.Audit("agent_offline", "system", "agent:"+a.AgentID, map[string]string{
+		"game_id":    a.GameID,
+		"env":        a.Env,
+		"session_id": a.SessionID,
+		"last_seen":  a.LastSeen.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,37 @@
+package svc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cuihairu/croupier/internal/db"
+	"github.com/cuihairu/croupier/services/server/internal/config"
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// openDatabase opens the server database configured under Server.DB. It returns nil
+// when no datasource is configured so that stores fall back to memory; a configured
+// database that cannot be opened stops the server instead of losing durability.
+func openDatabase(c config.Config) *gorm.DB {
+	dsn := strings.TrimSpace(c.Server.Database.DataSource)
+	if dsn == "" {
+		return nil
+	}
+	if strings.EqualFold(strings.TrimSpace(c.Server.Database.Driver), "sqlite") &&
+		!strings.HasPrefix(dsn, "file:") && !strings.HasPrefix(dsn, "sqlite:") && dsn != ":memory:" {
+		path := ResolveServerPath(dsn)
+		_ = os.MkdirAll(filepath.Dir(path), 0o755)
+		dsn = "file:" + path
+	}
+	gdb, err := db.Open(dsn)
+	if err != nil {
+		logx.Must(fmt.Errorf("open database: %w", err))
+	}
+	return gdb
+}
+
+// DB returns the server database, or nil when running without one.
+func (s *ServiceContext) DB() *gorm.DB { return s.db }
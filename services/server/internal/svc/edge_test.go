@@ -0,0 +1,127 @@
+package svc
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/app/agent"
+	"github.com/cuihairu/croupier/internal/app/edge"
+	"github.com/cuihairu/croupier/internal/devcert"
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	"github.com/cuihairu/croupier/internal/platform/tlsutil"
+	_ "github.com/cuihairu/croupier/internal/transport/jsoncodec"
+	localv1 "github.com/cuihairu/croupier/pkg/pb/croupier/agent/local/v1"
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// serveGRPC serves register's services on a local port and returns its address.
+func serveGRPC(t *testing.T, register func(*grpc.Server), opts ...grpc.ServerOption) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(opts...)
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestInvokeFunctionThroughEdge(t *testing.T) {
+	dir := t.TempDir()
+	caCrt, caKey, err := devcert.EnsureDevCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvCrt, srvKey, err := devcert.EnsureServerCert(dir, caCrt, caKey, []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edgeDir := t.TempDir()
+	edgeCrt, edgeKey, err := devcert.EnsureServerCert(edgeDir, caCrt, caKey, []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// edge with mTLS, as services/edge runs it with TLS enabled
+	edgeApp := edge.New(nil)
+	edgeApp.RequireAgentIdentity()
+	edgeCreds, err := tlsutil.ServerTLS(edgeCrt, edgeKey, caCrt, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edgeAddr := serveGRPC(t, edgeApp.RegisterGRPC, grpc.Creds(edgeCreds))
+
+	// agent accepting no inbound calls, serving a game server instance locally
+	agentDir := t.TempDir()
+	certPEM, keyPEM := issueAgentCert(t, caCrt, caKey, "a1")
+	agentCrt, agentKey := filepath.Join(agentDir, "agent.crt"), filepath.Join(agentDir, "agent.key")
+	if err := os.WriteFile(agentCrt, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(agentKey, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	agentApp := agent.New("", "a1")
+	if err := agentApp.UseTLS(agent.TLSConfig{CertFile: agentCrt, KeyFile: agentKey, CAFile: caCrt}); err != nil {
+		t.Fatal(err)
+	}
+	localAddr := serveGRPC(t, agentApp.RegisterGRPC)
+	sdkAddr := serveGRPC(t, func(s *grpc.Server) { functionv1.RegisterFunctionServiceServer(s, echoFunctions{}) })
+	cc, err := grpc.NewClient(localAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := localv1.NewLocalControlServiceClient(cc).RegisterLocal(ctx, &localv1.RegisterLocalRequest{
+		ServiceId: "game-1",
+		RpcAddr:   sdkAddr,
+		Functions: []*localv1.LocalFunctionDescriptor{{Id: "player.ban"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	go agentApp.RunTunnel(ctx, edgeAddr, "g1", "")
+	for len(edgeApp.Hub().Agents()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("agent tunnel not opened")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	s := newTestContext(t, &registry.AgentSession{AgentID: "a1", GameID: "g1", EdgeAddr: edgeAddr})
+	s.Config.Server.Cert, s.Config.Server.Key, s.Config.Server.CA = srvCrt, srvKey, caCrt
+	res, err := s.InvokeFunction(ctx, InvokeInput{FunctionID: "player.ban", GameID: "g1", Payload: []byte(`{"player_id":"1"}`)})
+	if err != nil {
+		t.Fatalf("invoke agent through the edge: %v", err)
+	}
+	if res.AgentID != "a1" || string(res.Payload) != `{"player_id":"1"}` {
+		t.Fatalf("invoke result %+v", res)
+	}
+
+	// another agent's certificate may open its own tunnel but not drive a1
+	other := newTestContext(t, &registry.AgentSession{AgentID: "a1", GameID: "g1", EdgeAddr: edgeAddr})
+	otherCrt, otherKey := filepath.Join(agentDir, "other.crt"), filepath.Join(agentDir, "other.key")
+	certPEM, keyPEM = issueAgentCert(t, caCrt, caKey, "a2")
+	if err := os.WriteFile(otherCrt, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(otherKey, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	other.Config.Server.Cert, other.Config.Server.Key, other.Config.Server.CA = otherCrt, otherKey, caCrt
+	if _, err := other.InvokeFunction(ctx, InvokeInput{FunctionID: "player.ban", GameID: "g1"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("invoke through the edge with an agent certificate: %v, want PermissionDenied", err)
+	}
+}
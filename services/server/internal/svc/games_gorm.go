@@ -0,0 +1,58 @@
+package svc
+
+import (
+	"context"
+
+	"github.com/cuihairu/croupier/internal/ports"
+	gamesgorm "github.com/cuihairu/croupier/internal/repo/gorm/games"
+	"gorm.io/gorm"
+)
+
+// gormGamesRepo keeps games in the server database so that their IDs, which user
+// game grants refer to, survive restarts. It reports missing games as
+// ErrGameNotFound like the memory repository.
+type gormGamesRepo struct {
+	*gamesgorm.PortRepo
+}
+
+func newGormGamesRepo(gdb *gorm.DB) (*gormGamesRepo, error) {
+	if err := gamesgorm.AutoMigrate(gdb); err != nil {
+		return nil, err
+	}
+	return &gormGamesRepo{PortRepo: gamesgorm.NewPortRepo(gamesgorm.NewRepo(gdb))}, nil
+}
+
+func (g *gormGamesRepo) Update(ctx context.Context, game *ports.Game) error {
+	return notFound(g.PortRepo.Update(ctx, game), ErrGameNotFound)
+}
+
+func (g *gormGamesRepo) Get(ctx context.Context, id uint) (*ports.Game, error) {
+	game, err := g.PortRepo.Get(ctx, id)
+	return game, notFound(err, ErrGameNotFound)
+}
+
+func (g *gormGamesRepo) ListEnvs(ctx context.Context, gameID uint) ([]string, error) {
+	envs, err := g.PortRepo.ListEnvs(ctx, gameID)
+	return envs, notFound(err, ErrGameNotFound)
+}
+
+func (g *gormGamesRepo) ListEnvRecords(ctx context.Context, gameID uint) ([]*ports.GameEnvDef, error) {
+	recs, err := g.PortRepo.ListEnvRecords(ctx, gameID)
+	return recs, notFound(err, ErrGameNotFound)
+}
+
+func (g *gormGamesRepo) AddEnv(ctx context.Context, gameID uint, env string) error {
+	return g.AddEnvWithMeta(ctx, gameID, env, "", "")
+}
+
+func (g *gormGamesRepo) AddEnvWithMeta(ctx context.Context, gameID uint, env, desc, color string) error {
+	return notFound(g.PortRepo.AddEnvWithMeta(ctx, gameID, env, desc, color), ErrGameNotFound)
+}
+
+func (g *gormGamesRepo) UpdateEnv(ctx context.Context, gameID uint, oldEnv, newEnv, desc, color string) error {
+	return notFound(g.PortRepo.UpdateEnv(ctx, gameID, oldEnv, newEnv, desc, color), ErrGameNotFound)
+}
+
+func (g *gormGamesRepo) RemoveEnv(ctx context.Context, gameID uint, env string) error {
+	return notFound(g.PortRepo.RemoveEnv(ctx, gameID, env), ErrGameNotFound)
+}
@@ -3,12 +3,15 @@ package svc
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/cuihairu/croupier/internal/ports"
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
 )
 
 type memoryGamesRepo struct {
@@ -18,6 +21,19 @@ type memoryGamesRepo struct {
 	envs  map[uint]map[string]ports.GameEnvDef
 }
 
+// newGamesRepo keeps games in gdb, or in memory without a database. User game grants
+// refer to game IDs, so games must live as long as the users that hold the grants.
+func newGamesRepo(gdb *gorm.DB) ports.GamesRepository {
+	if gdb == nil {
+		return newMemoryGamesRepo()
+	}
+	repo, err := newGormGamesRepo(gdb)
+	if err != nil {
+		logx.Must(fmt.Errorf("init games repository: %w", err))
+	}
+	return repo
+}
+
 func newMemoryGamesRepo() *memoryGamesRepo {
 	return &memoryGamesRepo{
 		next:  1,
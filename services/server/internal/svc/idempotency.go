@@ -0,0 +1,97 @@
+package svc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/platform/idempotency"
+	"github.com/zeromicro/go-zero/core/logx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// defaultIdempotencyTTL is how long a completed request is replayed for retries.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// Fingerprint identifies the request body an idempotency key was first used with.
+func (in InvokeInput) Fingerprint() string {
+	h := sha256.New()
+	for _, part := range []string{in.Route, in.TargetServiceID, in.HashKey} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(in.Payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotent runs fn at most once per (function, game, env, idempotency key) within
+// the TTL. Retries get the cached record back with replayed=true; a retry while the
+// first request is still running fails with idempotency.ErrInProgress. A failure that
+// may have reached the game server, i.e. a timeout or a lost agent, is stored as an
+// unknown outcome and its retries fail with idempotency.ErrOutcomeUnknown instead of
+// running again; other failures release the key. Requests without a key always run.
+func (s *ServiceContext) Idempotent(ctx context.Context, in InvokeInput, fn func() (*idempotency.Record, error)) (rec *idempotency.Record, replayed bool, err error) {
+	if in.IdempotencyKey == "" || s.idempotency == nil {
+		rec, err = fn()
+		return rec, false, err
+	}
+	key := idempotency.Key(in.FunctionID, in.GameID, in.Env, in.IdempotencyKey)
+	fp := in.Fingerprint()
+	cached, err := s.idempotency.Reserve(ctx, key, fp, defaultIdempotencyTTL)
+	if err != nil {
+		return nil, false, err
+	}
+	if cached != nil {
+		if cached.Unknown {
+			return nil, false, idempotency.ErrOutcomeUnknown
+		}
+		return cached, true, nil
+	}
+	rec, err = fn()
+	if err != nil {
+		if outcomeUnknown(err) {
+			unknown := idempotency.Record{Fingerprint: fp, Unknown: true}
+			if cerr := s.idempotency.Complete(context.Background(), key, unknown, defaultIdempotencyTTL); cerr != nil {
+				logx.Errorf("record unknown outcome of %s: %v", key, cerr)
+			}
+			return nil, false, err
+		}
+		_ = s.idempotency.Release(context.Background(), key)
+		return nil, false, err
+	}
+	rec.Fingerprint = fp
+	if err := s.idempotency.Complete(context.Background(), key, *rec, defaultIdempotencyTTL); err != nil {
+		return nil, false, err
+	}
+	return rec, false, nil
+}
+
+// outcomeUnknown reports whether a failed call may still have taken effect.
+func outcomeUnknown(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.Unavailable:
+		return true
+	}
+	return false
+}
+
+// newIdempotencyStore keeps idempotency keys in the database when one is configured,
+// so retries are deduplicated across replicas and restarts.
+func newIdempotencyStore(gdb *gorm.DB) idempotency.Store {
+	if gdb == nil {
+		return idempotency.NewMemStore()
+	}
+	store, err := idempotency.NewGormStore(gdb)
+	if err != nil {
+		logx.Must(fmt.Errorf("migrate idempotency table: %w", err))
+	}
+	return store
+}
@@ -0,0 +1,48 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cuihairu/croupier/internal/platform/idempotency"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIdempotentKeepsUnknownOutcomes(t *testing.T) {
+	s := &ServiceContext{idempotency: idempotency.NewMemStore()}
+	ctx := context.Background()
+	in := InvokeInput{FunctionID: "wallet.grant", GameID: "g1", IdempotencyKey: "k1", Payload: []byte(`{}`)}
+	runs := 0
+	call := func(err error) func() (*idempotency.Record, error) {
+		return func() (*idempotency.Record, error) {
+			runs++
+			if err != nil {
+				return nil, err
+			}
+			return &idempotency.Record{Payload: []byte(`{"ok":true}`)}, nil
+		}
+	}
+
+	// A failure known not to have reached the game server may be retried.
+	if _, _, err := s.Idempotent(ctx, in, call(ErrNoAgentAvailable)); !errors.Is(err, ErrNoAgentAvailable) {
+		t.Fatal(err)
+	}
+	if rec, replayed, err := s.Idempotent(ctx, in, call(nil)); err != nil || replayed || string(rec.Payload) != `{"ok":true}` {
+		t.Fatalf("retry after release = %+v, %v, %v", rec, replayed, err)
+	}
+
+	// A timeout may have taken effect; retries must not run the call again.
+	in.IdempotencyKey = "k2"
+	if _, _, err := s.Idempotent(ctx, in, call(status.Error(codes.DeadlineExceeded, "timeout"))); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	before := runs
+	if _, _, err := s.Idempotent(ctx, in, call(nil)); !errors.Is(err, idempotency.ErrOutcomeUnknown) {
+		t.Fatalf("retry after timeout: %v, want ErrOutcomeUnknown", err)
+	}
+	if runs != before {
+		t.Fatal("retry after timeout ran the call again")
+	}
+}
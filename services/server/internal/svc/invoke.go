@@ -0,0 +1,540 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/connpool"
+	"github.com/cuihairu/croupier/internal/function/route"
+	"github.com/cuihairu/croupier/internal/jobs"
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	localv1 "github.com/cuihairu/croupier/pkg/pb/croupier/agent/local/v1"
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	"github.com/zeromicro/go-zero/core/logx"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultInvokeTimeout = 10 * time.Second
+	defaultJobTimeout    = 30 * time.Minute
+)
+
+var ErrNoAgentAvailable = errors.New("no agent available")
+
+// InvokeInput describes a function call that should be forwarded to an agent.
+type InvokeInput struct {
+	FunctionID      string
+	GameID          string
+	Env             string
+	Actor           string
+	IdempotencyKey  string
+	Route           string
+	TargetServiceID string
+	HashKey         string
+	Payload         []byte
+	Timeout         time.Duration
+}
+
+// InvokeResult carries the agent response of a synchronous invocation.
+type InvokeResult struct {
+	Payload []byte
+	AgentID string
+	RPCAddr string
+	TraceID string
+}
+
+func (in InvokeInput) request(traceID string) *functionv1.InvokeRequest {
+	meta := map[string]string{}
+	setMeta := func(k, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			meta[k] = v
+		}
+	}
+	setMeta("game_id", in.GameID)
+	setMeta("env", in.Env)
+	setMeta("actor", in.Actor)
+	setMeta("route", in.Route)
+	setMeta("target_service_id", in.TargetServiceID)
+	setMeta("hash_key", in.HashKey)
+	setMeta("trace_id", traceID)
+	return &functionv1.InvokeRequest{
+		FunctionId:     in.FunctionID,
+		IdempotencyKey: in.IdempotencyKey,
+		Payload:        in.Payload,
+		Metadata:       meta,
+	}
+}
+
+// agentConnPool returns the pool of connections to agentID. Each agent has its own
+// pool, dialing over TLS that accepts only a certificate of the Croupier CA issued to
+// that agent, so an address taken over by another agent is refused rather than
+// reused. With Server.AgentInsecure agents are dialed in plaintext.
+func (s *ServiceContext) agentConnPool(agentID string) (connpool.ConnectionPool, error) {
+	if agentID == "" {
+		return nil, errors.New("agent id required to dial an agent")
+	}
+	s.agentConnsMu.Lock()
+	defer s.agentConnsMu.Unlock()
+	if pool, ok := s.agentConns[agentID]; ok {
+		return pool, nil
+	}
+	cfg := &connpool.PoolConfig{DialTimeout: 5 * time.Second}
+	if s.Config.Server.AgentInsecure {
+		s.warnAgentInsecure()
+		cfg.InsecureSkipVerify = true
+	} else {
+		tc, err := s.agentTLSConfig(agentID)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = tc
+	}
+	if s.agentConns == nil {
+		s.agentConns = map[string]connpool.ConnectionPool{}
+	}
+	pool := connpool.NewConnectionPool(cfg)
+	s.agentConns[agentID] = pool
+	return pool, nil
+}
+
+// edgeConnPool returns the pool of connections to the edges holding the tunnels of
+// agents that accept no inbound calls. Edges are dialed over mTLS with the server
+// certificate, or in plaintext with Server.AgentInsecure.
+func (s *ServiceContext) edgeConnPool() (connpool.ConnectionPool, error) {
+	s.agentConnsMu.Lock()
+	defer s.agentConnsMu.Unlock()
+	if s.edgeConns != nil {
+		return s.edgeConns, nil
+	}
+	cfg := &connpool.PoolConfig{DialTimeout: 5 * time.Second}
+	if s.Config.Server.AgentInsecure {
+		s.warnAgentInsecure()
+		cfg.InsecureSkipVerify = true
+	} else {
+		tc, err := s.edgeTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = tc
+	}
+	s.edgeConns = connpool.NewConnectionPool(cfg)
+	return s.edgeConns, nil
+}
+
+func (s *ServiceContext) warnAgentInsecure() {
+	s.agentInsecureOnce.Do(func() {
+		logx.Errorf("server.agent_insecure is set: calls to agents are neither encrypted nor authenticated")
+	})
+}
+
+// closeAgentConns drops the connections to agentID.
+func (s *ServiceContext) closeAgentConns(agentID string) {
+	s.agentConnsMu.Lock()
+	pool, ok := s.agentConns[agentID]
+	delete(s.agentConns, agentID)
+	s.agentConnsMu.Unlock()
+	if ok {
+		_ = pool.Close()
+	}
+}
+
+// AgentConn returns a pooled connection to agentID at addr. It must not be closed.
+func (s *ServiceContext) AgentConn(ctx context.Context, agentID, addr string) (*grpc.ClientConn, error) {
+	pool, err := s.agentConnPool(agentID)
+	if err != nil {
+		return nil, err
+	}
+	return pool.Get(ctx, addr)
+}
+
+// SelectAgent picks a live agent serving the function of in for its game/env.
+// Agents registered without a game or env act as wildcards for that dimension.
+// Targeted calls go to the agent hosting in.TargetServiceID and hash calls to the
+// agent their hash key maps to; other calls rotate over the agents.
+func (s *ServiceContext) SelectAgent(ctx context.Context, in InvokeInput) (*registry.AgentSession, error) {
+	live := s.liveAgents(in.FunctionID, in.GameID, in.Env)
+	if len(live) == 0 {
+		return nil, ErrNoAgentAvailable
+	}
+	switch route.Mode(in.Route) {
+	case route.Targeted:
+		return s.targetAgent(ctx, live, in)
+	case route.Hash:
+		key, err := route.HashValue(in.HashKey, in.Payload)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		ids := make([]string, len(live))
+		for i, a := range live {
+			ids[i] = a.AgentID
+		}
+		return &live[route.Pick(ids, key)], nil
+	default:
+		idx := atomic.AddUint64(&s.agentRR, 1) % uint64(len(live))
+		return &live[idx], nil
+	}
+}
+
+// liveAgents returns the unexpired, undrained agents serving functionID for
+// gameID/env, ordered by agent id.
+func (s *ServiceContext) liveAgents(functionID, gameID, env string) []registry.AgentSession {
+	if s.RegistryStore == nil || strings.TrimSpace(functionID) == "" {
+		return nil
+	}
+	now := time.Now()
+	candidates := make([]registry.AgentSession, 0, 4)
+	s.RegistryStore.Mu().RLock()
+	for _, a := range s.RegistryStore.AgentsUnsafe() {
+		if a == nil || (strings.TrimSpace(a.RPCAddr) == "" && strings.TrimSpace(a.EdgeAddr) == "") {
+			continue
+		}
+		if !a.ExpireAt.IsZero() && now.After(a.ExpireAt) {
+			continue
+		}
+		if a.GameID != "" && a.GameID != gameID {
+			continue
+		}
+		if a.Env != "" && env != "" && a.Env != env {
+			continue
+		}
+		if meta, ok := a.Functions[functionID]; !ok || !meta.Enabled {
+			continue
+		}
+		candidates = append(candidates, *a)
+	}
+	s.RegistryStore.Mu().RUnlock()
+
+	live := candidates[:0]
+	for _, a := range candidates {
+		if s.NodeDraining(a.AgentID) {
+			continue
+		}
+		live = append(live, a)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].AgentID < live[j].AgentID })
+	return live
+}
+
+// targetAgent returns the agent named by in.TargetServiceID, or else the one whose
+// game server instances include that service.
+func (s *ServiceContext) targetAgent(ctx context.Context, live []registry.AgentSession, in InvokeInput) (*registry.AgentSession, error) {
+	target := strings.TrimSpace(in.TargetServiceID)
+	if target == "" {
+		return nil, status.Error(codes.InvalidArgument, route.ErrNoTarget.Error())
+	}
+	for i := range live {
+		if live[i].AgentID == target {
+			return &live[i], nil
+		}
+	}
+	for i := range live {
+		if s.agentHostsService(ctx, live[i], in.FunctionID, target) {
+			return &live[i], nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "target service %s not found for function %s", target, in.FunctionID)
+}
+
+// agentHostsService asks the agent for its instances of functionID. Agents behind
+// an edge accept no inbound calls and are only targeted by agent id.
+func (s *ServiceContext) agentHostsService(ctx context.Context, agent registry.AgentSession, functionID, serviceID string) bool {
+	if agent.RPCAddr == "" {
+		return false
+	}
+	cc, err := s.AgentConn(ctx, agent.AgentID, agent.RPCAddr)
+	if err != nil {
+		return false
+	}
+	cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := localv1.NewLocalControlServiceClient(cc).ListLocal(cctx, &localv1.ListLocalRequest{})
+	if err != nil {
+		logx.Errorf("list instances of agent %s: %v", agent.AgentID, err)
+		return false
+	}
+	for _, fn := range resp.GetFunctions() {
+		if fn.GetId() != functionID {
+			continue
+		}
+		for _, inst := range fn.GetInstances() {
+			if inst.GetServiceId() == serviceID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FunctionRegistered reports whether any registered agent exposes the function.
+func (s *ServiceContext) FunctionRegistered(functionID string) bool {
+	if s.RegistryStore == nil || functionID == "" {
+		return false
+	}
+	s.RegistryStore.Mu().RLock()
+	defer s.RegistryStore.Mu().RUnlock()
+	for _, a := range s.RegistryStore.AgentsUnsafe() {
+		if a == nil {
+			continue
+		}
+		if _, ok := a.Functions[functionID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// functionClient returns the FunctionService of agentID, called on addr or, when
+// edgeAddr is set, through the edge holding the agent's tunnel.
+func (s *ServiceContext) functionClient(ctx context.Context, agentID, addr, edgeAddr string) (functionv1.FunctionServiceClient, error) {
+	if edgeAddr != "" {
+		pool, err := s.edgeConnPool()
+		if err != nil {
+			return nil, err
+		}
+		cc, err := pool.Get(ctx, edgeAddr)
+		if err != nil {
+			return nil, err
+		}
+		return edgeFunctionClient{FunctionServiceClient: functionv1.NewFunctionServiceClient(cc), agentID: agentID}, nil
+	}
+	cc, err := s.AgentConn(ctx, agentID, addr)
+	if err != nil {
+		return nil, err
+	}
+	return functionv1.NewFunctionServiceClient(cc), nil
+}
+
+// edgeFunctionClient names the agent in the metadata of the calls it sends to an
+// edge, which forwards them over that agent's tunnel. Job streams and cancels are
+// routed by the edge from the job id.
+type edgeFunctionClient struct {
+	functionv1.FunctionServiceClient
+	agentID string
+}
+
+func (c edgeFunctionClient) Invoke(ctx context.Context, in *functionv1.InvokeRequest, opts ...grpc.CallOption) (*functionv1.InvokeResponse, error) {
+	return c.FunctionServiceClient.Invoke(ctx, c.route(in), opts...)
+}
+
+func (c edgeFunctionClient) StartJob(ctx context.Context, in *functionv1.InvokeRequest, opts ...grpc.CallOption) (*functionv1.StartJobResponse, error) {
+	return c.FunctionServiceClient.StartJob(ctx, c.route(in), opts...)
+}
+
+func (c edgeFunctionClient) route(in *functionv1.InvokeRequest) *functionv1.InvokeRequest {
+	out := proto.Clone(in).(*functionv1.InvokeRequest)
+	if out.Metadata == nil {
+		out.Metadata = map[string]string{}
+	}
+	out.Metadata["agent_id"] = c.agentID
+	return out
+}
+
+// InvokeFunction forwards a synchronous invocation to an agent and returns its response.
+func (s *ServiceContext) InvokeFunction(ctx context.Context, in InvokeInput) (*InvokeResult, error) {
+	atomic.AddInt64(&s.invocations, 1)
+	res, err := s.invokeFunction(ctx, in)
+	if err != nil {
+		atomic.AddInt64(&s.invocationsError, 1)
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *ServiceContext) invokeFunction(ctx context.Context, in InvokeInput) (*InvokeResult, error) {
+	if route.Mode(in.Route) == route.Broadcast {
+		return s.broadcastFunction(ctx, in)
+	}
+	agent, err := s.SelectAgent(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRateLimit(ctx, in, agent); err != nil {
+		return nil, err
+	}
+	cli, err := s.functionClient(ctx, agent.AgentID, agent.RPCAddr, agent.EdgeAddr)
+	if err != nil {
+		return nil, err
+	}
+	timeout := in.Timeout
+	if timeout <= 0 {
+		timeout = defaultInvokeTimeout
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	traceID := traceIDFromContext(ctx)
+	resp, err := cli.Invoke(cctx, in.request(traceID))
+	if err != nil {
+		return nil, err
+	}
+	return &InvokeResult{
+		Payload: resp.GetPayload(),
+		AgentID: agent.AgentID,
+		RPCAddr: agent.RPCAddr,
+		TraceID: traceID,
+	}, nil
+}
+
+// broadcastFunction invokes every live agent serving the function, each fanning
+// out to its own instances, and merges their results into
+// {"results":[{agent_id, service_id, addr, payload, error}]}.
+func (s *ServiceContext) broadcastFunction(ctx context.Context, in InvokeInput) (*InvokeResult, error) {
+	live := s.liveAgents(in.FunctionID, in.GameID, in.Env)
+	if len(live) == 0 {
+		return nil, ErrNoAgentAvailable
+	}
+	timeout := in.Timeout
+	if timeout <= 0 {
+		timeout = defaultInvokeTimeout
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	traceID := traceIDFromContext(ctx)
+	parts := make([][]map[string]any, len(live))
+	var wg sync.WaitGroup
+	for i := range live {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			parts[i] = s.broadcastAgent(cctx, &live[i], in, traceID)
+		}(i)
+	}
+	wg.Wait()
+	results := make([]map[string]any, 0, len(live))
+	for _, p := range parts {
+		results = append(results, p...)
+	}
+	payload, err := json.Marshal(map[string]any{"results": results})
+	if err != nil {
+		return nil, err
+	}
+	return &InvokeResult{Payload: payload, TraceID: traceID}, nil
+}
+
+// broadcastAgent returns the per-instance results of one agent, or a single
+// entry carrying the error when the agent could not be called.
+func (s *ServiceContext) broadcastAgent(ctx context.Context, agent *registry.AgentSession, in InvokeInput, traceID string) []map[string]any {
+	fail := func(err error) []map[string]any {
+		return []map[string]any{{"agent_id": agent.AgentID, "error": err.Error()}}
+	}
+	if err := s.checkRateLimit(ctx, in, agent); err != nil {
+		return fail(err)
+	}
+	cli, err := s.functionClient(ctx, agent.AgentID, agent.RPCAddr, agent.EdgeAddr)
+	if err != nil {
+		return fail(err)
+	}
+	resp, err := cli.Invoke(ctx, in.request(traceID))
+	if err != nil {
+		return fail(err)
+	}
+	var out struct {
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.Unmarshal(resp.GetPayload(), &out); err != nil {
+		return fail(fmt.Errorf("unexpected broadcast response: %w", err))
+	}
+	for _, r := range out.Results {
+		r["agent_id"] = agent.AgentID
+	}
+	return out.Results
+}
+
+// StartFunctionJob asks an agent to start a job and tracks its lifecycle.
+func (s *ServiceContext) StartFunctionJob(ctx context.Context, in InvokeInput) (*JobInfo, error) {
+	atomic.AddInt64(&s.jobsStarted, 1)
+	info, err := s.startFunctionJob(ctx, in)
+	if err != nil {
+		atomic.AddInt64(&s.jobsError, 1)
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *ServiceContext) startFunctionJob(ctx context.Context, in InvokeInput) (*JobInfo, error) {
+	agent, err := s.SelectAgent(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRateLimit(ctx, in, agent); err != nil {
+		return nil, err
+	}
+	cli, err := s.functionClient(ctx, agent.AgentID, agent.RPCAddr, agent.EdgeAddr)
+	if err != nil {
+		return nil, err
+	}
+	cctx, cancel := context.WithTimeout(ctx, defaultInvokeTimeout)
+	defer cancel()
+	traceID := traceIDFromContext(ctx)
+	resp, err := cli.StartJob(cctx, in.request(traceID))
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(resp.GetJobId()) == "" {
+		return nil, errors.New("agent returned empty job id")
+	}
+	timeout := in.Timeout
+	if timeout <= 0 {
+		timeout = defaultJobTimeout
+	}
+	job := &jobs.Job{
+		ID:             jobs.NewID(),
+		FunctionID:     in.FunctionID,
+		GameID:         in.GameID,
+		Env:            in.Env,
+		Actor:          in.Actor,
+		IdempotencyKey: in.IdempotencyKey,
+		AgentID:        agent.AgentID,
+		AgentJobID:     resp.GetJobId(),
+		AgentAddr:      agent.RPCAddr,
+		EdgeAddr:       agent.EdgeAddr,
+		TraceID:        traceID,
+		State:          jobs.StateRunning,
+		Timeout:        timeout,
+	}
+	if err := s.jobEngine.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	go s.watchJob(job, cli)
+	return jobInfoFromJob(job), nil
+}
+
+// CancelFunctionJob forwards a cancel request to the agent owning the job.
+func (s *ServiceContext) CancelFunctionJob(ctx context.Context, jobID string) (*JobInfo, error) {
+	job, err := s.jobEngine.Get(ctx, jobID)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	if job.State.Terminal() {
+		return jobInfoFromJob(job), nil
+	}
+	cli, err := s.functionClient(ctx, job.AgentID, job.AgentAddr, job.EdgeAddr)
+	if err != nil {
+		return nil, err
+	}
+	cctx, cancel := context.WithTimeout(ctx, defaultInvokeTimeout)
+	defer cancel()
+	if _, err := cli.CancelJob(cctx, &functionv1.CancelJobRequest{JobId: agentJobID(job)}); err != nil {
+		return nil, err
+	}
+	s.finishJob(jobID, jobs.StateCancelled, "", nil)
+	info, _ := s.JobInfo(jobID)
+	return info, nil
+}
+
+func traceIDFromContext(ctx context.Context) string {
+	if sc := oteltrace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
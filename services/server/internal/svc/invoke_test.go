@@ -0,0 +1,207 @@
+package svc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/devcert"
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	"github.com/cuihairu/croupier/internal/security/agentcert"
+	"github.com/cuihairu/croupier/internal/security/rbac"
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func newTestContext(t *testing.T, agents ...*registry.AgentSession) *ServiceContext {
+	t.Helper()
+	s := &ServiceContext{RegistryStore: registry.NewStore(), authorizer: &policyAuthorizer{policy: rbac.NewPolicy()}}
+	for _, a := range agents {
+		if a.Functions == nil {
+			a.Functions = map[string]registry.FunctionMeta{"player.ban": {Enabled: true}}
+		}
+		if err := s.RegistryStore.UpsertAgent(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestSelectAgentRoutes(t *testing.T) {
+	s := newTestContext(t,
+		&registry.AgentSession{AgentID: "a1", GameID: "g1", RPCAddr: "127.0.0.1:1"},
+		&registry.AgentSession{AgentID: "a2", GameID: "g1", RPCAddr: "127.0.0.1:1"},
+		&registry.AgentSession{AgentID: "a3", GameID: "g1", RPCAddr: "127.0.0.1:1"},
+		&registry.AgentSession{AgentID: "other", GameID: "g2", RPCAddr: "127.0.0.1:1"},
+	)
+	s.Config.Server.AgentInsecure = true
+	ctx := context.Background()
+	in := InvokeInput{FunctionID: "player.ban", GameID: "g1"}
+
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		a, err := s.SelectAgent(ctx, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[a.AgentID] = true
+	}
+	if len(seen) != 3 || seen["other"] {
+		t.Fatalf("lb rotated over %v", seen)
+	}
+
+	hash := in
+	hash.Route, hash.Payload = "hash", []byte(`{"player_id":"1004"}`)
+	first, err := s.SelectAgent(ctx, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if a, _ := s.SelectAgent(ctx, hash); a.AgentID != first.AgentID {
+			t.Fatalf("hash route moved from %s to %s", first.AgentID, a.AgentID)
+		}
+	}
+	hash.HashKey = "account_id"
+	if _, err := s.SelectAgent(ctx, hash); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("missing hash field: %v, want InvalidArgument", err)
+	}
+
+	target := in
+	target.Route, target.TargetServiceID = "targeted", "a2"
+	if a, err := s.SelectAgent(ctx, target); err != nil || a.AgentID != "a2" {
+		t.Fatalf("targeted agent = %v, %v", a, err)
+	}
+	target.TargetServiceID = ""
+	if _, err := s.SelectAgent(ctx, target); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("targeted without target: %v, want InvalidArgument", err)
+	}
+	// No agent is reachable to say it hosts the service.
+	target.TargetServiceID = "shard-9"
+	if _, err := s.SelectAgent(ctx, target); status.Code(err) != codes.NotFound {
+		t.Fatalf("unknown target: %v, want NotFound", err)
+	}
+}
+
+type echoFunctions struct {
+	functionv1.UnimplementedFunctionServiceServer
+}
+
+func (echoFunctions) Invoke(_ context.Context, in *functionv1.InvokeRequest) (*functionv1.InvokeResponse, error) {
+	return &functionv1.InvokeResponse{Payload: in.GetPayload()}, nil
+}
+
+// issueAgentCert returns a certificate for agentID in game g1 issued by the CA
+// caCrt/caKey, and its key.
+func issueAgentCert(t *testing.T, caCrt, caKey, agentID string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	ca, err := agentcert.LoadCA(caCrt, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, keyPEM, err := agentcert.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM, err := agentcert.NewCSR(key, agentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := agentcert.ParseCSR(csrPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, _, err = ca.Issue(csr, agentcert.Identity{AgentID: agentID, Games: []string{"g1"}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certPEM, keyPEM
+}
+
+// serveAgent serves echoFunctions over TLS with a certificate for agentID
+// issued by the CA caCrt/caKey and returns its address.
+func serveAgent(t *testing.T, caCrt, caKey, agentID string) string {
+	t.Helper()
+	cert, err := tls.X509KeyPair(issueAgentCert(t, caCrt, caKey, agentID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})))
+	functionv1.RegisterFunctionServiceServer(srv, echoFunctions{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestInvokeFunctionVerifiesAgentCertificate(t *testing.T) {
+	dir := t.TempDir()
+	caCrt, caKey, err := devcert.EnsureDevCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvCrt, srvKey, err := devcert.EnsureServerCert(dir, caCrt, caKey, []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDir := t.TempDir()
+	otherCrt, otherKey, err := devcert.EnsureDevCA(otherDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted := serveAgent(t, caCrt, caKey, "trusted")
+	s := newTestContext(t,
+		&registry.AgentSession{AgentID: "trusted", GameID: "g1", RPCAddr: trusted},
+		&registry.AgentSession{AgentID: "rogue", GameID: "g2", RPCAddr: serveAgent(t, otherCrt, otherKey, "rogue")},
+		// Registered at the address of another agent, as after the other agent took it over.
+		&registry.AgentSession{AgentID: "moved", GameID: "g3", RPCAddr: trusted},
+	)
+	s.Config.Server.Cert, s.Config.Server.Key, s.Config.Server.CA = srvCrt, srvKey, caCrt
+	ctx := context.Background()
+
+	res, err := s.InvokeFunction(ctx, InvokeInput{FunctionID: "player.ban", GameID: "g1", Payload: []byte(`{"player_id":"1"}`)})
+	if err != nil {
+		t.Fatalf("invoke agent with a Croupier certificate: %v", err)
+	}
+	if res.AgentID != "trusted" || string(res.Payload) != `{"player_id":"1"}` {
+		t.Fatalf("invoke result %+v", res)
+	}
+	if _, err := s.InvokeFunction(ctx, InvokeInput{FunctionID: "player.ban", GameID: "g2"}); status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("invoke agent with a foreign certificate: %v, want Unavailable", err)
+	}
+	if _, err := s.InvokeFunction(ctx, InvokeInput{FunctionID: "player.ban", GameID: "g3"}); status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("invoke agent with the certificate of another agent: %v, want Unavailable", err)
+	}
+}
+
+func TestCanAccessJob(t *testing.T) {
+	s := newTestContext(t)
+	s.authorizer.(*policyAuthorizer).policy.(*rbac.Policy).Grant("role:ops", rbac.Scoped("job:read", "g1", ""))
+	job := &JobInfo{ID: "j1", Actor: "alice", GameID: "g1", Env: "prod"}
+	cases := []struct {
+		user  string
+		roles []string
+		job   *JobInfo
+		want  bool
+	}{
+		{"alice", nil, job, true},
+		{"bob", []string{"ops"}, job, true},
+		{"bob", nil, job, false},
+		{"bob", []string{"ops"}, &JobInfo{ID: "j2", Actor: "alice", GameID: "g2", Env: "prod"}, false},
+		{"", nil, &JobInfo{ID: "j3", GameID: "g2"}, false},
+	}
+	for _, c := range cases {
+		if got := s.CanAccessJob(c.user, c.roles, c.job, "job:read"); got != c.want {
+			t.Errorf("CanAccessJob(%s, %v, %s) = %v, want %v", c.user, c.roles, c.job.ID, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/jobs"
+	jobsgorm "github.com/cuihairu/croupier/internal/repo/gorm/jobs"
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	"github.com/zeromicro/go-zero/core/logx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// maxListedJobs bounds the jobs returned by JobsSnapshot.
+const maxListedJobs = 1000
+
+var ErrJobNotFound = errors.New("job not found")
+
+// newJobEngine persists jobs in gdb when available, in memory otherwise.
+func newJobEngine(gdb *gorm.DB) *jobs.Engine {
+	if gdb == nil {
+		return jobs.NewEngine(jobs.NewMemStore())
+	}
+	if err := jobsgorm.AutoMigrate(gdb); err != nil {
+		logx.Must(fmt.Errorf("migrate job tables: %w", err))
+	}
+	return jobs.NewEngine(jobsgorm.New(gdb))
+}
+
+// JobEngine returns the job engine backing job tracking and streaming.
+func (s *ServiceContext) JobEngine() *jobs.Engine { return s.jobEngine }
+
+// ResumeJobs re-arms job deadlines after a restart and re-attaches to the agent
+// event streams of jobs that were still running.
+func (s *ServiceContext) ResumeJobs(ctx context.Context) {
+	s.jobEngine.OnTimeout(s.cancelTimedOutJob)
+	active, err := s.jobEngine.Recover(ctx)
+	if err != nil {
+		logx.Errorf("recover jobs: %v", err)
+		return
+	}
+	for _, job := range active {
+		if job.AgentAddr == "" && job.EdgeAddr == "" {
+			continue
+		}
+		cli, err := s.functionClient(ctx, job.AgentID, job.AgentAddr, job.EdgeAddr)
+		if err != nil {
+			s.finishJob(job.ID, jobs.StateFailed, "agent unreachable after restart: "+err.Error(), nil)
+			continue
+		}
+		go s.watchJob(job, cli)
+	}
+	if len(active) > 0 {
+		logx.Infof("resumed %d running jobs", len(active))
+	}
+}
+
+// cancelTimedOutJob asks the owning agent to stop a job that exceeded its timeout.
+func (s *ServiceContext) cancelTimedOutJob(job *jobs.Job) {
+	atomic.AddInt64(&s.jobsError, 1)
+	if job.AgentAddr == "" && job.EdgeAddr == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultInvokeTimeout)
+	defer cancel()
+	cli, err := s.functionClient(ctx, job.AgentID, job.AgentAddr, job.EdgeAddr)
+	if err != nil {
+		return
+	}
+	if _, err := cli.CancelJob(ctx, &functionv1.CancelJobRequest{JobId: agentJobID(job)}); err != nil {
+		logx.Errorf("cancel timed out job %s: %v", job.ID, err)
+	}
+}
+
+// watchJobAttempts bounds how often watchJob attaches to a job stream that keeps
+// ending without a result; watchJobBackoff grows the wait between attempts.
+const watchJobAttempts = 3
+
+var watchJobBackoff = time.Second
+
+// watchJob follows the agent job stream and records progress and the final outcome
+// until the job finishes or its deadline passes. Only a done event marks the job
+// succeeded: a stream that ends without one, e.g. when the agent restarts or the
+// connection drops, is attached to again, and the job fails if that keeps happening.
+func (s *ServiceContext) watchJob(job *jobs.Job, cli functionv1.FunctionServiceClient) {
+	jobID := job.ID
+	ctx := context.Background()
+	if !job.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, job.Deadline)
+		defer cancel()
+	}
+	for attempt := 1; ; attempt++ {
+		received, err := s.followJob(ctx, job, cli)
+		if err == nil {
+			return
+		}
+		switch {
+		case ctx.Err() != nil:
+			// the engine moves the job to timed_out at its deadline
+			return
+		case status.Code(err) == codes.Unimplemented:
+			return
+		case status.Code(err) == codes.NotFound:
+			s.finishJob(jobID, jobs.StateFailed, "job no longer tracked by agent", nil)
+			return
+		case err != io.EOF && status.Code(err) != codes.Unavailable:
+			s.finishJob(jobID, jobs.StateFailed, err.Error(), nil)
+			return
+		}
+		if received {
+			attempt = 1
+		}
+		if attempt >= watchJobAttempts {
+			s.finishJob(jobID, jobs.StateFailed, "agent job stream ended without a result", nil)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(attempt) * watchJobBackoff):
+		}
+	}
+}
+
+// followJob records the events of one agent job stream. It returns nil once the
+// job finished, and io.EOF if the stream ended before; received reports whether
+// any event arrived.
+func (s *ServiceContext) followJob(ctx context.Context, job *jobs.Job, cli functionv1.FunctionServiceClient) (received bool, err error) {
+	jobID := job.ID
+	stream, err := cli.StreamJob(ctx, &functionv1.JobStreamRequest{JobId: agentJobID(job)})
+	if err != nil {
+		return false, err
+	}
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			return received, err
+		}
+		received = true
+		switch ev.GetType() {
+		case jobs.EventProgress:
+			_ = s.jobEngine.Progress(ctx, jobID, ev.GetProgress(), ev.GetMessage())
+		case jobs.EventLog:
+			_ = s.jobEngine.Log(ctx, jobID, ev.GetMessage())
+		case jobs.EventDone:
+			s.finishJob(jobID, jobs.StateSucceeded, "", ev.GetPayload())
+			return true, nil
+		case jobs.EventError:
+			s.finishJob(jobID, jobs.StateFailed, ev.GetMessage(), ev.GetPayload())
+			return true, nil
+		}
+	}
+}
+
+// agentJobID is the id the owning agent knows job by; jobs recorded before server
+// ids were assigned used it as their id.
+func agentJobID(job *jobs.Job) string {
+	if job.AgentJobID != "" {
+		return job.AgentJobID
+	}
+	return job.ID
+}
+
+func (s *ServiceContext) finishJob(jobID string, state jobs.State, errMsg string, result []byte) {
+	if _, err := s.jobEngine.Finish(context.Background(), jobID, state, result, errMsg); err != nil {
+		if !errors.Is(err, jobs.ErrInvalidTransition) {
+			logx.Errorf("finish job %s: %v", jobID, err)
+		}
+		return
+	}
+	if state == jobs.StateFailed {
+		atomic.AddInt64(&s.jobsError, 1)
+	}
+}
+
+// JobInfo returns a tracked job.
+func (s *ServiceContext) JobInfo(jobID string) (*JobInfo, bool) {
+	job, err := s.jobEngine.Get(context.Background(), jobID)
+	if err != nil {
+		return nil, false
+	}
+	return jobInfoFromJob(job), true
+}
+
+// CanAccessJob lets the job's owner through, and others holding perm within the
+// job's game/env.
+func (s *ServiceContext) CanAccessJob(user string, roles []string, job *JobInfo, perm string) bool {
+	if user != "" && job.Actor == user {
+		return true
+	}
+	return s.EnforceScopedPermission(user, roles, perm, job.GameID, job.Env)
+}
+
+// JobsSnapshot returns the most recent jobs keyed by id, with ids ordered oldest first.
+func (s *ServiceContext) JobsSnapshot() (map[string]*JobInfo, []string) {
+	list, _, err := s.jobEngine.List(context.Background(), jobs.ListOptions{Limit: maxListedJobs})
+	if err != nil {
+		logx.Errorf("list jobs: %v", err)
+	}
+	data := make(map[string]*JobInfo, len(list))
+	order := make([]string, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		data[list[i].ID] = jobInfoFromJob(list[i])
+		order = append(order, list[i].ID)
+	}
+	return data, order
+}
+
+func jobInfoFromJob(job *jobs.Job) *JobInfo {
+	info := &JobInfo{
+		ID:         job.ID,
+		FunctionID: job.FunctionID,
+		Actor:      job.Actor,
+		GameID:     job.GameID,
+		Env:        job.Env,
+		State:      string(job.State),
+		StartedAt:  job.StartedAt,
+		EndedAt:    job.EndedAt,
+		Error:      job.Error,
+		RPCAddr:    job.AgentAddr,
+		TraceID:    job.TraceID,
+		Progress:   job.Progress,
+		Result:     job.Result,
+	}
+	if !job.EndedAt.IsZero() && !job.StartedAt.IsZero() {
+		info.DurationMs = job.EndedAt.Sub(job.StartedAt).Milliseconds()
+	}
+	return info
+}
@@ -0,0 +1,91 @@
+package svc
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/jobs"
+	functionv1 "github.com/cuihairu/croupier/pkg/pb/croupier/function/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scriptedJobs answers each StreamJob call with the next script: its events,
+// then io.EOF.
+type scriptedJobs struct {
+	functionv1.FunctionServiceClient
+	scripts [][]*functionv1.JobEvent
+	calls   int
+}
+
+func (c *scriptedJobs) StreamJob(_ context.Context, in *functionv1.JobStreamRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[functionv1.JobEvent], error) {
+	if !strings.HasPrefix(in.GetJobId(), "agent-") {
+		return nil, status.Errorf(codes.NotFound, "job %s", in.GetJobId())
+	}
+	var evs []*functionv1.JobEvent
+	if c.calls < len(c.scripts) {
+		evs = c.scripts[c.calls]
+	}
+	c.calls++
+	return &scriptedStream{evs: evs}, nil
+}
+
+type scriptedStream struct {
+	grpc.ClientStream
+	evs []*functionv1.JobEvent
+}
+
+func (s *scriptedStream) Recv() (*functionv1.JobEvent, error) {
+	if len(s.evs) == 0 {
+		return nil, io.EOF
+	}
+	ev := s.evs[0]
+	s.evs = s.evs[1:]
+	return ev, nil
+}
+
+func TestWatchJobNeedsDoneEvent(t *testing.T) {
+	watchJobBackoff = time.Millisecond
+	defer func() { watchJobBackoff = time.Second }()
+	ctx := context.Background()
+	s := newTestContext(t)
+	s.jobEngine = jobs.NewEngine(jobs.NewMemStore())
+	defer s.jobEngine.Close()
+
+	start := func(id string) *jobs.Job {
+		t.Helper()
+		job := &jobs.Job{ID: id, AgentJobID: "agent-" + id, State: jobs.StateRunning}
+		if err := s.jobEngine.Create(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+
+	// The stream drops twice before the agent reports the result.
+	j1 := start("j1")
+	cli := &scriptedJobs{scripts: [][]*functionv1.JobEvent{
+		{{Type: jobs.EventProgress, Progress: 50}},
+		nil,
+		{{Type: jobs.EventDone, Payload: []byte("ok")}},
+	}}
+	s.watchJob(j1, cli)
+	job, err := s.jobEngine.Get(ctx, "j1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != jobs.StateSucceeded || string(job.Result) != "ok" || cli.calls != 3 {
+		t.Fatalf("job %s with result %q after %d streams, want succeeded with ok after 3", job.State, job.Result, cli.calls)
+	}
+
+	// A stream that keeps ending without a result fails the job.
+	j2 := start("j2")
+	cli = &scriptedJobs{}
+	s.watchJob(j2, cli)
+	if job, _ = s.jobEngine.Get(ctx, "j2"); job.State != jobs.StateFailed || cli.calls != watchJobAttempts {
+		t.Fatalf("job %s after %d streams, want failed after %d", job.State, cli.calls, watchJobAttempts)
+	}
+}
@@ -0,0 +1,55 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrMaintenance is matched by errors returned when a maintenance window blocks writes.
+var ErrMaintenance = errors.New("maintenance in progress")
+
+// MaintenanceError reports the window that blocks a write.
+type MaintenanceError struct {
+	Window MaintenanceWindow
+}
+
+func (e *MaintenanceError) Error() string {
+	msg := fmt.Sprintf("writes are blocked by maintenance until %s", e.Window.End.UTC().Format(time.RFC3339))
+	if e.Window.Message != "" {
+		msg += ": " + e.Window.Message
+	}
+	return msg
+}
+
+func (e *MaintenanceError) Is(target error) bool { return target == ErrMaintenance }
+
+// RetryAfter returns how long until the blocking window ends.
+func (e *MaintenanceError) RetryAfter(now time.Time) time.Duration {
+	if d := e.Window.End.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// WriteBlockingMaintenance returns the active block_writes window covering game/env
+// that ends last, or nil. Windows without game or env cover all games or envs.
+func (s *ServiceContext) WriteBlockingMaintenance(gameID, env string, now time.Time) *MaintenanceWindow {
+	var found *MaintenanceWindow
+	for _, w := range s.ActiveMaintenance(now) {
+		if !w.BlockWrites {
+			continue
+		}
+		if w.GameID != "" && w.GameID != gameID {
+			continue
+		}
+		if w.Env != "" && w.Env != env {
+			continue
+		}
+		if found == nil || w.End.After(found.End) {
+			w := w
+			found = &w
+		}
+	}
+	return found
+}
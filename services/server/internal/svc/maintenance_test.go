@@ -0,0 +1,31 @@
+package svc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteBlockingMaintenance(t *testing.T) {
+	now := time.Now()
+	s := &ServiceContext{maintenance: []MaintenanceWindow{
+		{ID: "notice", Start: now.Add(-time.Hour), End: now.Add(3 * time.Hour)},
+		{ID: "all", Start: now.Add(-time.Hour), End: now.Add(time.Hour), BlockWrites: true, Env: "prod"},
+		{ID: "g1", GameID: "g1", Start: now.Add(-time.Hour), End: now.Add(2 * time.Hour), BlockWrites: true},
+		{ID: "later", GameID: "g2", Start: now.Add(time.Hour), End: now.Add(2 * time.Hour), BlockWrites: true},
+	}}
+	cases := []struct{ game, env, want string }{
+		{"g1", "prod", "g1"}, // both cover it; the one ending last wins
+		{"g1", "dev", "g1"},
+		{"g2", "prod", "all"},
+		{"g2", "dev", ""},
+	}
+	for _, c := range cases {
+		got := ""
+		if w := s.WriteBlockingMaintenance(c.game, c.env, now); w != nil {
+			got = w.ID
+		}
+		if got != c.want {
+			t.Errorf("WriteBlockingMaintenance(%s, %s) = %q, want %q", c.game, c.env, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package svc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/otp"
+)
+
+// MFAEnrollScope is the token scope of sessions that may only enroll in MFA.
+const MFAEnrollScope = "mfa_enroll"
+
+const (
+	defaultStepUpTTL  = 5 * time.Minute
+	recoveryCodeCount = 10
+	defaultMFAIssuer  = "Croupier"
+	totpSkew          = 1
+	mfaMethodTOTP     = "totp"
+	mfaMethodRecovery = "recovery_code"
+)
+
+var (
+	ErrMFANotEnrolled     = errors.New("mfa not enrolled")
+	ErrMFAAlreadyEnrolled = errors.New("mfa already enrolled")
+	ErrMFAInvalidCode     = errors.New("invalid mfa code")
+)
+
+// MFAState is the TOTP enrollment of a user. Secret is set once enrollment is
+// confirmed with a first code; until then the secret is Pending. RecoveryCodes
+// holds hashes of the unused recovery codes.
+type MFAState struct {
+	Secret        string
+	Pending       string
+	RecoveryCodes []string
+}
+
+// Enrolled reports whether the user completed MFA enrollment.
+func (m *MFAState) Enrolled() bool { return m != nil && m.Secret != "" }
+
+func (m *MFAState) clone() *MFAState {
+	if m == nil {
+		return &MFAState{}
+	}
+	cp := *m
+	cp.RecoveryCodes = append([]string(nil), m.RecoveryCodes...)
+	return &cp
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(otp.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// MFAMandated reports whether Auth.MFA requires the user to use MFA, because of one
+// of its roles or a permission it holds, e.g. player.ban.
+func (s *ServiceContext) MFAMandated(user string, roles []string) bool {
+	cfg := s.Config.Auth.MFA
+	for _, want := range cfg.RequiredRoles {
+		for _, r := range roles {
+			if strings.EqualFold(strings.TrimSpace(want), r) {
+				return true
+			}
+		}
+	}
+	for _, perm := range cfg.RequiredPerms {
+		if perm = strings.TrimSpace(perm); perm != "" && s.can(user, roles, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// StepUpTTL is how long a TOTP verification satisfies functions requiring MFA.
+func (s *ServiceContext) StepUpTTL() time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(s.Config.Auth.MFA.StepUpTTL)); err == nil && d > 0 {
+		return d
+	}
+	return defaultStepUpTTL
+}
+
+// MFARecent reports whether a second factor verified at mfaAt still satisfies a
+// step-up check.
+func (s *ServiceContext) MFARecent(mfaAt time.Time) bool {
+	return !mfaAt.IsZero() && time.Since(mfaAt) <= s.StepUpTTL()
+}
+
+// BeginMFAEnrollment generates a new pending TOTP secret for user and returns it
+// with its otpauth URI. Enrolled users must have MFA reset first.
+func (s *ServiceContext) BeginMFAEnrollment(ctx context.Context, user *UserRecord) (string, string, error) {
+	repo := s.UserRepository()
+	st, err := repo.GetMFA(ctx, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if st.Enrolled() {
+		return "", "", ErrMFAAlreadyEnrolled
+	}
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	st.Pending = secret
+	if err := repo.SetMFA(ctx, user.ID, st); err != nil {
+		return "", "", err
+	}
+	issuer := strings.TrimSpace(s.Config.Auth.MFA.Issuer)
+	if issuer == "" {
+		issuer = defaultMFAIssuer
+	}
+	return secret, otp.URI(issuer, user.Username, secret), nil
+}
+
+// ConfirmMFAEnrollment activates the pending secret of userID once code matches it
+// and returns freshly generated recovery codes, which are only stored hashed.
+func (s *ServiceContext) ConfirmMFAEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	repo := s.UserRepository()
+	st, err := repo.GetMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if st.Enrolled() {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	if st.Pending == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	if !s.matchTOTP(userID, st.Pending, code) {
+		return nil, ErrMFAInvalidCode
+	}
+	codes, err := otp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	next := &MFAState{Secret: st.Pending}
+	for _, c := range codes {
+		next.RecoveryCodes = append(next.RecoveryCodes, hashRecoveryCode(c))
+	}
+	if err := repo.SetMFA(ctx, userID, next); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyMFA checks a TOTP code, or consumes a recovery code, of an enrolled user and
+// returns the method that matched.
+func (s *ServiceContext) VerifyMFA(ctx context.Context, userID uint, code string) (string, error) {
+	repo := s.UserRepository()
+	st, err := repo.GetMFA(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if !st.Enrolled() {
+		return "", ErrMFANotEnrolled
+	}
+	code = strings.TrimSpace(code)
+	if s.matchTOTP(userID, st.Secret, code) {
+		return mfaMethodTOTP, nil
+	}
+	ok, err := repo.ConsumeRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return mfaMethodRecovery, nil
+	}
+	return "", ErrMFAInvalidCode
+}
+
+// removeRecoveryCode returns codes without hash and whether it was among them.
+func removeRecoveryCode(codes []string, hash string) ([]string, bool) {
+	for i, stored := range codes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			return append(append([]string(nil), codes[:i]...), codes[i+1:]...), true
+		}
+	}
+	return codes, false
+}
+
+// matchTOTP verifies code against secret and rejects codes of a time step that was
+// already used by userID.
+func (s *ServiceContext) matchTOTP(userID uint, secret, code string) bool {
+	step, ok := otp.MatchTOTP(secret, code, totpSkew, time.Now())
+	if !ok {
+		return false
+	}
+	s.mfaMu.Lock()
+	defer s.mfaMu.Unlock()
+	if last, seen := s.mfaLastStep[userID]; seen && step <= last {
+		return false
+	}
+	s.mfaLastStep[userID] = step
+	return true
+}
+
+// ResetMFA removes the MFA enrollment of userID, e.g. after a lost device.
+func (s *ServiceContext) ResetMFA(ctx context.Context, userID uint) error {
+	return s.UserRepository().SetMFA(ctx, userID, &MFAState{})
+}
+
+// MFA verification time key for context
+type mfaAtKey struct{}
+
+func WithMFAAt(ctx context.Context, at time.Time) context.Context {
+	return context.WithValue(ctx, mfaAtKey{}, at)
+}
+
+// MFAAtFromContext returns when the caller last completed a TOTP challenge.
+func MFAAtFromContext(ctx context.Context) time.Time {
+	if v, ok := ctx.Value(mfaAtKey{}).(time.Time); ok {
+		return v
+	}
+	return time.Time{}
+}
@@ -0,0 +1,56 @@
+package svc
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cuihairu/croupier/internal/db"
+)
+
+func TestRecoveryCodeIsUsedOnce(t *testing.T) {
+	ctx := context.Background()
+	gdb, err := db.Open("file:" + filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormRepo, err := newGormUserRepo(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, repo := range map[string]UserRepository{"memory": newMemoryUserRepo(), "gorm": gormRepo} {
+		t.Run(name, func(t *testing.T) {
+			s := &ServiceContext{userRepo: repo, mfaLastStep: map[uint]int64{}}
+			u := &UserRecord{Username: "alice", Active: true}
+			if err := repo.CreateUser(ctx, u, "pw", nil); err != nil {
+				t.Fatal(err)
+			}
+			st := &MFAState{Secret: "JBSWY3DPEHPK3PXP", RecoveryCodes: []string{hashRecoveryCode("aaaa-bbbb"), hashRecoveryCode("cccc-dddd")}}
+			if err := repo.SetMFA(ctx, u.ID, st); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			var ok int32
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if m, err := s.VerifyMFA(ctx, u.ID, "aaaa-bbbb"); err == nil && m == mfaMethodRecovery {
+						atomic.AddInt32(&ok, 1)
+					}
+				}()
+			}
+			wg.Wait()
+			if ok != 1 {
+				t.Fatalf("recovery code accepted %d times, want once", ok)
+			}
+			got, err := repo.GetMFA(ctx, u.ID)
+			if err != nil || len(got.RecoveryCodes) != 1 || got.RecoveryCodes[0] != hashRecoveryCode("cccc-dddd") {
+				t.Fatalf("remaining codes %v, %v", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/security/oidc"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const (
+	// oidcLoginTTL bounds the time between starting an SSO login and its callback.
+	oidcLoginTTL             = 10 * time.Minute
+	defaultOIDCUsernameClaim = "preferred_username"
+	defaultOIDCGroupsClaim   = "groups"
+)
+
+var (
+	ErrOIDCDisabled = errors.New("sso disabled")
+	// ErrOIDCState is returned for callbacks of unknown or expired logins.
+	ErrOIDCState  = errors.New("sso login unknown or expired")
+	ErrOIDCDenied = errors.New("sso login denied")
+)
+
+// oidcLogin is an SSO login waiting for its callback, keyed by state.
+type oidcLogin struct {
+	nonce    string
+	verifier string
+	redirect string
+	expires  time.Time
+}
+
+// OIDCGrant is what an ID token maps to: the account and its roles and game/env
+// grants. Games maps game ids to their allowed envs, none meaning all envs; a nil
+// Games allows all games.
+type OIDCGrant struct {
+	Username    string
+	Email       string
+	DisplayName string
+	ExternalID  string
+	Roles       []string
+	Games       map[uint][]string
+}
+
+// OIDCEnabled reports whether SSO login is configured.
+func (s *ServiceContext) OIDCEnabled() bool {
+	c := s.Config.Auth.OIDC
+	return c.Enabled && strings.TrimSpace(c.Issuer) != "" && strings.TrimSpace(c.ClientID) != ""
+}
+
+// PasswordLoginAllowed reports whether username may log in with a password; when
+// SSO is enforced only the configured local users may.
+func (s *ServiceContext) PasswordLoginAllowed(username string) bool {
+	c := s.Config.Auth.OIDC
+	if !s.OIDCEnabled() || !c.Enforce {
+		return true
+	}
+	for _, u := range c.LocalUsers {
+		if strings.EqualFold(strings.TrimSpace(u), username) {
+			return true
+		}
+	}
+	return false
+}
+
+// oidcClient returns the provider, discovering it on first use so the server starts
+// while the provider is unreachable.
+func (s *ServiceContext) oidcClient(ctx context.Context) (*oidc.Provider, error) {
+	if !s.OIDCEnabled() {
+		return nil, ErrOIDCDisabled
+	}
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+	if s.oidcProvider != nil {
+		return s.oidcProvider, nil
+	}
+	c := s.Config.Auth.OIDC
+	p, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       c.Issuer,
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectURL,
+		Scopes:       append([]string{"profile", "email"}, c.Scopes...),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.oidcProvider = p
+	return p, nil
+}
+
+// BeginOIDCLogin starts an SSO login and returns the provider URL to send the
+// browser to. redirect is kept for the callback.
+func (s *ServiceContext) BeginOIDCLogin(ctx context.Context, redirect string) (string, error) {
+	p, err := s.oidcClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	state, login := oidc.NewState(), &oidcLogin{
+		nonce:    oidc.NewState(),
+		verifier: oidc.NewState(),
+		redirect: redirect,
+		expires:  time.Now().Add(oidcLoginTTL),
+	}
+	s.oidcMu.Lock()
+	now := time.Now()
+	for k, l := range s.oidcPending {
+		if now.After(l.expires) {
+			delete(s.oidcPending, k)
+		}
+	}
+	s.oidcPending[state] = login
+	s.oidcMu.Unlock()
+	return p.AuthCodeURL(state, login.nonce, oidc.Challenge(login.verifier)), nil
+}
+
+// FinishOIDCLogin redeems the code of the callback for state and returns the
+// verified ID token with the redirect the login was started with.
+func (s *ServiceContext) FinishOIDCLogin(ctx context.Context, state, code string) (*oidc.IDToken, string, error) {
+	p, err := s.oidcClient(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	s.oidcMu.Lock()
+	login := s.oidcPending[state]
+	delete(s.oidcPending, state)
+	s.oidcMu.Unlock()
+	if login == nil || time.Now().After(login.expires) {
+		return nil, "", ErrOIDCState
+	}
+	raw, err := p.Exchange(ctx, code, login.verifier)
+	if err != nil {
+		return nil, "", err
+	}
+	tok, err := p.Verify(ctx, raw, login.nonce)
+	if err != nil {
+		return nil, "", err
+	}
+	return tok, login.redirect, nil
+}
+
+// MapOIDCToken maps an ID token to its account and grants per Auth.OIDC.
+func (s *ServiceContext) MapOIDCToken(ctx context.Context, t *oidc.IDToken) (*OIDCGrant, error) {
+	c := s.Config.Auth.OIDC
+	g := &OIDCGrant{
+		Email:       t.String("email"),
+		DisplayName: t.String("name"),
+		ExternalID:  t.Issuer + "#" + t.Subject,
+	}
+	claim := strings.TrimSpace(c.UsernameClaim)
+	if claim == "" {
+		claim = defaultOIDCUsernameClaim
+	}
+	if g.Username = t.String(claim); g.Username == "" {
+		g.Username = g.Email
+	}
+	if g.Username == "" {
+		return nil, fmt.Errorf("%w: no %s claim", ErrOIDCDenied, claim)
+	}
+	if IsServiceAccount(g.Username) {
+		return nil, fmt.Errorf("%w: username %s is reserved", ErrOIDCDenied, g.Username)
+	}
+	if len(c.AllowedDomains) > 0 && !emailInDomains(g.Email, c.AllowedDomains) {
+		return nil, fmt.Errorf("%w: email domain not allowed", ErrOIDCDenied)
+	}
+	groupsClaim := strings.TrimSpace(c.GroupsClaim)
+	if groupsClaim == "" {
+		groupsClaim = defaultOIDCGroupsClaim
+	}
+	groups := t.Strings(groupsClaim)
+	g.Roles = append(g.Roles, c.DefaultRoles...)
+	var games []string
+	for _, m := range c.RoleMappings {
+		if !oidcMappingMatches(m.Group, m.Claim, m.Value, groups, t) {
+			continue
+		}
+		g.Roles = append(g.Roles, m.Roles...)
+		games = append(games, m.Games...)
+	}
+	g.Roles = dedupeStrings(g.Roles)
+	if len(g.Roles) == 0 {
+		return nil, fmt.Errorf("%w: no role mapping matches", ErrOIDCDenied)
+	}
+	if len(games) > 0 {
+		var err error
+		if g.Games, err = s.resolveGameGrants(ctx, games); err != nil {
+			return nil, err
+		}
+		// No games would mean all games, never what a limiting mapping intends.
+		if len(g.Games) == 0 {
+			return nil, fmt.Errorf("%w: mapped games unknown", ErrOIDCDenied)
+		}
+	}
+	return g, nil
+}
+
+func oidcMappingMatches(group, claim, value string, groups []string, t *oidc.IDToken) bool {
+	if group = strings.TrimSpace(group); group != "" {
+		for _, g := range groups {
+			if g == group {
+				return true
+			}
+		}
+	}
+	if claim = strings.TrimSpace(claim); claim != "" {
+		for _, v := range t.Strings(claim) {
+			if v == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func emailInDomains(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	for _, d := range domains {
+		if strings.EqualFold(email[at+1:], strings.TrimPrefix(strings.TrimSpace(d), "@")) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveGameGrants turns "game" and "game/env" entries, naming games by name,
+// alias or id, into env limits per game id. A game granted without an env allows
+// all of its envs. Unknown games are skipped.
+func (s *ServiceContext) resolveGameGrants(ctx context.Context, grants []string) (map[uint][]string, error) {
+	games, err := s.GamesRepository().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := map[uint][]string{}
+	all := map[uint]bool{}
+	for _, grant := range grants {
+		name, env, _ := strings.Cut(strings.TrimSpace(grant), "/")
+		var id uint
+		for _, game := range games {
+			if gameNamed(game, name) {
+				id = game.ID
+				break
+			}
+		}
+		if id == 0 {
+			logx.Infof("sso: role mapping names unknown game %q", name)
+			continue
+		}
+		if env = strings.TrimSpace(env); env == "" {
+			all[id] = true
+		}
+		out[id] = append(out[id], env)
+	}
+	for id, envs := range out {
+		if all[id] {
+			out[id] = nil
+		} else {
+			out[id] = dedupeStrings(envs)
+		}
+	}
+	return out, nil
+}
+
+// ProvisionOIDCUser creates or updates the account of g and replaces its roles and
+// game/env grants. An existing local account is only taken over with LinkExisting.
+func (s *ServiceContext) ProvisionOIDCUser(ctx context.Context, g *OIDCGrant) (*UserRecord, bool, error) {
+	repo := s.UserRepository()
+	created := false
+	u, err := repo.GetUserByUsername(ctx, g.Username)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		u = &UserRecord{Username: g.Username, DisplayName: g.DisplayName, Email: g.Email, Active: true, ExternalID: g.ExternalID}
+		if u.DisplayName == "" {
+			u.DisplayName = g.Username
+		}
+		if err := repo.CreateUser(ctx, u, "", nil); err != nil {
+			return nil, false, err
+		}
+		created = true
+	case err != nil:
+		return nil, false, err
+	case u.ExternalID == g.ExternalID:
+	case u.ExternalID == "" && s.Config.Auth.OIDC.LinkExisting:
+		if err := repo.SetExternalID(ctx, u.ID, g.ExternalID); err != nil {
+			return nil, false, err
+		}
+		u.ExternalID = g.ExternalID
+	default:
+		return nil, false, fmt.Errorf("%w: username %s belongs to another account", ErrOIDCDenied, g.Username)
+	}
+	if !u.Active {
+		return nil, false, fmt.Errorf("%w: user disabled", ErrOIDCDenied)
+	}
+	if !created && (g.Email != "" && g.Email != u.Email || g.DisplayName != "" && g.DisplayName != u.DisplayName) {
+		if g.Email != "" {
+			u.Email = g.Email
+		}
+		u.DisplayName = g.DisplayName
+		if err := repo.UpdateUser(ctx, u); err != nil {
+			return nil, false, err
+		}
+	}
+	for _, name := range g.Roles {
+		if err := repo.CreateRole(ctx, &RoleRecord{Name: name}); err != nil && !errors.Is(err, ErrRoleExists) {
+			return nil, false, err
+		}
+	}
+	if err := repo.SetUserRoles(ctx, u.ID, g.Roles); err != nil {
+		return nil, false, err
+	}
+	ids := make([]uint, 0, len(g.Games))
+	for id, envs := range g.Games {
+		ids = append(ids, id)
+		if err := repo.ReplaceUserGameEnvs(ctx, u.ID, id, envs); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := repo.ReplaceUserGameIDs(ctx, u.ID, ids); err != nil {
+		return nil, false, err
+	}
+	return u, created, nil
+}
@@ -0,0 +1,92 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/platform/ratelimit"
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	"github.com/cuihairu/croupier/services/server/internal/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// ErrRateLimited is matched by errors returned when a rate-limit rule rejects a call.
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError reports the rule that rejected a call and when to retry.
+type RateLimitError struct {
+	Scope      string
+	Key        string
+	LimitQPS   int
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by %s rule %q (%d qps)", e.Scope, e.Key, e.LimitQPS)
+}
+
+func (e *RateLimitError) Is(target error) bool { return target == ErrRateLimited }
+
+// newRateLimiter creates the invocation limiter. With RateLimit.RedisURL set, windows
+// are shared through Redis so that budgets hold across server replicas.
+func newRateLimiter(c config.RateLimitConfig, rules []RateLimitRule) *ratelimit.Limiter {
+	var counter ratelimit.Counter
+	if url := strings.TrimSpace(c.RedisURL); url != "" {
+		if opt, err := redis.ParseURL(url); err == nil {
+			counter = ratelimit.NewRedisCounter(redis.NewClient(opt), "")
+		} else {
+			logx.Errorf("rate limit redis url: %v", err)
+		}
+	}
+	l := ratelimit.New(counter)
+	l.SetRules(limiterRules(rules))
+	return l
+}
+
+func limiterRules(rules []RateLimitRule) []ratelimit.Rule {
+	out := make([]ratelimit.Rule, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, ratelimit.Rule{Scope: r.Scope, Key: r.Key, LimitQPS: r.LimitQPS, Match: r.Match, Percent: r.Percent})
+	}
+	return out
+}
+
+// checkRateLimit applies the rate-limit rules to an invocation routed to agent.
+func (s *ServiceContext) checkRateLimit(ctx context.Context, in InvokeInput, agent *registry.AgentSession) error {
+	if s.limiter == nil {
+		return nil
+	}
+	req := ratelimit.Request{
+		FunctionID: in.FunctionID,
+		GameID:     in.GameID,
+		Env:        in.Env,
+		Actor:      in.Actor,
+		TraceID:    traceIDFromContext(ctx),
+	}
+	if agent != nil {
+		req.AgentID = agent.AgentID
+		req.Labels = map[string]string{"region": agent.Region, "zone": agent.Zone}
+	}
+	d, err := s.limiter.Allow(ctx, req)
+	if err != nil {
+		logx.Errorf("rate limit check: %v", err)
+	}
+	if d.Allowed {
+		return nil
+	}
+	atomic.AddInt64(&s.rateLimited, 1)
+	return &RateLimitError{Scope: d.Rule.Scope, Key: d.Rule.Key, LimitQPS: d.Rule.LimitQPS, RetryAfter: d.RetryAfter}
+}
+
+// RateLimitStats returns the live counters of the rate-limit rules on this replica.
+func (s *ServiceContext) RateLimitStats() []ratelimit.Stat {
+	if s.limiter == nil {
+		return nil
+	}
+	return s.limiter.Stats()
+}
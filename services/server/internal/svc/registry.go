@@ -0,0 +1,50 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cuihairu/croupier/internal/platform/registry"
+	registrygorm "github.com/cuihairu/croupier/internal/repo/gorm/registry"
+	"github.com/cuihairu/croupier/services/server/internal/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// defaultRegistrySync is how often a shared registry is reloaded by default.
+const defaultRegistrySync = 10 * time.Second
+
+// newRegistryStore creates the agent registry. With Registry.Backend set to
+// "redis" or "sql" it is shared by every replica using the same backend; the
+// server does not start when that backend cannot be opened.
+func newRegistryStore(c config.RegistryConfig, gdb *gorm.DB) *registry.Store {
+	var backend registry.Backend
+	switch strings.ToLower(strings.TrimSpace(c.Backend)) {
+	case "", "memory":
+		return registry.NewStore()
+	case "redis":
+		opt, err := redis.ParseURL(strings.TrimSpace(c.RedisURL))
+		if err != nil {
+			logx.Must(fmt.Errorf("registry redis url: %w", err))
+		}
+		backend = registry.NewRedisBackend(redis.NewClient(opt), "")
+	case "sql":
+		if gdb == nil {
+			logx.Must(errors.New("registry backend sql needs Server.db"))
+		}
+		if err := registrygorm.AutoMigrate(gdb); err != nil {
+			logx.Must(fmt.Errorf("migrate registry tables: %w", err))
+		}
+		backend = registrygorm.New(gdb)
+	default:
+		logx.Must(fmt.Errorf("unknown registry backend %q", c.Backend))
+	}
+	store := registry.NewSharedStore(backend)
+	go store.Run(context.Background(), parseTTL(c.SyncInterval, defaultRegistrySync))
+	logx.Infof("agent registry shared through %s", c.Backend)
+	return store
+}
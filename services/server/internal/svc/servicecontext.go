@@ -29,16 +29,27 @@ import (
 
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/cuihairu/croupier/internal/analytics/mq"
+	"github.com/cuihairu/croupier/internal/analytics/schema"
+	"github.com/cuihairu/croupier/internal/audit/chain"
+	"github.com/cuihairu/croupier/internal/connpool"
 	"github.com/cuihairu/croupier/internal/function/descriptor"
+	"github.com/cuihairu/croupier/internal/jobs"
 	"github.com/cuihairu/croupier/internal/pack"
 	appr "github.com/cuihairu/croupier/internal/platform/approvals"
+	"github.com/cuihairu/croupier/internal/platform/idempotency"
 	"github.com/cuihairu/croupier/internal/platform/objstore"
+	"github.com/cuihairu/croupier/internal/platform/ratelimit"
 	"github.com/cuihairu/croupier/internal/platform/registry"
 	"github.com/cuihairu/croupier/internal/ports"
+	"github.com/cuihairu/croupier/internal/security/apitoken"
+	"github.com/cuihairu/croupier/internal/security/oidc"
+	"github.com/cuihairu/croupier/internal/security/rbac"
+	"github.com/cuihairu/croupier/internal/security/session"
 	"github.com/cuihairu/croupier/internal/security/token"
 	"github.com/cuihairu/croupier/services/server/internal/config"
 	"github.com/redis/go-redis/v9"
 	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
 )
 
 type ServiceContext struct {
@@ -55,6 +66,8 @@ type ServiceContext struct {
 	rateMu            sync.RWMutex
 	rateRules         []RateLimitRule
 	rateLimitsPath    string
+	limiter           *ratelimit.Limiter
+	rateLimited       int64
 	healthMu          sync.RWMutex
 	healthChecks      []HealthCheck
 	healthStatus      map[string]HealthStatus
@@ -77,9 +90,14 @@ type ServiceContext struct {
 	maintenancePath   string
 	maintenanceMu     sync.RWMutex
 	maintenance       []MaintenanceWindow
-	jobsMu            sync.Mutex
-	jobs              map[string]*JobInfo
-	jobsOrder         []string
+	db                *gorm.DB
+	jobEngine         *jobs.Engine
+	idempotency       idempotency.Store
+	agentConnsMu      sync.Mutex
+	agentConns        map[string]connpool.ConnectionPool
+	edgeConns         connpool.ConnectionPool
+	agentInsecureOnce sync.Once
+	agentRR           uint64
 
 	authenticator Authenticator
 	authorizer    Authorizer
@@ -87,6 +105,19 @@ type ServiceContext struct {
 	jwtMgr        *token.Manager
 	loginAttempts map[string][]time.Time
 	loginMu       sync.Mutex
+	sessions      session.Store
+	rolePolicy    atomic.Pointer[rbac.Policy]
+	mfaMu         sync.Mutex
+	mfaLastStep   map[uint]int64
+	oidcMu        sync.Mutex
+	oidcProvider  *oidc.Provider
+	oidcPending   map[string]*oidcLogin
+	apiTokens     apitoken.Store
+	// apiTokenGrants caches the compiled grants of API tokens by token id.
+	apiTokenGrants sync.Map
+	controlTLSOnce sync.Once
+	controlTLS     *controlTLSFiles
+	controlTLSErr  error
 
 	functionMu       sync.RWMutex
 	functionIndex    map[string]*descriptor.Descriptor
@@ -104,6 +135,7 @@ type ServiceContext struct {
 	jobsError        int64
 	rbacDenied       int64
 	auditErrors      int64
+	audit            *chain.Writer
 	objStore         objstore.Store
 	objConf          objstore.Config
 	gamesRepo        ports.GamesRepository
@@ -111,6 +143,8 @@ type ServiceContext struct {
 	supportRepo      SupportRepository
 	approvals        appr.Store
 	analyticsQueue   mq.Queue
+	analyticsSpec    *schema.Spec
+	analyticsInvalid schema.Mode
 	ch               clickhouse.Conn
 }
 
@@ -203,6 +237,7 @@ type MetricsSnapshot struct {
 	JobsError        int64
 	RbacDenied       int64
 	AuditErrors      int64
+	RateLimited      int64
 }
 
 type NotifyChannel struct {
@@ -260,6 +295,22 @@ type JobInfo struct {
 	Error      string    `json:"error"`
 	RPCAddr    string    `json:"rpc_addr"`
 	TraceID    string    `json:"trace_id"`
+	Progress   int32     `json:"progress"`
+	Result     []byte    `json:"-"`
+}
+
+const (
+	JobStatePending   = string(jobs.StatePending)
+	JobStateRunning   = string(jobs.StateRunning)
+	JobStateSucceeded = string(jobs.StateSucceeded)
+	JobStateFailed    = string(jobs.StateFailed)
+	JobStateCancelled = string(jobs.StateCancelled)
+	JobStateTimedOut  = string(jobs.StateTimedOut)
+)
+
+// Finished reports whether the job reached a terminal state.
+func (j *JobInfo) Finished() bool {
+	return jobs.State(j.State).Terminal()
 }
 
 func NewServiceContext(c config.Config) *ServiceContext {
@@ -284,6 +335,7 @@ func NewServiceContext(c config.Config) *ServiceContext {
 		rateLimitsPath = filepath.Join("data", "rate_limits.json")
 	}
 	rateLimitsPath = ResolveServerPath(rateLimitsPath)
+	rateRules := loadRateLimitRules(rateLimitsPath)
 	healthChecksPath := ResolveServerPath(filepath.Join("data", "health_checks.json"))
 	configsPath := ResolveServerPath(filepath.Join("data", "configs.json"))
 	notificationsPath := ResolveServerPath(filepath.Join("data", "notifications.json"))
@@ -320,24 +372,27 @@ func NewServiceContext(c config.Config) *ServiceContext {
 	notifyChannels, notifyRules := loadNotifications(notificationsPath)
 	maintenance := loadMaintenanceWindows(maintenancePath)
 	objSt, objConf := initObjectStore()
-	gamesRepo := newMemoryGamesRepo()
-	userRepo := newMemoryUserRepo()
-	var jwtMgr *token.Manager
-	if secret := strings.TrimSpace(c.Auth.JWTSecret); secret != "" {
-		jwtMgr = token.NewManager(secret)
+	jwtMgr, err := newTokenManager(c)
+	if err != nil && !errors.Is(err, errNoTokenKeys) {
+		// Keys that are configured but unusable must not leave the server open.
+		logx.Must(fmt.Errorf("init token keys: %w", err))
 	}
 	supportRepo := newMemorySupportRepo()
 	analyticsQueue := mq.NewFromEnv()
+	gdb := openDatabase(c)
+	userRepo := newUserRepo(c, gdb)
+	gamesRepo := newGamesRepo(gdb)
 
 	ctx := &ServiceContext{
 		Config:            c,
-		RegistryStore:     registry.NewStore(),
+		RegistryStore:     newRegistryStore(c.Registry, gdb),
 		assignments:       loadAssignments(assignPath),
 		assignmentsPath:   assignPath,
 		analytics:         loadAnalyticsFilters(analyticsPath),
 		analyticsPath:     analyticsPath,
-		rateRules:         loadRateLimitRules(rateLimitsPath),
+		rateRules:         rateRules,
 		rateLimitsPath:    rateLimitsPath,
+		limiter:           newRateLimiter(c.RateLimit, rateRules),
 		healthChecks:      loadHealthChecks(healthChecksPath),
 		healthChecksPath:  healthChecksPath,
 		healthStatus:      map[string]HealthStatus{},
@@ -353,9 +408,10 @@ func NewServiceContext(c config.Config) *ServiceContext {
 		nodeStatus:        map[string]NodeState{},
 		maintenancePath:   maintenancePath,
 		maintenance:       maintenance,
-		jobs:              map[string]*JobInfo{},
-		jobsOrder:         []string{},
-		authorizer:        newNoopRBAC(),
+		db:                gdb,
+		jobEngine:         newJobEngine(gdb),
+		idempotency:       newIdempotencyStore(gdb),
+		authorizer:        newAuthorizer(c),
 		functionIndex:     index,
 		descriptors:       descs,
 		componentMgr:      componentMgr,
@@ -371,15 +427,27 @@ func NewServiceContext(c config.Config) *ServiceContext {
 		userRepo:          userRepo,
 		jwtMgr:            jwtMgr,
 		loginAttempts:     map[string][]time.Time{},
+		sessions:          newSessionStore(gdb),
+		mfaLastStep:       map[uint]int64{},
+		oidcPending:       map[string]*oidcLogin{},
+		apiTokens:         newAPITokenStore(gdb),
 		supportRepo:       supportRepo,
-		approvals:         appr.NewMemStore(),
+		approvals:         newApprovalsStore(gdb),
+		audit:             openAuditWriter(c.Audit),
 		analyticsQueue:    analyticsQueue,
 	}
+	ctx.analyticsSpec, ctx.analyticsInvalid = loadAnalyticsSpec(c.Analytics)
 	ctx.initClickHouse()
-	if auth, err := newJWTAuthenticator(strings.TrimSpace(c.Auth.JWTSecret)); err == nil {
-		ctx.authenticator = auth
+	if err := ctx.sessions.DeleteExpired(context.Background(), time.Now()); err != nil {
+		logx.Errorf("prune sessions: %v", err)
+	}
+	if err := ctx.RefreshRolePolicy(context.Background()); err != nil {
+		logx.Errorf("load role permissions: %v", err)
+	}
+	if jwtMgr != nil {
+		ctx.authenticator = &jwtAuthenticator{manager: jwtMgr, active: ctx.sessionActive}
 	} else {
-		logx.Errorf("init authenticator: %v", err)
+		logx.Errorf("auth disabled: auth.jwt_secret and auth.signing_keys are empty")
 		ctx.authenticator = &noopAuthenticator{}
 	}
 	return ctx
@@ -395,12 +463,26 @@ func (s *ServiceContext) AssignmentsSnapshot() map[string][]string {
 	return out
 }
 
-// Authenticate validates incoming request and returns (user, roles, ok).
+// Authenticate validates incoming request and returns (user, roles, ok). Sessions
+// limited to MFA enrollment are rejected.
 func (s *ServiceContext) Authenticate(r *http.Request) (string, []string, bool) {
-	if s.authenticator == nil {
+	id, ok := s.Identify(r)
+	if !ok || id.EnrollOnly {
 		return "", nil, false
 	}
-	return s.authenticator.Authenticate(r)
+	return id.User, id.Roles, true
+}
+
+// Identify validates incoming request and returns the caller identity, including
+// sessions limited to MFA enrollment.
+func (s *ServiceContext) Identify(r *http.Request) (*Identity, bool) {
+	if raw := bearerToken(r); apitoken.IsToken(raw) {
+		return s.identifyAPIToken(r, raw)
+	}
+	if s.authenticator == nil {
+		return nil, false
+	}
+	return s.authenticator.Identify(r)
 }
 
 // EnforcePermission checks if the user with roles has specific permission.
@@ -408,10 +490,44 @@ func (s *ServiceContext) EnforcePermission(user string, roles []string, perm str
 	if strings.TrimSpace(perm) == "" {
 		return true
 	}
-	if s.authorizer == nil {
+	if s.can(user, roles, perm) {
+		return true
+	}
+	atomic.AddInt64(&s.rbacDenied, 1)
+	return false
+}
+
+// can checks perm against the RBAC config file and the roles managed through the
+// API, or the token grants of service accounts, without counting denials.
+func (s *ServiceContext) can(user string, roles []string, perm string) bool {
+	if IsServiceAccount(user) {
+		return s.apiTokenCan(roles, perm)
+	}
+	if s.authorizer != nil && s.authorizer.Can(user, roles, perm) {
+		return true
+	}
+	p := s.rolePolicy.Load()
+	return p != nil && rbac.Allowed(p, user, roles, perm)
+}
+
+// HasPermission is EnforcePermission for checks that only widen what a caller
+// sees, so a missing permission is not counted as a denial.
+func (s *ServiceContext) HasPermission(user string, roles []string, perm string) bool {
+	return s.can(user, roles, perm)
+}
+
+// EnforceScopedPermission checks perm within a game/env scope, e.g. a grant of
+// "player.ban@game1/prod" or "player.ban@game1" allows player.ban in game1/prod.
+// A scope outside the user's game/env grants is denied whatever the roles allow.
+func (s *ServiceContext) EnforceScopedPermission(user string, roles []string, perm, gameID, env string) bool {
+	if strings.TrimSpace(perm) == "" {
+		return true
+	}
+	if strings.TrimSpace(gameID) != "" && !s.UserCanAccessGame(context.Background(), user, gameID, env) {
+		atomic.AddInt64(&s.rbacDenied, 1)
 		return false
 	}
-	return s.authorizer.Can(user, roles, perm)
+	return s.EnforcePermission(user, roles, rbac.Scoped(perm, gameID, env))
 }
 
 func (s *ServiceContext) ConfigsSnapshot() []*ConfigEntry {
@@ -554,6 +670,7 @@ func (s *ServiceContext) MetricsSnapshot() MetricsSnapshot {
 		JobsError:        atomic.LoadInt64(&s.jobsError),
 		RbacDenied:       atomic.LoadInt64(&s.rbacDenied),
 		AuditErrors:      atomic.LoadInt64(&s.auditErrors),
+		RateLimited:      atomic.LoadInt64(&s.rateLimited),
 	}
 }
 
@@ -774,6 +891,9 @@ func (s *ServiceContext) UpdateAssignments(gameID, env string, functions []strin
 		snapshot[k] = append([]string{}, v...)
 	}
 	s.assignmentsMu.Unlock()
+	if s.RegistryStore != nil {
+		s.RegistryStore.Publish(registry.Event{Type: registry.EventAssignmentsChanged, GameID: gameID, Env: env})
+	}
 	if strings.TrimSpace(s.assignmentsPath) == "" {
 		return nil
 	}
@@ -990,21 +1110,6 @@ func (s *ServiceContext) PopNodeCommands(id string) []string {
 	return cmds
 }
 
-func (s *ServiceContext) JobsSnapshot() (map[string]*JobInfo, []string) {
-	s.jobsMu.Lock()
-	defer s.jobsMu.Unlock()
-	order := append([]string{}, s.jobsOrder...)
-	data := make(map[string]*JobInfo, len(s.jobs))
-	for k, v := range s.jobs {
-		if v == nil {
-			continue
-		}
-		cp := *v
-		data[k] = &cp
-	}
-	return data, order
-}
-
 func (s *ServiceContext) UpdateAnalyticsFilter(gameID, env string, events []string, payments bool, sample int) error {
 	gameID = strings.TrimSpace(gameID)
 	env = strings.TrimSpace(env)
@@ -1059,6 +1164,7 @@ func (s *ServiceContext) ReplaceRateLimitRules(rules []RateLimitRule) error {
 		r.Key = strings.TrimSpace(r.Key)
 		s.rateRules[i] = r
 	}
+	s.limiter.SetRules(limiterRules(s.rateRules))
 	err := s.persistRateLimitRulesLocked()
 	s.rateMu.Unlock()
 	return err
@@ -1079,6 +1185,7 @@ func (s *ServiceContext) DeleteRateLimitRule(scope, key string) error {
 		next = append(next, r)
 	}
 	s.rateRules = next
+	s.limiter.SetRules(limiterRules(s.rateRules))
 	err := s.persistRateLimitRulesLocked()
 	s.rateMu.Unlock()
 	return err
@@ -1672,6 +1779,20 @@ func ActorFromContext(ctx context.Context) string {
 	return ""
 }
 
+// Roles key for context
+type rolesKey struct{}
+
+func WithRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+func RolesFromContext(ctx context.Context) []string {
+	if v, ok := ctx.Value(rolesKey{}).([]string); ok {
+		return v
+	}
+	return nil
+}
+
 func (s *ServiceContext) GamesRepository() ports.GamesRepository {
 	if s.gamesRepo == nil {
 		s.gamesRepo = newMemoryGamesRepo()
@@ -1686,12 +1807,8 @@ func (s *ServiceContext) UserRepository() UserRepository {
 	return s.userRepo
 }
 
+// JWTManager returns the token keyset, or nil when auth is disabled.
 func (s *ServiceContext) JWTManager() *token.Manager {
-	if s.jwtMgr == nil {
-		if secret := strings.TrimSpace(s.Config.Auth.JWTSecret); secret != "" {
-			s.jwtMgr = token.NewManager(secret)
-		}
-	}
 	return s.jwtMgr
 }
 
@@ -1735,6 +1852,13 @@ func (s *ServiceContext) AnalyticsQueue() mq.Queue {
 	return s.analyticsQueue
 }
 
+// AnalyticsSpec returns the event specification ingested events are checked
+// against and what to do with those failing it. The spec is nil if it could
+// not be loaded.
+func (s *ServiceContext) AnalyticsSpec() (*schema.Spec, schema.Mode) {
+	return s.analyticsSpec, s.analyticsInvalid
+}
+
 func (s *ServiceContext) ClickHouse() clickhouse.Conn {
 	return s.ch
 }
@@ -0,0 +1,277 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sessionsgorm "github.com/cuihairu/croupier/internal/repo/gorm/sessions"
+	"github.com/cuihairu/croupier/internal/security/session"
+	"github.com/cuihairu/croupier/internal/security/token"
+	"github.com/cuihairu/croupier/services/server/internal/config"
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAccessTTL  = 15 * time.Minute
+	defaultRefreshTTL = 7 * 24 * time.Hour
+	// enrollSessionTTL bounds sessions that may only enroll in MFA.
+	enrollSessionTTL = 15 * time.Minute
+)
+
+var (
+	// ErrSessionInvalid is returned for unknown, revoked or expired sessions.
+	ErrSessionInvalid = errors.New("session invalid")
+	// ErrRefreshReused is returned when a rotated-out refresh token is presented
+	// again; the session is revoked since the token may have been stolen.
+	ErrRefreshReused = errors.New("refresh token reused")
+	// errNoTokenKeys is returned by newTokenManager when neither signing keys nor
+	// a secret are configured; only then does the server run without auth.
+	errNoTokenKeys = errors.New("no jwt secret or signing keys configured")
+)
+
+func newSessionStore(gdb *gorm.DB) session.Store {
+	if gdb == nil {
+		return session.NewMemStore()
+	}
+	if err := sessionsgorm.AutoMigrate(gdb); err != nil {
+		logx.Must(fmt.Errorf("migrate session tables: %w", err))
+	}
+	return sessionsgorm.New(gdb)
+}
+
+// newTokenManager builds the token keyset from Auth.SigningKeys, signing with
+// Auth.ActiveKey or the first key. Without keys it signs HS256 with Auth.JWTSecret,
+// and without a secret it returns errNoTokenKeys.
+func newTokenManager(c config.Config) (*token.Manager, error) {
+	if len(c.Auth.SigningKeys) == 0 {
+		secret := strings.TrimSpace(c.Auth.JWTSecret)
+		if secret == "" {
+			return nil, errNoTokenKeys
+		}
+		return token.NewManager(secret), nil
+	}
+	keys := make([]*token.Key, 0, len(c.Auth.SigningKeys))
+	for _, kc := range c.Auth.SigningKeys {
+		k, err := loadSigningKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", kc.ID, err)
+		}
+		keys = append(keys, k)
+	}
+	active := strings.TrimSpace(c.Auth.ActiveKey)
+	if active == "" {
+		active = keys[0].ID
+	}
+	return token.NewKeySet(active, keys...)
+}
+
+func loadSigningKey(kc config.SigningKeyConfig) (*token.Key, error) {
+	id := strings.TrimSpace(kc.ID)
+	if id == "" {
+		return nil, errors.New("id required")
+	}
+	alg := strings.TrimSpace(kc.Alg)
+	var k *token.Key
+	var err error
+	switch {
+	case kc.Secret != "":
+		k = token.NewHMACKey(id, kc.Secret)
+	case kc.PrivateKey != "":
+		k, err = token.LoadPrivateKeyFile(id, ResolveWorkspacePath(kc.PrivateKey))
+	case kc.PublicKey != "":
+		k, err = token.LoadPublicKeyFile(id, ResolveWorkspacePath(kc.PublicKey))
+	default:
+		return nil, errors.New("one of secret, private_key or public_key required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if alg != "" && alg != k.Alg {
+		return nil, fmt.Errorf("key is %s, configured as %s", k.Alg, alg)
+	}
+	return k, nil
+}
+
+// Sessions returns the server-side session store.
+func (s *ServiceContext) Sessions() session.Store { return s.sessions }
+
+// AccessTTL is the lifetime of access tokens.
+func (s *ServiceContext) AccessTTL() time.Duration {
+	return parseTTL(s.Config.Auth.AccessTTL, defaultAccessTTL)
+}
+
+// RefreshTTL is the lifetime of a session. Refreshing rotates the refresh token
+// but does not extend the session.
+func (s *ServiceContext) RefreshTTL() time.Duration {
+	return parseTTL(s.Config.Auth.RefreshTTL, defaultRefreshTTL)
+}
+
+func parseTTL(v string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// StartSession records a login of username and returns the session with its
+// refresh token. Sessions limited to MFA enrollment are short and get no refresh
+// token.
+func (s *ServiceContext) StartSession(ctx context.Context, username, ip, userAgent string, mfaAt time.Time, enrollOnly bool) (*session.Session, string, error) {
+	now := time.Now()
+	sess := &session.Session{
+		ID:         session.NewID(),
+		Username:   username,
+		MFAAt:      mfaAt,
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.RefreshTTL()),
+	}
+	refresh := ""
+	if enrollOnly {
+		sess.ExpiresAt = now.Add(enrollSessionTTL)
+	} else {
+		refresh, sess.RefreshHash = session.NewRefreshToken(sess.ID)
+	}
+	if err := s.sessions.Create(ctx, sess); err != nil {
+		return nil, "", err
+	}
+	return sess, refresh, nil
+}
+
+// UpgradeSession turns a session limited to MFA enrollment into a full session
+// verified at mfaAt and returns its new refresh token.
+func (s *ServiceContext) UpgradeSession(ctx context.Context, sess *session.Session, mfaAt time.Time) (string, error) {
+	refresh, hash := session.NewRefreshToken(sess.ID)
+	sess.RefreshHash = hash
+	sess.MFAAt = mfaAt
+	sess.ExpiresAt = time.Now().Add(s.RefreshTTL())
+	return refresh, s.sessions.Update(ctx, sess)
+}
+
+// IssueAccessToken signs an access token for sess, valid for AccessTTL but never
+// beyond the session.
+func (s *ServiceContext) IssueAccessToken(sess *session.Session, roles []string, scope string) (string, time.Duration, error) {
+	if s.jwtMgr == nil {
+		return "", 0, ErrSessionInvalid
+	}
+	ttl := s.AccessTTL()
+	if left := time.Until(sess.ExpiresAt); left < ttl {
+		ttl = left
+	}
+	if ttl <= 0 {
+		return "", 0, ErrSessionInvalid
+	}
+	tok, err := s.jwtMgr.Issue(token.Claims{
+		Subject:   sess.Username,
+		Roles:     roles,
+		SessionID: sess.ID,
+		MFAAt:     sess.MFAAt,
+		Scope:     scope,
+	}, ttl)
+	return tok, ttl, err
+}
+
+// ActiveSession returns the session id if it is active and belongs to username.
+func (s *ServiceContext) ActiveSession(ctx context.Context, id, username string) (*session.Session, error) {
+	sess, err := s.sessions.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			return nil, ErrSessionInvalid
+		}
+		return nil, err
+	}
+	if sess.Username != username || !sess.Active(time.Now()) {
+		return nil, ErrSessionInvalid
+	}
+	return sess, nil
+}
+
+// RefreshSession rotates the refresh token of its session and returns the session
+// with the new refresh token.
+func (s *ServiceContext) RefreshSession(ctx context.Context, refreshToken string) (*session.Session, string, error) {
+	id, hash, ok := session.ParseRefreshToken(refreshToken)
+	if !ok {
+		return nil, "", ErrSessionInvalid
+	}
+	sess, err := s.sessions.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			return nil, "", ErrSessionInvalid
+		}
+		return nil, "", err
+	}
+	now := time.Now()
+	if !sess.Active(now) {
+		return nil, "", ErrSessionInvalid
+	}
+	if !sess.MatchRefresh(hash) {
+		if _, err := session.Revoke(ctx, s.sessions, sess.ID, "refresh_reuse", now); err != nil {
+			return nil, "", err
+		}
+		return sess, "", ErrRefreshReused
+	}
+	refresh, newHash := session.NewRefreshToken(sess.ID)
+	sess.RefreshHash = newHash
+	sess.LastSeenAt = now
+	if err := s.sessions.Update(ctx, sess); err != nil {
+		return nil, "", err
+	}
+	return sess, refresh, nil
+}
+
+// MarkSessionMFA records a step-up verification at at for the session.
+func (s *ServiceContext) MarkSessionMFA(ctx context.Context, sess *session.Session, at time.Time) error {
+	sess.MFAAt = at
+	sess.LastSeenAt = at
+	return s.sessions.Update(ctx, sess)
+}
+
+// RevokeSession revokes one session on behalf of by.
+func (s *ServiceContext) RevokeSession(ctx context.Context, id, by string) (*session.Session, error) {
+	sess, err := session.Revoke(ctx, s.sessions, id, by, time.Now())
+	if errors.Is(err, session.ErrNotFound) {
+		return nil, ErrSessionInvalid
+	}
+	return sess, err
+}
+
+// RevokeSessions revokes every session of username on behalf of by, e.g. when the
+// user is deactivated, deleted or has its password or roles changed.
+func (s *ServiceContext) RevokeSessions(ctx context.Context, username, by string) int {
+	n, err := s.sessions.RevokeUser(ctx, username, by, time.Now())
+	if err != nil {
+		logx.Errorf("revoke sessions of %s: %v", username, err)
+	}
+	return n
+}
+
+// sessionActive reports whether an access token of username in session id may
+// still be used.
+func (s *ServiceContext) sessionActive(id, username string) bool {
+	if id == "" {
+		return false
+	}
+	_, err := s.ActiveSession(context.Background(), id, username)
+	return err == nil
+}
+
+// Session id key for context
+type sessionIDKey struct{}
+
+func WithSessionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, id)
+}
+
+// SessionIDFromContext returns the id of the session the caller authenticated with.
+func SessionIDFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(sessionIDKey{}).(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package svc
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/cuihairu/croupier/services/server/internal/config"
+)
+
+func TestNewTokenManagerFailsOnBadKeys(t *testing.T) {
+	var c config.Config
+	if _, err := newTokenManager(c); !errors.Is(err, errNoTokenKeys) {
+		t.Fatalf("no secret and no keys: %v, want errNoTokenKeys", err)
+	}
+	for name, keys := range map[string][]config.SigningKeyConfig{
+		"missing file": {{ID: "k1", Alg: "EdDSA", PrivateKey: filepath.Join(t.TempDir(), "missing.pem")}},
+		"no key":       {{ID: "k1"}},
+		"wrong alg":    {{ID: "k1", Alg: "EdDSA", Secret: "s"}},
+	} {
+		c.Auth.JWTSecret = "dev-secret"
+		c.Auth.SigningKeys = keys
+		if _, err := newTokenManager(c); err == nil || errors.Is(err, errNoTokenKeys) {
+			t.Errorf("%s: %v, want a key error", name, err)
+		}
+	}
+}
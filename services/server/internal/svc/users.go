@@ -0,0 +1,172 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cuihairu/croupier/internal/ports"
+	"github.com/cuihairu/croupier/internal/security/rbac"
+	"github.com/cuihairu/croupier/services/server/internal/config"
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// newUserRepo returns the gorm users repository when a database is configured, seeding
+// an empty one from Auth.UsersConfig, and the in-memory repository otherwise.
+func newUserRepo(c config.Config, gdb *gorm.DB) UserRepository {
+	if gdb == nil {
+		return newMemoryUserRepo()
+	}
+	repo, err := newGormUserRepo(gdb)
+	if err != nil {
+		logx.Must(fmt.Errorf("init users repository: %w", err))
+	}
+	if path := ResolveWorkspacePath(c.Auth.UsersConfig); path != "" {
+		seedUsers(context.Background(), repo, path)
+	}
+	return repo
+}
+
+type seedUser struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Salt     string   `json:"salt"`
+	Roles    []string `json:"roles"`
+}
+
+// seedUsers imports the users file into an empty repository, creating the roles it
+// references. Entries with salted password hashes cannot be imported and are skipped.
+func seedUsers(ctx context.Context, repo UserRepository, path string) {
+	existing, err := repo.ListUsers(ctx)
+	if err != nil || len(existing) > 0 {
+		return
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		logx.Errorf("read users config %s: %v", path, err)
+		return
+	}
+	var users []seedUser
+	if err := json.Unmarshal(b, &users); err != nil {
+		logx.Errorf("parse users config %s: %v", path, err)
+		return
+	}
+	for _, u := range users {
+		if strings.TrimSpace(u.Username) == "" || u.Password == "" || u.Salt != "" {
+			logx.Infof("users config: skipping %q without a plain password", u.Username)
+			continue
+		}
+		for _, name := range u.Roles {
+			if err := repo.CreateRole(ctx, &RoleRecord{Name: name}); err != nil && err != ErrRoleExists {
+				logx.Errorf("seed role %s: %v", name, err)
+			}
+		}
+		rec := &UserRecord{Username: strings.TrimSpace(u.Username), DisplayName: u.Username, Active: true}
+		if err := repo.CreateUser(ctx, rec, u.Password, u.Roles); err != nil {
+			logx.Errorf("seed user %s: %v", u.Username, err)
+			continue
+		}
+		logx.Infof("seeded user %s from %s", rec.Username, path)
+	}
+}
+
+// RefreshRolePolicy rebuilds the role grants managed through the roles API. They are
+// evaluated alongside the RBAC config file, so edits apply to the next request.
+func (s *ServiceContext) RefreshRolePolicy(ctx context.Context) error {
+	roles, err := s.UserRepository().ListRoles(ctx)
+	if err != nil {
+		return err
+	}
+	p := rbac.NewPolicy()
+	for _, r := range roles {
+		for _, perm := range r.Perms {
+			p.Grant("role:"+r.Name, perm)
+		}
+	}
+	s.rolePolicy.Store(p)
+	return nil
+}
+
+// gameNamed reports whether name refers to game by name, alias or numeric id.
+func gameNamed(game *ports.Game, name string) bool {
+	name = strings.TrimSpace(name)
+	return name != "" && (strings.EqualFold(game.Name, name) ||
+		(game.AliasName != "" && strings.EqualFold(game.AliasName, name)) ||
+		strconv.FormatUint(uint64(game.ID), 10) == name)
+}
+
+// UserCanAccessGame checks the game/env grants of user, set through the users API
+// or SSO group mappings. Users without grants, and callers unknown to the users
+// repository, may access every game; a limited user must name a granted game, and
+// an env of it if the grant lists envs.
+func (s *ServiceContext) UserCanAccessGame(ctx context.Context, user, gameID, env string) bool {
+	if user == "" || IsServiceAccount(user) || s.userRepo == nil {
+		return true
+	}
+	u, err := s.userRepo.GetUserByUsername(ctx, user)
+	if errors.Is(err, ErrUserNotFound) {
+		return true
+	}
+	if err != nil {
+		logx.Errorf("load game grants of %s: %v", user, err)
+		return false
+	}
+	ids, err := s.userRepo.ListUserGameIDs(ctx, u.ID)
+	if err != nil {
+		logx.Errorf("load game grants of %s: %v", user, err)
+		return false
+	}
+	if len(ids) == 0 {
+		return true
+	}
+	if strings.TrimSpace(gameID) == "" {
+		return false
+	}
+	for _, id := range ids {
+		game, err := s.GamesRepository().Get(ctx, id)
+		if err != nil || !gameNamed(game, gameID) {
+			continue
+		}
+		envs, err := s.userRepo.ListUserGameEnvs(ctx, u.ID, id)
+		if err != nil {
+			logx.Errorf("load env grants of %s: %v", user, err)
+			return false
+		}
+		return len(envs) == 0 || slices.Contains(envs, strings.TrimSpace(env))
+	}
+	return false
+}
+
+// RoleGrants lists the permissions role grants through the RBAC config file and the
+// roles API. ok is false when the RBAC config cannot list its grants, as with Casbin.
+func (s *ServiceContext) RoleGrants(ctx context.Context, role string) (perms []string, ok bool, err error) {
+	var policy rbac.PolicyInterface
+	switch a := s.authorizer.(type) {
+	case *rbac.Reloader:
+		policy = a.Policy()
+	case *policyAuthorizer:
+		policy = a.policy
+	}
+	ok = s.authorizer == nil
+	if p, isPolicy := policy.(*rbac.Policy); isPolicy {
+		ok = true
+		name := strings.TrimPrefix(role, "role:")
+		perms = append(p.Grants(name), p.Grants("role:"+name)...)
+	}
+	roles, err := s.UserRepository().ListRoles(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, r := range roles {
+		if r.Name == strings.TrimPrefix(role, "role:") {
+			perms = append(perms, r.Perms...)
+		}
+	}
+	return perms, ok, nil
+}
@@ -0,0 +1,320 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	usersgorm "github.com/cuihairu/croupier/internal/repo/gorm/users"
+	"gorm.io/gorm"
+)
+
+// gormUserRepo adapts the users gorm repository to UserRepository.
+type gormUserRepo struct {
+	r *usersgorm.Repo
+}
+
+func newGormUserRepo(gdb *gorm.DB) (*gormUserRepo, error) {
+	if err := usersgorm.AutoMigrate(gdb); err != nil {
+		return nil, err
+	}
+	return &gormUserRepo{r: usersgorm.New(gdb)}, nil
+}
+
+func userFromAccount(u *usersgorm.UserAccount) *UserRecord {
+	return &UserRecord{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Email:       u.Email,
+		Phone:       u.Phone,
+		Active:      u.Active,
+		ExternalID:  u.ExternalID,
+	}
+}
+
+func notFound(err, as error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return as
+	}
+	return err
+}
+
+func (g *gormUserRepo) Verify(ctx context.Context, username, password string) (*UserRecord, error) {
+	u, err := g.r.Verify(ctx, strings.TrimSpace(username), password)
+	if err != nil {
+		return nil, err
+	}
+	return userFromAccount(u), nil
+}
+
+func (g *gormUserRepo) ListUserRoles(ctx context.Context, userID uint) ([]string, error) {
+	roles, err := g.r.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(roles))
+	for _, r := range roles {
+		out = append(out, r.Name)
+	}
+	return out, nil
+}
+
+func (g *gormUserRepo) GetUserByUsername(ctx context.Context, username string) (*UserRecord, error) {
+	u, err := g.r.GetUserByUsername(ctx, strings.TrimSpace(username))
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return userFromAccount(u), nil
+}
+
+func (g *gormUserRepo) ListUserGameIDs(ctx context.Context, userID uint) ([]uint, error) {
+	return g.r.ListUserGameIDs(ctx, userID)
+}
+
+func (g *gormUserRepo) ListUserGameEnvs(ctx context.Context, userID, gameID uint) ([]string, error) {
+	return g.r.ListUserGameEnvs(ctx, userID, gameID)
+}
+
+func (g *gormUserRepo) UpdateUser(ctx context.Context, user *UserRecord) error {
+	u, err := g.r.GetUser(ctx, user.ID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if strings.TrimSpace(user.DisplayName) != "" {
+		u.DisplayName = user.DisplayName
+	}
+	u.Email = strings.TrimSpace(user.Email)
+	u.Phone = strings.TrimSpace(user.Phone)
+	u.Active = user.Active
+	return g.r.UpdateUser(ctx, u)
+}
+
+func (g *gormUserRepo) SetPassword(ctx context.Context, userID uint, password string) error {
+	if _, err := g.r.GetUser(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	return g.r.SetPassword(ctx, userID, password)
+}
+
+func (g *gormUserRepo) ListUsers(ctx context.Context) ([]*UserRecord, error) {
+	arr, err := g.r.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*UserRecord, 0, len(arr))
+	for _, u := range arr {
+		out = append(out, userFromAccount(u))
+	}
+	return out, nil
+}
+
+func (g *gormUserRepo) GetUser(ctx context.Context, userID uint) (*UserRecord, error) {
+	u, err := g.r.GetUser(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return userFromAccount(u), nil
+}
+
+func (g *gormUserRepo) CreateUser(ctx context.Context, user *UserRecord, password string, roles []string) error {
+	if _, err := g.r.GetUserByUsername(ctx, user.Username); err == nil {
+		return ErrUserExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	roleIDs, err := g.roleIDs(ctx, roles)
+	if err != nil {
+		return err
+	}
+	u := &usersgorm.UserAccount{
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Email:       user.Email,
+		Phone:       user.Phone,
+		Active:      user.Active,
+		ExternalID:  user.ExternalID,
+	}
+	if err := g.r.CreateUser(ctx, u); err != nil {
+		return err
+	}
+	// gorm skips zero values that have a default, so an inactive user needs an update.
+	if !user.Active {
+		if err := g.r.UpdateUser(ctx, u); err != nil {
+			return err
+		}
+	}
+	user.ID = u.ID
+	if password != "" {
+		if err := g.r.SetPassword(ctx, u.ID, password); err != nil {
+			return err
+		}
+	}
+	return g.r.ReplaceUserRoles(ctx, u.ID, roleIDs)
+}
+
+func (g *gormUserRepo) DeleteUser(ctx context.Context, userID uint) error {
+	if _, err := g.r.GetUser(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	return g.r.DeleteUser(ctx, userID)
+}
+
+func (g *gormUserRepo) SetUserRoles(ctx context.Context, userID uint, roles []string) error {
+	if _, err := g.r.GetUser(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	roleIDs, err := g.roleIDs(ctx, roles)
+	if err != nil {
+		return err
+	}
+	return g.r.ReplaceUserRoles(ctx, userID, roleIDs)
+}
+
+func (g *gormUserRepo) roleIDs(ctx context.Context, names []string) ([]uint, error) {
+	ids := make([]uint, 0, len(names))
+	for _, name := range names {
+		role, err := g.r.GetRoleByName(ctx, strings.TrimSpace(name))
+		if err != nil {
+			return nil, notFound(err, ErrRoleNotFound)
+		}
+		ids = append(ids, role.ID)
+	}
+	return ids, nil
+}
+
+func (g *gormUserRepo) ReplaceUserGameIDs(ctx context.Context, userID uint, gameIDs []uint) error {
+	if _, err := g.r.GetUser(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	return g.r.ReplaceUserGameIDs(ctx, userID, gameIDs)
+}
+
+func (g *gormUserRepo) ReplaceUserGameEnvs(ctx context.Context, userID, gameID uint, envs []string) error {
+	if _, err := g.r.GetUser(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	return g.r.ReplaceUserGameEnvs(ctx, userID, gameID, envs)
+}
+
+func (g *gormUserRepo) roleFromRecord(ctx context.Context, r *usersgorm.RoleRecord) (*RoleRecord, error) {
+	perms, err := g.r.ListRolePerms(ctx, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &RoleRecord{ID: r.ID, Name: r.Name, Description: r.Description, Perms: perms}, nil
+}
+
+func (g *gormUserRepo) ListRoles(ctx context.Context) ([]*RoleRecord, error) {
+	arr, err := g.r.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*RoleRecord, 0, len(arr))
+	for _, r := range arr {
+		role, err := g.roleFromRecord(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, role)
+	}
+	return out, nil
+}
+
+func (g *gormUserRepo) GetRole(ctx context.Context, roleID uint) (*RoleRecord, error) {
+	r, err := g.r.GetRole(ctx, roleID)
+	if err != nil {
+		return nil, notFound(err, ErrRoleNotFound)
+	}
+	return g.roleFromRecord(ctx, r)
+}
+
+func (g *gormUserRepo) CreateRole(ctx context.Context, role *RoleRecord) error {
+	if _, err := g.r.GetRoleByName(ctx, role.Name); err == nil {
+		return ErrRoleExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	rec := &usersgorm.RoleRecord{Name: role.Name, Description: role.Description}
+	if err := g.r.CreateRole(ctx, rec); err != nil {
+		return err
+	}
+	role.ID = rec.ID
+	return g.r.ReplaceRolePerms(ctx, rec.ID, role.Perms)
+}
+
+func (g *gormUserRepo) UpdateRole(ctx context.Context, role *RoleRecord) error {
+	rec, err := g.r.GetRole(ctx, role.ID)
+	if err != nil {
+		return notFound(err, ErrRoleNotFound)
+	}
+	if other, err := g.r.GetRoleByName(ctx, role.Name); err == nil && other.ID != role.ID {
+		return ErrRoleExists
+	}
+	rec.Name, rec.Description = role.Name, role.Description
+	return g.r.UpdateRole(ctx, rec)
+}
+
+func (g *gormUserRepo) DeleteRole(ctx context.Context, roleID uint) error {
+	if _, err := g.r.GetRole(ctx, roleID); err != nil {
+		return notFound(err, ErrRoleNotFound)
+	}
+	return g.r.DeleteRole(ctx, roleID)
+}
+
+func (g *gormUserRepo) SetRolePerms(ctx context.Context, roleID uint, perms []string) error {
+	if _, err := g.r.GetRole(ctx, roleID); err != nil {
+		return notFound(err, ErrRoleNotFound)
+	}
+	return g.r.ReplaceRolePerms(ctx, roleID, perms)
+}
+
+func (g *gormUserRepo) GetMFA(ctx context.Context, userID uint) (*MFAState, error) {
+	u, err := g.r.GetUser(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	st := &MFAState{Secret: u.OTPSecret, Pending: u.OTPPending}
+	if u.RecoveryCodes != "" {
+		st.RecoveryCodes = strings.Split(u.RecoveryCodes, ",")
+	}
+	return st, nil
+}
+
+func (g *gormUserRepo) SetExternalID(ctx context.Context, userID uint, externalID string) error {
+	if _, err := g.r.GetUser(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	return g.r.SetExternalID(ctx, userID, externalID)
+}
+
+// ConsumeRecoveryCode swaps the stored codes only if nobody changed them since they
+// were read, retrying after losing a race.
+func (g *gormUserRepo) ConsumeRecoveryCode(ctx context.Context, userID uint, hash string) (bool, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		u, err := g.r.GetUser(ctx, userID)
+		if err != nil {
+			return false, notFound(err, ErrUserNotFound)
+		}
+		if u.RecoveryCodes == "" {
+			return false, nil
+		}
+		next, ok := removeRecoveryCode(strings.Split(u.RecoveryCodes, ","), hash)
+		if !ok {
+			return false, nil
+		}
+		swapped, err := g.r.SwapRecoveryCodes(ctx, userID, u.RecoveryCodes, strings.Join(next, ","))
+		if err != nil || swapped {
+			return swapped, err
+		}
+	}
+	return false, fmt.Errorf("consume recovery code of user %d: too many concurrent updates", userID)
+}
+
+func (g *gormUserRepo) SetMFA(ctx context.Context, userID uint, state *MFAState) error {
+	if _, err := g.r.GetUser(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	return g.r.SetOTP(ctx, userID, state.Secret, state.Pending, strings.Join(state.RecoveryCodes, ","))
+}
@@ -3,10 +3,18 @@ package svc
 import (
 	"context"
 	"errors"
+	"sort"
 	"strings"
 	"sync"
 )
 
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+)
+
 type UserRecord struct {
 	ID          uint
 	Username    string
@@ -14,6 +22,16 @@ type UserRecord struct {
 	Email       string
 	Phone       string
 	Active      bool
+	// ExternalID is the identity provider subject of users signing in with SSO.
+	ExternalID string
+}
+
+// RoleRecord is a role and the permissions it grants as "role:<name>".
+type RoleRecord struct {
+	ID          uint
+	Name        string
+	Description string
+	Perms       []string
 }
 
 type UserRepository interface {
@@ -24,22 +42,52 @@ type UserRepository interface {
 	ListUserGameEnvs(ctx context.Context, userID, gameID uint) ([]string, error)
 	UpdateUser(ctx context.Context, user *UserRecord) error
 	SetPassword(ctx context.Context, userID uint, password string) error
+
+	ListUsers(ctx context.Context) ([]*UserRecord, error)
+	GetUser(ctx context.Context, userID uint) (*UserRecord, error)
+	// CreateUser stores user, assigning its ID; roles must exist.
+	CreateUser(ctx context.Context, user *UserRecord, password string, roles []string) error
+	DeleteUser(ctx context.Context, userID uint) error
+	SetUserRoles(ctx context.Context, userID uint, roles []string) error
+	ReplaceUserGameIDs(ctx context.Context, userID uint, gameIDs []uint) error
+	// ReplaceUserGameEnvs limits the user to envs under a game; none means all envs.
+	ReplaceUserGameEnvs(ctx context.Context, userID, gameID uint, envs []string) error
+
+	ListRoles(ctx context.Context) ([]*RoleRecord, error)
+	GetRole(ctx context.Context, roleID uint) (*RoleRecord, error)
+	CreateRole(ctx context.Context, role *RoleRecord) error
+	UpdateRole(ctx context.Context, role *RoleRecord) error
+	DeleteRole(ctx context.Context, roleID uint) error
+	SetRolePerms(ctx context.Context, roleID uint, perms []string) error
+
+	GetMFA(ctx context.Context, userID uint) (*MFAState, error)
+	SetMFA(ctx context.Context, userID uint, state *MFAState) error
+	// ConsumeRecoveryCode removes the recovery code hash of a user and reports whether
+	// it was there, so concurrent requests cannot use one code twice.
+	ConsumeRecoveryCode(ctx context.Context, userID uint, hash string) (bool, error)
+	SetExternalID(ctx context.Context, userID uint, externalID string) error
 }
 
 type userEntry struct {
 	record   UserRecord
 	password string
 	roles    []string
-	games    map[uint][]string
+	games    map[uint]struct{}
+	envs     map[uint][]string
+	mfa      MFAState
 }
 
 type memoryUserRepo struct {
-	mu    sync.Mutex
-	users map[string]*userEntry
+	mu     sync.Mutex
+	users  map[string]*userEntry
+	roles  map[uint]*RoleRecord
+	nextID uint
 }
 
 func newMemoryUserRepo() *memoryUserRepo {
 	return &memoryUserRepo{
+		roles:  map[uint]*RoleRecord{1: {ID: 1, Name: "admin", Description: "Administrator"}},
+		nextID: 2,
 		users: map[string]*userEntry{
 			"admin": {
 				record: UserRecord{
@@ -52,7 +100,8 @@ func newMemoryUserRepo() *memoryUserRepo {
 				},
 				password: "password",
 				roles:    []string{"admin"},
-				games:    map[uint][]string{},
+				games:    map[uint]struct{}{},
+				envs:     map[uint][]string{},
 			},
 		},
 	}
@@ -76,6 +125,9 @@ func (m *memoryUserRepo) Verify(ctx context.Context, username, password string)
 	if !ok || acc.password != password {
 		return nil, errors.New("invalid credentials")
 	}
+	if !acc.record.Active {
+		return nil, errors.New("user disabled")
+	}
 	return cloneUserRecord(acc.record), nil
 }
 
@@ -95,7 +147,7 @@ func (m *memoryUserRepo) GetUserByUsername(ctx context.Context, username string)
 	defer m.mu.Unlock()
 	acc, ok := m.lookup(username)
 	if !ok {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	return cloneUserRecord(acc.record), nil
 }
@@ -120,8 +172,7 @@ func (m *memoryUserRepo) ListUserGameEnvs(ctx context.Context, userID, gameID ui
 	defer m.mu.Unlock()
 	for _, acc := range m.users {
 		if acc.record.ID == userID {
-			envs := acc.games[gameID]
-			return append([]string(nil), envs...), nil
+			return append([]string(nil), acc.envs[gameID]...), nil
 		}
 	}
 	return []string{}, nil
@@ -141,7 +192,7 @@ func (m *memoryUserRepo) UpdateUser(ctx context.Context, user *UserRecord) error
 			return nil
 		}
 	}
-	return errors.New("user not found")
+	return ErrUserNotFound
 }
 
 func (m *memoryUserRepo) SetPassword(ctx context.Context, userID uint, password string) error {
@@ -153,5 +204,285 @@ func (m *memoryUserRepo) SetPassword(ctx context.Context, userID uint, password
 			return nil
 		}
 	}
-	return errors.New("user not found")
+	return ErrUserNotFound
+}
+
+func (m *memoryUserRepo) byID(userID uint) *userEntry {
+	for _, acc := range m.users {
+		if acc.record.ID == userID {
+			return acc
+		}
+	}
+	return nil
+}
+
+func (m *memoryUserRepo) GetMFA(ctx context.Context, userID uint) (*MFAState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.byID(userID)
+	if acc == nil {
+		return nil, ErrUserNotFound
+	}
+	return acc.mfa.clone(), nil
+}
+
+func (m *memoryUserRepo) SetMFA(ctx context.Context, userID uint, state *MFAState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.byID(userID)
+	if acc == nil {
+		return ErrUserNotFound
+	}
+	acc.mfa = *state.clone()
+	return nil
+}
+
+func (m *memoryUserRepo) ConsumeRecoveryCode(ctx context.Context, userID uint, hash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.byID(userID)
+	if acc == nil {
+		return false, ErrUserNotFound
+	}
+	next, ok := removeRecoveryCode(acc.mfa.RecoveryCodes, hash)
+	acc.mfa.RecoveryCodes = next
+	return ok, nil
+}
+
+func (m *memoryUserRepo) SetExternalID(ctx context.Context, userID uint, externalID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.byID(userID)
+	if acc == nil {
+		return ErrUserNotFound
+	}
+	acc.record.ExternalID = externalID
+	return nil
+}
+
+func (m *memoryUserRepo) ListUsers(ctx context.Context) ([]*UserRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*UserRecord, 0, len(m.users))
+	for _, acc := range m.users {
+		out = append(out, cloneUserRecord(acc.record))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+func (m *memoryUserRepo) GetUser(ctx context.Context, userID uint) (*UserRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.byID(userID)
+	if acc == nil {
+		return nil, ErrUserNotFound
+	}
+	return cloneUserRecord(acc.record), nil
+}
+
+func (m *memoryUserRepo) CreateUser(ctx context.Context, user *UserRecord, password string, roles []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(user.Username); ok {
+		return ErrUserExists
+	}
+	if err := m.checkRoles(roles); err != nil {
+		return err
+	}
+	user.ID = m.nextID
+	m.nextID++
+	m.users[strings.ToLower(strings.TrimSpace(user.Username))] = &userEntry{
+		record:   *user,
+		password: password,
+		roles:    append([]string(nil), roles...),
+		games:    map[uint]struct{}{},
+		envs:     map[uint][]string{},
+	}
+	return nil
+}
+
+func (m *memoryUserRepo) DeleteUser(ctx context.Context, userID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, acc := range m.users {
+		if acc.record.ID == userID {
+			delete(m.users, key)
+			return nil
+		}
+	}
+	return ErrUserNotFound
+}
+
+func (m *memoryUserRepo) SetUserRoles(ctx context.Context, userID uint, roles []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.byID(userID)
+	if acc == nil {
+		return ErrUserNotFound
+	}
+	if err := m.checkRoles(roles); err != nil {
+		return err
+	}
+	acc.roles = append([]string(nil), roles...)
+	return nil
+}
+
+func (m *memoryUserRepo) checkRoles(roles []string) error {
+	for _, name := range roles {
+		if m.roleByName(name) == nil {
+			return ErrRoleNotFound
+		}
+	}
+	return nil
+}
+
+func (m *memoryUserRepo) ReplaceUserGameIDs(ctx context.Context, userID uint, gameIDs []uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.byID(userID)
+	if acc == nil {
+		return ErrUserNotFound
+	}
+	games := make(map[uint]struct{}, len(gameIDs))
+	for _, gid := range gameIDs {
+		if gid != 0 {
+			games[gid] = struct{}{}
+		}
+	}
+	acc.games = games
+	return nil
+}
+
+func (m *memoryUserRepo) ReplaceUserGameEnvs(ctx context.Context, userID, gameID uint, envs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.byID(userID)
+	if acc == nil {
+		return ErrUserNotFound
+	}
+	if envs = dedupeStrings(envs); len(envs) == 0 {
+		delete(acc.envs, gameID)
+	} else {
+		acc.envs[gameID] = envs
+	}
+	return nil
+}
+
+func (m *memoryUserRepo) roleByName(name string) *RoleRecord {
+	for _, r := range m.roles {
+		if strings.EqualFold(r.Name, strings.TrimSpace(name)) {
+			return r
+		}
+	}
+	return nil
+}
+
+func cloneRoleRecord(r *RoleRecord) *RoleRecord {
+	cp := *r
+	cp.Perms = append([]string(nil), r.Perms...)
+	return &cp
+}
+
+func (m *memoryUserRepo) ListRoles(ctx context.Context) ([]*RoleRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*RoleRecord, 0, len(m.roles))
+	for _, r := range m.roles {
+		out = append(out, cloneRoleRecord(r))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+func (m *memoryUserRepo) GetRole(ctx context.Context, roleID uint) (*RoleRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.roles[roleID]
+	if r == nil {
+		return nil, ErrRoleNotFound
+	}
+	return cloneRoleRecord(r), nil
+}
+
+func (m *memoryUserRepo) CreateRole(ctx context.Context, role *RoleRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.roleByName(role.Name) != nil {
+		return ErrRoleExists
+	}
+	role.ID = m.nextID
+	m.nextID++
+	role.Perms = dedupeStrings(role.Perms)
+	m.roles[role.ID] = cloneRoleRecord(role)
+	return nil
+}
+
+func (m *memoryUserRepo) UpdateRole(ctx context.Context, role *RoleRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur := m.roles[role.ID]
+	if cur == nil {
+		return ErrRoleNotFound
+	}
+	if other := m.roleByName(role.Name); other != nil && other.ID != role.ID {
+		return ErrRoleExists
+	}
+	if cur.Name != role.Name {
+		for _, acc := range m.users {
+			for i, name := range acc.roles {
+				if name == cur.Name {
+					acc.roles[i] = role.Name
+				}
+			}
+		}
+	}
+	cur.Name, cur.Description = role.Name, role.Description
+	return nil
+}
+
+func (m *memoryUserRepo) DeleteRole(ctx context.Context, roleID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur := m.roles[roleID]
+	if cur == nil {
+		return ErrRoleNotFound
+	}
+	for _, acc := range m.users {
+		kept := acc.roles[:0]
+		for _, name := range acc.roles {
+			if name != cur.Name {
+				kept = append(kept, name)
+			}
+		}
+		acc.roles = kept
+	}
+	delete(m.roles, roleID)
+	return nil
+}
+
+func (m *memoryUserRepo) SetRolePerms(ctx context.Context, roleID uint, perms []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur := m.roles[roleID]
+	if cur == nil {
+		return ErrRoleNotFound
+	}
+	cur.Perms = dedupeStrings(perms)
+	return nil
+}
+
+// dedupeStrings trims values and drops blanks and duplicates, keeping order.
+func dedupeStrings(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := map[string]struct{}{}
+	for _, v := range in {
+		v = strings.TrimSpace(v)
+		if _, ok := seen[v]; ok || v == "" {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return out
 }
@@ -0,0 +1,117 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cuihairu/croupier/internal/db"
+	"github.com/cuihairu/croupier/internal/ports"
+	"github.com/cuihairu/croupier/internal/security/rbac"
+)
+
+func TestScopedPermissionHonoursGameGrants(t *testing.T) {
+	ctx := context.Background()
+	s := newTestContext(t)
+	s.authorizer.(*policyAuthorizer).policy.(*rbac.Policy).Grant("role:ops", "player.ban")
+	s.gamesRepo, s.userRepo = newMemoryGamesRepo(), newMemoryUserRepo()
+	g1, g2 := &ports.Game{Name: "g1", Envs: []string{"dev", "prod"}}, &ports.Game{Name: "g2"}
+	for _, g := range []*ports.Game{g1, g2} {
+		if err := s.gamesRepo.Create(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if err := s.userRepo.CreateUser(ctx, &UserRecord{Username: name, Active: true}, "pw", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice, _ := s.userRepo.GetUserByUsername(ctx, "alice")
+	if err := s.userRepo.ReplaceUserGameIDs(ctx, alice.ID, []uint{g1.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.userRepo.ReplaceUserGameEnvs(ctx, alice.ID, g1.ID, []string{"dev"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ops := []string{"ops"}
+	cases := []struct {
+		user, game, env string
+		want            bool
+	}{
+		{"alice", "g1", "dev", true},
+		{"alice", "g1", "prod", false},
+		{"alice", "g2", "", false},
+		{"bob", "g2", "prod", true},
+		{"carol", "g2", "", true},
+	}
+	for _, c := range cases {
+		if got := s.EnforceScopedPermission(c.user, ops, "player.ban", c.game, c.env); got != c.want {
+			t.Errorf("EnforceScopedPermission(%s, %s/%s) = %v, want %v", c.user, c.game, c.env, got, c.want)
+		}
+	}
+	if s.UserCanAccessGame(ctx, "alice", "", "") {
+		t.Error("a user limited to some games reached an unscoped call")
+	}
+}
+
+func TestGameGrantsSurviveRestart(t *testing.T) {
+	ctx := context.Background()
+	gdb, err := db.Open("file:" + filepath.Join(t.TempDir(), "server.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := func() *ServiceContext {
+		t.Helper()
+		gormUsers, err := newGormUserRepo(gdb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &ServiceContext{userRepo: gormUsers, gamesRepo: newGamesRepo(gdb)}
+	}
+
+	s := start()
+	g1, g2 := &ports.Game{Name: "g1"}, &ports.Game{Name: "g2"}
+	for _, g := range []*ports.Game{g1, g2} {
+		if err := s.gamesRepo.Create(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.userRepo.CreateUser(ctx, &UserRecord{Username: "alice", Active: true}, "pw", nil); err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := s.userRepo.GetUserByUsername(ctx, "alice")
+	if err := s.userRepo.ReplaceUserGameIDs(ctx, alice.ID, []uint{g2.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart the grant still names g2, not whichever game is created first.
+	s = start()
+	if err := s.gamesRepo.Create(ctx, &ports.Game{Name: "g3"}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.UserCanAccessGame(ctx, "alice", "g2", "") || s.UserCanAccessGame(ctx, "alice", "g1", "") || s.UserCanAccessGame(ctx, "alice", "g3", "") {
+		t.Fatal("game grant changed across a restart")
+	}
+	if _, err := s.gamesRepo.Get(ctx, 99); !errors.Is(err, ErrGameNotFound) {
+		t.Fatalf("get missing game: %v, want ErrGameNotFound", err)
+	}
+}
+
+func TestRoleGrants(t *testing.T) {
+	ctx := context.Background()
+	s := newTestContext(t)
+	s.authorizer.(*policyAuthorizer).policy.(*rbac.Policy).Grant("role:admin", "*")
+	s.userRepo = newMemoryUserRepo()
+	if err := s.userRepo.CreateRole(ctx, &RoleRecord{Name: "support", Perms: []string{"player.read"}}); err != nil {
+		t.Fatal(err)
+	}
+	if perms, ok, err := s.RoleGrants(ctx, "admin"); err != nil || !ok || !slices.Equal(perms, []string{"*"}) {
+		t.Fatalf("admin grants %v, %v, %v", perms, ok, err)
+	}
+	if perms, ok, err := s.RoleGrants(ctx, "support"); err != nil || !ok || !slices.Equal(perms, []string{"player.read"}) {
+		t.Fatalf("support grants %v, %v, %v", perms, ok, err)
+	}
+}
@@ -229,6 +229,19 @@ type AnalyticsIngestRequest struct {
 	Events []map[string]interface{} `json:"events"`
 }
 
+type AnalyticsIngestError struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+	Field  string `json:"field,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type AnalyticsIngestResponse struct {
+	Accepted int                    `json:"accepted"`
+	Rejected int                    `json:"rejected"`
+	Errors   []AnalyticsIngestError `json:"errors,omitempty"`
+}
+
 type AnalyticsPaymentsIngestRequest struct {
 	Events []map[string]interface{} `json:"events"`
 }
@@ -279,6 +292,114 @@ type MePasswordRequest struct {
 	NewPassword string `json:"new_password,optional"`
 }
 
+type UserInfo struct {
+	Id          uint     `json:"id"`
+	Username    string   `json:"username"`
+	DisplayName string   `json:"display_name"`
+	Email       string   `json:"email"`
+	Phone       string   `json:"phone"`
+	Active      bool     `json:"active"`
+	Roles       []string `json:"roles"`
+	MfaEnrolled bool     `json:"mfa_enrolled"`
+	ExternalId  string   `json:"external_id,omitempty"`
+}
+
+type UsersListResponse struct {
+	Users []UserInfo `json:"users"`
+}
+
+type UserCreateRequest struct {
+	Username    string   `json:"username"`
+	DisplayName string   `json:"display_name,optional"`
+	Email       string   `json:"email,optional"`
+	Phone       string   `json:"phone,optional"`
+	Password    string   `json:"password,optional"`
+	Active      *bool    `json:"active,optional"`
+	Roles       []string `json:"roles,optional"`
+}
+
+type UserCreateResponse struct {
+	Id uint `json:"id"`
+}
+
+type UserUpdateRequest struct {
+	Id          uint     `path:"id"`
+	DisplayName string   `json:"display_name,optional"`
+	Email       string   `json:"email,optional"`
+	Phone       string   `json:"phone,optional"`
+	Active      *bool    `json:"active,optional"`
+	Roles       []string `json:"roles,optional"`
+}
+
+type UserIdRequest struct {
+	Id uint `path:"id"`
+}
+
+type UserPasswordResetRequest struct {
+	Id       uint   `path:"id"`
+	Password string `json:"password"`
+}
+
+type UserGamesResponse struct {
+	GameIds []uint `json:"game_ids"`
+}
+
+type UserGamesUpdateRequest struct {
+	Id      uint   `path:"id"`
+	GameIds []uint `json:"game_ids"`
+}
+
+type UserGameEnvsRequest struct {
+	Id     uint `path:"id"`
+	GameId uint `path:"game_id"`
+}
+
+type UserGameEnvsResponse struct {
+	Envs []string `json:"envs"`
+}
+
+type UserGameEnvsUpdateRequest struct {
+	Id     uint     `path:"id"`
+	GameId uint     `path:"game_id"`
+	Envs   []string `json:"envs"`
+}
+
+type RoleInfo struct {
+	Id          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Perms       []string `json:"perms"`
+}
+
+type RolesListResponse struct {
+	Roles []RoleInfo `json:"roles"`
+}
+
+type RoleCreateRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,optional"`
+	Perms       []string `json:"perms,optional"`
+}
+
+type RoleCreateResponse struct {
+	Id uint `json:"id"`
+}
+
+type RoleUpdateRequest struct {
+	Id          uint   `path:"id"`
+	Name        string `json:"name,optional"`
+	Description string `json:"description,optional"`
+}
+
+type RoleIdRequest struct {
+	Id uint `path:"id"`
+}
+
+type RolePermsUpdateRequest struct {
+	Id    uint     `path:"id"`
+	Perms []string `json:"perms"`
+}
+
 type AnalyticsPaymentsSummaryQuery struct {
 	GameId   string `form:"game_id,optional"`
 	Env      string `form:"env,optional"`
@@ -557,7 +678,16 @@ type RateLimitRule struct {
 }
 
 type RateLimitRulesResponse struct {
-	Rules []RateLimitRule `json:"rules"`
+	Rules    []RateLimitRule    `json:"rules"`
+	Counters []RateLimitCounter `json:"counters"`
+}
+
+type RateLimitCounter struct {
+	Scope    string `json:"scope"`
+	Key      string `json:"key"`
+	Allowed  int64  `json:"allowed"`
+	Rejected int64  `json:"rejected"`
+	Qps      int64  `json:"qps"`
 }
 
 type RateLimitRulesRequest struct {
@@ -1070,6 +1200,7 @@ type SupportFeedbackCreateResponse struct {
 type AuthLoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	OtpCode  string `json:"otp_code,optional"`
 }
 
 type AuthUserInfo struct {
@@ -1078,8 +1209,174 @@ type AuthUserInfo struct {
 }
 
 type AuthLoginResponse struct {
+	Token             string       `json:"token"`
+	RefreshToken      string       `json:"refresh_token,omitempty"`
+	ExpiresIn         int64        `json:"expires_in"`
+	User              AuthUserInfo `json:"user"`
+	MfaEnrollRequired bool         `json:"mfa_enroll_required,omitempty"`
+}
+
+type AuthOidcLoginRequest struct {
+	Redirect string `form:"redirect,optional"`
+}
+
+type AuthOidcCallbackRequest struct {
+	Code             string `form:"code,optional"`
+	State            string `form:"state,optional"`
+	Error            string `form:"error,optional"`
+	ErrorDescription string `form:"error_description,optional"`
+}
+
+type AuthRefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type AuthRefreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type SessionInfo struct {
+	Id         string `json:"id"`
+	Username   string `json:"username"`
+	Ip         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	ExpiresAt  string `json:"expires_at"`
+	MfaAt      string `json:"mfa_at,omitempty"`
+	RevokedAt  string `json:"revoked_at,omitempty"`
+	RevokedBy  string `json:"revoked_by,omitempty"`
+	Active     bool   `json:"active"`
+	Current    bool   `json:"current"`
+}
+
+type SessionsListRequest struct {
+	Username string `form:"username,optional"`
+	All      bool   `form:"all,optional"`
+}
+
+type SessionsListResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type SessionIdRequest struct {
+	Id string `path:"id"`
+}
+
+type ServiceAccountInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Perms       []string `json:"perms"`
+	Scopes      []string `json:"scopes"`
+	Disabled    bool     `json:"disabled"`
+	CreatedBy   string   `json:"created_by"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+type ServiceAccountsListResponse struct {
+	Accounts []ServiceAccountInfo `json:"accounts"`
+}
+
+type ServiceAccountCreateRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,optional"`
+	Perms       []string `json:"perms"`
+	Scopes      []string `json:"scopes,optional"`
+}
+
+type ServiceAccountUpdateRequest struct {
+	Name        string   `path:"name"`
+	Description *string  `json:"description,optional"`
+	Perms       []string `json:"perms,optional"`
+	Scopes      []string `json:"scopes,optional"`
+	Disabled    *bool    `json:"disabled,optional"`
+}
+
+type ServiceAccountNameRequest struct {
+	Name string `path:"name"`
+}
+
+type ApiTokenInfo struct {
+	Id         string   `json:"id"`
+	Prefix     string   `json:"prefix"`
+	Account    string   `json:"account"`
+	Name       string   `json:"name"`
+	Perms      []string `json:"perms"`
+	Scopes     []string `json:"scopes"`
+	AllowedIps []string `json:"allowed_ips"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	CreatedBy  string   `json:"created_by"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	LastUsedIp string   `json:"last_used_ip,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+	RevokedBy  string   `json:"revoked_by,omitempty"`
+	Active     bool     `json:"active"`
+}
+
+type ApiTokensListResponse struct {
+	Tokens []ApiTokenInfo `json:"tokens"`
+}
+
+type ApiTokenCreateRequest struct {
+	Account    string   `path:"name"`
+	Name       string   `json:"name"`
+	Perms      []string `json:"perms,optional"`
+	Scopes     []string `json:"scopes,optional"`
+	AllowedIps []string `json:"allowed_ips,optional"`
+	ExpiresIn  string   `json:"expires_in,optional"`
+}
+
+type ApiTokenCreateResponse struct {
 	Token string       `json:"token"`
-	User  AuthUserInfo `json:"user"`
+	Info  ApiTokenInfo `json:"info"`
+}
+
+type ApiTokenIdRequest struct {
+	Account string `path:"name"`
+	Id      string `path:"id"`
+}
+
+type AgentCertificateIssueRequest struct {
+	AgentId string   `json:"agent_id"`
+	Games   []string `json:"games"`
+	Csr     string   `json:"csr"`
+}
+
+type AgentCertificateIssueResponse struct {
+	Certificate string `json:"certificate"`
+	Ca          string `json:"ca"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+type MfaStatusResponse struct {
+	Enrolled          bool `json:"enrolled"`
+	Pending           bool `json:"pending"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type MfaEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthUri string `json:"otpauth_uri"`
+}
+
+type MfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type MfaActivateResponse struct {
+	Token         string   `json:"token"`
+	RefreshToken  string   `json:"refresh_token,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MfaVerifyResponse struct {
+	Token        string `json:"token"`
+	MfaExpiresAt string `json:"mfa_expires_at"`
 }
 
 type AuthMeResponse struct {
@@ -1584,16 +1881,21 @@ type DescriptorsResponse struct {
 }
 
 type RegistryAgent struct {
-	AgentId      string `json:"agent_id"`
-	GameId       string `json:"game_id"`
-	Env          string `json:"env"`
-	RpcAddr      string `json:"rpc_addr"`
-	Ip           string `json:"ip"`
-	Type         string `json:"type"`
-	Version      string `json:"version"`
-	Functions    int64  `json:"functions"`
-	Healthy      bool   `json:"healthy"`
-	ExpiresInSec int64  `json:"expires_in_sec"`
+	AgentId      string            `json:"agent_id"`
+	GameId       string            `json:"game_id"`
+	Env          string            `json:"env"`
+	RpcAddr      string            `json:"rpc_addr"`
+	Ip           string            `json:"ip"`
+	Type         string            `json:"type"`
+	Version      string            `json:"version"`
+	Functions    int64             `json:"functions"`
+	Healthy      bool              `json:"healthy"`
+	ExpiresInSec int64             `json:"expires_in_sec"`
+	Region       string            `json:"region,omitempty"`
+	Zone         string            `json:"zone,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	RegisteredAt string            `json:"registered_at,omitempty"`
+	LastSeen     string            `json:"last_seen,omitempty"`
 }
 
 type RegistryCoverage struct {
@@ -1618,6 +1920,23 @@ type RegistryResponse struct {
 	Functions   []RegistryFunction  `json:"functions"`
 	Assignments map[string][]string `json:"assignments"`
 	Coverage    []RegistryCoverage  `json:"coverage"`
+	Revision    uint64              `json:"revision"`
+}
+
+type RegistryWatchRequest struct {
+	After uint64 `form:"after,optional"`
+	Types string `form:"types,optional"`
+}
+
+type RegistryWatchEvent struct {
+	Revision   uint64 `json:"revision"`
+	Type       string `json:"type"`
+	Time       string `json:"time"`
+	AgentId    string `json:"agent_id,omitempty"`
+	GameId     string `json:"game_id,omitempty"`
+	Env        string `json:"env,omitempty"`
+	ProviderId string `json:"provider_id,omitempty"`
+	FunctionId string `json:"function_id,omitempty"`
 }
 
 type SchemaCreateRequest struct {
@@ -1814,6 +2133,37 @@ type AdminPublishResponse struct {
 	Ok bool `json:"ok"`
 }
 
+type AuditListRequest struct {
+	GameId string `form:"game_id,optional"`
+	Env    string `form:"env,optional"`
+	Actor  string `form:"actor,optional"`
+	Kind   string `form:"kind,optional"`
+	Kinds  string `form:"kinds,optional"`
+	Target string `form:"target,optional"`
+	Ip     string `form:"ip,optional"`
+	Start  string `form:"start,optional"`
+	End    string `form:"end,optional"`
+	Page   int    `form:"page,optional"`
+	Size   int    `form:"size,optional"`
+	Limit  int    `form:"limit,optional"`
+	Offset int    `form:"offset,optional"`
+}
+
+type AuditEvent struct {
+	Time   string            `json:"time"`
+	Kind   string            `json:"kind"`
+	Actor  string            `json:"actor"`
+	Target string            `json:"target"`
+	Meta   map[string]string `json:"meta"`
+	Prev   string            `json:"prev"`
+	Hash   string            `json:"hash"`
+}
+
+type AuditListResponse struct {
+	Events []AuditEvent `json:"events"`
+	Total  int          `json:"total"`
+}
+
 type ApprovalsListRequest struct {
 	State         string `form:"state,optional"`
 	FunctionId    string `form:"function_id,optional"`
@@ -1828,19 +2178,27 @@ type ApprovalsListRequest struct {
 }
 
 type ApprovalSummary struct {
-	Id              string `json:"id"`
-	CreatedAt       string `json:"created_at"`
-	Actor           string `json:"actor"`
-	FunctionId      string `json:"function_id"`
-	IdempotencyKey  string `json:"idempotency_key,omitempty"`
-	Route           string `json:"route,omitempty"`
-	TargetServiceId string `json:"target_service_id,omitempty"`
-	HashKey         string `json:"hash_key,omitempty"`
-	GameId          string `json:"game_id,omitempty"`
-	Env             string `json:"env,omitempty"`
-	State           string `json:"state"`
-	Mode            string `json:"mode,omitempty"`
-	Reason          string `json:"reason,omitempty"`
+	Id              string   `json:"id"`
+	CreatedAt       string   `json:"created_at"`
+	Actor           string   `json:"actor"`
+	FunctionId      string   `json:"function_id"`
+	IdempotencyKey  string   `json:"idempotency_key,omitempty"`
+	Route           string   `json:"route,omitempty"`
+	TargetServiceId string   `json:"target_service_id,omitempty"`
+	HashKey         string   `json:"hash_key,omitempty"`
+	GameId          string   `json:"game_id,omitempty"`
+	Env             string   `json:"env,omitempty"`
+	State           string   `json:"state"`
+	Mode            string   `json:"mode,omitempty"`
+	Reason          string   `json:"reason,omitempty"`
+	Threshold       int      `json:"threshold,omitempty"`
+	ApproverRoles   []string `json:"approver_roles,omitempty"`
+	ApprovedBy      []string `json:"approved_by,omitempty"`
+	RejectedBy      string   `json:"rejected_by,omitempty"`
+	ExpiresAt       string   `json:"expires_at,omitempty"`
+	ExecutedAt      string   `json:"executed_at,omitempty"`
+	JobId           string   `json:"job_id,omitempty"`
+	Error           string   `json:"error,omitempty"`
 }
 
 type ApprovalsListResponse struct {
@@ -1856,7 +2214,18 @@ type ApprovalGetRequest struct {
 
 type ApprovalDetailResponse struct {
 	ApprovalSummary
-	PayloadPreview string `json:"payload_preview,omitempty"`
+	PayloadPreview string          `json:"payload_preview,omitempty"`
+	Result         interface{}     `json:"result,omitempty"`
+	History        []ApprovalEvent `json:"history,omitempty"`
+}
+
+type ApprovalEvent struct {
+	Action string `json:"action"`
+	Actor  string `json:"actor,omitempty"`
+	Role   string `json:"role,omitempty"`
+	State  string `json:"state"`
+	Detail string `json:"detail,omitempty"`
+	At     string `json:"at"`
 }
 
 type ApprovalApproveRequest struct {
@@ -1867,3 +2236,60 @@ type ApprovalRejectRequest struct {
 	Id     string `json:"id"`
 	Reason string `json:"reason,optional"`
 }
+
+type InvokeRequest struct {
+	FunctionId      string                 `json:"function_id"`
+	Payload         map[string]interface{} `json:"payload,optional"`
+	GameId          string                 `json:"game_id,optional"`
+	Env             string                 `json:"env,optional"`
+	IdempotencyKey  string                 `json:"idempotency_key,optional"`
+	Route           string                 `json:"route,optional"`
+	TargetServiceId string                 `json:"target_service_id,optional"`
+	HashKey         string                 `json:"hash_key,optional"`
+}
+
+type InvokeResponse struct {
+	Payload interface{} `json:"payload,omitempty"`
+	JobId   string      `json:"job_id,omitempty"`
+	AgentId string      `json:"agent_id,omitempty"`
+	TraceId string      `json:"trace_id,omitempty"`
+	// ApprovalId is set when the call is held for two-person approval.
+	ApprovalId string `json:"approval_id,omitempty"`
+	Replayed   bool   `json:"replayed,omitempty"`
+}
+
+type JobStartResponse struct {
+	JobId      string `json:"job_id"`
+	ApprovalId string `json:"approval_id,omitempty"`
+	Replayed   bool   `json:"replayed,omitempty"`
+}
+
+type JobCancelRequest struct {
+	JobId string `json:"job_id"`
+}
+
+type JobResultRequest struct {
+	Id string `form:"id"`
+}
+
+type JobStreamRequest struct {
+	Id    string `form:"id"`
+	After int64  `form:"after,optional"`
+}
+
+type JobStreamEvent struct {
+	Seq      int64       `json:"seq"`
+	Type     string      `json:"type"`
+	Message  string      `json:"message,omitempty"`
+	Progress int32       `json:"progress,omitempty"`
+	Payload  interface{} `json:"payload,omitempty"`
+	Time     string      `json:"time"`
+}
+
+type JobResultResponse struct {
+	Id       string      `json:"id"`
+	State    string      `json:"state"`
+	Progress int32       `json:"progress"`
+	Payload  interface{} `json:"payload,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}